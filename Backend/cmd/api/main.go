@@ -15,7 +15,7 @@ import (
 	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/db"
 	"github.com/lin-snow/edumgr/internal/handler"
-	appmw "github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/server"
 )
 
@@ -53,7 +53,12 @@ func main() {
 	e.Use(middleware.CORS())
 
 	// Register routes
-	registerRoutes(e, handlers, cfg)
+	registerRoutes(e, handlers)
+
+	// Purge audit log entries past AUDIT_RETENTION_DAYS
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	go handlers.Audit.RunRetention(retentionCtx)
 
 	srv := &http.Server{
 		Addr:         cfg.Addr(),
@@ -79,75 +84,133 @@ func main() {
 }
 
 // registerRoutes registers all HTTP routes
-func registerRoutes(e *echo.Echo, h *server.Handlers, cfg config.Config) {
+func registerRoutes(e *echo.Echo, h *server.Handlers) {
 	// Public routes
 	h.Health.Register(e)
 	h.Auth.Register(e)
+	h.Certificate.Register(e)
 
 	// Protected API group
 	api := e.Group("/api/v1")
-	api.Use(appmw.JWT(cfg))
+	api.Use(h.Auth.JWT())
+	// Every POST/PUT/PATCH/DELETE below is recorded in the audit log
+	api.Use(h.Audit.Middleware("/api/v1"))
 
 	api.GET("/ping", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, handler.OK(map[string]any{"pong": true}))
-	}, appmw.RequireRole("admin", "teacher", "student"))
-
-	// CRUD (read: admin/teacher; write: admin)
-	ro := api.Group("")
-	ro.Use(appmw.RequireRole("admin", "teacher"))
-	ro.Use(appmw.WriteAdminOnly())
-	h.Department.Register(ro)
-	h.Student.Register(ro)
-	h.Staff.Register(ro)
-	// Course write routes (admin only via WriteAdminOnly)
-	ro.POST("/courses", h.Course.Create)
-	ro.PUT("/courses/:id", h.Course.Update)
-	ro.DELETE("/courses/:id", h.Course.Delete)
-
-	// Courses list - all authenticated users can view (for student course selection)
-	courseReadAPI := api.Group("")
-	courseReadAPI.Use(appmw.RequireRole("admin", "teacher", "student"))
-	courseReadAPI.GET("/courses", h.Course.List)
-
-	// Terms (all authenticated users can view)
-	termAPI := api.Group("")
-	termAPI.Use(appmw.RequireRole("admin", "teacher", "student"))
-	h.Term.Register(termAPI)
+	})
+
+	// Each group below needs the permissions of its routes; the roles granting them are
+	// defined in the roles table, see model.Permissions. Users with a department only see
+	// and change that department's records; groups of records of no single department
+	// are closed to them.
+	// CRUD (read and write permissions per resource)
+	h.Department.Register(api.Group("", h.Role.Access(model.PermDeptRead, model.PermDeptWrite)))
+	h.Student.Register(api.Group("", h.Role.Require(model.PermStudentWrite)))
+	h.Staff.Register(api.Group("", h.Role.Access(model.PermStaffRead, model.PermStaffWrite)))
+
+	// Graduation and transfers
+	h.Student.RegisterArchive(api.Group("", h.Role.Require(model.PermStudentArchive)))
+
+	// Courses and offerings - readable for student course selection
+	courseAPI := api.Group("", h.Role.Access(model.PermCourseRead, model.PermCourseWrite))
+	courseAPI.GET("/courses", h.Course.List)
+	courseAPI.GET("/courses/:id/prerequisites", h.Course.Prerequisites)
+	courseAPI.GET("/courses/:id/grading-scheme", h.Course.GradingScheme)
+	courseAPI.GET("/offerings", h.Offering.List)
+	courseAPI.GET("/offerings/:id", h.Offering.Get)
+	courseAPI.POST("/courses", h.Course.Create)
+	courseAPI.PUT("/courses/:id", h.Course.Update)
+	courseAPI.DELETE("/courses/:id", h.Course.Delete)
+	courseAPI.PUT("/courses/:id/prerequisites", h.Course.SetPrerequisites)
+	courseAPI.PUT("/courses/:id/grading-scheme", h.Course.SetGradingScheme)
+	courseAPI.DELETE("/courses/:id/grading-scheme", h.Course.ResetGradingScheme)
+	courseAPI.POST("/offerings", h.Offering.Create)
+	courseAPI.PUT("/offerings/:id", h.Offering.Update)
+	courseAPI.DELETE("/offerings/:id", h.Offering.Delete)
+
+	// Terms
+	h.Term.Register(api.Group("", h.Role.Access(model.PermTermRead, model.PermTermWrite)))
+
+	// Students - any, or only those enrolled in offerings one teaches with student:read:own_course
+	api.GET("/students", h.Student.List, h.Role.Require(model.PermStudentRead, model.PermStudentReadOwnCourse))
 
 	// Student self-service: view own info
-	studentSelfAPI := api.Group("")
-	studentSelfAPI.Use(appmw.RequireRole("admin", "teacher", "student"))
-	studentSelfAPI.GET("/students/my", h.Student.MyInfo)
+	api.GET("/students/my", h.Student.MyInfo, h.Role.Require(model.PermStudentReadSelf))
 
-	// Enrollments - list for admin/teacher, create/delete for admin/student
-	enrListAPI := api.Group("")
-	enrListAPI.Use(appmw.RequireRole("admin", "teacher", "student"))
+	// Enrollments - any student's or one's own, as the service decides by permission;
+	// listings with enrollment:read:own_course only cover offerings one teaches
+	enrListAPI := api.Group("", h.Role.Require(model.PermEnrollmentRead, model.PermEnrollmentReadOwnCourse))
 	enrListAPI.GET("/enrollments", h.Enrollment.List)
-	enrListAPI.GET("/enrollments/my", h.Enrollment.MyEnrollments)
+	enrListAPI.GET("/waitlists", h.Enrollment.Waitlist)
+
+	enrMyAPI := api.Group("", h.Role.Require(model.PermEnrollmentRead, model.PermEnrollmentReadSelf))
+	enrMyAPI.GET("/enrollments/my", h.Enrollment.MyEnrollments)
+	enrMyAPI.GET("/waitlists/my", h.Enrollment.MyWaitlist)
 
-	enrWriteAPI := api.Group("")
-	enrWriteAPI.Use(appmw.RequireRole("admin", "student"))
+	enrWriteAPI := api.Group("", h.Role.Require(model.PermEnrollmentWrite, model.PermEnrollmentWriteSelf))
 	enrWriteAPI.POST("/enrollments", h.Enrollment.Create)
 	enrWriteAPI.DELETE("/enrollments/:id", h.Enrollment.Delete)
+	enrWriteAPI.DELETE("/waitlists/:id", h.Enrollment.LeaveWaitlist)
 
-	// Grades - query for all, upsert for admin/teacher
-	gradeQueryAPI := api.Group("")
-	gradeQueryAPI.Use(appmw.RequireRole("admin", "teacher", "student"))
-	gradeQueryAPI.GET("/grades", h.Grade.Query)
-	gradeQueryAPI.GET("/grades/my", h.Grade.MyGrades)
+	// Prerequisite overrides
+	overrideAPI := api.Group("", h.Role.Require(model.PermPrereqOverride), h.Role.AllDepartments())
+	overrideAPI.GET("/prerequisite-overrides", h.Enrollment.PrerequisiteOverrides)
+	overrideAPI.POST("/prerequisite-overrides", h.Enrollment.CreatePrerequisiteOverride)
+	overrideAPI.DELETE("/prerequisite-overrides/:id", h.Enrollment.DeletePrerequisiteOverride)
 
-	gradeWriteAPI := api.Group("")
-	gradeWriteAPI.Use(appmw.RequireRole("admin", "teacher"))
-	gradeWriteAPI.PUT("/grades/by-course", h.Grade.UpsertByCourse)
-	gradeWriteAPI.PUT("/grades/by-student", h.Grade.UpsertByStudent)
+	// Grades of any offering, or only those taught with grade:read:own_course
+	api.GET("/grades", h.Grade.Query, h.Role.Require(model.PermGradeRead, model.PermGradeReadOwnCourse))
 
-	// Reports (teacher/admin)
-	reportAPI := api.Group("")
-	reportAPI.Use(appmw.RequireRole("admin", "teacher"))
-	h.Report.Register(reportAPI)
+	// GPA summaries of any student, or with grade:read:own_course of those one teaches
+	api.GET("/grades/summary", h.Grade.StudentSummary, h.Role.Require(model.PermGradeRead, model.PermGradeReadOwnCourse))
 
-	// User management (admin only)
-	userAPI := api.Group("")
-	userAPI.Use(appmw.RequireRole("admin"))
-	h.User.Register(userAPI)
+	// ... and one's own
+	gradeSelfAPI := api.Group("", h.Role.Require(model.PermGradeReadSelf))
+	gradeSelfAPI.GET("/grades/my", h.Grade.MyGrades)
+	gradeSelfAPI.GET("/grades/my/summary", h.Grade.MySummary)
+	gradeSelfAPI.GET("/transcripts/my", h.Transcript.My)
+	gradeSelfAPI.GET("/certificates/enrollment/my", h.Certificate.My)
+
+	// Grade entry - any offering, or only those taught with grade:write:own_course
+	gradeWriteAPI := api.Group("", h.Role.Require(model.PermGradeWrite, model.PermGradeWriteOwnCourse))
+	gradeWriteAPI.PUT("/grades/by-course", h.Grade.UpsertByCourse)
+	gradeWriteAPI.PUT("/grades/by-student", h.Grade.UpsertByStudent)
+	gradeWriteAPI.GET("/grades/template", h.Grade.Template)
+	gradeWriteAPI.POST("/grades/template", h.Grade.UploadTemplate)
+
+	// Grade workflow and audit trail - teachers submit own offerings and request amendments,
+	// reviewers approve, return and lock
+	gradeFlowAPI := api.Group("", h.Role.Require(model.PermGradeWrite, model.PermGradeWriteOwnCourse, model.PermGradeApprove))
+	gradeFlowAPI.GET("/offerings/:id/grade-status", h.GradeFlow.Status)
+	gradeFlowAPI.POST("/offerings/:id/grade-status/:action", h.GradeFlow.Transition)
+	gradeFlowAPI.POST("/offerings/:id/amendments", h.GradeFlow.RequestAmendment)
+	gradeFlowAPI.GET("/grade-amendments", h.GradeFlow.ListAmendments)
+	gradeFlowAPI.GET("/grades/:id/history", h.GradeFlow.GradeHistory)
+	gradeFlowAPI.GET("/offerings/:id/grade-history", h.GradeFlow.OfferingHistory)
+
+	amendmentAPI := api.Group("", h.Role.Require(model.PermGradeApprove))
+	amendmentAPI.POST("/grade-amendments/:id/approve", h.GradeFlow.Approve)
+	amendmentAPI.POST("/grade-amendments/:id/reject", h.GradeFlow.Reject)
+
+	// Transcripts, enrollment certificates and issued documents of any student
+	transcriptAPI := api.Group("", h.Role.Require(model.PermTranscriptIssue))
+	transcriptAPI.GET("/transcripts/:student_no", h.Transcript.ByStudent)
+	transcriptAPI.GET("/certificates/enrollment/:student_no", h.Certificate.ByStudent)
+	transcriptAPI.GET("/issuances", h.Certificate.Issuances)
+	transcriptAPI.POST("/issuances/:code/revoke", h.Certificate.Revoke)
+
+	// Reports - of any offering, or only those taught with report:read:own_course
+	h.Report.Register(api.Group("", h.Role.Require(model.PermReportRead, model.PermReportReadOwnCourse)))
+
+	// User and role management
+	h.User.Register(api.Group("", h.Role.Require(model.PermUserManage)))
+	h.Account.Register(api.Group("", h.Role.Require(model.PermUserManage)))
+	h.Role.Register(api.Group("", h.Role.Require(model.PermRoleManage), h.Role.AllDepartments()))
+
+	// Bulk imports
+	h.Import.Register(api.Group("", h.Role.Require(model.PermDataImport), h.Role.AllDepartments()))
+
+	// Audit log
+	h.Audit.Register(api.Group("", h.Role.Access(model.PermAuditRead, model.PermAuditPurge), h.Role.AllDepartments()))
 }
@@ -21,34 +21,68 @@ import (
 func InitializeHandlers(db *gorm.DB, cfg config.Config) (*server.Handlers, error) {
 	healthHandler := handler.NewHealthHandler()
 	userRepository := repository.NewUserRepository(db)
-	authService := service.NewAuthService(userRepository, cfg)
+	sessionRepository := repository.NewSessionRepository(db)
+	mfaRepository := repository.NewMFARepository(db)
+	identityRepository := repository.NewIdentityRepository(db)
+	studentRepository := repository.NewStudentRepository(db)
+	staffRepository := repository.NewStaffRepository(db)
+	loginThrottleStore := repository.NewLoginThrottleStore(db, cfg)
+	auditRepository := repository.NewAuditRepository(db)
+	auditService := service.NewAuditService(auditRepository, cfg)
+	roleRepository := repository.NewRoleRepository(db)
+	roleService := service.NewRoleService(roleRepository)
+	authService := service.NewAuthService(userRepository, sessionRepository, mfaRepository, identityRepository, studentRepository, staffRepository, loginThrottleStore, auditService, roleService, db, cfg)
 	authHandler := handler.NewAuthHandler(authService, cfg)
 	departmentRepository := repository.NewDepartmentRepository(db)
 	departmentService := service.NewDepartmentService(departmentRepository)
 	departmentHandler := handler.NewDepartmentHandler(departmentService)
-	studentRepository := repository.NewStudentRepository(db)
-	studentService := service.NewStudentService(studentRepository, userRepository, db)
+	studentService := service.NewStudentService(studentRepository, userRepository, roleService, db, cfg)
 	studentHandler := handler.NewStudentHandler(studentService)
-	staffRepository := repository.NewStaffRepository(db)
-	staffService := service.NewStaffService(staffRepository)
+	staffService := service.NewStaffService(staffRepository, userRepository, roleService, db, cfg)
 	staffHandler := handler.NewStaffHandler(staffService)
 	courseRepository := repository.NewCourseRepository(db)
-	courseService := service.NewCourseService(courseRepository)
+	prerequisiteRepository := repository.NewPrerequisiteRepository(db)
+	gradingSchemeRepository := repository.NewGradingSchemeRepository(db)
+	courseService := service.NewCourseService(courseRepository, prerequisiteRepository, gradingSchemeRepository, db, cfg)
 	courseHandler := handler.NewCourseHandler(courseService)
+	offeringRepository := repository.NewOfferingRepository(db)
 	termRepository := repository.NewTermRepository(db)
+	enrollmentRepository := repository.NewEnrollmentRepository(db)
+	waitlistRepository := repository.NewWaitlistRepository(db)
+	offeringService := service.NewOfferingService(offeringRepository, courseRepository, termRepository, enrollmentRepository, waitlistRepository, db, cfg)
+	offeringHandler := handler.NewOfferingHandler(offeringService)
 	termService := service.NewTermService(termRepository)
 	termHandler := handler.NewTermHandler(termService)
-	enrollmentRepository := repository.NewEnrollmentRepository(db)
-	enrollmentService := service.NewEnrollmentService(enrollmentRepository, termRepository, courseRepository, studentRepository, userRepository, db)
+	gradeAuditRepository := repository.NewGradeAuditRepository(db)
+	enrollmentService := service.NewEnrollmentService(enrollmentRepository, waitlistRepository, prerequisiteRepository, offeringRepository, termRepository, courseRepository, studentRepository, userRepository, gradeAuditRepository, roleService, db, cfg)
 	enrollmentHandler := handler.NewEnrollmentHandler(enrollmentService)
 	gradeRepository := repository.NewGradeRepository(db)
-	gradeService := service.NewGradeService(gradeRepository, courseRepository, studentRepository, userRepository, staffRepository, db)
-	gradeHandler := handler.NewGradeHandler(gradeService)
+	gradeService := service.NewGradeService(gradeRepository, courseRepository, offeringRepository, studentRepository, userRepository, staffRepository, gradingSchemeRepository, gradeAuditRepository, roleService, db, cfg)
+	gpaService := service.NewGPAService(gradeRepository, studentRepository, userRepository, roleService, cfg)
+	gradeHandler := handler.NewGradeHandler(gradeService, gpaService)
+	gradeWorkflowRepository := repository.NewGradeWorkflowRepository(db)
+	gradeWorkflowService := service.NewGradeWorkflowService(gradeWorkflowRepository, offeringRepository, gradeRepository, gradingSchemeRepository, gradeAuditRepository, studentRepository, userRepository, roleService, db, cfg)
+	gradeWorkflowHandler := handler.NewGradeWorkflowHandler(gradeWorkflowService)
+	issuanceRepository := repository.NewIssuanceRepository(db)
+	documentIssuer, err := service.NewDocumentIssuer(issuanceRepository, cfg)
+	if err != nil {
+		return nil, err
+	}
+	transcriptService := service.NewTranscriptService(gradeRepository, studentRepository, userRepository, documentIssuer, cfg)
+	transcriptHandler := handler.NewTranscriptHandler(transcriptService)
+	certificateService := service.NewCertificateService(documentIssuer, issuanceRepository, studentRepository, userRepository)
+	certificateHandler := handler.NewCertificateHandler(certificateService)
 	reportRepository := repository.NewReportRepository(db)
-	reportService := service.NewReportService(reportRepository)
+	reportService := service.NewReportService(reportRepository, gradeRepository, userRepository, roleService, cfg)
 	reportHandler := handler.NewReportHandler(reportService)
-	userService := service.NewUserService(userRepository)
+	userService := service.NewUserService(userRepository, sessionRepository, mfaRepository, loginThrottleStore, roleService, cfg)
 	userHandler := handler.NewUserHandler(userService)
-	handlers := server.NewHandlers(healthHandler, authHandler, departmentHandler, studentHandler, staffHandler, courseHandler, termHandler, enrollmentHandler, gradeHandler, reportHandler, userHandler)
+	accountService := service.NewAccountService(studentRepository, staffRepository, userRepository, roleService, db, cfg)
+	accountHandler := handler.NewAccountHandler(accountService)
+	importService := service.NewImportService(departmentRepository, studentRepository, staffRepository, courseRepository, offeringRepository, termRepository, userRepository, roleService, db, cfg)
+	importHandler := handler.NewImportHandler(importService)
+	auditHandler := handler.NewAuditHandler(auditService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	handlers := server.NewHandlers(healthHandler, authHandler, departmentHandler, studentHandler, staffHandler, courseHandler, offeringHandler, termHandler, enrollmentHandler, gradeHandler, gradeWorkflowHandler, transcriptHandler, certificateHandler, reportHandler, userHandler, accountHandler, importHandler, auditHandler, roleHandler)
 	return handlers, nil
 }
@@ -0,0 +1,44 @@
+// Command mockidp is a local OpenID provider for trying single sign-on without a campus
+// identity provider. It signs in whichever of its configured users is picked on its
+// login page, enforcing the authorization code flow with PKCE as a real provider would.
+//
+//	MOCKIDP_ADDR           listen address, default :9000
+//	MOCKIDP_ISSUER         issuer URL, default http://localhost:9000
+//	MOCKIDP_CLIENT_ID      the only client accepted, default edumgr
+//	MOCKIDP_CLIENT_SECRET  its secret; empty accepts it as a public client
+//	MOCKIDP_USERS          users and their extra claims, separated by commas:
+//	                       alice:student_no=2023001,bob:staff_no=T001,carol
+//
+// Point the backend at it with OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=edumgr.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/lin-snow/edumgr/internal/pkg/mockidp"
+)
+
+func main() {
+	p, err := mockidp.New(
+		env("MOCKIDP_ISSUER", "http://localhost:9000"),
+		env("MOCKIDP_CLIENT_ID", "edumgr"),
+		os.Getenv("MOCKIDP_CLIENT_SECRET"),
+		mockidp.ParseUsers(env("MOCKIDP_USERS", "alice:student_no=2023001,bob:staff_no=T001")),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addr := env("MOCKIDP_ADDR", ":9000")
+	log.Printf("mock identity provider %s listening on %s", p.Issuer, addr)
+	log.Fatal(http.ListenAndServe(addr, p))
+}
+
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -19,6 +19,94 @@ type Config struct {
 
 	JWTSecret         string
 	JWTExpiresMinutes int
+	// RefreshTokenDays is how long a refresh token stays valid; each refresh issues a new one
+	RefreshTokenDays int
+
+	// Failed logins per username / per client IP before a lockout of LoginLockoutMinutes.
+	// Failures before that back off exponentially from one second. The counters live
+	// in the database, or in process memory when LoginThrottleStore is "memory".
+	LoginMaxFailures    int
+	LoginIPMaxFailures  int
+	LoginLockoutMinutes int
+	LoginThrottleStore  string
+
+	// Password policy applied whenever a password is set: a minimum length, a minimum
+	// number of character classes (lower case, upper case, digits, others), and whether
+	// passwords on the embedded common/breached list are refused
+	PasswordMinLength    int
+	PasswordMinClasses   int
+	PasswordRejectCommon bool
+
+	// MFARequiredRoles lists the roles (comma separated) whose accounts must enroll TOTP
+	// before they can use the API; other accounts may enroll voluntarily
+	MFARequiredRoles string
+	// MFAIssuer names this service in authenticator apps
+	MFAIssuer string
+	// MFASecretKey is a base64 AES-256 key sealing TOTP secrets at rest; when empty one is
+	// derived from JWTSecret, so changing that secret then invalidates every enrollment
+	MFASecretKey string
+
+	// OpenID Connect sign-in, enabled by OIDCIssuer: the provider, this client registered
+	// with it and the redirect URL (the frontend page that completes the sign-in). The
+	// claims name the username and the student or staff number of an identity.
+	OIDCIssuer         string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCRedirectURL    string
+	OIDCScopes         string
+	OIDCUsernameClaim  string
+	OIDCStudentNoClaim string
+	OIDCStaffNoClaim   string
+
+	// LDAP password login, enabled by LDAPURL (ldap:// or ldaps://). Users are searched
+	// under LDAPBaseDN with LDAPUserFilter, where %s is the escaped username, binding as
+	// LDAPBindDN when set, then the password is checked by binding as the entry found.
+	LDAPURL           string
+	LDAPStartTLS      bool
+	LDAPBindDN        string
+	LDAPBindPassword  string
+	LDAPBaseDN        string
+	LDAPUserFilter    string
+	LDAPUsernameAttr  string
+	LDAPStudentNoAttr string
+	LDAPStaffNoAttr   string
+
+	// SSOAutoProvision creates the account of a student or staff member signing in through
+	// OIDC or LDAP for the first time, linked by their number; staff get SSOStaffRole.
+	// SSOLinkUsername links a first sign-in to the existing account of the same username.
+	SSOAutoProvision bool
+	SSOStaffRole     string
+	SSOLinkUsername  bool
+
+	// GradePassScore is the final score at or above which a course counts as passed
+	GradePassScore float64
+	// GradeAttemptPolicy picks which attempts of a retaken course count: highest, latest or all
+	GradeAttemptPolicy string
+	// Default grading scheme for courses without their own: usual/exam weights in percent
+	// and how the computed final score is rounded (half_up, half_even, floor, ceil)
+	GradeUsualWeight float64
+	GradeExamWeight  float64
+	GradeRounding    string
+	GradeDecimals    int
+	// GPAScale is the conversion table used when a GPA request names none: 4.0, 5.0 or cn
+	GPAScale string
+	// TeacherReadScope is what roles reading with the :own_course permissions see: course
+	// (the offerings they teach) or department (those taught by anyone of their department)
+	TeacherReadScope string
+
+	// Ed25519 key that signs issued transcripts and certificates: a base64 seed,
+	// or a PEM file that is generated on first start when it does not exist
+	DocSigningKey     string
+	DocSigningKeyFile string
+	// DocRetiredKeys lists the base64 public keys of replaced signing keys, comma
+	// separated, so documents signed with them still verify
+	DocRetiredKeys string
+
+	// ImportMaxRows caps the data rows accepted by one bulk import file
+	ImportMaxRows int
+
+	// AuditRetentionDays is how long audit log entries are kept; 0 keeps them forever
+	AuditRetentionDays int
 }
 
 func Load() Config {
@@ -34,7 +122,61 @@ func Load() Config {
 		DBSSLMode: env("DB_SSLMODE", "disable"),
 
 		JWTSecret:         env("JWT_SECRET", "change-me-in-prod"),
-		JWTExpiresMinutes: envInt("JWT_EXPIRES_MINUTES", 720),
+		JWTExpiresMinutes: envInt("JWT_EXPIRES_MINUTES", 15),
+		RefreshTokenDays:  envInt("REFRESH_TOKEN_DAYS", 14),
+
+		LoginMaxFailures:    envInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:  envInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginLockoutMinutes: envInt("LOGIN_LOCKOUT_MINUTES", 15),
+		LoginThrottleStore:  env("LOGIN_THROTTLE_STORE", "db"),
+
+		PasswordMinLength:    envInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinClasses:   envInt("PASSWORD_MIN_CLASSES", 3),
+		PasswordRejectCommon: envBool("PASSWORD_REJECT_COMMON", true),
+
+		MFARequiredRoles: env("MFA_REQUIRED_ROLES", ""),
+		MFAIssuer:        env("MFA_ISSUER", "EduMgr"),
+		MFASecretKey:     env("MFA_SECRET_KEY", ""),
+
+		OIDCIssuer:         env("OIDC_ISSUER", ""),
+		OIDCClientID:       env("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:   env("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:    env("OIDC_REDIRECT_URL", "http://localhost:3000/login/callback"),
+		OIDCScopes:         env("OIDC_SCOPES", "openid profile"),
+		OIDCUsernameClaim:  env("OIDC_USERNAME_CLAIM", "preferred_username"),
+		OIDCStudentNoClaim: env("OIDC_STUDENT_NO_CLAIM", "student_no"),
+		OIDCStaffNoClaim:   env("OIDC_STAFF_NO_CLAIM", "staff_no"),
+
+		LDAPURL:           env("LDAP_URL", ""),
+		LDAPStartTLS:      envBool("LDAP_STARTTLS", false),
+		LDAPBindDN:        env("LDAP_BIND_DN", ""),
+		LDAPBindPassword:  env("LDAP_BIND_PASSWORD", ""),
+		LDAPBaseDN:        env("LDAP_BASE_DN", ""),
+		LDAPUserFilter:    env("LDAP_USER_FILTER", "(uid=%s)"),
+		LDAPUsernameAttr:  env("LDAP_USERNAME_ATTR", "uid"),
+		LDAPStudentNoAttr: env("LDAP_STUDENT_NO_ATTR", "studentNumber"),
+		LDAPStaffNoAttr:   env("LDAP_STAFF_NO_ATTR", "employeeNumber"),
+
+		SSOAutoProvision: envBool("SSO_AUTO_PROVISION", false),
+		SSOStaffRole:     env("SSO_STAFF_ROLE", "teacher"),
+		SSOLinkUsername:  envBool("SSO_LINK_USERNAME", false),
+
+		GradePassScore:     envFloat("GRADE_PASS_SCORE", 60),
+		GradeAttemptPolicy: env("GRADE_ATTEMPT_POLICY", "highest"),
+		GradeUsualWeight:   envFloat("GRADE_USUAL_WEIGHT", 30),
+		GradeExamWeight:    envFloat("GRADE_EXAM_WEIGHT", 70),
+		GradeRounding:      env("GRADE_ROUNDING", "half_up"),
+		GradeDecimals:      envInt("GRADE_DECIMALS", 0),
+		GPAScale:           env("GPA_SCALE", "4.0"),
+		TeacherReadScope:   env("TEACHER_READ_SCOPE", "course"),
+
+		DocSigningKey:     env("DOC_SIGNING_KEY", ""),
+		DocSigningKeyFile: env("DOC_SIGNING_KEY_FILE", "keys/doc_signing.pem"),
+		DocRetiredKeys:    env("DOC_RETIRED_KEYS", ""),
+
+		ImportMaxRows: envInt("IMPORT_MAX_ROWS", 5000),
+
+		AuditRetentionDays: envInt("AUDIT_RETENTION_DAYS", 365),
 	}
 }
 
@@ -69,3 +211,26 @@ func envInt(key string, def int) int {
 	return n
 }
 
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
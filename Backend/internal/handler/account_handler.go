@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// AccountHandler handles the provisioning of student and staff accounts
+type AccountHandler struct {
+	svc service.AccountService
+}
+
+// NewAccountHandler creates a new AccountHandler
+func NewAccountHandler(svc service.AccountService) *AccountHandler {
+	return &AccountHandler{svc: svc}
+}
+
+// Register registers account provisioning routes
+func (h *AccountHandler) Register(g *echo.Group) {
+	g.POST("/accounts/provision", h.Provision)
+	g.POST("/accounts/credentials-sheet", h.CredentialsSheet)
+}
+
+// Provision handles POST /accounts/provision - accounts for the students or staff that
+// have none. With format=pdf the credentials sheet of the created accounts is returned
+// instead of the result.
+func (h *AccountHandler) Provision(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var req service.ProvisionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	svc := h.svc.WithScope(scopeOf(c))
+	result, err := svc.Provision(req, actorOf(c, claims))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if c.QueryParam("format") == "pdf" && len(result.Created) > 0 {
+		data, err := svc.CredentialsPDF(result.Created)
+		if err != nil {
+			return HandleError(c, err)
+		}
+		return sendPDF(c, "credentials.pdf", data)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// CredentialsSheet handles POST /accounts/credentials-sheet - prints the accounts
+// returned by a provisioning, student/staff creation or import
+func (h *AccountHandler) CredentialsSheet(c echo.Context) error {
+	var req service.CredentialsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	data, err := h.svc.CredentialsPDF(req.Accounts)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return sendPDF(c, "credentials.pdf", data)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// AuditHandler handles audit log HTTP requests
+type AuditHandler struct {
+	svc service.AuditService
+}
+
+// NewAuditHandler creates a new AuditHandler
+func NewAuditHandler(svc service.AuditService) *AuditHandler {
+	return &AuditHandler{svc: svc}
+}
+
+// Register registers audit log routes
+func (h *AuditHandler) Register(g *echo.Group) {
+	g.GET("/audit", h.List)
+	g.POST("/audit/purge", h.Purge)
+}
+
+// Middleware records the write requests of the routes below prefix
+func (h *AuditHandler) Middleware(prefix string) echo.MiddlewareFunc {
+	return middleware.Audit(h.svc, prefix)
+}
+
+// RunRetention purges expired entries daily until ctx is done
+func (h *AuditHandler) RunRetention(ctx context.Context) {
+	h.svc.RunRetention(ctx)
+}
+
+// List handles GET /audit
+// Query: user_id, username, entity_type, entity_id, action, method, outcome, request_id,
+// from, to (RFC 3339 or YYYY-MM-DD, to exclusive), page, page_size
+func (h *AuditHandler) List(c echo.Context) error {
+	params := repository.AuditQueryParams{
+		Username:   c.QueryParam("username"),
+		EntityType: c.QueryParam("entity_type"),
+		EntityID:   c.QueryParam("entity_id"),
+		Action:     c.QueryParam("action"),
+		Method:     strings.ToUpper(c.QueryParam("method")),
+		Outcome:    c.QueryParam("outcome"),
+		RequestID:  c.QueryParam("request_id"),
+	}
+	if v := c.QueryParam("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid user_id"))
+		}
+		params.UserID = uint(id)
+	}
+	var err error
+	if params.From, err = queryTime(c, "from"); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidTimeRange, "invalid from"))
+	}
+	if params.To, err = queryTime(c, "to"); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidTimeRange, "invalid to"))
+	}
+	params.Page, _ = strconv.Atoi(c.QueryParam("page"))
+	params.PageSize, _ = strconv.Atoi(c.QueryParam("page_size"))
+
+	result, err := h.svc.List(params)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Purge handles POST /audit/purge - applies the retention policy now
+func (h *AuditHandler) Purge(c echo.Context) error {
+	n, err := h.svc.Purge()
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"purged": n}))
+}
+
+// queryTime parses an optional RFC 3339 timestamp or YYYY-MM-DD date (local midnight)
+func queryTime(c echo.Context, name string) (*time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		if t, err = time.ParseInLocation("2006-01-02", v, time.Local); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
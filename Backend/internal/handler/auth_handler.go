@@ -2,12 +2,12 @@ package handler
 
 import (
 	"net/http"
-	"strings"
+	"strconv"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 
 	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/middleware"
 	"github.com/lin-snow/edumgr/internal/pkg"
 	"github.com/lin-snow/edumgr/internal/service"
 )
@@ -18,6 +18,16 @@ type loginReq struct {
 	Password string `json:"password"`
 }
 
+// refreshReq is the request body for refresh and logout
+type refreshReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// mfaCodeReq is the request body of the two-factor routes that confirm a code
+type mfaCodeReq struct {
+	Code string `json:"code"`
+}
+
 // AuthHandler handles authentication-related HTTP requests
 type AuthHandler struct {
 	svc service.AuthService
@@ -32,11 +42,28 @@ func NewAuthHandler(svc service.AuthService, cfg config.Config) *AuthHandler {
 // Register registers auth routes
 func (h *AuthHandler) Register(e *echo.Echo) {
 	e.POST("/auth/login", h.Login)
-	e.GET("/auth/me", h.Me)
+	e.GET("/auth/providers", h.Providers)
+	e.GET("/auth/oidc/start", h.OIDCStart)
+	e.POST("/auth/oidc/callback", h.OIDCCallback)
+	e.POST("/auth/refresh", h.Refresh)
+	e.POST("/auth/logout", h.Logout)
+	e.GET("/auth/me", h.Me, h.JWT())
+	e.POST(middleware.ChangePasswordPath, h.ChangePassword, h.JWT())
+	e.POST(middleware.MFAPath+"/verify", h.VerifyMFA)
+	e.GET(middleware.MFAPath, h.MFAStatus, h.JWT())
+	e.POST(middleware.MFAPath+"/setup", h.SetupMFA, h.JWT())
+	e.POST(middleware.MFAPath+"/enable", h.EnableMFA, h.JWT())
+	e.POST(middleware.MFAPath+"/disable", h.DisableMFA, h.JWT())
+	e.POST(middleware.MFAPath+"/recovery-codes", h.RegenerateRecoveryCodes, h.JWT())
 	e.GET("/auth/setup", h.CheckSetup)
 	e.POST("/auth/setup", h.Setup)
 }
 
+// JWT authenticates access tokens and rejects those of revoked sessions
+func (h *AuthHandler) JWT() echo.MiddlewareFunc {
+	return middleware.JWT(h.cfg, h.svc)
+}
+
 // CheckSetup handles GET /auth/setup - checks if initial setup is required
 func (h *AuthHandler) CheckSetup(c echo.Context) error {
 	required, err := h.svc.IsSetupRequired()
@@ -67,49 +94,216 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Login(req.Username, req.Password)
+	result, err := h.svc.Login(req.Username, req.Password, clientInfo(c))
+	if err != nil {
+		return loginError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// loginError responds to a refused login step, telling throttled clients when to retry
+func loginError(c echo.Context, err error) error {
+	if appErr, ok := err.(*pkg.AppError); ok && appErr.Code == pkg.ErrCodeTooManyAttempts {
+		if details, ok := appErr.Details.(map[string]any); ok {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(details["retry_after"].(int)))
+		}
+	}
+	return HandleError(c, err)
+}
+
+// clientInfo describes the client of a request for the sessions it opens
+func clientInfo(c echo.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request().UserAgent(),
+		IP:        c.RealIP(),
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+}
+
+// Refresh handles POST /auth/refresh - exchanges a refresh token for new tokens
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req refreshReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.Refresh(req.RefreshToken)
 	if err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(result))
 }
 
+// Logout handles POST /auth/logout - revokes the session of a refresh token
+func (h *AuthHandler) Logout(c echo.Context) error {
+	var req refreshReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	if err := h.svc.Logout(req.RefreshToken); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"logged_out": true}))
+}
+
 // Me handles GET /auth/me
 func (h *AuthHandler) Me(c echo.Context) error {
-	// Parse JWT from header
-	header := c.Request().Header.Get("Authorization")
-	if header == "" {
-		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingAuthHeader, "missing Authorization header"))
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
 	}
 
-	parts := strings.SplitN(header, " ", 2)
-	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeInvalidAuthHeader, "invalid Authorization header"))
+	result, err := h.svc.GetCurrentUser(claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
 	}
+	return c.JSON(http.StatusOK, OK(result))
+}
 
-	tokenStr := strings.TrimSpace(parts[1])
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.cfg.JWTSecret), nil
-	})
-	if err != nil || token == nil || !token.Valid {
-		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeInvalidToken, "invalid token"))
+// ChangePassword handles POST /auth/change-password - changes the caller's own password
+// and returns the tokens of a new session, as all previous sessions are revoked
+func (h *AuthHandler) ChangePassword(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeInvalidClaims, "invalid claims"))
+	var req service.ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	userIDAny := claims["user_id"]
-	userIDFloat, ok := userIDAny.(float64)
-	if !ok {
-		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeInvalidClaims, "invalid claims"))
+	result, err := h.svc.ChangePassword(claims.UserID, req, clientInfo(c))
+	if err != nil {
+		return HandleError(c, err)
 	}
+	return c.JSON(http.StatusOK, OK(result))
+}
 
-	userID := uint(userIDFloat)
-	result, err := h.svc.GetCurrentUser(userID)
+// VerifyMFA handles POST /auth/mfa/verify - completes a login with a two-factor code
+func (h *AuthHandler) VerifyMFA(c echo.Context) error {
+	var req service.MFAVerifyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.VerifyMFA(req, clientInfo(c))
+	if err != nil {
+		return loginError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Providers handles GET /auth/providers - the sign-in methods the login page offers
+func (h *AuthHandler) Providers(c echo.Context) error {
+	return c.JSON(http.StatusOK, OK(h.svc.Providers()))
+}
+
+// OIDCStart handles GET /auth/oidc/start - where to send the browser to sign in
+func (h *AuthHandler) OIDCStart(c echo.Context) error {
+	result, err := h.svc.OIDCStart()
 	if err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(result))
 }
+
+// OIDCCallback handles POST /auth/oidc/callback - completes the sign-in with the code
+// and state the identity provider redirected back with
+func (h *AuthHandler) OIDCCallback(c echo.Context) error {
+	var req service.OIDCCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.OIDCCallback(req, clientInfo(c))
+	if err != nil {
+		return loginError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// MFAStatus handles GET /auth/mfa
+func (h *AuthHandler) MFAStatus(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	result, err := h.svc.MFAStatus(claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// SetupMFA handles POST /auth/mfa/setup - issues a new TOTP secret to confirm
+func (h *AuthHandler) SetupMFA(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	result, err := h.svc.SetupMFA(claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// EnableMFA handles POST /auth/mfa/enable - confirms the secret and returns recovery codes
+func (h *AuthHandler) EnableMFA(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var req mfaCodeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	codes, err := h.svc.EnableMFA(claims.UserID, req.Code)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"recovery_codes": codes}))
+}
+
+// DisableMFA handles POST /auth/mfa/disable
+func (h *AuthHandler) DisableMFA(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var req mfaCodeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	if err := h.svc.DisableMFA(claims.UserID, req.Code, clientInfo(c)); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"disabled": true}))
+}
+
+// RegenerateRecoveryCodes handles POST /auth/mfa/recovery-codes - replaces all recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var req mfaCodeReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	codes, err := h.svc.RegenerateRecoveryCodes(claims.UserID, req.Code, clientInfo(c))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"recovery_codes": codes}))
+}
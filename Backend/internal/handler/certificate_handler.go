@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// CertificateHandler handles enrollment certificate and document verification HTTP requests
+type CertificateHandler struct {
+	svc service.CertificateService
+}
+
+// NewCertificateHandler creates a new CertificateHandler
+func NewCertificateHandler(svc service.CertificateService) *CertificateHandler {
+	return &CertificateHandler{svc: svc}
+}
+
+// Register registers the public verification route
+func (h *CertificateHandler) Register(e *echo.Echo) {
+	e.GET("/verify/:code", h.Verify)
+}
+
+// RevokeRequest represents the request body for revoking a document
+type RevokeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Verify handles GET /verify/:code
+func (h *CertificateHandler) Verify(c echo.Context) error {
+	result, err := h.svc.Verify(c.Param("code"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// My handles GET /certificates/enrollment/my (PDF for the current student)
+func (h *CertificateHandler) My(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	data, issuance, err := h.svc.MyEnrollmentCertificatePDF(claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return sendPDF(c, "enrollment-"+issuance.Code+".pdf", data)
+}
+
+// ByStudent handles GET /certificates/enrollment/:student_no
+func (h *CertificateHandler) ByStudent(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	data, issuance, err := h.svc.WithScope(scopeOf(c)).EnrollmentCertificatePDF(c.Param("student_no"), claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return sendPDF(c, "enrollment-"+issuance.Code+".pdf", data)
+}
+
+// Issuances handles GET /issuances
+func (h *CertificateHandler) Issuances(c echo.Context) error {
+	params := repository.IssuanceQueryParams{
+		StudentNo: c.QueryParam("student_no"),
+		DocType:   c.QueryParam("doc_type"),
+	}
+	items, err := h.svc.WithScope(scopeOf(c)).ListIssuances(params)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(items))
+}
+
+// Revoke handles POST /issuances/:code/revoke
+func (h *CertificateHandler) Revoke(c echo.Context) error {
+	var req RevokeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeMissingRequired, "reason required"))
+	}
+	if err := h.svc.WithScope(scopeOf(c)).Revoke(c.Param("code"), req.Reason); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"revoked": true}))
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/service"
 )
 
@@ -27,13 +28,26 @@ func (h *CourseHandler) Register(g *echo.Group) {
 	g.POST("/courses", h.Create)
 	g.PUT("/courses/:id", h.Update)
 	g.DELETE("/courses/:id", h.Delete)
+	g.GET("/courses/:id/prerequisites", h.Prerequisites)
+	g.PUT("/courses/:id/prerequisites", h.SetPrerequisites)
+	g.GET("/courses/:id/grading-scheme", h.GradingScheme)
+	g.PUT("/courses/:id/grading-scheme", h.SetGradingScheme)
+	g.DELETE("/courses/:id/grading-scheme", h.ResetGradingScheme)
 }
 
-// List handles GET /courses
+// List handles GET /courses (format=csv|xlsx exports every match, ignoring pagination)
 func (h *CourseHandler) List(c echo.Context) error {
 	courseNo := c.QueryParam("course_no")
 	name := c.QueryParam("name")
-	teacherName := c.QueryParam("teacher_name")
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "courses", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).Export(w, courseNo, name)
+		})
+	}
 	pageStr := c.QueryParam("page")
 	pageSizeStr := c.QueryParam("page_size")
 
@@ -41,7 +55,7 @@ func (h *CourseHandler) List(c echo.Context) error {
 	if pageStr != "" || pageSizeStr != "" {
 		page, _ := strconv.Atoi(pageStr)
 		pageSize, _ := strconv.Atoi(pageSizeStr)
-		result, err := h.svc.ListPaginated(courseNo, name, teacherName, page, pageSize)
+		result, err := h.svc.WithScope(scopeOf(c)).ListPaginated(courseNo, name, page, pageSize)
 		if err != nil {
 			return HandleError(c, err)
 		}
@@ -49,7 +63,7 @@ func (h *CourseHandler) List(c echo.Context) error {
 	}
 
 	// Otherwise return all results
-	items, err := h.svc.List(courseNo, name, teacherName)
+	items, err := h.svc.WithScope(scopeOf(c)).List(courseNo, name)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -63,7 +77,7 @@ func (h *CourseHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.Create(&in); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Create(&in); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(in))
@@ -81,7 +95,7 @@ func (h *CourseHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Update(id, &in)
+	result, err := h.svc.WithScope(scopeOf(c)).Update(id, &in)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -95,8 +109,94 @@ func (h *CourseHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Delete(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
 }
+
+// prerequisitesReq is the body of PUT /courses/:id/prerequisites.
+// Each inner list holds alternative course numbers; all groups are required.
+type prerequisitesReq struct {
+	Groups [][]string `json:"groups"`
+}
+
+// Prerequisites handles GET /courses/:id/prerequisites
+func (h *CourseHandler) Prerequisites(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).GetPrerequisites(id)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// SetPrerequisites handles PUT /courses/:id/prerequisites
+func (h *CourseHandler) SetPrerequisites(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	var req prerequisitesReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).SetPrerequisites(id, req.Groups)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// GradingScheme handles GET /courses/:id/grading-scheme
+func (h *CourseHandler) GradingScheme(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).GetGradingScheme(id)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// SetGradingScheme handles PUT /courses/:id/grading-scheme
+func (h *CourseHandler) SetGradingScheme(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	var req model.GradingScheme
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).SetGradingScheme(id, &req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// ResetGradingScheme handles DELETE /courses/:id/grading-scheme
+func (h *CourseHandler) ResetGradingScheme(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).ResetGradingScheme(id)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
@@ -33,7 +33,7 @@ func (h *DepartmentHandler) List(c echo.Context) error {
 	deptNo := c.QueryParam("dept_no")
 	name := c.QueryParam("name")
 
-	items, err := h.svc.List(deptNo, name)
+	items, err := h.svc.WithScope(scopeOf(c)).List(deptNo, name)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -47,7 +47,7 @@ func (h *DepartmentHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.Create(&in); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Create(&in); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(in))
@@ -65,7 +65,7 @@ func (h *DepartmentHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Update(id, &in)
+	result, err := h.svc.WithScope(scopeOf(c)).Update(id, &in)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -79,7 +79,7 @@ func (h *DepartmentHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Delete(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
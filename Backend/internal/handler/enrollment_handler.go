@@ -8,15 +8,18 @@ import (
 
 	"github.com/lin-snow/edumgr/internal/middleware"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
+	"github.com/lin-snow/edumgr/internal/repository"
 	"github.com/lin-snow/edumgr/internal/service"
 )
 
 // enrollReq is the request body for enrollment
 type enrollReq struct {
-	TermCode   string   `json:"term_code"`
-	StudentNo  string   `json:"student_no"`
-	StudentNos []string `json:"student_nos"`
-	CourseNos  []string `json:"course_nos"`
+	TermCode    string   `json:"term_code"`
+	StudentNo   string   `json:"student_no"`
+	StudentNos  []string `json:"student_nos"`
+	CourseNos   []string `json:"course_nos"`
+	OfferingIDs []uint   `json:"offering_ids"`
 }
 
 // EnrollmentHandler handles enrollment-related HTTP requests
@@ -34,15 +37,29 @@ func (h *EnrollmentHandler) Register(g *echo.Group) {
 	// Routes are now registered manually in main.go for fine-grained RBAC control
 }
 
-// List handles GET /enrollments
+// List handles GET /enrollments (format=csv|xlsx exports every match, ignoring pagination)
 func (h *EnrollmentHandler) List(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	studentNo := c.QueryParam("student_no")
 	courseNo := c.QueryParam("course_no")
 	termCode := c.QueryParam("term_code")
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "enrollments", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).Export(w, studentNo, courseNo, termCode, claims.Role, claims.UserID)
+		})
+	}
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	pageSize, _ := strconv.Atoi(c.QueryParam("page_size"))
 
-	result, err := h.svc.List(studentNo, courseNo, termCode, page, pageSize)
+	result, err := h.svc.WithScope(scopeOf(c)).List(studentNo, courseNo, termCode, page, pageSize, claims.Role, claims.UserID)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -57,7 +74,7 @@ func (h *EnrollmentHandler) MyEnrollments(c echo.Context) error {
 	}
 
 	studentNo := c.QueryParam("student_no")
-	result, err := h.svc.ListByStudent(claims.Role, claims.UserID, studentNo)
+	result, err := h.svc.WithScope(scopeOf(c)).ListByStudent(claims.Role, claims.UserID, studentNo)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -77,13 +94,14 @@ func (h *EnrollmentHandler) Create(c echo.Context) error {
 	}
 
 	svcReq := service.EnrollRequest{
-		TermCode:   req.TermCode,
-		StudentNo:  req.StudentNo,
-		StudentNos: req.StudentNos,
-		CourseNos:  req.CourseNos,
+		TermCode:    req.TermCode,
+		StudentNo:   req.StudentNo,
+		StudentNos:  req.StudentNos,
+		CourseNos:   req.CourseNos,
+		OfferingIDs: req.OfferingIDs,
 	}
 
-	results, err := h.svc.Enroll(svcReq, claims.Role, claims.UserID)
+	results, err := h.svc.WithScope(scopeOf(c)).Enroll(svcReq, claims.Role, claims.UserID)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -102,7 +120,106 @@ func (h *EnrollmentHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Delete(id, claims.Role, claims.UserID); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id, actorOf(c, claims)); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
+}
+
+// Waitlist handles GET /waitlists
+func (h *EnrollmentHandler) Waitlist(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	params := repository.WaitlistQueryParams{
+		StudentNo: c.QueryParam("student_no"),
+		CourseNo:  c.QueryParam("course_no"),
+		TermCode:  c.QueryParam("term_code"),
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).ListWaitlist(params, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// MyWaitlist handles GET /waitlists/my (for students to view their own queue positions)
+func (h *EnrollmentHandler) MyWaitlist(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	studentNo := c.QueryParam("student_no")
+	result, err := h.svc.WithScope(scopeOf(c)).ListWaitlistByStudent(claims.Role, claims.UserID, studentNo)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// LeaveWaitlist handles DELETE /waitlists/:id
+func (h *EnrollmentHandler) LeaveWaitlist(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	if err := h.svc.WithScope(scopeOf(c)).LeaveWaitlist(id, claims.Role, claims.UserID); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
+}
+
+// PrerequisiteOverrides handles GET /prerequisite-overrides
+func (h *EnrollmentHandler) PrerequisiteOverrides(c echo.Context) error {
+	params := repository.OverrideQueryParams{
+		StudentNo: c.QueryParam("student_no"),
+		CourseNo:  c.QueryParam("course_no"),
+	}
+
+	result, err := h.svc.ListPrerequisiteOverrides(params)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// CreatePrerequisiteOverride handles POST /prerequisite-overrides
+func (h *EnrollmentHandler) CreatePrerequisiteOverride(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var req service.CreateOverrideRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.CreatePrerequisiteOverride(req, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// DeletePrerequisiteOverride handles DELETE /prerequisite-overrides/:id
+func (h *EnrollmentHandler) DeletePrerequisiteOverride(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	if err := h.svc.DeletePrerequisiteOverride(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
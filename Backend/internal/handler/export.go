@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
+)
+
+// exportFormat picks a spreadsheet format from ?format= or, failing that, the Accept
+// header. An empty result means the caller should answer with JSON as usual.
+func exportFormat(c echo.Context) (string, error) {
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" {
+		accept := c.Request().Header.Get(echo.HeaderAccept)
+		switch {
+		case strings.Contains(accept, export.ContentTypeXLSX):
+			format = export.FormatXLSX
+		case strings.Contains(accept, "text/csv"):
+			format = export.FormatCSV
+		}
+	}
+
+	switch format {
+	case "", "json":
+		return "", nil
+	case export.FormatCSV, export.FormatXLSX:
+		return format, nil
+	}
+	return "", pkg.NewAppError(pkg.ErrCodeExportFormat, "format must be json, csv or xlsx")
+}
+
+// sendExport streams a spreadsheet download named name.<format>. Errors raised before
+// anything reached the client are answered as JSON; later ones can only cut the file short.
+func sendExport(c echo.Context, format, name string, write func(w export.Writer) error) error {
+	res := c.Response()
+	w, err := export.NewWriter(format, res)
+	if err != nil {
+		return HandleError(c, pkg.NewAppError(pkg.ErrCodeExportFormat, err.Error()))
+	}
+	res.Header().Set(echo.HeaderContentType, export.ContentType(format))
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+"."+format+`"`)
+
+	if err = write(w); err == nil {
+		err = w.Close()
+	}
+	if err != nil && !res.Committed {
+		res.Header().Del(echo.HeaderContentType)
+		res.Header().Del(echo.HeaderContentDisposition)
+		return HandleError(c, err)
+	}
+	return err
+}
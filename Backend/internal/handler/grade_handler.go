@@ -2,17 +2,20 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/lin-snow/edumgr/internal/middleware"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/service"
 )
 
 // putGradesByCourseReq is the request body for grades by course
 type putGradesByCourseReq struct {
 	CourseNo string              `json:"course_no"`
+	TermCode string              `json:"term_code"`
 	Items    []service.GradeItem `json:"items"`
 }
 
@@ -24,12 +27,13 @@ type putGradesByStudentReq struct {
 
 // GradeHandler handles grade-related HTTP requests
 type GradeHandler struct {
-	svc service.GradeService
+	svc    service.GradeService
+	gpaSvc service.GPAService
 }
 
 // NewGradeHandler creates a new GradeHandler
-func NewGradeHandler(svc service.GradeService) *GradeHandler {
-	return &GradeHandler{svc: svc}
+func NewGradeHandler(svc service.GradeService, gpaSvc service.GPAService) *GradeHandler {
+	return &GradeHandler{svc: svc, gpaSvc: gpaSvc}
 }
 
 // Register registers grade routes (not used when routes are manually registered in main.go)
@@ -51,8 +55,41 @@ func (h *GradeHandler) MyGrades(c echo.Context) error {
 	return c.JSON(http.StatusOK, OK(result))
 }
 
+// MySummary handles GET /grades/my/summary (GPA and credits of the current student)
+func (h *GradeHandler) MySummary(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	result, err := h.gpaSvc.MySummary(claims.UserID, c.QueryParam("scale"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// StudentSummary handles GET /grades/summary?student_no= (admin/teacher)
+func (h *GradeHandler) StudentSummary(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	result, err := h.gpaSvc.WithScope(scopeOf(c)).StudentSummary(c.QueryParam("student_no"), c.QueryParam("scale"), claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
 // Query handles GET /grades
 func (h *GradeHandler) Query(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	params := service.GradeQueryParams{
 		StudentNo:   c.QueryParam("student_no"),
 		StudentName: c.QueryParam("student_name"),
@@ -60,9 +97,19 @@ func (h *GradeHandler) Query(c echo.Context) error {
 		CourseName:  c.QueryParam("course_name"),
 		TeacherName: c.QueryParam("teacher_name"),
 		DeptNo:      c.QueryParam("dept_no"),
+		TermCode:    c.QueryParam("term_code"),
+	}
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "grades", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).ExportQuery(w, params, claims.Role, claims.UserID)
+		})
 	}
 
-	result, err := h.svc.Query(params)
+	result, err := h.svc.WithScope(scopeOf(c)).Query(params, claims.Role, claims.UserID)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -75,16 +122,13 @@ func (h *GradeHandler) UpsertByCourse(c echo.Context) error {
 	if claims == nil {
 		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
 	}
-	if claims.Role != "admin" && claims.Role != "teacher" {
-		return c.JSON(http.StatusForbidden, Err(pkg.ErrCodeForbidden, "forbidden"))
-	}
 
 	var req putGradesByCourseReq
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.UpsertByCourse(req.CourseNo, req.Items, claims.Role, claims.UserID); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).UpsertByCourse(req.CourseNo, req.TermCode, req.Items, actorOf(c, claims)); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"updated": true}))
@@ -96,17 +140,75 @@ func (h *GradeHandler) UpsertByStudent(c echo.Context) error {
 	if claims == nil {
 		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
 	}
-	if claims.Role != "admin" && claims.Role != "teacher" {
-		return c.JSON(http.StatusForbidden, Err(pkg.ErrCodeForbidden, "forbidden"))
-	}
 
 	var req putGradesByStudentReq
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.UpsertByStudent(req.StudentNo, req.Items, claims.Role, claims.UserID); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).UpsertByStudent(req.StudentNo, req.Items, actorOf(c, claims)); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"updated": true}))
 }
+
+// Template handles GET /grades/template?offering_id=&format=xlsx|csv (xlsx by default)
+func (h *GradeHandler) Template(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	offeringID, err := strconv.ParseUint(c.QueryParam("offering_id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid offering_id"))
+	}
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format == "" {
+		format = export.FormatXLSX
+	}
+	name := "grade-template-" + strconv.FormatUint(offeringID, 10)
+	return sendExport(c, format, name, func(w export.Writer) error {
+		return h.svc.WithScope(scopeOf(c)).GradeTemplate(w, uint(offeringID), claims.Role, claims.UserID)
+	})
+}
+
+// UploadTemplate handles POST /grades/template?offering_id=&mode=dry_run|commit (multipart field "file")
+func (h *GradeHandler) UploadTemplate(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	offeringID, err := strconv.ParseUint(c.QueryParam("offering_id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid offering_id"))
+	}
+	var commit bool
+	switch c.QueryParam("mode") {
+	case "", "dry_run":
+	case "commit":
+		commit = true
+	default:
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeImportFormat, "mode must be dry_run or commit"))
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeMissingRequired, "file required"))
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeImportFormat, "cannot read file"))
+	}
+	defer f.Close()
+
+	result, err := h.svc.WithScope(scopeOf(c)).UploadGradeTemplate(uint(offeringID), fh.Filename, f, commit, actorOf(c, claims))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// gradeStatusReq is the request body of a grade status action
+type gradeStatusReq struct {
+	Note string `json:"note"`
+}
+
+// GradeWorkflowHandler handles grade submission and amendment HTTP requests
+type GradeWorkflowHandler struct {
+	svc service.GradeWorkflowService
+}
+
+// NewGradeWorkflowHandler creates a new GradeWorkflowHandler
+func NewGradeWorkflowHandler(svc service.GradeWorkflowService) *GradeWorkflowHandler {
+	return &GradeWorkflowHandler{svc: svc}
+}
+
+// Status handles GET /offerings/:id/grade-status
+func (h *GradeWorkflowHandler) Status(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).Status(id, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Transition handles POST /offerings/:id/grade-status/:action (submit/approve/return/lock)
+func (h *GradeWorkflowHandler) Transition(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+	var req gradeStatusReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).Transition(id, c.Param("action"), req.Note, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// RequestAmendment handles POST /offerings/:id/amendments
+func (h *GradeWorkflowHandler) RequestAmendment(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+	var req service.AmendmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).RequestAmendment(id, req, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// ListAmendments handles GET /grade-amendments?status=&offering_id=
+func (h *GradeWorkflowHandler) ListAmendments(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	params := service.AmendmentQueryParams{Status: c.QueryParam("status")}
+	if s := c.QueryParam("offering_id"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid offering_id"))
+		}
+		params.OfferingID = uint(id)
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).ListAmendments(params, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Approve handles POST /grade-amendments/:id/approve
+func (h *GradeWorkflowHandler) Approve(c echo.Context) error {
+	return h.review(c, true)
+}
+
+// Reject handles POST /grade-amendments/:id/reject (note required)
+func (h *GradeWorkflowHandler) Reject(c echo.Context) error {
+	return h.review(c, false)
+}
+
+func (h *GradeWorkflowHandler) review(c echo.Context, approve bool) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+	var req gradeStatusReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).ReviewAmendment(id, approve, req.Note, actorOf(c, claims))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// GradeHistory handles GET /grades/:id/history
+func (h *GradeWorkflowHandler) GradeHistory(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).GradeHistory(id, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// OfferingHistory handles GET /offerings/:id/grade-history
+func (h *GradeWorkflowHandler) OfferingHistory(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).OfferingHistory(id, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
@@ -4,6 +4,10 @@ import (
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/repository"
+	"github.com/lin-snow/edumgr/internal/service"
 )
 
 // pathUint extracts a uint path parameter
@@ -15,3 +19,21 @@ func pathUint(c echo.Context, name string) (uint, error) {
 	}
 	return uint(n), nil
 }
+
+// actorOf identifies the caller for the audit trail by the request ID set by the RequestID middleware
+func actorOf(c echo.Context, claims *middleware.Claims) service.Actor {
+	return service.Actor{
+		UserID:    claims.UserID,
+		Role:      claims.Role,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}
+}
+
+// scopeOf limits data access to the department of a department-scoped caller
+func scopeOf(c echo.Context) repository.Scope {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return repository.Scope{}
+	}
+	return repository.Scope{DeptID: claims.DeptID}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// ImportHandler handles bulk import HTTP requests
+type ImportHandler struct {
+	svc service.ImportService
+}
+
+// NewImportHandler creates a new ImportHandler
+func NewImportHandler(svc service.ImportService) *ImportHandler {
+	return &ImportHandler{svc: svc}
+}
+
+// Register registers import routes
+func (h *ImportHandler) Register(g *echo.Group) {
+	g.POST("/imports/:entity", h.Import)
+}
+
+// Import handles POST /imports/:entity?mode=dry_run|commit (multipart field "file", .csv or .xlsx).
+// Students and staff are imported with their accounts given provision_accounts=true,
+// staff ones of account_role or teacher.
+func (h *ImportHandler) Import(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var commit bool
+	switch c.QueryParam("mode") {
+	case "", "dry_run":
+	case "commit":
+		commit = true
+	default:
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeImportFormat, "mode must be dry_run or commit"))
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeMissingRequired, "file required"))
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeImportFormat, "cannot read file"))
+	}
+	defer f.Close()
+
+	account := service.AccountOption{
+		Provision: c.QueryParam("provision_accounts") == "true",
+		Role:      c.QueryParam("account_role"),
+	}
+	result, err := h.svc.Import(c.Param("entity"), fh.Filename, f, commit, account, actorOf(c, claims))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// OfferingHandler handles course offering (section) HTTP requests
+type OfferingHandler struct {
+	svc service.OfferingService
+}
+
+// NewOfferingHandler creates a new OfferingHandler
+func NewOfferingHandler(svc service.OfferingService) *OfferingHandler {
+	return &OfferingHandler{svc: svc}
+}
+
+// Register registers course offering routes
+func (h *OfferingHandler) Register(g *echo.Group) {
+	g.GET("/offerings", h.List)
+	g.GET("/offerings/:id", h.Get)
+	g.POST("/offerings", h.Create)
+	g.PUT("/offerings/:id", h.Update)
+	g.DELETE("/offerings/:id", h.Delete)
+}
+
+// List handles GET /offerings
+func (h *OfferingHandler) List(c echo.Context) error {
+	pageStr := c.QueryParam("page")
+	pageSizeStr := c.QueryParam("page_size")
+	params := repository.OfferingQueryParams{
+		CourseNo:    c.QueryParam("course_no"),
+		Name:        c.QueryParam("name"),
+		TeacherName: c.QueryParam("teacher_name"),
+		TermCode:    c.QueryParam("term_code"),
+	}
+
+	// If pagination params provided, use paginated query
+	if pageStr != "" || pageSizeStr != "" {
+		params.Page, _ = strconv.Atoi(pageStr)
+		params.PageSize, _ = strconv.Atoi(pageSizeStr)
+		if params.Page <= 0 {
+			params.Page = 1
+		}
+		if params.PageSize <= 0 {
+			params.PageSize = 20
+		}
+		result, err := h.svc.WithScope(scopeOf(c)).List(params)
+		if err != nil {
+			return HandleError(c, err)
+		}
+		return c.JSON(http.StatusOK, OK(result))
+	}
+
+	// Otherwise return all results
+	result, err := h.svc.WithScope(scopeOf(c)).List(params)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result.Items))
+}
+
+// Get handles GET /offerings/:id
+func (h *OfferingHandler) Get(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).GetByID(id)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Create handles POST /offerings
+func (h *OfferingHandler) Create(c echo.Context) error {
+	var in model.CourseOffering
+	if err := c.Bind(&in); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	if err := h.svc.WithScope(scopeOf(c)).Create(&in); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(in))
+}
+
+// Update handles PUT /offerings/:id
+func (h *OfferingHandler) Update(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	var in model.CourseOffering
+	if err := c.Bind(&in); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.WithScope(scopeOf(c)).Update(id, &in)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Delete handles DELETE /offerings/:id
+func (h *OfferingHandler) Delete(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
+}
@@ -10,9 +10,17 @@ var ProviderSet = wire.NewSet(
 	NewStudentHandler,
 	NewStaffHandler,
 	NewCourseHandler,
+	NewOfferingHandler,
 	NewTermHandler,
 	NewEnrollmentHandler,
 	NewGradeHandler,
+	NewGradeWorkflowHandler,
+	NewTranscriptHandler,
+	NewCertificateHandler,
 	NewReportHandler,
 	NewUserHandler,
+	NewAccountHandler,
+	NewImportHandler,
+	NewAuditHandler,
+	NewRoleHandler,
 )
@@ -5,6 +5,9 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/service"
 )
 
@@ -22,18 +25,34 @@ func NewReportHandler(svc service.ReportService) *ReportHandler {
 func (h *ReportHandler) Register(g *echo.Group) {
 	g.GET("/reports/grade-roster", h.GradeRoster)
 	g.GET("/reports/grade-report", h.GradeReport)
+	g.GET("/reports/grade-report/pdf", h.GradeReportPDF)
 }
 
 // GradeRoster handles GET /reports/grade-roster
 func (h *ReportHandler) GradeRoster(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	params := service.ReportQueryParams{
 		CourseNo:    c.QueryParam("course_no"),
 		CourseName:  c.QueryParam("course_name"),
 		TeacherName: c.QueryParam("teacher_name"),
 		DeptNo:      c.QueryParam("dept_no"),
+		TermCode:    c.QueryParam("term_code"),
+	}
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "grade-roster", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).ExportGradeRoster(w, params, claims.Role, claims.UserID)
+		})
 	}
 
-	result, err := h.svc.GetGradeRoster(params)
+	result, err := h.svc.WithScope(scopeOf(c)).GetGradeRoster(params, claims.Role, claims.UserID)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -42,16 +61,53 @@ func (h *ReportHandler) GradeRoster(c echo.Context) error {
 
 // GradeReport handles GET /reports/grade-report
 func (h *ReportHandler) GradeReport(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	params := service.ReportQueryParams{
 		CourseNo:    c.QueryParam("course_no"),
 		CourseName:  c.QueryParam("course_name"),
 		TeacherName: c.QueryParam("teacher_name"),
 		DeptNo:      c.QueryParam("dept_no"),
+		TermCode:    c.QueryParam("term_code"),
+	}
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "grade-report", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).ExportGradeReport(w, params, claims.Role, claims.UserID)
+		})
 	}
 
-	result, err := h.svc.GetGradeReport(params)
+	result, err := h.svc.WithScope(scopeOf(c)).GetGradeReport(params, claims.Role, claims.UserID)
 	if err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(result))
 }
+
+// GradeReportPDF handles GET /reports/grade-report/pdf (same filters as GradeReport)
+func (h *ReportHandler) GradeReportPDF(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	params := service.ReportQueryParams{
+		CourseNo:    c.QueryParam("course_no"),
+		CourseName:  c.QueryParam("course_name"),
+		TeacherName: c.QueryParam("teacher_name"),
+		DeptNo:      c.QueryParam("dept_no"),
+		TermCode:    c.QueryParam("term_code"),
+	}
+
+	data, err := h.svc.WithScope(scopeOf(c)).GradeReportPDF(params, claims.Role, claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return sendPDF(c, "grade-report.pdf", data)
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
 )
 
 // Response is the unified API response structure
@@ -27,9 +29,13 @@ func Err(code int, message string) Response {
 
 // HandleError handles service errors and returns appropriate HTTP response
 func HandleError(c echo.Context, err error) error {
+	// A department-scoped repository refused to write outside its department
+	if errors.Is(err, repository.ErrOutOfScope) {
+		return c.JSON(http.StatusForbidden, Err(pkg.ErrCodeOutOfScope, "outside your department"))
+	}
 	if appErr, ok := err.(*pkg.AppError); ok {
 		httpStatus := getHTTPStatus(appErr.Code)
-		return c.JSON(httpStatus, Err(appErr.Code, appErr.Message))
+		return c.JSON(httpStatus, Response{Code: appErr.Code, Message: appErr.Message, Data: appErr.Details})
 	}
 	return c.JSON(http.StatusInternalServerError, Err(pkg.ErrCodeDBError, "internal error"))
 }
@@ -43,6 +49,8 @@ func getHTTPStatus(code int) int {
 		return http.StatusForbidden
 	case code >= 40400 && code < 40500:
 		return http.StatusNotFound
+	case code >= 42900 && code < 43000:
+		return http.StatusTooManyRequests
 	case code >= 50000:
 		return http.StatusInternalServerError
 	default:
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// RoleHandler handles role management HTTP requests and guards routes by permission
+type RoleHandler struct {
+	svc service.RoleService
+}
+
+// NewRoleHandler creates a new RoleHandler
+func NewRoleHandler(svc service.RoleService) *RoleHandler {
+	return &RoleHandler{svc: svc}
+}
+
+// Register registers role management routes
+func (h *RoleHandler) Register(g *echo.Group) {
+	g.GET("/permissions", h.Permissions)
+	g.GET("/roles", h.List)
+	g.POST("/roles", h.Create)
+	g.PUT("/roles/:id", h.Update)
+	g.DELETE("/roles/:id", h.Delete)
+}
+
+// Require lets requests through when the caller's role holds any of perms
+func (h *RoleHandler) Require(perms ...string) echo.MiddlewareFunc {
+	return middleware.RequirePermission(h.svc, perms...)
+}
+
+// Access needs read for reading requests and write for the others
+func (h *RoleHandler) Access(read, write string) echo.MiddlewareFunc {
+	return middleware.RequireAccess(h.svc, read, write)
+}
+
+// AllDepartments lets through only callers not limited to one department
+func (h *RoleHandler) AllDepartments() echo.MiddlewareFunc {
+	return middleware.RequireAllDepartments()
+}
+
+// Permissions handles GET /permissions - every permission a role can be granted
+func (h *RoleHandler) Permissions(c echo.Context) error {
+	return c.JSON(http.StatusOK, OK(model.Permissions))
+}
+
+// List handles GET /roles
+func (h *RoleHandler) List(c echo.Context) error {
+	result, err := h.svc.List()
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Create handles POST /roles
+func (h *RoleHandler) Create(c echo.Context) error {
+	var req service.RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.Create(req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Update handles PUT /roles/:id
+func (h *RoleHandler) Update(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	var req service.RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
+	}
+
+	result, err := h.svc.Update(id, req)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(result))
+}
+
+// Delete handles DELETE /roles/:id
+func (h *RoleHandler) Delete(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	if err := h.svc.Delete(id); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
+}
@@ -6,11 +6,25 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/lin-snow/edumgr/internal/middleware"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/service"
 )
 
+// createStaffReq is a staff member to create, optionally with their account
+type createStaffReq struct {
+	model.Staff
+	service.AccountOption
+}
+
+// staffCreated is a created staff member with the account provisioned for them, if any
+type staffCreated struct {
+	*model.Staff
+	Account *service.ProvisionedAccount `json:"account,omitempty"`
+}
+
 // StaffHandler handles staff-related HTTP requests
 type StaffHandler struct {
 	svc service.StaffService
@@ -29,11 +43,20 @@ func (h *StaffHandler) Register(g *echo.Group) {
 	g.DELETE("/staff/:id", h.Delete)
 }
 
-// List handles GET /staff
+// List handles GET /staff (format=csv|xlsx exports every match, ignoring pagination)
 func (h *StaffHandler) List(c echo.Context) error {
 	staffNo := c.QueryParam("staff_no")
 	name := c.QueryParam("name")
 	deptNo := c.QueryParam("dept_no")
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "staff", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).Export(w, staffNo, name, deptNo)
+		})
+	}
 	pageStr := c.QueryParam("page")
 	pageSizeStr := c.QueryParam("page_size")
 
@@ -41,7 +64,7 @@ func (h *StaffHandler) List(c echo.Context) error {
 	if pageStr != "" || pageSizeStr != "" {
 		page, _ := strconv.Atoi(pageStr)
 		pageSize, _ := strconv.Atoi(pageSizeStr)
-		result, err := h.svc.ListPaginated(staffNo, name, deptNo, page, pageSize)
+		result, err := h.svc.WithScope(scopeOf(c)).ListPaginated(staffNo, name, deptNo, page, pageSize)
 		if err != nil {
 			return HandleError(c, err)
 		}
@@ -49,24 +72,31 @@ func (h *StaffHandler) List(c echo.Context) error {
 	}
 
 	// Otherwise return all results
-	items, err := h.svc.List(staffNo, name, deptNo)
+	items, err := h.svc.WithScope(scopeOf(c)).List(staffNo, name, deptNo)
 	if err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(items))
 }
 
-// Create handles POST /staff
+// Create handles POST /staff (provision_account also creates the member's account,
+// of account_role or teacher)
 func (h *StaffHandler) Create(c echo.Context) error {
-	var in model.Staff
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var in createStaffReq
 	if err := c.Bind(&in); err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.Create(&in); err != nil {
+	account, err := h.svc.WithScope(scopeOf(c)).Create(&in.Staff, in.AccountOption, actorOf(c, claims))
+	if err != nil {
 		return HandleError(c, err)
 	}
-	return c.JSON(http.StatusOK, OK(in))
+	return c.JSON(http.StatusOK, OK(staffCreated{Staff: &in.Staff, Account: account}))
 }
 
 // Update handles PUT /staff/:id
@@ -81,7 +111,7 @@ func (h *StaffHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Update(id, &in)
+	result, err := h.svc.WithScope(scopeOf(c)).Update(id, &in)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -95,7 +125,7 @@ func (h *StaffHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Delete(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
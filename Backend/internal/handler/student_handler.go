@@ -9,9 +9,22 @@ import (
 	"github.com/lin-snow/edumgr/internal/middleware"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/service"
 )
 
+// createStudentReq is a student to create, optionally with their account
+type createStudentReq struct {
+	model.Student
+	service.AccountOption
+}
+
+// studentCreated is a created student with the account provisioned for them, if any
+type studentCreated struct {
+	*model.Student
+	Account *service.ProvisionedAccount `json:"account,omitempty"`
+}
+
 // StudentHandler handles student-related HTTP requests
 type StudentHandler struct {
 	svc service.StudentService
@@ -22,22 +35,40 @@ func NewStudentHandler(svc service.StudentService) *StudentHandler {
 	return &StudentHandler{svc: svc}
 }
 
-// Register registers student routes
+// Register registers the routes that change students
 func (h *StudentHandler) Register(g *echo.Group) {
-	g.GET("/students", h.List)
 	g.POST("/students", h.Create)
 	g.PUT("/students/:id", h.Update)
 	g.DELETE("/students/:id", h.Delete)
+}
+
+// RegisterArchive registers the routes that change a student's enrollment status
+func (h *StudentHandler) RegisterArchive(g *echo.Group) {
 	g.POST("/students/:id/graduate", h.Graduate)
 	g.POST("/students/:id/transfer-out", h.TransferOut)
 	g.POST("/students/:id/transfer-in", h.TransferIn)
 }
 
-// List handles GET /students
+// List handles GET /students (format=csv|xlsx exports every match, ignoring pagination).
+// With student:read:own_course only students enrolled in offerings one teaches are listed.
 func (h *StudentHandler) List(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	studentNo := c.QueryParam("student_no")
 	name := c.QueryParam("name")
 	deptNo := c.QueryParam("dept_no")
+	format, err := exportFormat(c)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	if format != "" {
+		return sendExport(c, format, "students", func(w export.Writer) error {
+			return h.svc.WithScope(scopeOf(c)).Export(w, studentNo, name, deptNo, claims.Role, claims.UserID)
+		})
+	}
 	pageStr := c.QueryParam("page")
 	pageSizeStr := c.QueryParam("page_size")
 
@@ -45,7 +76,7 @@ func (h *StudentHandler) List(c echo.Context) error {
 	if pageStr != "" || pageSizeStr != "" {
 		page, _ := strconv.Atoi(pageStr)
 		pageSize, _ := strconv.Atoi(pageSizeStr)
-		result, err := h.svc.ListPaginated(studentNo, name, deptNo, page, pageSize)
+		result, err := h.svc.WithScope(scopeOf(c)).ListPaginated(studentNo, name, deptNo, page, pageSize, claims.Role, claims.UserID)
 		if err != nil {
 			return HandleError(c, err)
 		}
@@ -53,24 +84,30 @@ func (h *StudentHandler) List(c echo.Context) error {
 	}
 
 	// Otherwise return all results
-	items, err := h.svc.List(studentNo, name, deptNo)
+	items, err := h.svc.WithScope(scopeOf(c)).List(studentNo, name, deptNo, claims.Role, claims.UserID)
 	if err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(items))
 }
 
-// Create handles POST /students
+// Create handles POST /students (provision_account also creates the student's account)
 func (h *StudentHandler) Create(c echo.Context) error {
-	var in model.Student
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	var in createStudentReq
 	if err := c.Bind(&in); err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.Create(&in); err != nil {
+	account, err := h.svc.WithScope(scopeOf(c)).Create(&in.Student, in.AccountOption, actorOf(c, claims))
+	if err != nil {
 		return HandleError(c, err)
 	}
-	return c.JSON(http.StatusOK, OK(in))
+	return c.JSON(http.StatusOK, OK(studentCreated{Student: &in.Student, Account: account}))
 }
 
 // Update handles PUT /students/:id
@@ -85,7 +122,7 @@ func (h *StudentHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Update(id, &in)
+	result, err := h.svc.WithScope(scopeOf(c)).Update(id, &in)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -99,7 +136,7 @@ func (h *StudentHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Delete(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
@@ -112,7 +149,7 @@ func (h *StudentHandler) Graduate(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Graduate(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Graduate(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"archived": true}))
@@ -125,7 +162,7 @@ func (h *StudentHandler) TransferOut(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.TransferOut(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).TransferOut(id); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"archived": true}))
@@ -138,7 +175,7 @@ func (h *StudentHandler) TransferIn(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	result, err := h.svc.TransferIn(id)
+	result, err := h.svc.WithScope(scopeOf(c)).TransferIn(id)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/middleware"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/service"
+)
+
+// TranscriptHandler handles transcript HTTP requests
+type TranscriptHandler struct {
+	svc service.TranscriptService
+}
+
+// NewTranscriptHandler creates a new TranscriptHandler
+func NewTranscriptHandler(svc service.TranscriptService) *TranscriptHandler {
+	return &TranscriptHandler{svc: svc}
+}
+
+// My handles GET /transcripts/my (PDF of the current student's record)
+func (h *TranscriptHandler) My(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	data, t, err := h.svc.MyTranscriptPDF(claims.UserID, c.QueryParam("scale"))
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return sendPDF(c, "transcript-"+t.StudentNo+".pdf", data)
+}
+
+// ByStudent handles GET /transcripts/:student_no
+func (h *TranscriptHandler) ByStudent(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	data, t, err := h.svc.WithScope(scopeOf(c)).StudentTranscriptPDF(c.Param("student_no"), c.QueryParam("scale"), claims.UserID)
+	if err != nil {
+		return HandleError(c, err)
+	}
+	return sendPDF(c, "transcript-"+t.StudentNo+".pdf", data)
+}
+
+// sendPDF writes a PDF as a download
+func sendPDF(c echo.Context, filename string, data []byte) error {
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	return c.Blob(http.StatusOK, "application/pdf", data)
+}
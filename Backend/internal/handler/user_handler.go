@@ -5,6 +5,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/lin-snow/edumgr/internal/middleware"
 	"github.com/lin-snow/edumgr/internal/pkg"
 	"github.com/lin-snow/edumgr/internal/service"
 )
@@ -32,11 +33,13 @@ func (h *UserHandler) Register(g *echo.Group) {
 	g.PUT("/users/:id", h.Update)
 	g.DELETE("/users/:id", h.Delete)
 	g.POST("/users/:id/reset-password", h.ResetPassword)
+	g.POST("/users/:id/unlock", h.Unlock)
+	g.POST("/users/:id/reset-mfa", h.ResetMFA)
 }
 
 // List handles GET /users
 func (h *UserHandler) List(c echo.Context) error {
-	result, err := h.svc.List()
+	result, err := h.svc.WithScope(scopeOf(c)).List()
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -50,7 +53,7 @@ func (h *UserHandler) GetByID(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	result, err := h.svc.GetByID(id)
+	result, err := h.svc.WithScope(scopeOf(c)).GetByID(id)
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -59,12 +62,17 @@ func (h *UserHandler) GetByID(c echo.Context) error {
 
 // Create handles POST /users
 func (h *UserHandler) Create(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	var req service.CreateUserRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Create(req)
+	result, err := h.svc.WithScope(scopeOf(c)).Create(req, actorOf(c, claims))
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -73,6 +81,11 @@ func (h *UserHandler) Create(c echo.Context) error {
 
 // Update handles PUT /users/:id
 func (h *UserHandler) Update(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	id, err := pathUint(c, "id")
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
@@ -83,7 +96,7 @@ func (h *UserHandler) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	result, err := h.svc.Update(id, req)
+	result, err := h.svc.WithScope(scopeOf(c)).Update(id, req, actorOf(c, claims))
 	if err != nil {
 		return HandleError(c, err)
 	}
@@ -92,12 +105,17 @@ func (h *UserHandler) Update(c echo.Context) error {
 
 // Delete handles DELETE /users/:id
 func (h *UserHandler) Delete(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	id, err := pathUint(c, "id")
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
 	}
 
-	if err := h.svc.Delete(id); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).Delete(id, actorOf(c, claims)); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"deleted": true}))
@@ -105,6 +123,11 @@ func (h *UserHandler) Delete(c echo.Context) error {
 
 // ResetPassword handles POST /users/:id/reset-password
 func (h *UserHandler) ResetPassword(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
 	id, err := pathUint(c, "id")
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
@@ -115,8 +138,39 @@ func (h *UserHandler) ResetPassword(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidJSON, "invalid json"))
 	}
 
-	if err := h.svc.ResetPassword(id, req.Password); err != nil {
+	if err := h.svc.WithScope(scopeOf(c)).ResetPassword(id, req.Password, actorOf(c, claims)); err != nil {
 		return HandleError(c, err)
 	}
 	return c.JSON(http.StatusOK, OK(map[string]any{"updated": true}))
 }
+
+// Unlock handles POST /users/:id/unlock
+func (h *UserHandler) Unlock(c echo.Context) error {
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	if err := h.svc.WithScope(scopeOf(c)).Unlock(id); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"unlocked": true}))
+}
+
+// ResetMFA handles POST /users/:id/reset-mfa
+func (h *UserHandler) ResetMFA(c echo.Context) error {
+	claims := middleware.GetClaims(c)
+	if claims == nil {
+		return c.JSON(http.StatusUnauthorized, Err(pkg.ErrCodeMissingClaims, "missing claims"))
+	}
+
+	id, err := pathUint(c, "id")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err(pkg.ErrCodeInvalidID, "invalid id"))
+	}
+
+	if err := h.svc.WithScope(scopeOf(c)).ResetMFA(id, actorOf(c, claims)); err != nil {
+		return HandleError(c, err)
+	}
+	return c.JSON(http.StatusOK, OK(map[string]any{"reset": true}))
+}
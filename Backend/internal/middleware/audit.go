@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+// auditBodyLimit caps how much of a response is kept to read its code, message and data.id
+const auditBodyLimit = 64 << 10
+
+// AuditRecorder stores the audit log of write requests
+type AuditRecorder interface {
+	// Snapshot returns the current state of an entity, or nil when it is not tracked or absent
+	Snapshot(entityType, entityID string) any
+	// Record stores an entry together with the fields changed between before and after
+	Record(entry *model.AuditLog, before, after any)
+}
+
+// Audit records every POST/PUT/PATCH/DELETE request of the routes below prefix.
+// The route names the entity: /students/:id/graduate targets "students" with the value
+// of its first parameter as ID and "graduate" as action; plain routes take create, update
+// or delete from the method. A create takes its ID from the data.id of the response.
+// The entity is snapshotted before and after the handler to record what changed.
+func Audit(rec AuditRecorder, prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method := c.Request().Method
+			route := c.Path()
+			switch method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				return next(c)
+			}
+			if strings.Contains(route, "*") {
+				return next(c)
+			}
+
+			entityType, entityID, action := auditTarget(c, strings.TrimPrefix(route, prefix))
+			var before any
+			if entityID != "" {
+				before = rec.Snapshot(entityType, entityID)
+			}
+
+			w := &auditWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = w
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+			c.Response().Writer = w.ResponseWriter
+
+			var body struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+				Data    struct {
+					ID *uint `json:"id"`
+				} `json:"data"`
+			}
+			_ = json.Unmarshal(w.buf.Bytes(), &body)
+
+			entry := &model.AuditLog{
+				Method:     method,
+				Route:      route,
+				EntityType: entityType,
+				EntityID:   entityID,
+				Action:     action,
+				Outcome:    model.AuditSuccess,
+				Status:     c.Response().Status,
+				RequestID:  c.Response().Header().Get(echo.HeaderXRequestID),
+				RemoteIP:   c.RealIP(),
+			}
+			if claims := GetClaims(c); claims != nil {
+				entry.UserID = claims.UserID
+				entry.Role = claims.Role
+			}
+
+			var after any
+			if entry.Status >= http.StatusBadRequest {
+				entry.Outcome = model.AuditFailure
+				entry.ErrorCode = body.Code
+				entry.Message = body.Message
+				after = before
+			} else {
+				if entry.EntityID == "" && method == http.MethodPost && body.Data.ID != nil {
+					entry.EntityID = strconv.FormatUint(uint64(*body.Data.ID), 10)
+				}
+				if entry.EntityID != "" {
+					after = rec.Snapshot(entityType, entry.EntityID)
+				}
+			}
+			rec.Record(entry, before, after)
+			return nil
+		}
+	}
+}
+
+// auditTarget splits a route below the API prefix into entity type, entity ID and action
+func auditTarget(c echo.Context, route string) (entityType, entityID, action string) {
+	segments := strings.Split(strings.Trim(route, "/"), "/")
+	entityType = segments[0]
+	var rest []string
+	for _, seg := range segments[1:] {
+		if !strings.HasPrefix(seg, ":") {
+			rest = append(rest, seg)
+			continue
+		}
+		value := c.Param(seg[1:])
+		if entityID == "" {
+			entityID = value
+		} else {
+			rest = append(rest, value)
+		}
+	}
+	if len(rest) > 0 {
+		return entityType, entityID, strings.Join(rest, "/")
+	}
+
+	switch c.Request().Method {
+	case http.MethodPost:
+		action = "create"
+	case http.MethodDelete:
+		action = "delete"
+	default:
+		action = "update"
+	}
+	return entityType, entityID, action
+}
+
+// auditWriter passes a response through while keeping its first bytes
+type auditWriter struct {
+	http.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if room := auditBodyLimit - w.buf.Len(); room > 0 {
+		w.buf.Write(b[:min(len(b), room)])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
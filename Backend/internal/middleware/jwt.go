@@ -12,13 +12,29 @@ import (
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID             uint   `json:"user_id"`
+	Role               string `json:"role"`
+	SessionID          uint   `json:"sid"`
+	DeptID             uint   `json:"dept,omitempty"` // department a scoped user is limited to, 0 for all
+	MustChangePassword bool   `json:"mcp,omitempty"`
+	MFASetup           bool   `json:"mfa_setup,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// SessionValidator reports whether the login session of an access token is still active,
+// so that tokens of revoked sessions or deleted users are rejected before they expire
+type SessionValidator interface {
+	SessionActive(sessionID, userID uint) bool
+}
+
 const CtxClaimsKey = "auth_claims"
 
+// ChangePasswordPath is the only route that accepts tokens of users who must change their password
+const ChangePasswordPath = "/auth/change-password"
+
+// MFAPath prefixes the routes that accept tokens of users who must enroll two-factor authentication
+const MFAPath = "/auth/mfa"
+
 type apiResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -28,7 +44,7 @@ func jsonErr(c echo.Context, httpStatus int, code int, message string) error {
 	return c.JSON(httpStatus, apiResponse{Code: code, Message: message})
 }
 
-func JWT(cfg config.Config) echo.MiddlewareFunc {
+func JWT(cfg config.Config, sessions SessionValidator) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			h := c.Request().Header.Get("Authorization")
@@ -52,9 +68,18 @@ func JWT(cfg config.Config) echo.MiddlewareFunc {
 			}
 
 			claims, ok := token.Claims.(*Claims)
-			if !ok || claims == nil {
+			if !ok || claims == nil || claims.SessionID == 0 {
 				return jsonErr(c, http.StatusUnauthorized, 40105, "invalid claims")
 			}
+			if !sessions.SessionActive(claims.SessionID, claims.UserID) {
+				return jsonErr(c, http.StatusUnauthorized, 40107, "session revoked")
+			}
+			if claims.MustChangePassword && c.Path() != ChangePasswordPath {
+				return jsonErr(c, http.StatusForbidden, 40303, "password change required")
+			}
+			if claims.MFASetup && c.Path() != MFAPath && !strings.HasPrefix(c.Path(), MFAPath+"/") {
+				return jsonErr(c, http.StatusForbidden, 40304, "two-factor enrollment required")
+			}
 
 			c.Set(CtxClaimsKey, claims)
 			return next(c)
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Authorizer decides whether a role holds a permission
+type Authorizer interface {
+	Can(role, perm string) bool
+}
+
+// RequirePermission lets a request through when the caller's role holds any of perms
+func RequirePermission(az Authorizer, perms ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := GetClaims(c)
+			if claims == nil {
+				return jsonErr(c, http.StatusUnauthorized, 40106, "missing claims")
+			}
+			for _, perm := range perms {
+				if az.Can(claims.Role, perm) {
+					return next(c)
+				}
+			}
+			return jsonErr(c, http.StatusForbidden, 40301, "forbidden")
+		}
+	}
+}
+
+// RequireAccess needs read for GET/HEAD/OPTIONS requests and write for the others
+func RequireAccess(az Authorizer, read, write string) echo.MiddlewareFunc {
+	readMW := RequirePermission(az, read)
+	writeMW := RequirePermission(az, write)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		readNext, writeNext := readMW(next), writeMW(next)
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return readNext(c)
+			default:
+				return writeNext(c)
+			}
+		}
+	}
+}
+
+// RequireAllDepartments rejects department-scoped callers, for records that belong to no
+// single department such as roles and the audit log
+func RequireAllDepartments() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := GetClaims(c)
+			if claims == nil {
+				return jsonErr(c, http.StatusUnauthorized, 40106, "missing claims")
+			}
+			if claims.DeptID != 0 {
+				return jsonErr(c, http.StatusForbidden, 40305, "not available to department-scoped accounts")
+			}
+			return next(c)
+		}
+	}
+}
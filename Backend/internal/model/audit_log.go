@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// Audit log outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditChange is the old and new value of one field of an audited entity
+type AuditChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// AuditLog records one write request of the API: who made it, which entity it targeted,
+// the fields it changed and how it ended. Entries are purged after the retention period.
+type AuditLog struct {
+	ID         uint                   `gorm:"primaryKey" json:"id"`
+	UserID     uint                   `gorm:"not null;index" json:"user_id"`
+	Role       string                 `gorm:"not null" json:"role"`
+	Method     string                 `gorm:"not null" json:"method"`
+	Route      string                 `gorm:"not null" json:"route"`
+	EntityType string                 `gorm:"not null" json:"entity_type"`
+	EntityID   string                 `gorm:"not null;default:''" json:"entity_id"`
+	Action     string                 `gorm:"not null" json:"action"`
+	Changes    map[string]AuditChange `gorm:"type:jsonb;serializer:json" json:"changes,omitempty"`
+	Outcome    string                 `gorm:"not null" json:"outcome"`
+	Status     int                    `gorm:"not null" json:"status"`
+	ErrorCode  int                    `gorm:"not null;default:0" json:"error_code,omitempty"`
+	Message    string                 `gorm:"not null;default:''" json:"message,omitempty"`
+	RequestID  string                 `gorm:"not null;default:''" json:"request_id"`
+	RemoteIP   string                 `gorm:"not null;default:''" json:"remote_ip"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// Session revocation reasons
+const (
+	SessionLogout          = "logout"
+	SessionReuse           = "refresh_reuse"
+	SessionPasswordReset   = "password_reset"
+	SessionPasswordChanged = "password_changed"
+	SessionRoleChanged     = "role_changed"
+	SessionScopeChanged    = "scope_changed"
+)
+
+// AuthSession is one login of a user. Its access tokens carry the session ID, so that
+// revoking the session rejects them before they expire.
+type AuthSession struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	UserAgent    string     `gorm:"not null;default:''" json:"user_agent"`
+	IP           string     `gorm:"not null;default:''" json:"ip"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `gorm:"not null;default:''" json:"revoke_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// RefreshToken is a single-use token of a session, stored as its SHA-256 hash.
+// Each refresh marks the token used and issues the next one.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	SessionID uint       `gorm:"not null;index" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"-"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"-"`
+}
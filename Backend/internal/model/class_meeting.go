@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// ClassMeeting is one structured weekly slot of a course offering, e.g. 周一 1-2节 (1-16周)
+type ClassMeeting struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OfferingID  uint      `gorm:"not null;index" json:"offering_id"`
+	Weekday     int       `gorm:"not null" json:"weekday"`
+	StartPeriod int       `gorm:"not null" json:"start_period"`
+	EndPeriod   int       `gorm:"not null" json:"end_period"`
+	StartWeek   int       `gorm:"not null;default:1" json:"start_week"`
+	EndWeek     int       `gorm:"not null;default:16" json:"end_week"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Overlaps reports whether two meetings share a weekday, a period and a teaching week
+func (m ClassMeeting) Overlaps(o ClassMeeting) bool {
+	return m.Weekday == o.Weekday &&
+		m.StartPeriod <= o.EndPeriod && o.StartPeriod <= m.EndPeriod &&
+		m.StartWeek <= o.EndWeek && o.StartWeek <= m.EndWeek
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Grade statuses of an offering. Teachers edit drafts and submit them; an admin publishes
+// submitted grades, which students then see, and locks them at the end of the term.
+const (
+	GradeStatusDraft     = "draft"
+	GradeStatusSubmitted = "submitted"
+	GradeStatusPublished = "published"
+	GradeStatusLocked    = "locked"
+)
+
+// CourseOffering is one section of a catalog course taught in a given term
+type CourseOffering struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CourseID      uint      `gorm:"not null;index" json:"course_id"`
+	TermID        uint      `gorm:"not null;index" json:"term_id"`
+	SectionNo     string    `gorm:"not null;default:'01'" json:"section_no"`
+	TeacherID     uint      `gorm:"not null;index" json:"teacher_id"`
+	ClassTime     string    `gorm:"not null;default:''" json:"class_time"`
+	ClassLocation string    `gorm:"not null;default:''" json:"class_location"`
+	ExamTime      string    `gorm:"not null;default:''" json:"exam_time"`
+	Capacity      int       `gorm:"not null;default:0" json:"capacity"` // seats, 0 = unlimited
+	GradeStatus   string    `gorm:"not null;default:'draft'" json:"grade_status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Meetings is the structured form of ClassTime, stored in class_meetings
+	Meetings []ClassMeeting `gorm:"-" json:"meetings"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// CoursePrerequisite is one edge of the prerequisite graph.
+// Rows sharing a GroupNo are alternatives (OR); distinct groups must all be met (AND).
+type CoursePrerequisite struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CourseID       uint      `gorm:"not null;index" json:"course_id"`
+	GroupNo        int       `gorm:"not null" json:"group_no"`
+	PrereqCourseID uint      `gorm:"not null;index" json:"prereq_course_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PrerequisiteOverride lets a student enroll in a course without meeting its prerequisites
+type PrerequisiteOverride struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	StudentID uint      `gorm:"not null;index" json:"student_id"`
+	CourseID  uint      `gorm:"not null;index" json:"course_id"`
+	Reason    string    `gorm:"not null;default:''" json:"reason"`
+	CreatedBy uint      `gorm:"not null" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Document types that can be issued and verified
+const (
+	DocTypeTranscript            = "transcript"
+	DocTypeEnrollmentCertificate = "enrollment_certificate"
+)
+
+// DocumentIssuance records one issued document. Payload holds the exact JSON bytes
+// that Signature (Ed25519, base64) was computed over.
+type DocumentIssuance struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Code         string     `gorm:"uniqueIndex;not null" json:"code"`
+	DocType      string     `gorm:"not null" json:"doc_type"`
+	StudentID    uint       `gorm:"not null;index" json:"student_id"`
+	Payload      string     `gorm:"not null" json:"-"`
+	Signature    string     `gorm:"not null" json:"-"`
+	KeyID        string     `gorm:"not null" json:"key_id"`
+	IssuedBy     uint       `gorm:"not null" json:"issued_by"`
+	IssuedAt     time.Time  `json:"issued_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `gorm:"not null;default:''" json:"revoke_reason,omitempty"`
+}
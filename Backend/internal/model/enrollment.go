@@ -3,10 +3,8 @@ package model
 import "time"
 
 type Enrollment struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	StudentID uint      `gorm:"not null;index" json:"student_id"`
-	CourseID  uint      `gorm:"not null;index" json:"course_id"`
-	TermID    uint      `gorm:"not null;index" json:"term_id"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	StudentID  uint      `gorm:"not null;index" json:"student_id"`
+	OfferingID uint      `gorm:"not null;index" json:"offering_id"`
+	CreatedAt  time.Time `json:"created_at"`
 }
-
@@ -2,13 +2,15 @@ package model
 
 import "time"
 
+// Grade is a student's result in one course offering.
+// FinalOverridden marks a final score entered by hand instead of computed from the grading scheme.
 type Grade struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	StudentID  uint      `gorm:"not null;index" json:"student_id"`
-	CourseID   uint      `gorm:"not null;index" json:"course_id"`
-	UsualScore *float64  `json:"usual_score,omitempty"`
-	ExamScore  *float64  `json:"exam_score,omitempty"`
-	FinalScore *float64  `json:"final_score,omitempty"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	StudentID       uint      `gorm:"not null;index" json:"student_id"`
+	OfferingID      uint      `gorm:"not null;index" json:"offering_id"`
+	UsualScore      *float64  `json:"usual_score,omitempty"`
+	ExamScore       *float64  `json:"exam_score,omitempty"`
+	FinalScore      *float64  `json:"final_score,omitempty"`
+	FinalOverridden bool      `gorm:"not null;default:false" json:"final_overridden"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
-
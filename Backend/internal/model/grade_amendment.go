@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Amendment request statuses
+const (
+	AmendmentPending  = "pending"
+	AmendmentApproved = "approved"
+	AmendmentRejected = "rejected"
+)
+
+// GradeAmendment asks to change one student's grade after the offering was published.
+// Components and FinalScore/OverrideFinal are applied like a grade entry once approved.
+type GradeAmendment struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	OfferingID    uint                `gorm:"not null;index" json:"offering_id"`
+	StudentID     uint                `gorm:"not null" json:"student_id"`
+	Components    map[string]*float64 `gorm:"type:jsonb;serializer:json;not null" json:"components"`
+	FinalScore    *float64            `json:"final_score"`
+	OverrideFinal bool                `gorm:"not null;default:false" json:"override_final"`
+	Reason        string              `gorm:"not null" json:"reason"`
+	Status        string              `gorm:"not null;default:'pending'" json:"status"`
+	RequestedBy   uint                `gorm:"not null" json:"requested_by"`
+	ReviewedBy    *uint               `json:"reviewed_by,omitempty"`
+	ReviewNote    string              `gorm:"not null;default:''" json:"review_note,omitempty"`
+	ReviewedAt    *time.Time          `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+// GradeStatusLog records one transition of an offering's grade status
+type GradeStatusLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	OfferingID uint      `gorm:"not null;index" json:"offering_id"`
+	FromStatus string    `gorm:"not null" json:"from_status"`
+	ToStatus   string    `gorm:"not null" json:"to_status"`
+	UserID     uint      `gorm:"not null" json:"user_id"`
+	Note       string    `gorm:"not null;default:''" json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Grade audit actions
+const (
+	GradeAuditInsert = "insert"
+	GradeAuditUpdate = "update"
+	GradeAuditDelete = "delete"
+)
+
+// GradeSnapshot is the state of a grade as recorded in the audit trail
+type GradeSnapshot struct {
+	UsualScore      *float64            `json:"usual_score"`
+	ExamScore       *float64            `json:"exam_score"`
+	FinalScore      *float64            `json:"final_score"`
+	FinalOverridden bool                `json:"final_overridden"`
+	Components      map[string]*float64 `json:"components"`
+}
+
+// GradeAudit is an append-only record of one grade change.
+// OldValue is nil for inserts and NewValue is nil for deletes.
+type GradeAudit struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	GradeID    uint           `gorm:"not null;index" json:"grade_id"`
+	StudentID  uint           `gorm:"not null" json:"student_id"`
+	OfferingID uint           `gorm:"not null;index" json:"offering_id"`
+	Action     string         `gorm:"not null" json:"action"`
+	OldValue   *GradeSnapshot `gorm:"type:jsonb;serializer:json" json:"old_value"`
+	NewValue   *GradeSnapshot `gorm:"type:jsonb;serializer:json" json:"new_value"`
+	UserID     uint           `gorm:"not null" json:"user_id"`
+	Role       string         `gorm:"not null" json:"role"`
+	RequestID  string         `gorm:"not null;default:''" json:"request_id"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
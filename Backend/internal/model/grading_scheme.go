@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// GradingScheme defines how a course's final score is computed from weighted components
+type GradingScheme struct {
+	CourseID   uint               `gorm:"primaryKey;autoIncrement:false" json:"course_id"`
+	Rounding   string             `gorm:"not null;default:'half_up'" json:"rounding"`
+	Decimals   int                `gorm:"not null;default:0" json:"decimals"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	Components []GradingComponent `gorm:"-" json:"components"`
+}
+
+// GradingComponent is one weighted part of a grading scheme, e.g. usual 30 / exam 70.
+// Weights are percentages and sum to 100 within a scheme.
+type GradingComponent struct {
+	ID        uint    `gorm:"primaryKey" json:"-"`
+	CourseID  uint    `gorm:"not null;index" json:"-"`
+	Code      string  `gorm:"not null" json:"code"`
+	Name      string  `gorm:"not null;default:''" json:"name"`
+	Weight    float64 `gorm:"not null" json:"weight"`
+	SortOrder int     `gorm:"not null;default:0" json:"sort_order"`
+}
+
+// GradeComponentScore is a student's score for one component of a grade
+type GradeComponentScore struct {
+	ID      uint     `gorm:"primaryKey" json:"-"`
+	GradeID uint     `gorm:"not null;index" json:"-"`
+	Code    string   `gorm:"not null" json:"code"`
+	Score   *float64 `json:"score"`
+}
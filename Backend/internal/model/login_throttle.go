@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// LoginThrottle tracks recent failed logins of one key, "user:<username>" or "ip:<address>".
+// BlockedUntil delays the next attempt; Locked marks a lockout after too many failures
+// rather than a backoff between attempts.
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty"`
+	Locked        bool       `gorm:"not null;default:false" json:"locked"`
+}
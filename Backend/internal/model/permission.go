@@ -0,0 +1,94 @@
+package model
+
+// Permissions are named resource:action, optionally narrowed by a scope such as
+// :self (the caller's own records) or :own_course (offerings the caller teaches, or
+// for reads those of the caller's department when TEACHER_READ_SCOPE is department).
+// A role holds a bundle of them; see Role.
+const (
+	PermDeptRead                = "dept:read"
+	PermDeptWrite               = "dept:write"
+	PermStudentRead             = "student:read"
+	PermStudentReadSelf         = "student:read:self"
+	PermStudentReadOwnCourse    = "student:read:own_course"
+	PermStudentWrite            = "student:write"
+	PermStudentArchive          = "student:archive"
+	PermStaffRead               = "staff:read"
+	PermStaffWrite              = "staff:write"
+	PermCourseRead              = "course:read"
+	PermCourseWrite             = "course:write"
+	PermTermRead                = "term:read"
+	PermTermWrite               = "term:write"
+	PermEnrollmentRead          = "enrollment:read"
+	PermEnrollmentReadSelf      = "enrollment:read:self"
+	PermEnrollmentReadOwnCourse = "enrollment:read:own_course"
+	PermEnrollmentWrite         = "enrollment:write"
+	PermEnrollmentWriteSelf     = "enrollment:write:self"
+	PermPrereqOverride          = "prerequisite:override"
+	PermGradeRead               = "grade:read"
+	PermGradeReadSelf           = "grade:read:self"
+	PermGradeReadOwnCourse      = "grade:read:own_course"
+	PermGradeWrite              = "grade:write"
+	PermGradeWriteOwnCourse     = "grade:write:own_course"
+	PermGradeApprove            = "grade:approve"
+	PermTranscriptIssue         = "transcript:issue"
+	PermReportRead              = "report:read"
+	PermReportReadOwnCourse     = "report:read:own_course"
+	PermUserManage              = "user:manage"
+	PermRoleManage              = "role:manage"
+	PermDataImport              = "data:import"
+	PermAuditRead               = "audit:read"
+	PermAuditPurge              = "audit:purge"
+)
+
+// PermissionInfo describes a permission for the role editor
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions lists every permission a role can be granted
+var Permissions = []PermissionInfo{
+	{PermDeptRead, "view departments"},
+	{PermDeptWrite, "create, update and delete departments"},
+	{PermStudentRead, "view students"},
+	{PermStudentReadSelf, "view the own student record"},
+	{PermStudentReadOwnCourse, "view students enrolled in offerings one teaches"},
+	{PermStudentWrite, "create, update and delete students"},
+	{PermStudentArchive, "graduate and transfer students"},
+	{PermStaffRead, "view staff"},
+	{PermStaffWrite, "create, update and delete staff"},
+	{PermCourseRead, "view courses, offerings, prerequisites and grading schemes"},
+	{PermCourseWrite, "maintain courses, offerings, prerequisites and grading schemes"},
+	{PermTermRead, "view terms"},
+	{PermTermWrite, "create terms"},
+	{PermEnrollmentRead, "view enrollments and waitlists of any student"},
+	{PermEnrollmentReadSelf, "view the own enrollments and waitlist positions"},
+	{PermEnrollmentReadOwnCourse, "view enrollments and waitlists of offerings one teaches"},
+	{PermEnrollmentWrite, "enroll and drop any student"},
+	{PermEnrollmentWriteSelf, "enroll and drop oneself"},
+	{PermPrereqOverride, "grant prerequisite overrides"},
+	{PermGradeRead, "query grades and GPA summaries of any student"},
+	{PermGradeReadSelf, "view the own grades, GPA, transcript and enrollment certificate"},
+	{PermGradeReadOwnCourse, "query grades of offerings one teaches and GPA summaries of their students"},
+	{PermGradeWrite, "enter and correct grades of any offering"},
+	{PermGradeWriteOwnCourse, "enter grades and request amendments for offerings one teaches"},
+	{PermGradeApprove, "approve, return and lock grades and review amendments"},
+	{PermTranscriptIssue, "issue transcripts and certificates of any student and revoke them"},
+	{PermReportRead, "view grade rosters and reports"},
+	{PermReportReadOwnCourse, "view grade rosters and reports of offerings one teaches"},
+	{PermUserManage, "manage user accounts"},
+	{PermRoleManage, "manage roles and their permissions"},
+	{PermDataImport, "bulk import records"},
+	{PermAuditRead, "view the audit log"},
+	{PermAuditPurge, "purge the audit log"},
+}
+
+// IsPermission reports whether name is a known permission
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
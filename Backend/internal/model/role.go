@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Built-in roles, seeded by the migrations
+const (
+	RoleAdmin   = "admin"
+	RoleTeacher = "teacher"
+	RoleStudent = "student"
+)
+
+// Role is a named bundle of permissions. Users refer to it by Name, which is also
+// carried in their access tokens, so it cannot change once created.
+type Role struct {
+	ID    uint   `gorm:"primaryKey" json:"id"`
+	Name  string `gorm:"uniqueIndex;size:16;not null" json:"name"`
+	Label string `gorm:"not null;default:''" json:"label"`
+	// Builtin roles cannot be deleted
+	Builtin     bool      `gorm:"not null;default:false" json:"builtin"`
+	Permissions []string  `gorm:"type:jsonb;serializer:json;not null" json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -15,3 +15,7 @@ type StudentHistory struct {
 	ArchiveReason string     `gorm:"not null" json:"archive_reason"`
 }
 
+// TableName overrides the table name used by GORM
+func (StudentHistory) TableName() string {
+	return "students_history"
+}
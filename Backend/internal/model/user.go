@@ -3,13 +3,17 @@ package model
 import "time"
 
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Username     string    `gorm:"uniqueIndex;size:64;not null" json:"username"`
-	PasswordHash string    `gorm:"not null" json:"-"`
-	Role         string    `gorm:"size:16;not null" json:"role"`
-	StudentID    *uint     `json:"student_id,omitempty"`
-	StaffID      *uint     `json:"staff_id,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Username     string `gorm:"uniqueIndex;size:64;not null" json:"username"`
+	PasswordHash string `gorm:"not null" json:"-"`
+	Role         string `gorm:"size:16;not null" json:"role"`
+	StudentID    *uint  `json:"student_id,omitempty"`
+	StaffID      *uint  `json:"staff_id,omitempty"`
+	// DeptID limits the user to the records of one department; nil means all departments
+	DeptID *uint `json:"dept_id,omitempty"`
+	// MustChangePassword limits the user's access tokens to changing the password
+	MustChangePassword bool      `gorm:"not null;default:false" json:"must_change_password"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
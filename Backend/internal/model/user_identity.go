@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// External identity providers
+const (
+	IdentityOIDC = "oidc"
+	IdentityLDAP = "ldap"
+)
+
+// UserIdentity links a user to an identity at an external provider, so that signing in
+// there logs in as the user. Subject is the provider's stable ID of the identity.
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"not null;uniqueIndex:idx_user_identities_subject" json:"provider"`
+	Subject     string     `gorm:"not null;uniqueIndex:idx_user_identities_subject" json:"subject"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// UserMFA is a user's TOTP enrollment. It is pending until the first code is verified.
+type UserMFA struct {
+	UserID uint `gorm:"primaryKey" json:"user_id"`
+	// Secret is the TOTP secret sealed with AES-GCM, see MFA_SECRET_KEY
+	Secret    string     `gorm:"not null" json:"-"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	// LastStep is the time step of the last accepted code, so that no code is used twice
+	LastStep  int64     `gorm:"not null;default:0" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName overrides the table name used by GORM
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code, stored as its SHA-256 hash
+type MFARecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"-"`
+}
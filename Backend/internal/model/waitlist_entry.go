@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// WaitlistEntry queues a student for a full course offering; order is by CreatedAt then ID
+type WaitlistEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	StudentID  uint      `gorm:"not null;index" json:"student_id"`
+	OfferingID uint      `gorm:"not null;index" json:"offering_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -4,9 +4,10 @@ import "fmt"
 
 // AppError represents an application-level error with code and message
 type AppError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Err     error  `json:"-"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	Err     error       `json:"-"`
 }
 
 func (e *AppError) Error() string {
@@ -25,6 +26,11 @@ func NewAppError(code int, message string) *AppError {
 	return &AppError{Code: code, Message: message}
 }
 
+// NewAppErrorWithDetails creates a new AppError carrying structured details for the client
+func NewAppErrorWithDetails(code int, message string, details interface{}) *AppError {
+	return &AppError{Code: code, Message: message, Details: details}
+}
+
 // WrapError wraps an error with an AppError
 func WrapError(code int, message string, err error) *AppError {
 	return &AppError{Code: code, Message: message, Err: err}
@@ -44,6 +50,14 @@ const (
 	ErrCodeStaffHasCourses  = 40030
 	ErrCodeCourseHasEnroll  = 40040
 	ErrCodeCourseHasGrades  = 40041
+	ErrCodeInvalidClassTime = 40042
+	ErrCodeInvalidCapacity  = 40043
+	ErrCodePrereqCycle      = 40044
+	ErrCodeInvalidPrereq    = 40045
+	ErrCodeOverrideExists   = 40046
+	ErrCodeCourseOffered    = 40047
+	ErrCodeOfferingExists   = 40048
+	ErrCodeInvalidScheme    = 40049
 	ErrCodeArchiveFailed    = 40050
 	ErrCodeTermNotFound     = 40060
 	ErrCodeCourseNotFound   = 40061
@@ -52,9 +66,37 @@ const (
 	ErrCodeCreditExceeded   = 40064
 	ErrCodeEnrollFailed     = 40065
 	ErrCodeEnrollDelFailed  = 40066
+	ErrCodeScheduleConflict = 40067
+	ErrCodeOnWaitlist       = 40068
+	ErrCodePrereqUnmet      = 40069
 	ErrCodeGradeCourseNF    = 40070
 	ErrCodeGradeStudentNF   = 40071
 	ErrCodeGradeUpsertFail  = 40072
+	ErrCodeGradeNotEnrolled = 40073
+	ErrCodeInvalidScore     = 40074
+	ErrCodeManualFinal      = 40075
+	ErrCodeInvalidGPAScale  = 40076
+	ErrCodeGradeLocked      = 40077
+	ErrCodeGradeStatus      = 40078
+	ErrCodeAmendmentExists  = 40079
+	ErrCodeOfferingNF       = 40080
+	ErrCodeSectionRequired  = 40081
+	ErrCodeMixedTerms       = 40082
+	ErrCodeInvalidRole      = 40083
+	ErrCodeInvalidPerm      = 40084
+	ErrCodeRoleInUse        = 40085
+	ErrCodeUsernameTaken    = 40086
+	ErrCodeSSODisabled      = 40087
+	ErrCodeAlreadyRevoked   = 40090
+	ErrCodeImportFormat     = 40091
+	ErrCodeImportInvalid    = 40092
+	ErrCodeExportFormat     = 40093
+	ErrCodeAmendmentClosed  = 40094
+	ErrCodeInvalidTimeRange = 40095
+	ErrCodeWeakPassword     = 40096
+	ErrCodeWrongPassword    = 40097
+	ErrCodeInvalidMFACode   = 40098
+	ErrCodeMFAState         = 40099
 
 	// 401xx - Authentication errors
 	ErrCodeMissingAuthHeader   = 40101
@@ -63,19 +105,35 @@ const (
 	ErrCodeInvalidToken        = 40104
 	ErrCodeInvalidClaims       = 40105
 	ErrCodeMissingClaims       = 40106
+	ErrCodeSessionRevoked      = 40107
 	ErrCodeInvalidCredentials  = 40110
 	ErrCodeUserNotFound        = 40111
+	ErrCodeInvalidRefresh      = 40112
+	ErrCodeRefreshReused       = 40113
+	ErrCodeInvalidMFAToken     = 40114
+	ErrCodeInvalidSSOState     = 40115
+	ErrCodeSSOFailed           = 40116
 
 	// 403xx - Forbidden errors
 	ErrCodeForbidden       = 40301
 	ErrCodeStudentNotBound = 40302
+	ErrCodePasswordChange  = 40303
+	ErrCodeMFASetup        = 40304
+	ErrCodeOutOfScope      = 40305
+	ErrCodeNoAccount       = 40306
 
 	// 404xx - Not Found errors
 	ErrCodeNotFound = 40401
 
+	// 429xx - Too Many Requests errors
+	ErrCodeTooManyAttempts = 42901
+
 	// 500xx - Internal errors
-	ErrCodeDBError     = 50010
-	ErrCodeSignToken   = 50001
+	ErrCodeDBError      = 50010
+	ErrCodeRenderFailed = 50020
+	ErrCodeIssueFailed  = 50030
+	ErrCodeIdPError     = 50040
+	ErrCodeSignToken    = 50001
 )
 
 // Predefined errors
@@ -0,0 +1,210 @@
+// Package export writes tabular data as CSV or XLSX, one row at a time.
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Supported formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Content types of the supported formats
+const (
+	ContentTypeCSV  = "text/csv; charset=utf-8"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// csvFlushRows is how often buffered CSV rows are pushed to the client
+const csvFlushRows = 500
+
+// Writer receives tables row by row
+type Writer interface {
+	// Sheet starts a table: a new worksheet in XLSX, a blank line and a header row in CSV
+	Sheet(name string, header []string) error
+	// Row appends a row; nil and nil pointers become empty cells
+	Row(values ...any) error
+	// Close finishes the file. XLSX output is only written here.
+	Close() error
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return ContentTypeXLSX
+	}
+	return ContentTypeCSV
+}
+
+// NewWriter creates a Writer of the given format on out
+func NewWriter(format string, out io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{out: out, w: csv.NewWriter(out)}, nil
+	case FormatXLSX:
+		return &xlsxWriter{out: out, f: excelize.NewFile()}, nil
+	}
+	return nil, errors.New("unsupported export format: " + format)
+}
+
+type csvWriter struct {
+	out    io.Writer
+	w      *csv.Writer
+	sheets int
+	rows   int
+}
+
+func (c *csvWriter) Sheet(_ string, header []string) error {
+	if c.sheets == 0 {
+		// UTF-8 BOM so that Excel opens Chinese text correctly
+		header = append([]string{"\ufeff" + header[0]}, header[1:]...)
+	} else if err := c.w.Write(nil); err != nil {
+		return err
+	}
+	c.sheets++
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) Row(values ...any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvCell(v)
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%csvFlushRows == 0 {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	return c.flush()
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return err
+	}
+	if f, ok := c.out.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func csvCell(v any) string {
+	switch x := cellValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+type xlsxWriter struct {
+	out    io.Writer
+	f      *excelize.File
+	sw     *excelize.StreamWriter
+	bold   int
+	sheets int
+	row    int
+}
+
+func (x *xlsxWriter) Sheet(name string, header []string) error {
+	if x.sw != nil {
+		if err := x.sw.Flush(); err != nil {
+			return err
+		}
+	}
+	if x.sheets == 0 {
+		if err := x.f.SetSheetName(x.f.GetSheetName(0), name); err != nil {
+			return err
+		}
+		style, err := x.f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+		if err != nil {
+			return err
+		}
+		x.bold = style
+	} else if _, err := x.f.NewSheet(name); err != nil {
+		return err
+	}
+	x.sheets++
+
+	sw, err := x.f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	x.sw = sw
+	x.row = 1
+	cells := make([]any, len(header))
+	for i, h := range header {
+		cells[i] = h
+	}
+	return sw.SetRow("A1", cells, excelize.RowOpts{StyleID: x.bold})
+}
+
+func (x *xlsxWriter) Row(values ...any) error {
+	if x.sw == nil {
+		return errors.New("export: Row called before Sheet")
+	}
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	cells := make([]any, len(values))
+	for i, v := range values {
+		cells[i] = cellValue(v)
+	}
+	return x.sw.SetRow(cell, cells)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.f.Close()
+	if x.sw != nil {
+		if err := x.sw.Flush(); err != nil {
+			return err
+		}
+	}
+	return x.f.Write(x.out)
+}
+
+// cellValue dereferences pointers and formats times, leaving other values as they are
+func cellValue(v any) any {
+	switch x := v.(type) {
+	case *float64:
+		if x == nil {
+			return nil
+		}
+		return *x
+	case *string:
+		if x == nil {
+			return nil
+		}
+		return *x
+	case *time.Time:
+		if x == nil {
+			return nil
+		}
+		return x.Format("2006-01-02")
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05")
+	}
+	return v
+}
@@ -0,0 +1,13 @@
+// Package fonts embeds the fonts used when rendering PDF documents.
+package fonts
+
+import _ "embed"
+
+// CJK is WenQuanYi Micro Hei (Apache-2.0 / GPL-3.0 with font exception), a sans-serif
+// TrueType font covering Simplified Chinese; extracted from the upstream .ttc collection.
+//
+//go:embed wqy-microhei.ttf
+var CJK []byte
+
+// CJKFamily is the family name PDF renderers register CJK under
+const CJKFamily = "wqy"
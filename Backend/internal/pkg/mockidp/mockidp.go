@@ -0,0 +1,339 @@
+// Package mockidp is an OpenID provider signing in whichever of its configured users is
+// picked on its login page. It enforces the authorization code flow with PKCE as a real
+// provider would; cmd/mockidp serves it for local use and tests run sign-ins against it.
+package mockidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyID   = "mockidp"
+	codeTTL = time.Minute
+)
+
+// User is a user to sign in as, with the claims added to their ID tokens
+type User struct {
+	Username string
+	Claims   map[string]string
+}
+
+// grant is an issued authorization code awaiting its exchange
+type grant struct {
+	user        User
+	redirectURI string
+	challenge   string
+	nonce       string
+	expiresAt   time.Time
+}
+
+// Provider is the identity provider; it serves the discovery document, its keys, the
+// login page and the token endpoint under Issuer
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty accepts the client as a public client
+	Users        []User
+	// Claims are set on every ID token over the issued ones, to issue tokens a relying
+	// party must refuse
+	Claims map[string]any
+
+	key *rsa.PrivateKey
+	mux *http.ServeMux
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// New creates a Provider with a fresh signing key
+func New(issuer, clientID, clientSecret string, users []User) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	p := &Provider{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Users:        users,
+		key:          key,
+		mux:          http.NewServeMux(),
+		grants:       make(map[string]grant),
+	}
+	p.mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("GET /jwks", p.jwks)
+	p.mux.HandleFunc("GET /authorize", p.loginPage)
+	p.mux.HandleFunc("POST /authorize", p.authorize)
+	p.mux.HandleFunc("POST /token", p.token)
+	return p, nil
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// ParseUsers reads "name:claim=value:claim=value,name2,..."
+func ParseUsers(s string) []User {
+	var users []User
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if parts[0] == "" {
+			continue
+		}
+		u := User{Username: parts[0], Claims: map[string]string{}}
+		for _, kv := range parts[1:] {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				u.Claims[k] = v
+			}
+		}
+		users = append(users, u)
+	}
+	return users
+}
+
+// SignIn does what a browser does when username is picked on the login page of
+// authURL: it returns the redirect back to the client, carrying the code and state
+func SignIn(client *http.Client, authURL, username string) (*url.URL, error) {
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login page: status %d", resp.StatusCode)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	form := u.Query()
+	form.Set("username", username)
+	u.RawQuery = ""
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err = noRedirect.PostForm(u.String(), form)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("authorize: status %d", resp.StatusCode)
+	}
+	return resp.Location()
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": keyID,
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+var loginTmpl = template.Must(template.New("login").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>Mock IdP</title></head>
+<body style="font-family:sans-serif;max-width:28rem;margin:4rem auto">
+<h2>Mock identity provider</h2>
+<p>Sign in to {{.ClientID}} as:</p>
+<form method="post" action="/authorize">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">
+{{end}}{{range .Users}}<p><button name="username" value="{{.Username}}">{{.Username}}</button>
+{{range $k, $v := .Claims}} {{$k}}={{$v}}{{end}}</p>
+{{end}}</form>
+</body></html>`))
+
+// loginPage checks the authorization request and lists the users to sign in as
+func (p *Provider) loginPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if msg := p.checkAuthRequest(q); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	params := map[string]string{}
+	for _, k := range []string{"client_id", "redirect_uri", "state", "nonce", "code_challenge"} {
+		params[k] = q.Get(k)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	loginTmpl.Execute(w, map[string]any{"ClientID": p.ClientID, "Params": params, "Users": p.Users})
+}
+
+func (p *Provider) checkAuthRequest(q url.Values) string {
+	switch {
+	case q.Get("client_id") != p.ClientID:
+		return "unknown client_id"
+	case q.Get("redirect_uri") == "":
+		return "redirect_uri required"
+	case q.Has("response_type") && q.Get("response_type") != "code":
+		return "only response_type=code is supported"
+	case q.Has("code_challenge_method") && q.Get("code_challenge_method") != "S256":
+		return "only code_challenge_method=S256 is supported"
+	case q.Get("code_challenge") == "":
+		return "code_challenge required (PKCE)"
+	}
+	return ""
+}
+
+// authorize issues a code for the picked user and redirects back to the client
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg := p.checkAuthRequest(r.PostForm); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	var picked *User
+	for i := range p.Users {
+		if p.Users[i].Username == r.PostForm.Get("username") {
+			picked = &p.Users[i]
+		}
+	}
+	if picked == nil {
+		http.Error(w, "unknown user", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.grants[code] = grant{
+		user:        *picked,
+		redirectURI: r.PostForm.Get("redirect_uri"),
+		challenge:   r.PostForm.Get("code_challenge"),
+		nonce:       r.PostForm.Get("nonce"),
+		expiresAt:   time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	back, err := url.Parse(r.PostForm.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	q := back.Query()
+	q.Set("code", code)
+	q.Set("state", r.PostForm.Get("state"))
+	back.RawQuery = q.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+// token exchanges a code, once, for an ID token when the PKCE verifier matches
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != p.ClientID ||
+		(p.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(p.ClientSecret)) != 1) {
+		tokenError(w, "invalid_client", "unknown client or wrong secret")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(g.expiresAt) || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", "unknown, used or expired code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		tokenError(w, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.Issuer,
+		"sub":                "mock-" + g.user.Username,
+		"aud":                p.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              g.nonce,
+		"preferred_username": g.user.Username,
+	}
+	for k, v := range g.user.Claims {
+		claims[k] = v
+	}
+	for k, v := range p.Claims {
+		claims[k] = v
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = keyID
+	idToken, err := t.SignedString(p.key)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code, desc string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": desc})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/pkg/mockidp"
+)
+
+const redirectURL = "http://localhost:3000/login/callback"
+
+// newTestIdP serves a mock provider for the client "edumgr" and returns it with a
+// relying party configured for it
+func newTestIdP(t *testing.T, secret string) (*mockidp.Provider, *Provider) {
+	t.Helper()
+	idp, err := mockidp.New("", "edumgr", secret, mockidp.ParseUsers("alice:student_no=2023001,bob:staff_no=T001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp)
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+	return idp, New(Config{
+		Issuer:       srv.URL,
+		ClientID:     "edumgr",
+		ClientSecret: secret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "profile"},
+	})
+}
+
+// signIn starts a sign-in, picks username at the provider and returns the code it
+// redirected back with, the verifier and the nonce
+func signIn(t *testing.T, rp *Provider, username string) (code, verifier, nonce string) {
+	t.Helper()
+	state, _ := RandomToken()
+	nonce, _ = RandomToken()
+	verifier, _ = RandomToken()
+	authURL, err := rp.AuthURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := mockidp.SignIn(rp.client, authURL, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(back.String(), redirectURL+"?") || back.Query().Get("state") != state {
+		t.Fatalf("redirect back = %s, want %s with state %s", back, redirectURL, state)
+	}
+	return back.Query().Get("code"), verifier, nonce
+}
+
+func TestExchange(t *testing.T) {
+	for _, secret := range []string{"", "s3cret/+&"} {
+		_, rp := newTestIdP(t, secret)
+		code, verifier, nonce := signIn(t, rp, "alice")
+		claims, err := rp.Exchange(context.Background(), code, verifier, nonce)
+		if err != nil {
+			t.Fatalf("secret %q: Exchange: %v", secret, err)
+		}
+		for name, want := range map[string]string{"sub": "mock-alice", "preferred_username": "alice", "student_no": "2023001"} {
+			if got := claims.String(name); got != want {
+				t.Errorf("secret %q: claim %s = %q, want %q", secret, name, got, want)
+			}
+		}
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// claims the provider sets on its ID token
+		claims map[string]any
+		// exchange changes how the code is redeemed
+		exchange func(rp *Provider, code, verifier, nonce string) (Claims, error)
+		want     string
+	}{
+		{name: "bad nonce", exchange: func(rp *Provider, code, verifier, nonce string) (Claims, error) {
+			return rp.Exchange(context.Background(), code, verifier, nonce+"x")
+		}, want: "nonce mismatch"},
+		{name: "replayed nonce", claims: map[string]any{"nonce": "from-another-sign-in"}, want: "nonce mismatch"},
+		{name: "bad audience", claims: map[string]any{"aud": "other-client"}, want: "audience"},
+		{name: "bad issuer", claims: map[string]any{"iss": "https://idp.example.com"}, want: "issuer"},
+		{name: "expired", claims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, want: "expired"},
+		{name: "no expiry", claims: map[string]any{"exp": nil}, want: "exp"},
+		{name: "bad verifier", exchange: func(rp *Provider, code, verifier, nonce string) (Claims, error) {
+			return rp.Exchange(context.Background(), code, verifier+"x", nonce)
+		}, want: "invalid_grant"},
+		{name: "unknown code", exchange: func(rp *Provider, code, verifier, nonce string) (Claims, error) {
+			return rp.Exchange(context.Background(), "forged", verifier, nonce)
+		}, want: "invalid_grant"},
+		{name: "code used twice", exchange: func(rp *Provider, code, verifier, nonce string) (Claims, error) {
+			if _, err := rp.Exchange(context.Background(), code, verifier, nonce); err != nil {
+				return nil, err
+			}
+			return rp.Exchange(context.Background(), code, verifier, nonce)
+		}, want: "invalid_grant"},
+	}
+	for _, tt := range tests {
+		idp, rp := newTestIdP(t, "")
+		idp.Claims = tt.claims
+		code, verifier, nonce := signIn(t, rp, "alice")
+		exchange := tt.exchange
+		if exchange == nil {
+			exchange = func(rp *Provider, code, verifier, nonce string) (Claims, error) {
+				return rp.Exchange(context.Background(), code, verifier, nonce)
+			}
+		}
+		claims, err := exchange(rp, code, verifier, nonce)
+		if err == nil {
+			t.Errorf("%s: Exchange = %v, want error", tt.name, claims)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Exchange error = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestExchangeWrongClientSecret(t *testing.T) {
+	idp, rp := newTestIdP(t, "right")
+	code, verifier, nonce := signIn(t, rp, "bob")
+	idp.ClientSecret = "changed"
+	if _, err := rp.Exchange(context.Background(), code, verifier, nonce); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("Exchange with a wrong client secret: error = %v, want invalid_client", err)
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp, _ := newTestIdP(t, "")
+	rp := New(Config{Issuer: idp.Issuer + "/", ClientID: "edumgr", RedirectURL: redirectURL})
+	if _, err := rp.AuthURL(context.Background(), "state", "nonce", "verifier"); err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Errorf("AuthURL with a mismatching issuer: error = %v, want issuer error", err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636 Appendix B
+	if got := Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Challenge = %s", got)
+	}
+}
@@ -0,0 +1,32 @@
+// Package passwords embeds the list of common and breached passwords the password
+// policy rejects.
+package passwords
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+//go:embed common.txt
+var commonList string
+
+var common = sync.OnceValue(func() map[string]struct{} {
+	set := make(map[string]struct{})
+	sc := bufio.NewScanner(strings.NewReader(commonList))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+})
+
+// IsCommon reports whether password is on the embedded list, ignoring case
+func IsCommon(password string) bool {
+	_, ok := common()[strings.ToLower(password)]
+	return ok
+}
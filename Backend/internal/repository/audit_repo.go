@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// AuditQueryParams represents the filters of the audit log
+type AuditQueryParams struct {
+	UserID     uint
+	Username   string
+	EntityType string
+	EntityID   string
+	Action     string
+	Method     string
+	Outcome    string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Page       int
+	PageSize   int
+}
+
+// AuditRow represents an audit log entry with the username of its actor
+type AuditRow struct {
+	model.AuditLog
+	Username string `json:"username"`
+}
+
+// AuditRepository defines data access for the audit log
+type AuditRepository interface {
+	Create(log *model.AuditLog) error
+	FindAllPaginated(params AuditQueryParams) ([]AuditRow, int64, error)
+	DeleteBefore(t time.Time) (int64, error)
+	FindEntity(entityType, entityID string) (any, error)
+}
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Create(log *model.AuditLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *auditRepo) FindAllPaginated(params AuditQueryParams) ([]AuditRow, int64, error) {
+	q := r.db.Table("audit_logs").
+		Select("audit_logs.*, COALESCE(users.username, '') AS username").
+		Joins("LEFT JOIN users ON users.id = audit_logs.user_id")
+
+	if params.UserID != 0 {
+		q = q.Where("audit_logs.user_id = ?", params.UserID)
+	}
+	if params.Username != "" {
+		q = q.Where("users.username = ?", params.Username)
+	}
+	if params.EntityType != "" {
+		q = q.Where("audit_logs.entity_type = ?", params.EntityType)
+	}
+	if params.EntityID != "" {
+		q = q.Where("audit_logs.entity_id = ?", params.EntityID)
+	}
+	if params.Action != "" {
+		q = q.Where("audit_logs.action = ?", params.Action)
+	}
+	if params.Method != "" {
+		q = q.Where("audit_logs.method = ?", params.Method)
+	}
+	if params.Outcome != "" {
+		q = q.Where("audit_logs.outcome = ?", params.Outcome)
+	}
+	if params.RequestID != "" {
+		q = q.Where("audit_logs.request_id = ?", params.RequestID)
+	}
+	if params.From != nil {
+		q = q.Where("audit_logs.created_at >= ?", *params.From)
+	}
+	if params.To != nil {
+		q = q.Where("audit_logs.created_at < ?", *params.To)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if params.Page > 0 && params.PageSize > 0 {
+		offset := (params.Page - 1) * params.PageSize
+		q = q.Offset(offset).Limit(params.PageSize)
+	}
+
+	var items []AuditRow
+	if err := q.Order("audit_logs.created_at desc, audit_logs.id desc").Scan(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// DeleteBefore purges the entries created before t
+func (r *auditRepo) DeleteBefore(t time.Time) (int64, error) {
+	res := r.db.Where("created_at < ?", t).Delete(&model.AuditLog{})
+	return res.RowsAffected, res.Error
+}
+
+// auditEntities loads the entity behind an audited route by its path parameter
+var auditEntities = map[string]func(db *gorm.DB, key string) (any, error){
+	"departments":            findByID[model.Department],
+	"students":               findByID[model.Student],
+	"staff":                  findByID[model.Staff],
+	"courses":                findByID[model.Course],
+	"offerings":              findByID[model.CourseOffering],
+	"terms":                  findByID[model.Term],
+	"enrollments":            findByID[model.Enrollment],
+	"waitlists":              findByID[model.WaitlistEntry],
+	"prerequisite-overrides": findByID[model.PrerequisiteOverride],
+	"grade-amendments":       findByID[model.GradeAmendment],
+	"users":                  findByID[model.User],
+	"roles":                  findByID[model.Role],
+	"issuances": func(db *gorm.DB, code string) (any, error) {
+		var issuance model.DocumentIssuance
+		if err := db.Where("code = ?", code).First(&issuance).Error; err != nil {
+			return nil, err
+		}
+		return &issuance, nil
+	},
+}
+
+func findByID[T any](db *gorm.DB, key string) (any, error) {
+	id, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var row T
+	if err := db.First(&row, id).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// FindEntity loads an audited entity by type and key. It returns nil without error
+// when the type is not snapshotted or the entity does not exist.
+func (r *auditRepo) FindEntity(entityType, entityID string) (any, error) {
+	find, ok := auditEntities[entityType]
+	if !ok || entityID == "" {
+		return nil, nil
+	}
+	row, err := find(r.db, entityID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return row, err
+}
@@ -5,44 +5,32 @@ import (
 	"gorm.io/gorm"
 )
 
-// CourseWithTeacher represents a course with teacher info
-type CourseWithTeacher struct {
-	model.Course
-	TeacherName string `json:"teacher_name"`
-	TeacherNo   string `json:"teacher_no"`
-}
-
-// CourseCredits represents course ID and credits
-type CourseCredits struct {
-	ID      uint
-	Credits int
-}
-
 // CourseQueryParams represents course query parameters
 type CourseQueryParams struct {
-	CourseNo    string
-	Name        string
-	TeacherName string
-	Page        int
-	PageSize    int
+	CourseNo string
+	Name     string
+	Page     int
+	PageSize int
 }
 
 // CourseRepository defines the interface for course data access
 type CourseRepository interface {
-	FindAll(courseNo, name, teacherName string) ([]CourseWithTeacher, error)
-	FindAllPaginated(params CourseQueryParams) ([]CourseWithTeacher, int64, error)
+	FindAll(courseNo, name string) ([]model.Course, error)
+	FindAllPaginated(params CourseQueryParams) ([]model.Course, int64, error)
+	Each(courseNo, name string, fn func(*model.Course) error) error
 	FindByID(id uint) (*model.Course, error)
 	FindByCourseNo(courseNo string) (*model.Course, error)
-	FindByCourseNos(courseNos []string) ([]CourseCredits, error)
 	Create(course *model.Course) error
 	Update(course *model.Course) error
 	Delete(id uint) error
-	CountEnrollments(courseID uint) (int64, error)
-	CountGrades(courseID uint) (int64, error)
+	CountOfferings(courseID uint) (int64, error)
+	WithTx(tx *gorm.DB) CourseRepository
+	WithScope(scope Scope) CourseRepository
 }
 
 type courseRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewCourseRepository creates a new CourseRepository
@@ -50,10 +38,17 @@ func NewCourseRepository(db *gorm.DB) CourseRepository {
 	return &courseRepo{db: db}
 }
 
-func (r *courseRepo) FindAll(courseNo, name, teacherName string) ([]CourseWithTeacher, error) {
-	q := r.db.Table("courses").
-		Select("courses.*, staff.name AS teacher_name, staff.staff_no AS teacher_no").
-		Joins("JOIN staff ON staff.id = courses.teacher_id")
+func (r *courseRepo) WithTx(tx *gorm.DB) CourseRepository {
+	return &courseRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the courses of the scope's department
+func (r *courseRepo) WithScope(scope Scope) CourseRepository {
+	return &courseRepo{db: r.db, scope: scope}
+}
+
+func (r *courseRepo) listQuery(courseNo, name string) *gorm.DB {
+	q := r.scope.where(r.db.Model(&model.Course{}), "courses.dept_id")
 
 	if courseNo != "" {
 		q = q.Where("courses.course_no = ?", courseNo)
@@ -61,21 +56,23 @@ func (r *courseRepo) FindAll(courseNo, name, teacherName string) ([]CourseWithTe
 	if name != "" {
 		q = q.Where("courses.name ILIKE ?", "%"+name+"%")
 	}
-	if teacherName != "" {
-		q = q.Where("staff.name ILIKE ?", "%"+teacherName+"%")
-	}
+	return q.Order("courses.course_no asc")
+}
 
-	var items []CourseWithTeacher
-	if err := q.Order("courses.course_no asc").Scan(&items).Error; err != nil {
+func (r *courseRepo) FindAll(courseNo, name string) ([]model.Course, error) {
+	var items []model.Course
+	if err := r.listQuery(courseNo, name).Find(&items).Error; err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
-func (r *courseRepo) FindAllPaginated(params CourseQueryParams) ([]CourseWithTeacher, int64, error) {
-	q := r.db.Table("courses").
-		Select("courses.*, staff.name AS teacher_name, staff.staff_no AS teacher_no").
-		Joins("JOIN staff ON staff.id = courses.teacher_id")
+func (r *courseRepo) Each(courseNo, name string, fn func(*model.Course) error) error {
+	return scanEach(r.listQuery(courseNo, name), fn)
+}
+
+func (r *courseRepo) FindAllPaginated(params CourseQueryParams) ([]model.Course, int64, error) {
+	q := r.scope.where(r.db.Model(&model.Course{}), "courses.dept_id")
 
 	if params.CourseNo != "" {
 		q = q.Where("courses.course_no = ?", params.CourseNo)
@@ -83,9 +80,6 @@ func (r *courseRepo) FindAllPaginated(params CourseQueryParams) ([]CourseWithTea
 	if params.Name != "" {
 		q = q.Where("courses.name ILIKE ?", "%"+params.Name+"%")
 	}
-	if params.TeacherName != "" {
-		q = q.Where("staff.name ILIKE ?", "%"+params.TeacherName+"%")
-	}
 
 	var total int64
 	if err := q.Count(&total).Error; err != nil {
@@ -97,8 +91,8 @@ func (r *courseRepo) FindAllPaginated(params CourseQueryParams) ([]CourseWithTea
 		q = q.Offset(offset).Limit(params.PageSize)
 	}
 
-	var items []CourseWithTeacher
-	if err := q.Order("courses.course_no asc").Scan(&items).Error; err != nil {
+	var items []model.Course
+	if err := q.Order("courses.course_no asc").Find(&items).Error; err != nil {
 		return nil, 0, err
 	}
 	return items, total, nil
@@ -106,7 +100,7 @@ func (r *courseRepo) FindAllPaginated(params CourseQueryParams) ([]CourseWithTea
 
 func (r *courseRepo) FindByID(id uint) (*model.Course, error) {
 	var course model.Course
-	if err := r.db.First(&course, id).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").First(&course, id).Error; err != nil {
 		return nil, err
 	}
 	return &course, nil
@@ -114,43 +108,43 @@ func (r *courseRepo) FindByID(id uint) (*model.Course, error) {
 
 func (r *courseRepo) FindByCourseNo(courseNo string) (*model.Course, error) {
 	var course model.Course
-	if err := r.db.Where("course_no = ?", courseNo).First(&course).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").Where("course_no = ?", courseNo).First(&course).Error; err != nil {
 		return nil, err
 	}
 	return &course, nil
 }
 
-func (r *courseRepo) FindByCourseNos(courseNos []string) ([]CourseCredits, error) {
-	var result []CourseCredits
-	if err := r.db.Table("courses").
-		Select("id, credits").
-		Where("course_no IN ?", courseNos).
-		Scan(&result).Error; err != nil {
-		return nil, err
-	}
-	return result, nil
-}
-
+// Create adds the course; a scoped repository files a course without a department
+// under its own
 func (r *courseRepo) Create(course *model.Course) error {
+	if course.DeptID == nil && !r.scope.All() {
+		deptID := r.scope.DeptID
+		course.DeptID = &deptID
+	}
+	if !r.scope.AllowsPtr(course.DeptID) {
+		return ErrOutOfScope
+	}
 	return r.db.Create(course).Error
 }
 
+// Update saves the course; a scoped repository neither edits the courses of
+// another department nor hands a course to one
 func (r *courseRepo) Update(course *model.Course) error {
+	if !r.scope.AllowsPtr(course.DeptID) {
+		return ErrOutOfScope
+	}
+	if err := r.scope.check(r.db.Model(&model.Course{}).Where("id = ?", course.ID), "dept_id"); err != nil {
+		return err
+	}
 	return r.db.Save(course).Error
 }
 
 func (r *courseRepo) Delete(id uint) error {
-	return r.db.Delete(&model.Course{}, id).Error
-}
-
-func (r *courseRepo) CountEnrollments(courseID uint) (int64, error) {
-	var count int64
-	err := r.db.Table("enrollments").Where("course_id = ?", courseID).Count(&count).Error
-	return count, err
+	return r.scope.where(r.db, "dept_id").Delete(&model.Course{}, id).Error
 }
 
-func (r *courseRepo) CountGrades(courseID uint) (int64, error) {
+func (r *courseRepo) CountOfferings(courseID uint) (int64, error) {
 	var count int64
-	err := r.db.Table("grades").Where("course_id = ?", courseID).Count(&count).Error
+	err := r.db.Table("course_offerings").Where("course_id = ?", courseID).Count(&count).Error
 	return count, err
 }
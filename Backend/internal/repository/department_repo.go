@@ -15,10 +15,13 @@ type DepartmentRepository interface {
 	Delete(id uint) error
 	CountStudents(deptID uint) (int64, error)
 	CountStaff(deptID uint) (int64, error)
+	WithTx(tx *gorm.DB) DepartmentRepository
+	WithScope(scope Scope) DepartmentRepository
 }
 
 type departmentRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewDepartmentRepository creates a new DepartmentRepository
@@ -26,6 +29,16 @@ func NewDepartmentRepository(db *gorm.DB) DepartmentRepository {
 	return &departmentRepo{db: db}
 }
 
+func (r *departmentRepo) WithTx(tx *gorm.DB) DepartmentRepository {
+	return &departmentRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits writes to the scope's own department. Every department stays
+// readable, since records of one refer to the others.
+func (r *departmentRepo) WithScope(scope Scope) DepartmentRepository {
+	return &departmentRepo{db: r.db, scope: scope}
+}
+
 func (r *departmentRepo) FindAll(deptNo, name string) ([]model.Department, error) {
 	q := r.db.Model(&model.Department{})
 	if deptNo != "" {
@@ -59,14 +72,23 @@ func (r *departmentRepo) FindByDeptNo(deptNo string) (*model.Department, error)
 }
 
 func (r *departmentRepo) Create(dept *model.Department) error {
+	if !r.scope.All() {
+		return ErrOutOfScope
+	}
 	return r.db.Create(dept).Error
 }
 
 func (r *departmentRepo) Update(dept *model.Department) error {
+	if !r.scope.Allows(dept.ID) {
+		return ErrOutOfScope
+	}
 	return r.db.Save(dept).Error
 }
 
 func (r *departmentRepo) Delete(id uint) error {
+	if !r.scope.All() {
+		return ErrOutOfScope
+	}
 	return r.db.Delete(&model.Department{}, id).Error
 }
 
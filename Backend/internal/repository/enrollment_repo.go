@@ -5,6 +5,7 @@ import (
 
 	"github.com/lin-snow/edumgr/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // EnrollmentQueryParams represents query parameters for enrollments
@@ -12,6 +13,7 @@ type EnrollmentQueryParams struct {
 	StudentNo string
 	CourseNo  string
 	TermCode  string
+	Teaching  Teaching
 	Page      int
 	PageSize  int
 }
@@ -22,6 +24,8 @@ type EnrollmentRow struct {
 	StudentID   uint      `json:"student_id"`
 	StudentNo   string    `json:"student_no"`
 	StudentName string    `json:"student_name"`
+	OfferingID  uint      `json:"offering_id"`
+	SectionNo   string    `json:"section_no"`
 	CourseID    uint      `json:"course_id"`
 	CourseNo    string    `json:"course_no"`
 	CourseName  string    `json:"course_name"`
@@ -32,23 +36,37 @@ type EnrollmentRow struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// OfferingSeat represents the seat limit of a course offering locked for enrollment
+type OfferingSeat struct {
+	ID       uint
+	CourseID uint
+	Credits  int
+	Capacity int
+}
+
 // EnrollmentRepository defines the interface for enrollment data access
 type EnrollmentRepository interface {
 	FindByID(id uint) (*model.Enrollment, error)
-	FindByStudentAndCourse(studentID, courseID uint) (*model.Enrollment, error)
 	FindByFilters(params EnrollmentQueryParams) ([]EnrollmentRow, int64, error)
+	EachByFilters(params EnrollmentQueryParams, fn func(*EnrollmentRow) error) error
 	FindByStudentID(studentID uint) ([]EnrollmentRow, error)
 	GetCurrentCredits(studentID, termID uint) (int, error)
-	CountDuplicates(studentID uint, courseIDs []uint) (int64, error)
+	CountDuplicates(studentID uint, courseIDs []uint, passScore float64) (int64, error)
+	FindMeetingsByStudentAndTerm(studentID, termID uint) ([]OfferingMeetingRow, error)
+	LockOfferingSeats(offeringIDs []uint) ([]OfferingSeat, error)
+	CountByOfferings(offeringIDs []uint) (map[uint]int64, error)
+	FindPassedCourseIDs(studentID uint, passScore float64) ([]uint, error)
 	CreateBatch(enrollments []model.Enrollment) error
 	Delete(id uint) error
-	DeleteGradesByStudentAndCourse(studentID, courseID uint) error
+	DeleteGradesByStudentAndOffering(studentID, offeringID uint) error
 	WithTx(tx *gorm.DB) EnrollmentRepository
+	WithScope(scope Scope) EnrollmentRepository
 	GetDB() *gorm.DB
 }
 
 type enrollmentRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewEnrollmentRepository creates a new EnrollmentRepository
@@ -57,7 +75,12 @@ func NewEnrollmentRepository(db *gorm.DB) EnrollmentRepository {
 }
 
 func (r *enrollmentRepo) WithTx(tx *gorm.DB) EnrollmentRepository {
-	return &enrollmentRepo{db: tx}
+	return &enrollmentRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the enrollments of the students of the scope's department
+func (r *enrollmentRepo) WithScope(scope Scope) EnrollmentRepository {
+	return &enrollmentRepo{db: r.db, scope: scope}
 }
 
 func (r *enrollmentRepo) GetDB() *gorm.DB {
@@ -66,15 +89,7 @@ func (r *enrollmentRepo) GetDB() *gorm.DB {
 
 func (r *enrollmentRepo) FindByID(id uint) (*model.Enrollment, error) {
 	var enrollment model.Enrollment
-	if err := r.db.First(&enrollment, id).Error; err != nil {
-		return nil, err
-	}
-	return &enrollment, nil
-}
-
-func (r *enrollmentRepo) FindByStudentAndCourse(studentID, courseID uint) (*model.Enrollment, error) {
-	var enrollment model.Enrollment
-	if err := r.db.Where("student_id = ? AND course_id = ?", studentID, courseID).First(&enrollment).Error; err != nil {
+	if err := r.scope.whereStudent(r.db, "student_id").First(&enrollment, id).Error; err != nil {
 		return nil, err
 	}
 	return &enrollment, nil
@@ -84,8 +99,9 @@ func (r *enrollmentRepo) GetCurrentCredits(studentID, termID uint) (int, error)
 	var total int
 	row := r.db.Table("enrollments").
 		Select("COALESCE(SUM(courses.credits), 0) AS total").
-		Joins("JOIN courses ON courses.id = enrollments.course_id").
-		Where("enrollments.student_id = ? AND enrollments.term_id = ?", studentID, termID).
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Where("enrollments.student_id = ? AND course_offerings.term_id = ?", studentID, termID).
 		Row()
 	if err := row.Scan(&total); err != nil {
 		return 0, err
@@ -93,16 +109,77 @@ func (r *enrollmentRepo) GetCurrentCredits(studentID, termID uint) (int, error)
 	return total, nil
 }
 
-func (r *enrollmentRepo) CountDuplicates(studentID uint, courseIDs []uint) (int64, error) {
+// CountDuplicates counts the student's attempts at the given catalog courses that rule out a retake:
+// attempts that are still ungraded or were passed. Failed attempts may be retaken once their grades
+// are published or locked; a draft or submitted score may still change.
+func (r *enrollmentRepo) CountDuplicates(studentID uint, courseIDs []uint, passScore float64) (int64, error) {
 	var count int64
 	if err := r.db.Table("enrollments").
-		Where("student_id = ? AND course_id IN ?", studentID, courseIDs).
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id").
+		Joins("LEFT JOIN grades ON grades.student_id = enrollments.student_id AND grades.offering_id = enrollments.offering_id").
+		Where("enrollments.student_id = ? AND course_offerings.course_id IN ?", studentID, courseIDs).
+		Where("grades.final_score IS NULL OR grades.final_score >= ? OR course_offerings.grade_status NOT IN ?",
+			passScore, []string{model.GradeStatusPublished, model.GradeStatusLocked}).
 		Count(&count).Error; err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
+func (r *enrollmentRepo) FindMeetingsByStudentAndTerm(studentID, termID uint) ([]OfferingMeetingRow, error) {
+	var rows []OfferingMeetingRow
+	if err := r.db.Table("enrollments").
+		Select(`
+			class_meetings.*, course_offerings.course_id, course_offerings.section_no,
+			courses.course_no, courses.name AS course_name
+		`).
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN class_meetings ON class_meetings.offering_id = course_offerings.id").
+		Where("enrollments.student_id = ? AND course_offerings.term_id = ?", studentID, termID).
+		Order("courses.course_no asc, class_meetings.weekday asc, class_meetings.start_period asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// LockOfferingSeats takes row locks on the offerings (in id order to avoid deadlocks)
+// so concurrent enrollments cannot oversell the remaining seats.
+func (r *enrollmentRepo) LockOfferingSeats(offeringIDs []uint) ([]OfferingSeat, error) {
+	var seats []OfferingSeat
+	if err := r.db.Table("course_offerings").
+		Select("course_offerings.id, course_offerings.course_id, courses.credits, course_offerings.capacity").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Where("course_offerings.id IN ?", offeringIDs).
+		Order("course_offerings.id asc").
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "course_offerings"}}).
+		Scan(&seats).Error; err != nil {
+		return nil, err
+	}
+	return seats, nil
+}
+
+func (r *enrollmentRepo) CountByOfferings(offeringIDs []uint) (map[uint]int64, error) {
+	type offeringCount struct {
+		OfferingID uint
+		Count      int64
+	}
+	var rows []offeringCount
+	if err := r.db.Table("enrollments").
+		Select("offering_id, COUNT(*) AS count").
+		Where("offering_id IN ?", offeringIDs).
+		Group("offering_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.OfferingID] = row.Count
+	}
+	return counts, nil
+}
+
 func (r *enrollmentRepo) CreateBatch(enrollments []model.Enrollment) error {
 	return r.db.Create(&enrollments).Error
 }
@@ -111,21 +188,25 @@ func (r *enrollmentRepo) Delete(id uint) error {
 	return r.db.Delete(&model.Enrollment{}, id).Error
 }
 
-func (r *enrollmentRepo) DeleteGradesByStudentAndCourse(studentID, courseID uint) error {
-	return r.db.Table("grades").Where("student_id = ? AND course_id = ?", studentID, courseID).Delete(nil).Error
+func (r *enrollmentRepo) DeleteGradesByStudentAndOffering(studentID, offeringID uint) error {
+	return r.db.Table("grades").Where("student_id = ? AND offering_id = ?", studentID, offeringID).Delete(nil).Error
 }
 
-func (r *enrollmentRepo) FindByFilters(params EnrollmentQueryParams) ([]EnrollmentRow, int64, error) {
+func (r *enrollmentRepo) filterQuery(params EnrollmentQueryParams) *gorm.DB {
 	q := r.db.Table("enrollments").
 		Select(`
-			enrollments.id, enrollments.student_id, enrollments.course_id, enrollments.term_id, enrollments.created_at,
+			enrollments.id, enrollments.student_id, enrollments.offering_id, enrollments.created_at,
 			students.student_no, students.name AS student_name,
+			course_offerings.section_no, course_offerings.course_id, course_offerings.term_id,
 			courses.course_no, courses.name AS course_name, courses.credits,
 			terms.term_code, terms.name AS term_name
 		`).
 		Joins("JOIN students ON students.id = enrollments.student_id").
-		Joins("JOIN courses ON courses.id = enrollments.course_id").
-		Joins("JOIN terms ON terms.id = enrollments.term_id")
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id")
+	q = r.scope.where(q, "students.dept_id")
+	q = params.Teaching.where(q, "course_offerings.teacher_id")
 
 	if params.StudentNo != "" {
 		q = q.Where("students.student_no = ?", params.StudentNo)
@@ -136,6 +217,11 @@ func (r *enrollmentRepo) FindByFilters(params EnrollmentQueryParams) ([]Enrollme
 	if params.TermCode != "" {
 		q = q.Where("terms.term_code = ?", params.TermCode)
 	}
+	return q
+}
+
+func (r *enrollmentRepo) FindByFilters(params EnrollmentQueryParams) ([]EnrollmentRow, int64, error) {
+	q := r.filterQuery(params)
 
 	var total int64
 	if err := q.Count(&total).Error; err != nil {
@@ -154,22 +240,44 @@ func (r *enrollmentRepo) FindByFilters(params EnrollmentQueryParams) ([]Enrollme
 	return rows, total, nil
 }
 
+// EachByFilters streams every matching enrollment in list order, ignoring pagination
+func (r *enrollmentRepo) EachByFilters(params EnrollmentQueryParams, fn func(*EnrollmentRow) error) error {
+	return scanEach(r.filterQuery(params).Order("enrollments.created_at desc"), fn)
+}
+
 func (r *enrollmentRepo) FindByStudentID(studentID uint) ([]EnrollmentRow, error) {
 	var rows []EnrollmentRow
-	if err := r.db.Table("enrollments").
+	if err := r.scope.where(r.db.Table("enrollments"), "students.dept_id").
 		Select(`
-			enrollments.id, enrollments.student_id, enrollments.course_id, enrollments.term_id, enrollments.created_at,
+			enrollments.id, enrollments.student_id, enrollments.offering_id, enrollments.created_at,
 			students.student_no, students.name AS student_name,
+			course_offerings.section_no, course_offerings.course_id, course_offerings.term_id,
 			courses.course_no, courses.name AS course_name, courses.credits,
 			terms.term_code, terms.name AS term_name
 		`).
 		Joins("JOIN students ON students.id = enrollments.student_id").
-		Joins("JOIN courses ON courses.id = enrollments.course_id").
-		Joins("JOIN terms ON terms.id = enrollments.term_id").
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
 		Where("enrollments.student_id = ?", studentID).
-		Order("terms.term_code desc, courses.course_no asc").
+		Order("terms.start_date desc NULLS LAST, terms.term_code desc, courses.course_no asc").
 		Scan(&rows).Error; err != nil {
 		return nil, err
 	}
 	return rows, nil
 }
+
+// FindPassedCourseIDs returns the catalog courses the student passed. Only published or
+// locked grades count; a draft or submitted grade may still change.
+func (r *enrollmentRepo) FindPassedCourseIDs(studentID uint, passScore float64) ([]uint, error) {
+	var ids []uint
+	if err := r.db.Model(&model.Grade{}).
+		Joins("JOIN course_offerings ON course_offerings.id = grades.offering_id").
+		Where("grades.student_id = ? AND grades.final_score >= ?", studentID, passScore).
+		Where("course_offerings.grade_status IN ?", []string{model.GradeStatusPublished, model.GradeStatusLocked}).
+		Distinct().
+		Pluck("course_offerings.course_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
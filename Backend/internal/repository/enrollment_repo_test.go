@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+// A failing score only frees the course for a retake once its grades are published or locked
+func TestCountDuplicatesUnpublishedFailBlocks(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`(grades.final_score IS NULL OR grades.final_score >= $3 OR course_offerings.grade_status NOT IN ($4,$5))`)).
+		WithArgs(uint(3), uint(7), 60.0, model.GradeStatusPublished, model.GradeStatusLocked).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	count, err := NewEnrollmentRepository(db).CountDuplicates(3, []uint{7}, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("CountDuplicates = %d, want 1", count)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GradeAuditQueryParams selects audit entries by grade or by course offering
+type GradeAuditQueryParams struct {
+	GradeID    uint
+	OfferingID uint
+}
+
+// GradeAuditRow represents an audit entry with its student and acting user
+type GradeAuditRow struct {
+	model.GradeAudit
+	StudentNo   string `json:"student_no"`
+	StudentName string `json:"student_name"`
+	Username    string `json:"username"`
+}
+
+// GradeAuditRepository defines data access for the grade audit trail
+type GradeAuditRepository interface {
+	Snapshot(studentID, offeringID uint) (*model.Grade, *model.GradeSnapshot, error)
+	Create(audit *model.GradeAudit) error
+	FindAll(params GradeAuditQueryParams) ([]GradeAuditRow, error)
+	WithTx(tx *gorm.DB) GradeAuditRepository
+}
+
+type gradeAuditRepo struct {
+	db *gorm.DB
+}
+
+// NewGradeAuditRepository creates a new GradeAuditRepository
+func NewGradeAuditRepository(db *gorm.DB) GradeAuditRepository {
+	return &gradeAuditRepo{db: db}
+}
+
+func (r *gradeAuditRepo) WithTx(tx *gorm.DB) GradeAuditRepository {
+	return &gradeAuditRepo{db: tx}
+}
+
+// Snapshot locks a student's grade in an offering and returns it with its component scores.
+// Both are nil when the student has no grade there.
+func (r *gradeAuditRepo) Snapshot(studentID, offeringID uint) (*model.Grade, *model.GradeSnapshot, error) {
+	var grade model.Grade
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("student_id = ? AND offering_id = ?", studentID, offeringID).
+		First(&grade).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var scores []model.GradeComponentScore
+	if err := r.db.Where("grade_id = ?", grade.ID).Order("id asc").Find(&scores).Error; err != nil {
+		return nil, nil, err
+	}
+	snap := &model.GradeSnapshot{
+		UsualScore:      grade.UsualScore,
+		ExamScore:       grade.ExamScore,
+		FinalScore:      grade.FinalScore,
+		FinalOverridden: grade.FinalOverridden,
+		Components:      make(map[string]*float64, len(scores)),
+	}
+	for _, s := range scores {
+		snap.Components[s.Code] = s.Score
+	}
+	return &grade, snap, nil
+}
+
+func (r *gradeAuditRepo) Create(audit *model.GradeAudit) error {
+	return r.db.Create(audit).Error
+}
+
+func (r *gradeAuditRepo) FindAll(params GradeAuditQueryParams) ([]GradeAuditRow, error) {
+	q := r.db.Table("grade_audits").
+		Select(`
+			grade_audits.*,
+			COALESCE(students.student_no, '') AS student_no, COALESCE(students.name, '') AS student_name,
+			COALESCE(users.username, '') AS username
+		`).
+		// Entries outlive the grade, and possibly the student and user they refer to
+		Joins("LEFT JOIN students ON students.id = grade_audits.student_id").
+		Joins("LEFT JOIN users ON users.id = grade_audits.user_id")
+
+	if params.GradeID != 0 {
+		q = q.Where("grade_audits.grade_id = ?", params.GradeID)
+	}
+	if params.OfferingID != 0 {
+		q = q.Where("grade_audits.offering_id = ?", params.OfferingID)
+	}
+
+	var rows []GradeAuditRow
+	if err := q.Order("grade_audits.created_at asc, grade_audits.id asc").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
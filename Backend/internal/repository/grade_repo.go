@@ -1,16 +1,22 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/lin-snow/edumgr/internal/model"
 	"gorm.io/gorm"
 )
 
 // GradeQueryRow represents a grade query result row
 type GradeQueryRow struct {
+	GradeID     uint     `json:"grade_id"`
 	StudentID   uint     `json:"student_id"`
 	StudentNo   string   `json:"student_no"`
 	StudentName string   `json:"student_name"`
 	Gender      string   `json:"gender"`
+	OfferingID  uint     `json:"offering_id"`
+	TermCode    string   `json:"term_code"`
+	SectionNo   string   `json:"section_no"`
 	CourseID    uint     `json:"course_id"`
 	CourseNo    string   `json:"course_no"`
 	CourseName  string   `json:"course_name"`
@@ -26,17 +32,46 @@ type GradeQueryRow struct {
 	UsualScore  *float64 `json:"usual_score"`
 	ExamScore   *float64 `json:"exam_score"`
 	FinalScore  *float64 `json:"final_score"`
+	Overridden  bool     `json:"final_overridden"`
 }
 
 // StudentGradeRow represents a student's grade with term info
 type StudentGradeRow struct {
-	CourseNo   string   `json:"course_no"`
-	CourseName string   `json:"course_name"`
-	Credits    int      `json:"credits"`
-	TermCode   string   `json:"term_code"`
-	UsualScore *float64 `json:"usual_score"`
-	ExamScore  *float64 `json:"exam_score"`
-	FinalScore *float64 `json:"final_score"`
+	GradeID    uint       `json:"grade_id"`
+	CourseID   uint       `json:"course_id"`
+	OfferingID uint       `json:"offering_id"`
+	CourseNo   string     `json:"course_no"`
+	CourseName string     `json:"course_name"`
+	Credits    int        `json:"credits"`
+	TermCode   string     `json:"term_code"`
+	TermName   string     `json:"term_name"`
+	TermStart  *time.Time `json:"term_start,omitempty"`
+	SectionNo  string     `json:"section_no"`
+	UsualScore *float64   `json:"usual_score"`
+	ExamScore  *float64   `json:"exam_score"`
+	FinalScore *float64   `json:"final_score"`
+	Published  bool       `json:"published"`
+}
+
+// GradeAttempt is one graded attempt of a student at a catalog course. Attempts order
+// by TermStart, the start date of their term, and by TermCode where that is equal.
+type GradeAttempt struct {
+	StudentID  uint
+	CourseID   uint
+	OfferingID uint
+	TermCode   string
+	TermStart  *time.Time
+	FinalScore *float64
+}
+
+// OfferingGradeRow is a student enrolled in an offering with their grade there, if any
+type OfferingGradeRow struct {
+	StudentID   uint     `json:"student_id"`
+	StudentNo   string   `json:"student_no"`
+	StudentName string   `json:"student_name"`
+	GradeID     *uint    `json:"grade_id"`
+	FinalScore  *float64 `json:"final_score"`
+	Overridden  bool     `json:"final_overridden"`
 }
 
 // GradeQueryParams represents the query parameters for grades
@@ -47,22 +82,29 @@ type GradeQueryParams struct {
 	CourseName  string
 	TeacherName string
 	DeptNo      string
+	TermCode    string
+	Teaching    Teaching
 }
 
 // GradeRepository defines the interface for grade data access
 type GradeRepository interface {
 	FindByFilters(params GradeQueryParams) ([]GradeQueryRow, error)
+	EachByFilters(params GradeQueryParams, fn func(*GradeQueryRow) error) error
 	FindByStudentID(studentID uint) ([]StudentGradeRow, error)
-	FindByStudentAndCourse(studentID, courseID uint) (*model.Grade, error)
+	FindAttempts(studentIDs, courseIDs []uint) ([]GradeAttempt, error)
+	FindByStudentAndOffering(studentID, offeringID uint) (*model.Grade, error)
+	FindOfferingRoster(offeringID uint) ([]OfferingGradeRow, error)
 	Create(grade *model.Grade) error
 	Update(grade *model.Grade) error
 	Upsert(grade *model.Grade) error
 	WithTx(tx *gorm.DB) GradeRepository
+	WithScope(scope Scope) GradeRepository
 	GetDB() *gorm.DB
 }
 
 type gradeRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewGradeRepository creates a new GradeRepository
@@ -71,27 +113,38 @@ func NewGradeRepository(db *gorm.DB) GradeRepository {
 }
 
 func (r *gradeRepo) WithTx(tx *gorm.DB) GradeRepository {
-	return &gradeRepo{db: tx}
+	return &gradeRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the grades of the students of the scope's department
+func (r *gradeRepo) WithScope(scope Scope) GradeRepository {
+	return &gradeRepo{db: r.db, scope: scope}
 }
 
 func (r *gradeRepo) GetDB() *gorm.DB {
 	return r.db
 }
 
-func (r *gradeRepo) FindByFilters(params GradeQueryParams) ([]GradeQueryRow, error) {
+func (r *gradeRepo) filterQuery(params GradeQueryParams) *gorm.DB {
 	q := r.db.Table("grades").
 		Select(`
-			students.id AS student_id, students.student_no, students.name AS student_name, students.gender,
+			grades.id AS grade_id, students.id AS student_id, students.student_no, students.name AS student_name, students.gender,
+			course_offerings.id AS offering_id, terms.term_code, course_offerings.section_no,
 			courses.id AS course_id, courses.course_no, courses.name AS course_name,
 			staff.id AS teacher_id, staff.staff_no AS teacher_no, staff.name AS teacher_name,
 			departments.dept_no,
-			courses.hours, courses.credits, courses.class_time, courses.class_location, courses.exam_time,
-			grades.usual_score, grades.exam_score, grades.final_score
+			courses.hours, courses.credits,
+			course_offerings.class_time, course_offerings.class_location, course_offerings.exam_time,
+			grades.usual_score, grades.exam_score, grades.final_score, grades.final_overridden AS overridden
 		`).
 		Joins("JOIN students ON students.id = grades.student_id").
-		Joins("JOIN courses ON courses.id = grades.course_id").
-		Joins("JOIN staff ON staff.id = courses.teacher_id").
+		Joins("JOIN course_offerings ON course_offerings.id = grades.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
+		Joins("JOIN staff ON staff.id = course_offerings.teacher_id").
 		Joins("JOIN departments ON departments.id = students.dept_id")
+	q = r.scope.where(q, "students.dept_id")
+	q = params.Teaching.where(q, "course_offerings.teacher_id")
 
 	if params.StudentNo != "" {
 		q = q.Where("students.student_no = ?", params.StudentNo)
@@ -111,28 +164,40 @@ func (r *gradeRepo) FindByFilters(params GradeQueryParams) ([]GradeQueryRow, err
 	if params.DeptNo != "" {
 		q = q.Where("departments.dept_no = ?", params.DeptNo)
 	}
+	if params.TermCode != "" {
+		q = q.Where("terms.term_code = ?", params.TermCode)
+	}
+	return q.Order("courses.course_no asc, terms.start_date desc NULLS LAST, terms.term_code desc, course_offerings.section_no asc, grades.final_score desc NULLS LAST, students.student_no asc")
+}
 
+func (r *gradeRepo) FindByFilters(params GradeQueryParams) ([]GradeQueryRow, error) {
 	var rows []GradeQueryRow
-	if err := q.Order("courses.course_no asc, grades.final_score desc NULLS LAST, students.student_no asc").
-		Scan(&rows).Error; err != nil {
+	if err := r.filterQuery(params).Scan(&rows).Error; err != nil {
 		return nil, err
 	}
 	return rows, nil
 }
 
+// EachByFilters streams the rows of FindByFilters; rows of one offering are adjacent
+func (r *gradeRepo) EachByFilters(params GradeQueryParams, fn func(*GradeQueryRow) error) error {
+	return scanEach(r.filterQuery(params), fn)
+}
+
 func (r *gradeRepo) FindByStudentID(studentID uint) ([]StudentGradeRow, error) {
 	var rows []StudentGradeRow
-	err := r.db.Table("grades").
+	err := r.scope.whereStudent(r.db.Table("grades"), "grades.student_id").
 		Select(`
+			grades.id AS grade_id, course_offerings.course_id, grades.offering_id,
 			courses.course_no, courses.name AS course_name, courses.credits,
-			COALESCE(terms.term_code, '') AS term_code,
-			grades.usual_score, grades.exam_score, grades.final_score
+			terms.term_code, terms.name AS term_name, terms.start_date AS term_start,
+			course_offerings.section_no, grades.usual_score, grades.exam_score, grades.final_score,
+			course_offerings.grade_status IN ('published', 'locked') AS published
 		`).
-		Joins("JOIN courses ON courses.id = grades.course_id").
-		Joins("LEFT JOIN enrollments ON enrollments.student_id = grades.student_id AND enrollments.course_id = grades.course_id").
-		Joins("LEFT JOIN terms ON terms.id = enrollments.term_id").
+		Joins("JOIN course_offerings ON course_offerings.id = grades.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
 		Where("grades.student_id = ?", studentID).
-		Order("terms.term_code DESC, courses.course_no ASC").
+		Order("terms.start_date DESC NULLS LAST, terms.term_code DESC, courses.course_no ASC").
 		Scan(&rows).Error
 	if err != nil {
 		return nil, err
@@ -140,24 +205,65 @@ func (r *gradeRepo) FindByStudentID(studentID uint) ([]StudentGradeRow, error) {
 	return rows, nil
 }
 
-func (r *gradeRepo) FindByStudentAndCourse(studentID, courseID uint) (*model.Grade, error) {
+// FindAttempts returns every grade the students hold in any offering of the given courses
+func (r *gradeRepo) FindAttempts(studentIDs, courseIDs []uint) ([]GradeAttempt, error) {
+	var rows []GradeAttempt
+	if len(studentIDs) == 0 || len(courseIDs) == 0 {
+		return rows, nil
+	}
+	if err := r.db.Table("grades").
+		Select(`grades.student_id, course_offerings.course_id, grades.offering_id,
+			terms.term_code, terms.start_date AS term_start, grades.final_score`).
+		Joins("JOIN course_offerings ON course_offerings.id = grades.offering_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
+		Where("grades.student_id IN ? AND course_offerings.course_id IN ?", studentIDs, courseIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *gradeRepo) FindByStudentAndOffering(studentID, offeringID uint) (*model.Grade, error) {
 	var grade model.Grade
-	if err := r.db.Where("student_id = ? AND course_id = ?", studentID, courseID).First(&grade).Error; err != nil {
+	if err := r.scope.whereStudent(r.db, "student_id").
+		Where("student_id = ? AND offering_id = ?", studentID, offeringID).First(&grade).Error; err != nil {
 		return nil, err
 	}
 	return &grade, nil
 }
 
+// FindOfferingRoster lists the students enrolled in an offering by student_no, graded or not
+func (r *gradeRepo) FindOfferingRoster(offeringID uint) ([]OfferingGradeRow, error) {
+	var rows []OfferingGradeRow
+	err := r.scope.where(r.db.Table("enrollments"), "students.dept_id").
+		Select(`
+			students.id AS student_id, students.student_no, students.name AS student_name,
+			grades.id AS grade_id, grades.final_score, COALESCE(grades.final_overridden, false) AS overridden
+		`).
+		Joins("JOIN students ON students.id = enrollments.student_id").
+		Joins("LEFT JOIN grades ON grades.student_id = enrollments.student_id AND grades.offering_id = enrollments.offering_id").
+		Where("enrollments.offering_id = ?", offeringID).
+		Order("students.student_no ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
 func (r *gradeRepo) Create(grade *model.Grade) error {
+	if err := r.scope.check(r.db.Model(&model.Student{}).Where("id = ?", grade.StudentID), "dept_id"); err != nil {
+		return err
+	}
 	return r.db.Create(grade).Error
 }
 
 func (r *gradeRepo) Update(grade *model.Grade) error {
+	if err := r.scope.check(r.db.Model(&model.Student{}).Where("id = ?", grade.StudentID), "dept_id"); err != nil {
+		return err
+	}
 	return r.db.Save(grade).Error
 }
 
 func (r *gradeRepo) Upsert(grade *model.Grade) error {
-	existing, err := r.FindByStudentAndCourse(grade.StudentID, grade.CourseID)
+	existing, err := r.FindByStudentAndOffering(grade.StudentID, grade.OfferingID)
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return err
 	}
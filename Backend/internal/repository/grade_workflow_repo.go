@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// AmendmentQueryParams represents query parameters for grade amendments
+type AmendmentQueryParams struct {
+	Status     string
+	OfferingID uint
+	TeacherID  uint // only offerings taught by this staff member when set
+}
+
+// AmendmentRow represents a grade amendment with its student and course offering
+type AmendmentRow struct {
+	model.GradeAmendment
+	StudentNo   string `json:"student_no"`
+	StudentName string `json:"student_name"`
+	CourseNo    string `json:"course_no"`
+	CourseName  string `json:"course_name"`
+	TermCode    string `json:"term_code"`
+	SectionNo   string `json:"section_no"`
+	TeacherNo   string `json:"teacher_no"`
+	TeacherName string `json:"teacher_name"`
+}
+
+// GradeWorkflowRepository defines data access for grade status logs and amendment requests
+type GradeWorkflowRepository interface {
+	CreateStatusLog(log *model.GradeStatusLog) error
+	FindStatusLogs(offeringID uint) ([]model.GradeStatusLog, error)
+	FindAmendments(params AmendmentQueryParams) ([]AmendmentRow, error)
+	FindAmendmentByID(id uint) (*model.GradeAmendment, error)
+	CountPendingAmendments(offeringID, studentID uint) (int64, error)
+	CreateAmendment(amendment *model.GradeAmendment) error
+	ReviewAmendment(id uint, status string, reviewer uint, note string, at time.Time) (bool, error)
+	WithTx(tx *gorm.DB) GradeWorkflowRepository
+	WithScope(scope Scope) GradeWorkflowRepository
+}
+
+type gradeWorkflowRepo struct {
+	db    *gorm.DB
+	scope Scope
+}
+
+// NewGradeWorkflowRepository creates a new GradeWorkflowRepository
+func NewGradeWorkflowRepository(db *gorm.DB) GradeWorkflowRepository {
+	return &gradeWorkflowRepo{db: db}
+}
+
+func (r *gradeWorkflowRepo) WithTx(tx *gorm.DB) GradeWorkflowRepository {
+	return &gradeWorkflowRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the amendments to those of the students of the scope's department
+func (r *gradeWorkflowRepo) WithScope(scope Scope) GradeWorkflowRepository {
+	return &gradeWorkflowRepo{db: r.db, scope: scope}
+}
+
+func (r *gradeWorkflowRepo) CreateStatusLog(log *model.GradeStatusLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *gradeWorkflowRepo) FindStatusLogs(offeringID uint) ([]model.GradeStatusLog, error) {
+	var logs []model.GradeStatusLog
+	err := r.db.Where("offering_id = ?", offeringID).Order("created_at asc, id asc").Find(&logs).Error
+	return logs, err
+}
+
+func (r *gradeWorkflowRepo) FindAmendments(params AmendmentQueryParams) ([]AmendmentRow, error) {
+	q := r.db.Table("grade_amendments").
+		Select(`
+			grade_amendments.*,
+			students.student_no, students.name AS student_name,
+			courses.course_no, courses.name AS course_name,
+			terms.term_code, course_offerings.section_no,
+			staff.staff_no AS teacher_no, staff.name AS teacher_name
+		`).
+		Joins("JOIN students ON students.id = grade_amendments.student_id").
+		Joins("JOIN course_offerings ON course_offerings.id = grade_amendments.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
+		Joins("JOIN staff ON staff.id = course_offerings.teacher_id")
+	q = r.scope.where(q, "students.dept_id")
+
+	if params.Status != "" {
+		q = q.Where("grade_amendments.status = ?", params.Status)
+	}
+	if params.OfferingID != 0 {
+		q = q.Where("grade_amendments.offering_id = ?", params.OfferingID)
+	}
+	if params.TeacherID != 0 {
+		q = q.Where("course_offerings.teacher_id = ?", params.TeacherID)
+	}
+
+	var rows []AmendmentRow
+	if err := q.Order("grade_amendments.created_at desc").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *gradeWorkflowRepo) FindAmendmentByID(id uint) (*model.GradeAmendment, error) {
+	var amendment model.GradeAmendment
+	if err := r.scope.whereStudent(r.db, "student_id").First(&amendment, id).Error; err != nil {
+		return nil, err
+	}
+	return &amendment, nil
+}
+
+func (r *gradeWorkflowRepo) CountPendingAmendments(offeringID, studentID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.GradeAmendment{}).
+		Where("offering_id = ? AND student_id = ? AND status = ?", offeringID, studentID, model.AmendmentPending).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gradeWorkflowRepo) CreateAmendment(amendment *model.GradeAmendment) error {
+	return r.db.Create(amendment).Error
+}
+
+// ReviewAmendment closes a pending amendment; it reports false when it was already reviewed
+func (r *gradeWorkflowRepo) ReviewAmendment(id uint, status string, reviewer uint, note string, at time.Time) (bool, error) {
+	res := r.db.Model(&model.GradeAmendment{}).
+		Where("id = ? AND status = ?", id, model.AmendmentPending).
+		Updates(map[string]any{"status": status, "reviewed_by": reviewer, "review_note": note, "reviewed_at": at})
+	return res.RowsAffected > 0, res.Error
+}
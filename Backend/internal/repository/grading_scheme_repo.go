@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// GradingSchemeRepository defines the interface for grading scheme data access
+type GradingSchemeRepository interface {
+	FindByCourseID(courseID uint) (*model.GradingScheme, error)
+	Save(scheme *model.GradingScheme) error
+	Delete(courseID uint) error
+	FindComponentScores(gradeIDs []uint) ([]model.GradeComponentScore, error)
+	ReplaceComponentScores(gradeID uint, scores []model.GradeComponentScore) error
+	WithTx(tx *gorm.DB) GradingSchemeRepository
+}
+
+type gradingSchemeRepo struct {
+	db *gorm.DB
+}
+
+// NewGradingSchemeRepository creates a new GradingSchemeRepository
+func NewGradingSchemeRepository(db *gorm.DB) GradingSchemeRepository {
+	return &gradingSchemeRepo{db: db}
+}
+
+func (r *gradingSchemeRepo) WithTx(tx *gorm.DB) GradingSchemeRepository {
+	return &gradingSchemeRepo{db: tx}
+}
+
+// FindByCourseID returns the scheme of a course with its components in display order
+func (r *gradingSchemeRepo) FindByCourseID(courseID uint) (*model.GradingScheme, error) {
+	var scheme model.GradingScheme
+	if err := r.db.Where("course_id = ?", courseID).First(&scheme).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("course_id = ?", courseID).
+		Order("sort_order asc, id asc").
+		Find(&scheme.Components).Error; err != nil {
+		return nil, err
+	}
+	return &scheme, nil
+}
+
+// Save creates or replaces a scheme together with its components
+func (r *gradingSchemeRepo) Save(scheme *model.GradingScheme) error {
+	if err := r.db.Save(scheme).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("course_id = ?", scheme.CourseID).Delete(&model.GradingComponent{}).Error; err != nil {
+		return err
+	}
+	if len(scheme.Components) == 0 {
+		return nil
+	}
+	for i := range scheme.Components {
+		scheme.Components[i].ID = 0
+		scheme.Components[i].CourseID = scheme.CourseID
+		scheme.Components[i].SortOrder = i
+	}
+	return r.db.Create(&scheme.Components).Error
+}
+
+func (r *gradingSchemeRepo) Delete(courseID uint) error {
+	return r.db.Where("course_id = ?", courseID).Delete(&model.GradingScheme{}).Error
+}
+
+func (r *gradingSchemeRepo) FindComponentScores(gradeIDs []uint) ([]model.GradeComponentScore, error) {
+	var scores []model.GradeComponentScore
+	if len(gradeIDs) == 0 {
+		return scores, nil
+	}
+	if err := r.db.Where("grade_id IN ?", gradeIDs).
+		Order("grade_id asc, id asc").
+		Find(&scores).Error; err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+func (r *gradingSchemeRepo) ReplaceComponentScores(gradeID uint, scores []model.GradeComponentScore) error {
+	if err := r.db.Where("grade_id = ?", gradeID).Delete(&model.GradeComponentScore{}).Error; err != nil {
+		return err
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+	for i := range scores {
+		scores[i].ID = 0
+		scores[i].GradeID = gradeID
+	}
+	return r.db.Create(&scores).Error
+}
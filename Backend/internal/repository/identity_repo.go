@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// IdentityRepository defines data access for the external identities linked to users
+type IdentityRepository interface {
+	FindBySubject(provider, subject string) (*model.UserIdentity, error)
+	Create(identity *model.UserIdentity) error
+	Touch(id uint, at time.Time) error
+	WithTx(tx *gorm.DB) IdentityRepository
+}
+
+type identityRepo struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository creates a new IdentityRepository
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepo{db: db}
+}
+
+func (r *identityRepo) WithTx(tx *gorm.DB) IdentityRepository {
+	return &identityRepo{db: tx}
+}
+
+// FindBySubject returns the identity, or nil when it is linked to no user
+func (r *identityRepo) FindBySubject(provider, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepo) Create(identity *model.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// Touch records a sign-in through the identity
+func (r *identityRepo) Touch(id uint, at time.Time) error {
+	return r.db.Model(&model.UserIdentity{}).Where("id = ?", id).Update("last_login_at", at).Error
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// IssuanceQueryParams represents query parameters for issued documents
+type IssuanceQueryParams struct {
+	StudentNo string
+	DocType   string
+}
+
+// IssuanceRow represents an issued document with student info
+type IssuanceRow struct {
+	model.DocumentIssuance
+	StudentNo   string `json:"student_no"`
+	StudentName string `json:"student_name"`
+}
+
+// IssuanceRepository defines the interface for document issuance data access
+type IssuanceRepository interface {
+	FindAll(params IssuanceQueryParams) ([]IssuanceRow, error)
+	FindByCode(code string) (*model.DocumentIssuance, error)
+	Create(issuance *model.DocumentIssuance) error
+	Revoke(id uint, reason string, at time.Time) error
+	WithScope(scope Scope) IssuanceRepository
+}
+
+type issuanceRepo struct {
+	db    *gorm.DB
+	scope Scope
+}
+
+// NewIssuanceRepository creates a new IssuanceRepository
+func NewIssuanceRepository(db *gorm.DB) IssuanceRepository {
+	return &issuanceRepo{db: db}
+}
+
+// WithScope limits the repository to the documents of the students of the scope's department
+func (r *issuanceRepo) WithScope(scope Scope) IssuanceRepository {
+	return &issuanceRepo{db: r.db, scope: scope}
+}
+
+func (r *issuanceRepo) FindAll(params IssuanceQueryParams) ([]IssuanceRow, error) {
+	q := r.db.Table("document_issuances").
+		Select("document_issuances.*, COALESCE(students.student_no, '') AS student_no, COALESCE(students.name, '') AS student_name").
+		Joins("LEFT JOIN students ON students.id = document_issuances.student_id")
+	q = r.scope.where(q, "students.dept_id")
+
+	if params.StudentNo != "" {
+		q = q.Where("students.student_no = ?", params.StudentNo)
+	}
+	if params.DocType != "" {
+		q = q.Where("document_issuances.doc_type = ?", params.DocType)
+	}
+
+	var rows []IssuanceRow
+	if err := q.Order("document_issuances.issued_at desc").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *issuanceRepo) FindByCode(code string) (*model.DocumentIssuance, error) {
+	var issuance model.DocumentIssuance
+	if err := r.scope.whereStudent(r.db, "student_id").Where("code = ?", code).First(&issuance).Error; err != nil {
+		return nil, err
+	}
+	return &issuance, nil
+}
+
+func (r *issuanceRepo) Create(issuance *model.DocumentIssuance) error {
+	return r.db.Create(issuance).Error
+}
+
+func (r *issuanceRepo) Revoke(id uint, reason string, at time.Time) error {
+	return r.db.Model(&model.DocumentIssuance{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]any{"revoked_at": at, "revoke_reason": reason}).Error
+}
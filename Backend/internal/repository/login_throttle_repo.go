@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// LoginThrottleStore keeps failed login counters, in the database or in process memory
+type LoginThrottleStore interface {
+	Get(key string) (*model.LoginThrottle, error)
+	// AddFailure counts a failure at t and returns the new count. Failures are counted
+	// from 1 again when the previous one is older than window.
+	AddFailure(key string, t time.Time, window time.Duration) (int, error)
+	Block(key string, until time.Time, locked bool) error
+	Reset(key string) error
+}
+
+// NewLoginThrottleStore creates the store selected by LOGIN_THROTTLE_STORE: "memory"
+// keeps counters in this process only, anything else keeps them in login_throttles
+func NewLoginThrottleStore(db *gorm.DB, cfg config.Config) LoginThrottleStore {
+	if cfg.LoginThrottleStore == "memory" {
+		return &memoryThrottleStore{entries: map[string]*model.LoginThrottle{}}
+	}
+	return &dbThrottleStore{db: db}
+}
+
+type dbThrottleStore struct {
+	db *gorm.DB
+}
+
+func (r *dbThrottleStore) Get(key string) (*model.LoginThrottle, error) {
+	var entry model.LoginThrottle
+	err := r.db.Where("key = ?", key).First(&entry).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// AddFailure increments in a single statement so that concurrent attempts all count
+func (r *dbThrottleStore) AddFailure(key string, t time.Time, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.Raw(`
+		INSERT INTO login_throttles (key, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END,
+			locked = CASE WHEN login_throttles.last_failure_at < ? THEN false ELSE login_throttles.locked END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures
+	`, key, t, t.Add(-window), t.Add(-window)).Scan(&failures).Error
+	return failures, err
+}
+
+func (r *dbThrottleStore) Block(key string, until time.Time, locked bool) error {
+	return r.db.Model(&model.LoginThrottle{}).Where("key = ?", key).
+		Updates(map[string]any{"blocked_until": until, "locked": locked}).Error
+}
+
+func (r *dbThrottleStore) Reset(key string) error {
+	return r.db.Where("key = ?", key).Delete(&model.LoginThrottle{}).Error
+}
+
+type memoryThrottleStore struct {
+	mu      sync.Mutex
+	entries map[string]*model.LoginThrottle
+}
+
+func (m *memoryThrottleStore) Get(key string) (*model.LoginThrottle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	cp := *entry
+	return &cp, nil
+}
+
+func (m *memoryThrottleStore) AddFailure(key string, t time.Time, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok || entry.LastFailureAt.Before(t.Add(-window)) {
+		entry = &model.LoginThrottle{Key: key}
+		m.entries[key] = entry
+	}
+	entry.Failures++
+	entry.LastFailureAt = t
+	m.evict(t, window)
+	return entry.Failures, nil
+}
+
+func (m *memoryThrottleStore) Block(key string, until time.Time, locked bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.entries[key]; ok {
+		entry.BlockedUntil = &until
+		entry.Locked = locked
+	}
+	return nil
+}
+
+func (m *memoryThrottleStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// evict drops entries that are neither blocked nor within the failure window, so that
+// the map does not grow with every address ever seen
+func (m *memoryThrottleStore) evict(now time.Time, window time.Duration) {
+	for key, entry := range m.entries {
+		if entry.LastFailureAt.Before(now.Add(-window)) && (entry.BlockedUntil == nil || entry.BlockedUntil.Before(now)) {
+			delete(m.entries, key)
+		}
+	}
+}
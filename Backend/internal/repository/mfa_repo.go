@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MFARepository defines data access for TOTP enrollments and recovery codes
+type MFARepository interface {
+	FindByUser(userID uint) (*model.UserMFA, error)
+	Save(mfa *model.UserMFA) error
+	Enable(userID uint, at time.Time, step int64) (bool, error)
+	AdvanceStep(userID uint, step int64) (bool, error)
+	Delete(userID uint) error
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	UseRecoveryCode(userID uint, hash string, at time.Time) (bool, error)
+	CountRecoveryCodes(userID uint) (int64, error)
+	WithTx(tx *gorm.DB) MFARepository
+}
+
+type mfaRepo struct {
+	db *gorm.DB
+}
+
+// NewMFARepository creates a new MFARepository
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepo{db: db}
+}
+
+func (r *mfaRepo) WithTx(tx *gorm.DB) MFARepository {
+	return &mfaRepo{db: tx}
+}
+
+// FindByUser returns the user's enrollment, or nil when there is none
+func (r *mfaRepo) FindByUser(userID uint) (*model.UserMFA, error) {
+	var mfa model.UserMFA
+	err := r.db.Where("user_id = ?", userID).First(&mfa).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// Save creates the enrollment of mfa.UserID, or replaces the one it has
+func (r *mfaRepo) Save(mfa *model.UserMFA) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "enabled_at", "last_step", "updated_at"}),
+	}).Create(mfa).Error
+}
+
+// Enable activates a pending enrollment with the step of its first code; it reports
+// false when the enrollment is missing or already enabled
+func (r *mfaRepo) Enable(userID uint, at time.Time, step int64) (bool, error) {
+	res := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND enabled_at IS NULL", userID).
+		Updates(map[string]any{"enabled_at": at, "last_step": step})
+	return res.RowsAffected > 0, res.Error
+}
+
+// AdvanceStep records the step of an accepted code; it reports false when that step
+// or a later one was already used, which refuses a replayed code
+func (r *mfaRepo) AdvanceStep(userID uint, step int64) (bool, error) {
+	res := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND last_step < ?", userID, step).
+		Update("last_step", step)
+	return res.RowsAffected > 0, res.Error
+}
+
+// Delete removes the enrollment and the recovery codes of a user
+func (r *mfaRepo) Delete(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.UserMFA{}).Error
+	})
+}
+
+// ReplaceRecoveryCodes discards the user's recovery codes, used or not, for new ones
+func (r *mfaRepo) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]model.MFARecoveryCode, len(hashes))
+	for i, h := range hashes {
+		codes[i] = model.MFARecoveryCode{UserID: userID, CodeHash: h}
+	}
+	return r.db.Create(&codes).Error
+}
+
+// UseRecoveryCode marks an unused code of the user used; it reports false when there is none
+func (r *mfaRepo) UseRecoveryCode(userID uint, hash string, at time.Time) (bool, error) {
+	res := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (r *mfaRepo) CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newMockDB returns a gorm connection backed by sqlmock, expecting statements in order
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+var advanceStepSQL = regexp.QuoteMeta(`UPDATE "user_mfa" SET "last_step"=$1,"updated_at"=$2 WHERE user_id = $3 AND last_step < $4`)
+
+func TestAdvanceStep(t *testing.T) {
+	tests := []struct {
+		name    string
+		updated int64
+		want    bool
+	}{
+		{"later step", 1, true},
+		// The step or a later one was used already: the code is a replay
+		{"replayed step", 0, false},
+	}
+	for _, tt := range tests {
+		db, mock := newMockDB(t)
+		mock.ExpectExec(advanceStepSQL).
+			WithArgs(int64(41152263), sqlmock.AnyArg(), uint(7), int64(41152263)).
+			WillReturnResult(sqlmock.NewResult(0, tt.updated))
+
+		fresh, err := NewMFARepository(db).AdvanceStep(7, 41152263)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if fresh != tt.want {
+			t.Errorf("%s: AdvanceStep = %v, want %v", tt.name, fresh, tt.want)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OfferingRow represents a course offering with catalog, term and teacher info
+type OfferingRow struct {
+	model.CourseOffering
+	CourseNo    string `json:"course_no"`
+	CourseName  string `json:"course_name"`
+	Hours       int    `json:"hours"`
+	Credits     int    `json:"credits"`
+	TermCode    string `json:"term_code"`
+	TermName    string `json:"term_name"`
+	TeacherNo   string `json:"teacher_no"`
+	TeacherName string `json:"teacher_name"`
+}
+
+// OfferingMeetingRow represents a class meeting with its offering and course identity
+type OfferingMeetingRow struct {
+	model.ClassMeeting
+	CourseID   uint   `json:"course_id"`
+	CourseNo   string `json:"course_no"`
+	CourseName string `json:"course_name"`
+	SectionNo  string `json:"section_no"`
+}
+
+// OfferingQueryParams represents course offering query parameters
+type OfferingQueryParams struct {
+	CourseNo    string
+	Name        string
+	TeacherName string
+	TermCode    string
+	Page        int
+	PageSize    int
+}
+
+// OfferingRepository defines the interface for course offering data access
+type OfferingRepository interface {
+	FindAll(params OfferingQueryParams) ([]OfferingRow, int64, error)
+	FindByID(id uint) (*model.CourseOffering, error)
+	FindRowsByIDs(ids []uint) ([]OfferingRow, error)
+	FindRowsByTermAndCourseNos(termID uint, courseNos []string) ([]OfferingRow, error)
+	FindBySection(courseID, termID uint, sectionNo string) (*model.CourseOffering, error)
+	FindEnrolled(studentID, courseID uint, termCode string) (*model.CourseOffering, error)
+	Create(offering *model.CourseOffering) error
+	Update(offering *model.CourseOffering) error
+	Delete(id uint) error
+	CountEnrollments(offeringID uint) (int64, error)
+	CountGrades(offeringID uint) (int64, error)
+	SetGradeStatus(id uint, from, to string) (bool, error)
+	LockGradeStatuses(ids []uint) (map[uint]string, error)
+	FindMeetingsByOfferingIDs(offeringIDs []uint) ([]model.ClassMeeting, error)
+	FindMeetingRowsByOfferingIDs(offeringIDs []uint) ([]OfferingMeetingRow, error)
+	ReplaceMeetings(offeringID uint, meetings []model.ClassMeeting) error
+	WithTx(tx *gorm.DB) OfferingRepository
+	WithScope(scope Scope) OfferingRepository
+}
+
+type offeringRepo struct {
+	db    *gorm.DB
+	scope Scope
+}
+
+// NewOfferingRepository creates a new OfferingRepository
+func NewOfferingRepository(db *gorm.DB) OfferingRepository {
+	return &offeringRepo{db: db}
+}
+
+func (r *offeringRepo) WithTx(tx *gorm.DB) OfferingRepository {
+	return &offeringRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the offerings of the courses of the scope's department
+func (r *offeringRepo) WithScope(scope Scope) OfferingRepository {
+	return &offeringRepo{db: r.db, scope: scope}
+}
+
+func (r *offeringRepo) rowsQuery() *gorm.DB {
+	q := r.db.Table("course_offerings").
+		Select(`
+			course_offerings.*,
+			courses.course_no, courses.name AS course_name, courses.hours, courses.credits,
+			terms.term_code, terms.name AS term_name,
+			staff.staff_no AS teacher_no, staff.name AS teacher_name
+		`).
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
+		Joins("JOIN staff ON staff.id = course_offerings.teacher_id")
+	return r.scope.where(q, "courses.dept_id")
+}
+
+func (r *offeringRepo) FindAll(params OfferingQueryParams) ([]OfferingRow, int64, error) {
+	q := r.rowsQuery()
+
+	if params.CourseNo != "" {
+		q = q.Where("courses.course_no = ?", params.CourseNo)
+	}
+	if params.Name != "" {
+		q = q.Where("courses.name ILIKE ?", "%"+params.Name+"%")
+	}
+	if params.TeacherName != "" {
+		q = q.Where("staff.name ILIKE ?", "%"+params.TeacherName+"%")
+	}
+	if params.TermCode != "" {
+		q = q.Where("terms.term_code = ?", params.TermCode)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if params.Page > 0 && params.PageSize > 0 {
+		offset := (params.Page - 1) * params.PageSize
+		q = q.Offset(offset).Limit(params.PageSize)
+	}
+
+	var items []OfferingRow
+	if err := q.Order("terms.start_date desc NULLS LAST, terms.term_code desc, courses.course_no asc, course_offerings.section_no asc").
+		Scan(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (r *offeringRepo) FindByID(id uint) (*model.CourseOffering, error) {
+	var offering model.CourseOffering
+	if err := r.scope.whereCourse(r.db, "course_id").First(&offering, id).Error; err != nil {
+		return nil, err
+	}
+	return &offering, nil
+}
+
+func (r *offeringRepo) FindRowsByIDs(ids []uint) ([]OfferingRow, error) {
+	var rows []OfferingRow
+	if len(ids) == 0 {
+		return rows, nil
+	}
+	if err := r.rowsQuery().
+		Where("course_offerings.id IN ?", ids).
+		Order("courses.course_no asc, course_offerings.section_no asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *offeringRepo) FindRowsByTermAndCourseNos(termID uint, courseNos []string) ([]OfferingRow, error) {
+	var rows []OfferingRow
+	if len(courseNos) == 0 {
+		return rows, nil
+	}
+	if err := r.rowsQuery().
+		Where("course_offerings.term_id = ? AND courses.course_no IN ?", termID, courseNos).
+		Order("courses.course_no asc, course_offerings.section_no asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *offeringRepo) FindBySection(courseID, termID uint, sectionNo string) (*model.CourseOffering, error) {
+	var offering model.CourseOffering
+	if err := r.db.Where("course_id = ? AND term_id = ? AND section_no = ?", courseID, termID, sectionNo).
+		First(&offering).Error; err != nil {
+		return nil, err
+	}
+	return &offering, nil
+}
+
+// FindEnrolled returns the offering of a course the student is enrolled in,
+// restricted to termCode when given and otherwise the term that started last.
+func (r *offeringRepo) FindEnrolled(studentID, courseID uint, termCode string) (*model.CourseOffering, error) {
+	q := r.db.Model(&model.CourseOffering{}).
+		Select("course_offerings.*").
+		Joins("JOIN enrollments ON enrollments.offering_id = course_offerings.id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
+		Where("enrollments.student_id = ? AND course_offerings.course_id = ?", studentID, courseID)
+	if termCode != "" {
+		q = q.Where("terms.term_code = ?", termCode)
+	}
+
+	var offering model.CourseOffering
+	if err := q.Order("terms.start_date desc NULLS LAST, terms.term_code desc").First(&offering).Error; err != nil {
+		return nil, err
+	}
+	return &offering, nil
+}
+
+func (r *offeringRepo) Create(offering *model.CourseOffering) error {
+	if err := r.scope.check(r.db.Model(&model.Course{}).Where("id = ?", offering.CourseID), "dept_id"); err != nil {
+		return err
+	}
+	return r.db.Create(offering).Error
+}
+
+func (r *offeringRepo) Update(offering *model.CourseOffering) error {
+	if err := r.scope.check(r.db.Model(&model.Course{}).Where("id = ?", offering.CourseID), "dept_id"); err != nil {
+		return err
+	}
+	return r.db.Save(offering).Error
+}
+
+func (r *offeringRepo) Delete(id uint) error {
+	return r.scope.whereCourse(r.db, "course_id").Delete(&model.CourseOffering{}, id).Error
+}
+
+func (r *offeringRepo) CountEnrollments(offeringID uint) (int64, error) {
+	var count int64
+	err := r.db.Table("enrollments").Where("offering_id = ?", offeringID).Count(&count).Error
+	return count, err
+}
+
+func (r *offeringRepo) CountGrades(offeringID uint) (int64, error) {
+	var count int64
+	err := r.db.Table("grades").Where("offering_id = ?", offeringID).Count(&count).Error
+	return count, err
+}
+
+// SetGradeStatus moves an offering from one grade status to another; it reports false
+// when the offering was no longer in the from status
+func (r *offeringRepo) SetGradeStatus(id uint, from, to string) (bool, error) {
+	res := r.db.Model(&model.CourseOffering{}).
+		Where("id = ? AND grade_status = ?", id, from).
+		Update("grade_status", to)
+	return res.RowsAffected > 0, res.Error
+}
+
+// LockGradeStatuses takes row locks on the offerings (in id order to avoid deadlocks) and
+// returns their grade status, which no transition can change until the transaction ends
+func (r *offeringRepo) LockGradeStatuses(ids []uint) (map[uint]string, error) {
+	var rows []model.CourseOffering
+	if err := r.db.Select("id, grade_status").
+		Where("id IN ?", ids).
+		Order("id asc").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	statuses := make(map[uint]string, len(rows))
+	for _, o := range rows {
+		statuses[o.ID] = o.GradeStatus
+	}
+	return statuses, nil
+}
+
+func (r *offeringRepo) FindMeetingsByOfferingIDs(offeringIDs []uint) ([]model.ClassMeeting, error) {
+	var meetings []model.ClassMeeting
+	if len(offeringIDs) == 0 {
+		return meetings, nil
+	}
+	if err := r.db.Where("offering_id IN ?", offeringIDs).
+		Order("offering_id asc, weekday asc, start_period asc").
+		Find(&meetings).Error; err != nil {
+		return nil, err
+	}
+	return meetings, nil
+}
+
+func (r *offeringRepo) FindMeetingRowsByOfferingIDs(offeringIDs []uint) ([]OfferingMeetingRow, error) {
+	var rows []OfferingMeetingRow
+	if len(offeringIDs) == 0 {
+		return rows, nil
+	}
+	if err := r.db.Table("class_meetings").
+		Select(`
+			class_meetings.*, course_offerings.course_id, course_offerings.section_no,
+			courses.course_no, courses.name AS course_name
+		`).
+		Joins("JOIN course_offerings ON course_offerings.id = class_meetings.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Where("class_meetings.offering_id IN ?", offeringIDs).
+		Order("courses.course_no asc, class_meetings.weekday asc, class_meetings.start_period asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *offeringRepo) ReplaceMeetings(offeringID uint, meetings []model.ClassMeeting) error {
+	if err := r.db.Where("offering_id = ?", offeringID).Delete(&model.ClassMeeting{}).Error; err != nil {
+		return err
+	}
+	if len(meetings) == 0 {
+		return nil
+	}
+	for i := range meetings {
+		meetings[i].ID = 0
+		meetings[i].OfferingID = offeringID
+	}
+	return r.db.Create(&meetings).Error
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// A retake in 2024-FALL after 2024-SPRING must resolve to the fall offering, although
+// the spring term code sorts after it
+func TestFindEnrolledLatestTermByStartDate(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`ORDER BY terms.start_date desc NULLS LAST, terms.term_code desc`)).
+		WithArgs(uint(3), uint(7), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "course_id"}).AddRow(12, 7))
+
+	offering, err := NewOfferingRepository(db).FindEnrolled(3, 7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offering.ID != 12 {
+		t.Errorf("FindEnrolled = offering %d, want 12", offering.ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// PrerequisiteRow represents a prerequisite edge with course identities
+type PrerequisiteRow struct {
+	CourseID         uint   `json:"course_id"`
+	GroupNo          int    `json:"group_no"`
+	PrereqCourseID   uint   `json:"prereq_course_id"`
+	PrereqCourseNo   string `json:"prereq_course_no"`
+	PrereqCourseName string `json:"prereq_course_name"`
+}
+
+// OverrideQueryParams represents query parameters for prerequisite overrides
+type OverrideQueryParams struct {
+	StudentNo string
+	CourseNo  string
+}
+
+// OverrideRow represents a prerequisite override with related info
+type OverrideRow struct {
+	ID          uint      `json:"id"`
+	StudentID   uint      `json:"student_id"`
+	StudentNo   string    `json:"student_no"`
+	StudentName string    `json:"student_name"`
+	CourseID    uint      `json:"course_id"`
+	CourseNo    string    `json:"course_no"`
+	CourseName  string    `json:"course_name"`
+	Reason      string    `json:"reason"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PrerequisiteRepository defines the interface for prerequisite data access
+type PrerequisiteRepository interface {
+	FindByCourseIDs(courseIDs []uint) ([]PrerequisiteRow, error)
+	FindAllEdges() ([]model.CoursePrerequisite, error)
+	Replace(courseID uint, edges []model.CoursePrerequisite) error
+	FindOverrides(params OverrideQueryParams) ([]OverrideRow, error)
+	FindOverrideByID(id uint) (*model.PrerequisiteOverride, error)
+	FindOverriddenCourseIDs(studentID uint, courseIDs []uint) ([]uint, error)
+	CreateOverride(override *model.PrerequisiteOverride) error
+	DeleteOverride(id uint) error
+	WithTx(tx *gorm.DB) PrerequisiteRepository
+}
+
+type prerequisiteRepo struct {
+	db *gorm.DB
+}
+
+// NewPrerequisiteRepository creates a new PrerequisiteRepository
+func NewPrerequisiteRepository(db *gorm.DB) PrerequisiteRepository {
+	return &prerequisiteRepo{db: db}
+}
+
+func (r *prerequisiteRepo) WithTx(tx *gorm.DB) PrerequisiteRepository {
+	return &prerequisiteRepo{db: tx}
+}
+
+func (r *prerequisiteRepo) FindByCourseIDs(courseIDs []uint) ([]PrerequisiteRow, error) {
+	var rows []PrerequisiteRow
+	if len(courseIDs) == 0 {
+		return rows, nil
+	}
+	if err := r.db.Table("course_prerequisites").
+		Select(`
+			course_prerequisites.course_id, course_prerequisites.group_no, course_prerequisites.prereq_course_id,
+			courses.course_no AS prereq_course_no, courses.name AS prereq_course_name
+		`).
+		Joins("JOIN courses ON courses.id = course_prerequisites.prereq_course_id").
+		Where("course_prerequisites.course_id IN ?", courseIDs).
+		Order("course_prerequisites.course_id asc, course_prerequisites.group_no asc, courses.course_no asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *prerequisiteRepo) FindAllEdges() ([]model.CoursePrerequisite, error) {
+	var edges []model.CoursePrerequisite
+	if err := r.db.Find(&edges).Error; err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
+
+func (r *prerequisiteRepo) Replace(courseID uint, edges []model.CoursePrerequisite) error {
+	if err := r.db.Where("course_id = ?", courseID).Delete(&model.CoursePrerequisite{}).Error; err != nil {
+		return err
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	for i := range edges {
+		edges[i].ID = 0
+		edges[i].CourseID = courseID
+	}
+	return r.db.Create(&edges).Error
+}
+
+func (r *prerequisiteRepo) FindOverrides(params OverrideQueryParams) ([]OverrideRow, error) {
+	q := r.db.Table("prerequisite_overrides").
+		Select(`
+			prerequisite_overrides.id, prerequisite_overrides.student_id, prerequisite_overrides.course_id,
+			prerequisite_overrides.reason, prerequisite_overrides.created_by, prerequisite_overrides.created_at,
+			students.student_no, students.name AS student_name,
+			courses.course_no, courses.name AS course_name
+		`).
+		Joins("JOIN students ON students.id = prerequisite_overrides.student_id").
+		Joins("JOIN courses ON courses.id = prerequisite_overrides.course_id")
+
+	if params.StudentNo != "" {
+		q = q.Where("students.student_no = ?", params.StudentNo)
+	}
+	if params.CourseNo != "" {
+		q = q.Where("courses.course_no = ?", params.CourseNo)
+	}
+
+	var rows []OverrideRow
+	if err := q.Order("prerequisite_overrides.created_at desc").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *prerequisiteRepo) FindOverrideByID(id uint) (*model.PrerequisiteOverride, error) {
+	var override model.PrerequisiteOverride
+	if err := r.db.First(&override, id).Error; err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (r *prerequisiteRepo) FindOverriddenCourseIDs(studentID uint, courseIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(courseIDs) == 0 {
+		return ids, nil
+	}
+	if err := r.db.Model(&model.PrerequisiteOverride{}).
+		Where("student_id = ? AND course_id IN ?", studentID, courseIDs).
+		Pluck("course_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *prerequisiteRepo) CreateOverride(override *model.PrerequisiteOverride) error {
+	return r.db.Create(override).Error
+}
+
+func (r *prerequisiteRepo) DeleteOverride(id uint) error {
+	return r.db.Delete(&model.PrerequisiteOverride{}, id).Error
+}
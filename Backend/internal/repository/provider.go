@@ -8,9 +8,22 @@ var ProviderSet = wire.NewSet(
 	NewStudentRepository,
 	NewStaffRepository,
 	NewCourseRepository,
+	NewOfferingRepository,
+	NewPrerequisiteRepository,
 	NewTermRepository,
 	NewEnrollmentRepository,
+	NewWaitlistRepository,
 	NewGradeRepository,
+	NewGradingSchemeRepository,
+	NewGradeWorkflowRepository,
+	NewGradeAuditRepository,
+	NewAuditRepository,
 	NewUserRepository,
+	NewSessionRepository,
+	NewLoginThrottleStore,
+	NewMFARepository,
+	NewIdentityRepository,
+	NewRoleRepository,
 	NewReportRepository,
+	NewIssuanceRepository,
 )
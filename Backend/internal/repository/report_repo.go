@@ -10,14 +10,22 @@ type ReportQueryParams struct {
 	CourseName  string
 	TeacherName string
 	DeptNo      string
+	TermCode    string
+	Teaching    Teaching
 }
 
 // RosterRow represents a row in the roster report
 type RosterRow struct {
+	OfferingID  uint     `json:"offering_id"`
+	CourseID    uint     `json:"course_id"`
+	StudentID   uint     `json:"student_id"`
+	TermCode    string   `json:"term_code"`
+	SectionNo   string   `json:"section_no"`
 	CourseNo    string   `json:"course_no"`
 	CourseName  string   `json:"course_name"`
 	TeacherNo   string   `json:"teacher_no"`
 	TeacherName string   `json:"teacher_name"`
+	TeacherDept string   `json:"teacher_dept"`
 	Hours       int      `json:"hours"`
 	Credits     int      `json:"credits"`
 	ClassTime   string   `json:"class_time"`
@@ -35,10 +43,13 @@ type RosterRow struct {
 // ReportRepository defines the interface for report data access
 type ReportRepository interface {
 	GetRosterData(params ReportQueryParams, withGrades bool) ([]RosterRow, error)
+	EachRosterRow(params ReportQueryParams, withGrades bool, fn func(*RosterRow) error) error
+	WithScope(scope Scope) ReportRepository
 }
 
 type reportRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewReportRepository creates a new ReportRepository
@@ -46,11 +57,19 @@ func NewReportRepository(db *gorm.DB) ReportRepository {
 	return &reportRepo{db: db}
 }
 
-func (r *reportRepo) GetRosterData(params ReportQueryParams, withGrades bool) ([]RosterRow, error) {
+// WithScope limits the rosters to the students of the scope's department
+func (r *reportRepo) WithScope(scope Scope) ReportRepository {
+	return &reportRepo{db: r.db, scope: scope}
+}
+
+func (r *reportRepo) rosterQuery(params ReportQueryParams, withGrades bool) *gorm.DB {
 	selectCols := `
+		course_offerings.id AS offering_id, course_offerings.course_id, students.id AS student_id,
+		terms.term_code, course_offerings.section_no,
 		courses.course_no, courses.name AS course_name,
-		staff.staff_no AS teacher_no, staff.name AS teacher_name,
-		courses.hours, courses.credits, courses.class_time, courses.class_location, courses.exam_time,
+		staff.staff_no AS teacher_no, staff.name AS teacher_name, teacher_dept.name AS teacher_dept,
+		courses.hours, courses.credits,
+		course_offerings.class_time, course_offerings.class_location, course_offerings.exam_time,
 		departments.dept_no,
 		students.student_no, students.name AS student_name, students.gender
 	`
@@ -63,12 +82,17 @@ func (r *reportRepo) GetRosterData(params ReportQueryParams, withGrades bool) ([
 	q := r.db.Table("enrollments").
 		Select(selectCols).
 		Joins("JOIN students ON students.id = enrollments.student_id").
-		Joins("JOIN courses ON courses.id = enrollments.course_id").
-		Joins("JOIN staff ON staff.id = courses.teacher_id").
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id").
+		Joins("JOIN staff ON staff.id = course_offerings.teacher_id").
+		Joins("JOIN departments AS teacher_dept ON teacher_dept.id = staff.dept_id").
 		Joins("JOIN departments ON departments.id = students.dept_id")
+	q = r.scope.where(q, "students.dept_id")
+	q = params.Teaching.where(q, "course_offerings.teacher_id")
 
 	if withGrades {
-		q = q.Joins("LEFT JOIN grades ON grades.student_id = students.id AND grades.course_id = courses.id")
+		q = q.Joins("LEFT JOIN grades ON grades.student_id = students.id AND grades.offering_id = course_offerings.id")
 	}
 
 	if params.CourseNo != "" {
@@ -80,15 +104,25 @@ func (r *reportRepo) GetRosterData(params ReportQueryParams, withGrades bool) ([
 	if params.TeacherName != "" {
 		q = q.Where("staff.name ILIKE ?", "%"+params.TeacherName+"%")
 	}
+	if params.TermCode != "" {
+		q = q.Where("terms.term_code = ?", params.TermCode)
+	}
 	if params.DeptNo != "" {
 		// PRD: 按系号输出"本系所有教师担任的课程"
-		q = q.Joins("JOIN departments AS teacher_dept ON teacher_dept.id = staff.dept_id").
-			Where("teacher_dept.dept_no = ?", params.DeptNo)
+		q = q.Where("teacher_dept.dept_no = ?", params.DeptNo)
 	}
+	return q.Order("courses.course_no asc, terms.start_date desc NULLS LAST, terms.term_code desc, course_offerings.section_no asc, students.student_no asc")
+}
 
+func (r *reportRepo) GetRosterData(params ReportQueryParams, withGrades bool) ([]RosterRow, error) {
 	var rows []RosterRow
-	if err := q.Order("courses.course_no asc, students.student_no asc").Scan(&rows).Error; err != nil {
+	if err := r.rosterQuery(params, withGrades).Scan(&rows).Error; err != nil {
 		return nil, err
 	}
 	return rows, nil
 }
+
+// EachRosterRow streams the rows of GetRosterData; rows of one offering are adjacent
+func (r *reportRepo) EachRosterRow(params ReportQueryParams, withGrades bool, fn func(*RosterRow) error) error {
+	return scanEach(r.rosterQuery(params, withGrades), fn)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// RoleRepository defines data access for roles and their permissions
+type RoleRepository interface {
+	FindAll() ([]model.Role, error)
+	FindByID(id uint) (*model.Role, error)
+	Create(role *model.Role) error
+	Update(role *model.Role) error
+	Delete(id uint) error
+	CountUsers(name string) (int64, error)
+}
+
+type roleRepo struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new RoleRepository
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepo{db: db}
+}
+
+func (r *roleRepo) FindAll() ([]model.Role, error) {
+	var roles []model.Role
+	if err := r.db.Order("id asc").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepo) FindByID(id uint) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepo) Create(role *model.Role) error {
+	return r.db.Create(role).Error
+}
+
+// Update saves the label and permissions; the name stays as created
+func (r *roleRepo) Update(role *model.Role) error {
+	return r.db.Model(role).Select("label", "permissions", "updated_at").Updates(role).Error
+}
+
+func (r *roleRepo) Delete(id uint) error {
+	return r.db.Delete(&model.Role{}, id).Error
+}
+
+// CountUsers counts the users holding the role
+func (r *roleRepo) CountUsers(name string) (int64, error) {
+	var n int64
+	err := r.db.Model(&model.User{}).Where("role = ?", name).Count(&n).Error
+	return n, err
+}
@@ -0,0 +1,24 @@
+package repository
+
+import "gorm.io/gorm"
+
+// scanEach runs q and hands every row to fn as it is read, so large result sets
+// can be streamed without loading them into a slice
+func scanEach[T any](q *gorm.DB, fn func(*T) error) error {
+	rows, err := q.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item T
+		if err := q.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrOutOfScope is returned when a scoped repository is asked to write a record
+// of a department outside its scope
+var ErrOutOfScope = errors.New("record outside the department scope")
+
+// Scope limits a repository to the records of one department. The zero Scope
+// covers every department.
+type Scope struct {
+	DeptID uint
+}
+
+// All reports whether the scope covers every department
+func (s Scope) All() bool {
+	return s.DeptID == 0
+}
+
+// Allows reports whether a record of the department is within the scope
+func (s Scope) Allows(deptID uint) bool {
+	return s.DeptID == 0 || s.DeptID == deptID
+}
+
+// AllowsPtr is Allows for an optional department; records without one are
+// only within the scope that covers every department
+func (s Scope) AllowsPtr(deptID *uint) bool {
+	if deptID == nil {
+		return s.All()
+	}
+	return s.Allows(*deptID)
+}
+
+// where limits q to the records whose department column col is within the scope
+func (s Scope) where(q *gorm.DB, col string) *gorm.DB {
+	if s.All() {
+		return q
+	}
+	return q.Where(col+" = ?", s.DeptID)
+}
+
+// whereCourse limits q to the records whose course, in column col, is one of the
+// scope's department
+func (s Scope) whereCourse(q *gorm.DB, col string) *gorm.DB {
+	if s.All() {
+		return q
+	}
+	return q.Where(col+" IN (SELECT id FROM courses WHERE dept_id = ?)", s.DeptID)
+}
+
+// whereStudent limits q to the records whose student, in column col, is one of the
+// scope's department
+func (s Scope) whereStudent(q *gorm.DB, col string) *gorm.DB {
+	if s.All() {
+		return q
+	}
+	return q.Where(col+" IN (SELECT id FROM students WHERE dept_id = ?)", s.DeptID)
+}
+
+// check runs a count of q limited to the scope and returns ErrOutOfScope when
+// it finds nothing, so a write can confirm the record it replaces is in scope
+func (s Scope) check(q *gorm.DB, col string) error {
+	if s.All() {
+		return nil
+	}
+	var n int64
+	if err := s.where(q, col).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOutOfScope
+	}
+	return nil
+}
+
+// Teaching limits rows to the offerings one staff member teaches or, with Dept, to those
+// taught by anyone of that staff member's department. The zero Teaching limits nothing.
+type Teaching struct {
+	StaffID uint
+	Dept    bool
+}
+
+// where limits q to the rows whose offering teacher, in column col, is within t
+func (t Teaching) where(q *gorm.DB, col string) *gorm.DB {
+	switch {
+	case t.StaffID == 0:
+		return q
+	case t.Dept:
+		return q.Where(col+" IN (SELECT id FROM staff WHERE dept_id = (SELECT dept_id FROM staff WHERE id = ?))", t.StaffID)
+	default:
+		return q.Where(col+" = ?", t.StaffID)
+	}
+}
+
+// whereStudent limits q to the rows whose student, in column col, is enrolled in an
+// offering within t
+func (t Teaching) whereStudent(q *gorm.DB, col string) *gorm.DB {
+	if t.StaffID == 0 {
+		return q
+	}
+	enrolled := q.Session(&gorm.Session{NewDB: true}).Table("enrollments").
+		Select("enrollments.student_id").
+		Joins("JOIN course_offerings ON course_offerings.id = enrollments.offering_id")
+	return q.Where(col+" IN (?)", t.where(enrolled, "course_offerings.teacher_id"))
+}
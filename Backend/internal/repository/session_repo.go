@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// SessionRepository defines data access for login sessions and their refresh tokens
+type SessionRepository interface {
+	CreateSession(session *model.AuthSession) error
+	FindSession(id uint) (*model.AuthSession, error)
+	IsActive(id, userID uint) (bool, error)
+	TouchSession(id uint, at time.Time) error
+	Revoke(id uint, reason string, at time.Time) (bool, error)
+	RevokeByUser(userID uint, reason string, at time.Time) (int64, error)
+	CreateRefreshToken(token *model.RefreshToken) error
+	FindRefreshToken(hash string) (*model.RefreshToken, error)
+	UseRefreshToken(id uint, at time.Time) (bool, error)
+	WithTx(tx *gorm.DB) SessionRepository
+}
+
+type sessionRepo struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new SessionRepository
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepo{db: db}
+}
+
+func (r *sessionRepo) WithTx(tx *gorm.DB) SessionRepository {
+	return &sessionRepo{db: tx}
+}
+
+func (r *sessionRepo) CreateSession(session *model.AuthSession) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepo) FindSession(id uint) (*model.AuthSession, error) {
+	var session model.AuthSession
+	if err := r.db.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// IsActive reports whether a session exists for the user and has not been revoked
+func (r *sessionRepo) IsActive(id, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.AuthSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *sessionRepo) TouchSession(id uint, at time.Time) error {
+	return r.db.Model(&model.AuthSession{}).Where("id = ?", id).Update("last_used_at", at).Error
+}
+
+// Revoke ends an active session; it reports false when it was already revoked
+func (r *sessionRepo) Revoke(id uint, reason string, at time.Time) (bool, error) {
+	res := r.db.Model(&model.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]any{"revoked_at": at, "revoke_reason": reason})
+	return res.RowsAffected > 0, res.Error
+}
+
+// RevokeByUser ends every active session of a user
+func (r *sessionRepo) RevokeByUser(userID uint, reason string, at time.Time) (int64, error) {
+	res := r.db.Model(&model.AuthSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]any{"revoked_at": at, "revoke_reason": reason})
+	return res.RowsAffected, res.Error
+}
+
+func (r *sessionRepo) CreateRefreshToken(token *model.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *sessionRepo) FindRefreshToken(hash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UseRefreshToken marks a token as rotated; it reports false when it was used already
+func (r *sessionRepo) UseRefreshToken(id uint, at time.Time) (bool, error) {
+	res := r.db.Model(&model.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	return res.RowsAffected > 0, res.Error
+}
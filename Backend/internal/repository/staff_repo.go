@@ -24,16 +24,21 @@ type StaffQueryParams struct {
 type StaffRepository interface {
 	FindAll(staffNo, name, deptNo string) ([]StaffWithDept, error)
 	FindAllPaginated(params StaffQueryParams) ([]StaffWithDept, int64, error)
+	EachWithDept(staffNo, name, deptNo string, fn func(*StaffWithDept) error) error
 	FindByID(id uint) (*model.Staff, error)
 	FindByStaffNo(staffNo string) (*model.Staff, error)
+	FindWithoutAccount(ids []uint) ([]model.Staff, error)
 	Create(staff *model.Staff) error
 	Update(staff *model.Staff) error
 	Delete(id uint) error
 	CountCourses(staffID uint) (int64, error)
+	WithTx(tx *gorm.DB) StaffRepository
+	WithScope(scope Scope) StaffRepository
 }
 
 type staffRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewStaffRepository creates a new StaffRepository
@@ -41,10 +46,20 @@ func NewStaffRepository(db *gorm.DB) StaffRepository {
 	return &staffRepo{db: db}
 }
 
-func (r *staffRepo) FindAll(staffNo, name, deptNo string) ([]StaffWithDept, error) {
+func (r *staffRepo) WithTx(tx *gorm.DB) StaffRepository {
+	return &staffRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the staff of the scope's department
+func (r *staffRepo) WithScope(scope Scope) StaffRepository {
+	return &staffRepo{db: r.db, scope: scope}
+}
+
+func (r *staffRepo) listQuery(staffNo, name, deptNo string) *gorm.DB {
 	q := r.db.Table("staff").
 		Select("staff.*, departments.dept_no AS dept_no").
 		Joins("JOIN departments ON departments.id = staff.dept_id")
+	q = r.scope.where(q, "staff.dept_id")
 
 	if staffNo != "" {
 		q = q.Where("staff.staff_no = ?", staffNo)
@@ -55,18 +70,26 @@ func (r *staffRepo) FindAll(staffNo, name, deptNo string) ([]StaffWithDept, erro
 	if deptNo != "" {
 		q = q.Where("departments.dept_no = ?", deptNo)
 	}
+	return q.Order("staff.staff_no asc")
+}
 
+func (r *staffRepo) FindAll(staffNo, name, deptNo string) ([]StaffWithDept, error) {
 	var items []StaffWithDept
-	if err := q.Order("staff.staff_no asc").Scan(&items).Error; err != nil {
+	if err := r.listQuery(staffNo, name, deptNo).Scan(&items).Error; err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
+func (r *staffRepo) EachWithDept(staffNo, name, deptNo string, fn func(*StaffWithDept) error) error {
+	return scanEach(r.listQuery(staffNo, name, deptNo), fn)
+}
+
 func (r *staffRepo) FindAllPaginated(params StaffQueryParams) ([]StaffWithDept, int64, error) {
 	q := r.db.Table("staff").
 		Select("staff.*, departments.dept_no AS dept_no").
 		Joins("JOIN departments ON departments.id = staff.dept_id")
+	q = r.scope.where(q, "staff.dept_id")
 
 	if params.StaffNo != "" {
 		q = q.Where("staff.staff_no = ?", params.StaffNo)
@@ -97,7 +120,7 @@ func (r *staffRepo) FindAllPaginated(params StaffQueryParams) ([]StaffWithDept,
 
 func (r *staffRepo) FindByID(id uint) (*model.Staff, error) {
 	var staff model.Staff
-	if err := r.db.First(&staff, id).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").First(&staff, id).Error; err != nil {
 		return nil, err
 	}
 	return &staff, nil
@@ -105,26 +128,52 @@ func (r *staffRepo) FindByID(id uint) (*model.Staff, error) {
 
 func (r *staffRepo) FindByStaffNo(staffNo string) (*model.Staff, error) {
 	var staff model.Staff
-	if err := r.db.Where("staff_no = ?", staffNo).First(&staff).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").Where("staff_no = ?", staffNo).First(&staff).Error; err != nil {
 		return nil, err
 	}
 	return &staff, nil
 }
 
+// FindWithoutAccount returns the staff no account is linked to, limited to ids when
+// they are given, in staff number order
+func (r *staffRepo) FindWithoutAccount(ids []uint) ([]model.Staff, error) {
+	q := r.scope.where(r.db, "dept_id").
+		Where("NOT EXISTS (SELECT 1 FROM users WHERE users.staff_id = staff.id)")
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	var staff []model.Staff
+	if err := q.Order("staff_no asc").Find(&staff).Error; err != nil {
+		return nil, err
+	}
+	return staff, nil
+}
+
 func (r *staffRepo) Create(staff *model.Staff) error {
+	if !r.scope.Allows(staff.DeptID) {
+		return ErrOutOfScope
+	}
 	return r.db.Create(staff).Error
 }
 
+// Update saves the staff member; a scoped repository neither edits the staff of
+// another department nor moves a member out of its own
 func (r *staffRepo) Update(staff *model.Staff) error {
+	if !r.scope.Allows(staff.DeptID) {
+		return ErrOutOfScope
+	}
+	if err := r.scope.check(r.db.Model(&model.Staff{}).Where("id = ?", staff.ID), "dept_id"); err != nil {
+		return err
+	}
 	return r.db.Save(staff).Error
 }
 
 func (r *staffRepo) Delete(id uint) error {
-	return r.db.Delete(&model.Staff{}, id).Error
+	return r.scope.where(r.db, "dept_id").Delete(&model.Staff{}, id).Error
 }
 
 func (r *staffRepo) CountCourses(staffID uint) (int64, error) {
 	var count int64
-	err := r.db.Model(&model.Course{}).Where("teacher_id = ?", staffID).Count(&count).Error
+	err := r.db.Model(&model.CourseOffering{}).Where("teacher_id = ?", staffID).Count(&count).Error
 	return count, err
 }
@@ -8,7 +8,8 @@ import (
 // StudentWithDept represents a student with department info
 type StudentWithDept struct {
 	model.Student
-	DeptNo string `json:"dept_no"`
+	DeptNo   string `json:"dept_no"`
+	DeptName string `json:"dept_name"`
 }
 
 // StudentQueryParams represents student query parameters
@@ -24,18 +25,26 @@ type StudentQueryParams struct {
 type StudentRepository interface {
 	FindAll(studentNo, name, deptNo string) ([]StudentWithDept, error)
 	FindAllPaginated(params StudentQueryParams) ([]StudentWithDept, int64, error)
+	EachWithDept(studentNo, name, deptNo string, fn func(*StudentWithDept) error) error
 	FindByID(id uint) (*model.Student, error)
+	FindWithDeptByID(id uint) (*StudentWithDept, error)
 	FindByStudentNo(studentNo string) (*model.Student, error)
 	FindByStudentNos(studentNos []string) ([]model.Student, error)
+	FindWithoutAccount(ids []uint, statuses []string) ([]model.Student, error)
 	Create(student *model.Student) error
 	Update(student *model.Student) error
 	Delete(id uint) error
 	CreateHistory(history *model.StudentHistory) error
+	FindHistoryByStudentNo(studentNo string) ([]model.StudentHistory, error)
 	WithTx(tx *gorm.DB) StudentRepository
+	WithScope(scope Scope) StudentRepository
+	WithTeaching(teaching Teaching) StudentRepository
 }
 
 type studentRepo struct {
-	db *gorm.DB
+	db       *gorm.DB
+	scope    Scope
+	teaching Teaching
 }
 
 // NewStudentRepository creates a new StudentRepository
@@ -44,13 +53,29 @@ func NewStudentRepository(db *gorm.DB) StudentRepository {
 }
 
 func (r *studentRepo) WithTx(tx *gorm.DB) StudentRepository {
-	return &studentRepo{db: tx}
+	return &studentRepo{db: tx, scope: r.scope, teaching: r.teaching}
 }
 
-func (r *studentRepo) FindAll(studentNo, name, deptNo string) ([]StudentWithDept, error) {
+// WithScope limits the repository to the students of the scope's department
+func (r *studentRepo) WithScope(scope Scope) StudentRepository {
+	return &studentRepo{db: r.db, scope: scope, teaching: r.teaching}
+}
+
+// WithTeaching limits the students read to those enrolled in an offering within teaching
+func (r *studentRepo) WithTeaching(teaching Teaching) StudentRepository {
+	return &studentRepo{db: r.db, scope: r.scope, teaching: teaching}
+}
+
+// where limits q to the students in scope and, for reads, within the teaching limit
+func (r *studentRepo) where(q *gorm.DB, deptCol, idCol string) *gorm.DB {
+	return r.teaching.whereStudent(r.scope.where(q, deptCol), idCol)
+}
+
+func (r *studentRepo) listQuery(studentNo, name, deptNo string) *gorm.DB {
 	q := r.db.Table("students").
-		Select("students.*, departments.dept_no AS dept_no").
+		Select("students.*, departments.dept_no AS dept_no, departments.name AS dept_name").
 		Joins("JOIN departments ON departments.id = students.dept_id")
+	q = r.where(q, "students.dept_id", "students.id")
 
 	if studentNo != "" {
 		q = q.Where("students.student_no = ?", studentNo)
@@ -61,18 +86,26 @@ func (r *studentRepo) FindAll(studentNo, name, deptNo string) ([]StudentWithDept
 	if deptNo != "" {
 		q = q.Where("departments.dept_no = ?", deptNo)
 	}
+	return q.Order("students.student_no asc")
+}
 
+func (r *studentRepo) FindAll(studentNo, name, deptNo string) ([]StudentWithDept, error) {
 	var items []StudentWithDept
-	if err := q.Order("students.student_no asc").Scan(&items).Error; err != nil {
+	if err := r.listQuery(studentNo, name, deptNo).Scan(&items).Error; err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
+func (r *studentRepo) EachWithDept(studentNo, name, deptNo string, fn func(*StudentWithDept) error) error {
+	return scanEach(r.listQuery(studentNo, name, deptNo), fn)
+}
+
 func (r *studentRepo) FindAllPaginated(params StudentQueryParams) ([]StudentWithDept, int64, error) {
 	q := r.db.Table("students").
-		Select("students.*, departments.dept_no AS dept_no").
+		Select("students.*, departments.dept_no AS dept_no, departments.name AS dept_name").
 		Joins("JOIN departments ON departments.id = students.dept_id")
+	q = r.where(q, "students.dept_id", "students.id")
 
 	if params.StudentNo != "" {
 		q = q.Where("students.student_no = ?", params.StudentNo)
@@ -103,15 +136,28 @@ func (r *studentRepo) FindAllPaginated(params StudentQueryParams) ([]StudentWith
 
 func (r *studentRepo) FindByID(id uint) (*model.Student, error) {
 	var student model.Student
-	if err := r.db.First(&student, id).Error; err != nil {
+	if err := r.where(r.db, "dept_id", "id").First(&student, id).Error; err != nil {
 		return nil, err
 	}
 	return &student, nil
 }
 
+func (r *studentRepo) FindWithDeptByID(id uint) (*StudentWithDept, error) {
+	var item StudentWithDept
+	q := r.db.Table("students").
+		Select("students.*, departments.dept_no AS dept_no, departments.name AS dept_name").
+		Joins("JOIN departments ON departments.id = students.dept_id")
+	if err := r.where(q, "students.dept_id", "students.id").
+		Where("students.id = ?", id).
+		Take(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
 func (r *studentRepo) FindByStudentNo(studentNo string) (*model.Student, error) {
 	var student model.Student
-	if err := r.db.Where("student_no = ?", studentNo).First(&student).Error; err != nil {
+	if err := r.where(r.db, "dept_id", "id").Where("student_no = ?", studentNo).First(&student).Error; err != nil {
 		return nil, err
 	}
 	return &student, nil
@@ -119,24 +165,61 @@ func (r *studentRepo) FindByStudentNo(studentNo string) (*model.Student, error)
 
 func (r *studentRepo) FindByStudentNos(studentNos []string) ([]model.Student, error) {
 	var students []model.Student
-	if err := r.db.Where("student_no IN ?", studentNos).Find(&students).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").Where("student_no IN ?", studentNos).Find(&students).Error; err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+// FindWithoutAccount returns the students no account is linked to, limited to ids
+// and statuses when they are given, in student number order
+func (r *studentRepo) FindWithoutAccount(ids []uint, statuses []string) ([]model.Student, error) {
+	q := r.scope.where(r.db, "dept_id").
+		Where("NOT EXISTS (SELECT 1 FROM users WHERE users.student_id = students.id)")
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	if len(statuses) > 0 {
+		q = q.Where("status IN ?", statuses)
+	}
+	var students []model.Student
+	if err := q.Order("student_no asc").Find(&students).Error; err != nil {
 		return nil, err
 	}
 	return students, nil
 }
 
 func (r *studentRepo) Create(student *model.Student) error {
+	if !r.scope.Allows(student.DeptID) {
+		return ErrOutOfScope
+	}
 	return r.db.Create(student).Error
 }
 
+// Update saves the student; a scoped repository neither edits the students of
+// another department nor moves a student out of its own
 func (r *studentRepo) Update(student *model.Student) error {
+	if !r.scope.Allows(student.DeptID) {
+		return ErrOutOfScope
+	}
+	if err := r.scope.check(r.db.Model(&model.Student{}).Where("id = ?", student.ID), "dept_id"); err != nil {
+		return err
+	}
 	return r.db.Save(student).Error
 }
 
 func (r *studentRepo) Delete(id uint) error {
-	return r.db.Delete(&model.Student{}, id).Error
+	return r.scope.where(r.db, "dept_id").Delete(&model.Student{}, id).Error
 }
 
 func (r *studentRepo) CreateHistory(history *model.StudentHistory) error {
 	return r.db.Create(history).Error
 }
+
+func (r *studentRepo) FindHistoryByStudentNo(studentNo string) ([]model.StudentHistory, error) {
+	var items []model.StudentHistory
+	if err := r.scope.where(r.db, "dept_id").Where("student_no = ?", studentNo).Order("archived_at asc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
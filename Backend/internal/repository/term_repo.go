@@ -26,7 +26,7 @@ func NewTermRepository(db *gorm.DB) TermRepository {
 
 func (r *termRepo) FindAll() ([]model.Term, error) {
 	var items []model.Term
-	if err := r.db.Order("term_code desc").Find(&items).Error; err != nil {
+	if err := r.db.Order("start_date desc NULLS LAST, term_code desc").Find(&items).Error; err != nil {
 		return nil, err
 	}
 	return items, nil
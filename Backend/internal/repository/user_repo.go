@@ -10,14 +10,19 @@ type UserRepository interface {
 	FindAll() ([]model.User, error)
 	FindByID(id uint) (*model.User, error)
 	FindByUsername(username string) (*model.User, error)
+	FindByStudentID(studentID uint) (*model.User, error)
+	FindByStaffID(staffID uint) (*model.User, error)
 	Create(user *model.User) error
 	Update(user *model.User) error
 	Delete(id uint) error
 	Count() (int64, error)
+	WithTx(tx *gorm.DB) UserRepository
+	WithScope(scope Scope) UserRepository
 }
 
 type userRepo struct {
-	db *gorm.DB
+	db    *gorm.DB
+	scope Scope
 }
 
 // NewUserRepository creates a new UserRepository
@@ -25,9 +30,19 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepo{db: db}
 }
 
+func (r *userRepo) WithTx(tx *gorm.DB) UserRepository {
+	return &userRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the accounts of the scope's department, which
+// may only be linked to the students and staff of that department
+func (r *userRepo) WithScope(scope Scope) UserRepository {
+	return &userRepo{db: r.db, scope: scope}
+}
+
 func (r *userRepo) FindAll() ([]model.User, error) {
 	var users []model.User
-	if err := r.db.Order("id asc").Find(&users).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").Order("id asc").Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
@@ -35,7 +50,7 @@ func (r *userRepo) FindAll() ([]model.User, error) {
 
 func (r *userRepo) FindByID(id uint) (*model.User, error) {
 	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
+	if err := r.scope.where(r.db, "dept_id").First(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -49,16 +64,67 @@ func (r *userRepo) FindByUsername(username string) (*model.User, error) {
 	return &user, nil
 }
 
+// FindByStudentID returns the account linked to the student
+func (r *userRepo) FindByStudentID(studentID uint) (*model.User, error) {
+	var user model.User
+	if err := r.scope.where(r.db, "dept_id").Where("student_id = ?", studentID).Order("id asc").First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByStaffID returns the account linked to the staff member
+func (r *userRepo) FindByStaffID(staffID uint) (*model.User, error) {
+	var user model.User
+	if err := r.scope.where(r.db, "dept_id").Where("staff_id = ?", staffID).Order("id asc").First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create adds the user; a scoped repository files a user without a department under its own
 func (r *userRepo) Create(user *model.User) error {
+	if user.DeptID == nil && !r.scope.All() {
+		deptID := r.scope.DeptID
+		user.DeptID = &deptID
+	}
+	if err := r.checkScope(user); err != nil {
+		return err
+	}
 	return r.db.Create(user).Error
 }
 
 func (r *userRepo) Update(user *model.User) error {
+	if err := r.checkScope(user); err != nil {
+		return err
+	}
+	if err := r.scope.check(r.db.Model(&model.User{}).Where("id = ?", user.ID), "dept_id"); err != nil {
+		return err
+	}
 	return r.db.Save(user).Error
 }
 
+// checkScope refuses a user of another department, or linked to a student or staff
+// member of another department
+func (r *userRepo) checkScope(user *model.User) error {
+	if !r.scope.AllowsPtr(user.DeptID) {
+		return ErrOutOfScope
+	}
+	if user.StudentID != nil {
+		if err := r.scope.check(r.db.Model(&model.Student{}).Where("id = ?", *user.StudentID), "dept_id"); err != nil {
+			return err
+		}
+	}
+	if user.StaffID != nil {
+		if err := r.scope.check(r.db.Model(&model.Staff{}).Where("id = ?", *user.StaffID), "dept_id"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *userRepo) Delete(id uint) error {
-	return r.db.Delete(&model.User{}, id).Error
+	return r.scope.where(r.db, "dept_id").Delete(&model.User{}, id).Error
 }
 
 func (r *userRepo) Count() (int64, error) {
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"gorm.io/gorm"
+)
+
+// WaitlistQueryParams represents query parameters for waitlists
+type WaitlistQueryParams struct {
+	StudentNo string
+	CourseNo  string
+	TermCode  string
+	Teaching  Teaching
+}
+
+// WaitlistRow represents a waitlist entry with related info and its queue position
+type WaitlistRow struct {
+	ID          uint      `json:"id"`
+	StudentID   uint      `json:"student_id"`
+	StudentNo   string    `json:"student_no"`
+	StudentName string    `json:"student_name"`
+	OfferingID  uint      `json:"offering_id"`
+	SectionNo   string    `json:"section_no"`
+	CourseID    uint      `json:"course_id"`
+	CourseNo    string    `json:"course_no"`
+	CourseName  string    `json:"course_name"`
+	TermID      uint      `json:"term_id"`
+	TermCode    string    `json:"term_code"`
+	TermName    string    `json:"term_name"`
+	Position    int       `json:"position"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WaitlistRepository defines the interface for waitlist data access
+type WaitlistRepository interface {
+	FindByID(id uint) (*model.WaitlistEntry, error)
+	FindByFilters(params WaitlistQueryParams) ([]WaitlistRow, error)
+	FindByStudentID(studentID uint) ([]WaitlistRow, error)
+	FindQueue(offeringID uint) ([]model.WaitlistEntry, error)
+	CountByStudentAndCourses(studentID uint, courseIDs []uint) (int64, error)
+	CreateBatch(entries []model.WaitlistEntry) error
+	Delete(id uint) error
+	WithTx(tx *gorm.DB) WaitlistRepository
+	WithScope(scope Scope) WaitlistRepository
+}
+
+type waitlistRepo struct {
+	db    *gorm.DB
+	scope Scope
+}
+
+// NewWaitlistRepository creates a new WaitlistRepository
+func NewWaitlistRepository(db *gorm.DB) WaitlistRepository {
+	return &waitlistRepo{db: db}
+}
+
+func (r *waitlistRepo) WithTx(tx *gorm.DB) WaitlistRepository {
+	return &waitlistRepo{db: tx, scope: r.scope}
+}
+
+// WithScope limits the repository to the entries of the students of the scope's department
+func (r *waitlistRepo) WithScope(scope Scope) WaitlistRepository {
+	return &waitlistRepo{db: r.db, scope: scope}
+}
+
+func (r *waitlistRepo) FindByID(id uint) (*model.WaitlistEntry, error) {
+	var entry model.WaitlistEntry
+	if err := r.scope.whereStudent(r.db, "student_id").First(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// rowsQuery numbers every queue before filtering so positions stay absolute
+func (r *waitlistRepo) rowsQuery() *gorm.DB {
+	ranked := r.db.Table("waitlist_entries").
+		Select("waitlist_entries.*, ROW_NUMBER() OVER (PARTITION BY offering_id ORDER BY created_at, id) AS position")
+
+	q := r.db.Table("(?) AS w", ranked).
+		Select(`
+			w.id, w.student_id, w.offering_id, w.position, w.created_at,
+			students.student_no, students.name AS student_name,
+			course_offerings.section_no, course_offerings.course_id, course_offerings.term_id,
+			courses.course_no, courses.name AS course_name,
+			terms.term_code, terms.name AS term_name
+		`).
+		Joins("JOIN students ON students.id = w.student_id").
+		Joins("JOIN course_offerings ON course_offerings.id = w.offering_id").
+		Joins("JOIN courses ON courses.id = course_offerings.course_id").
+		Joins("JOIN terms ON terms.id = course_offerings.term_id")
+	return r.scope.where(q, "students.dept_id")
+}
+
+func (r *waitlistRepo) FindByFilters(params WaitlistQueryParams) ([]WaitlistRow, error) {
+	q := params.Teaching.where(r.rowsQuery(), "course_offerings.teacher_id")
+	if params.StudentNo != "" {
+		q = q.Where("students.student_no = ?", params.StudentNo)
+	}
+	if params.CourseNo != "" {
+		q = q.Where("courses.course_no = ?", params.CourseNo)
+	}
+	if params.TermCode != "" {
+		q = q.Where("terms.term_code = ?", params.TermCode)
+	}
+
+	var rows []WaitlistRow
+	if err := q.Order("terms.start_date desc NULLS LAST, terms.term_code desc, courses.course_no asc, course_offerings.section_no asc, w.position asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *waitlistRepo) FindByStudentID(studentID uint) ([]WaitlistRow, error) {
+	var rows []WaitlistRow
+	if err := r.rowsQuery().
+		Where("w.student_id = ?", studentID).
+		Order("terms.start_date desc NULLS LAST, terms.term_code desc, courses.course_no asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *waitlistRepo) FindQueue(offeringID uint) ([]model.WaitlistEntry, error) {
+	var entries []model.WaitlistEntry
+	if err := r.db.Where("offering_id = ?", offeringID).
+		Order("created_at asc, id asc").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CountByStudentAndCourses counts queue entries of the student for any offering of the given catalog courses
+func (r *waitlistRepo) CountByStudentAndCourses(studentID uint, courseIDs []uint) (int64, error) {
+	var count int64
+	if err := r.db.Model(&model.WaitlistEntry{}).
+		Joins("JOIN course_offerings ON course_offerings.id = waitlist_entries.offering_id").
+		Where("waitlist_entries.student_id = ? AND course_offerings.course_id IN ?", studentID, courseIDs).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *waitlistRepo) CreateBatch(entries []model.WaitlistEntry) error {
+	return r.db.Create(&entries).Error
+}
+
+func (r *waitlistRepo) Delete(id uint) error {
+	return r.db.Delete(&model.WaitlistEntry{}, id).Error
+}
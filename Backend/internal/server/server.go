@@ -7,17 +7,25 @@ import (
 
 // Handlers holds all HTTP handlers
 type Handlers struct {
-	Health     *handler.HealthHandler
-	Auth       *handler.AuthHandler
-	Department *handler.DepartmentHandler
-	Student    *handler.StudentHandler
-	Staff      *handler.StaffHandler
-	Course     *handler.CourseHandler
-	Term       *handler.TermHandler
-	Enrollment *handler.EnrollmentHandler
-	Grade      *handler.GradeHandler
-	Report     *handler.ReportHandler
-	User       *handler.UserHandler
+	Health      *handler.HealthHandler
+	Auth        *handler.AuthHandler
+	Department  *handler.DepartmentHandler
+	Student     *handler.StudentHandler
+	Staff       *handler.StaffHandler
+	Course      *handler.CourseHandler
+	Offering    *handler.OfferingHandler
+	Term        *handler.TermHandler
+	Enrollment  *handler.EnrollmentHandler
+	Grade       *handler.GradeHandler
+	GradeFlow   *handler.GradeWorkflowHandler
+	Transcript  *handler.TranscriptHandler
+	Certificate *handler.CertificateHandler
+	Report      *handler.ReportHandler
+	User        *handler.UserHandler
+	Account     *handler.AccountHandler
+	Import      *handler.ImportHandler
+	Audit       *handler.AuditHandler
+	Role        *handler.RoleHandler
 }
 
 // NewHandlers creates a new Handlers instance
@@ -28,39 +36,63 @@ func NewHandlers(
 	student *handler.StudentHandler,
 	staff *handler.StaffHandler,
 	course *handler.CourseHandler,
+	offering *handler.OfferingHandler,
 	term *handler.TermHandler,
 	enrollment *handler.EnrollmentHandler,
 	grade *handler.GradeHandler,
+	gradeFlow *handler.GradeWorkflowHandler,
+	transcript *handler.TranscriptHandler,
+	certificate *handler.CertificateHandler,
 	report *handler.ReportHandler,
 	user *handler.UserHandler,
+	account *handler.AccountHandler,
+	imports *handler.ImportHandler,
+	audit *handler.AuditHandler,
+	role *handler.RoleHandler,
 ) *Handlers {
 	return &Handlers{
-		Health:     health,
-		Auth:       auth,
-		Department: department,
-		Student:    student,
-		Staff:      staff,
-		Course:     course,
-		Term:       term,
-		Enrollment: enrollment,
-		Grade:      grade,
-		Report:     report,
-		User:       user,
+		Health:      health,
+		Auth:        auth,
+		Department:  department,
+		Student:     student,
+		Staff:       staff,
+		Course:      course,
+		Offering:    offering,
+		Term:        term,
+		Enrollment:  enrollment,
+		Grade:       grade,
+		GradeFlow:   gradeFlow,
+		Transcript:  transcript,
+		Certificate: certificate,
+		Report:      report,
+		User:        user,
+		Account:     account,
+		Import:      imports,
+		Audit:       audit,
+		Role:        role,
 	}
 }
 
 // Services holds all business services
 type Services struct {
-	Department service.DepartmentService
-	Student    service.StudentService
-	Staff      service.StaffService
-	Course     service.CourseService
-	Term       service.TermService
-	Enrollment service.EnrollmentService
-	Grade      service.GradeService
-	Auth       service.AuthService
-	Report     service.ReportService
-	User       service.UserService
+	Department  service.DepartmentService
+	Student     service.StudentService
+	Staff       service.StaffService
+	Course      service.CourseService
+	Offering    service.OfferingService
+	Term        service.TermService
+	Enrollment  service.EnrollmentService
+	Grade       service.GradeService
+	GradeFlow   service.GradeWorkflowService
+	GPA         service.GPAService
+	Transcript  service.TranscriptService
+	Certificate service.CertificateService
+	Auth        service.AuthService
+	Report      service.ReportService
+	User        service.UserService
+	Import      service.ImportService
+	Audit       service.AuditService
+	Role        service.RoleService
 }
 
 // NewServices creates a new Services instance
@@ -69,23 +101,39 @@ func NewServices(
 	student service.StudentService,
 	staff service.StaffService,
 	course service.CourseService,
+	offering service.OfferingService,
 	term service.TermService,
 	enrollment service.EnrollmentService,
 	grade service.GradeService,
+	gradeFlow service.GradeWorkflowService,
+	gpa service.GPAService,
+	transcript service.TranscriptService,
+	certificate service.CertificateService,
 	auth service.AuthService,
 	report service.ReportService,
 	user service.UserService,
+	imports service.ImportService,
+	audit service.AuditService,
+	role service.RoleService,
 ) *Services {
 	return &Services{
-		Department: department,
-		Student:    student,
-		Staff:      staff,
-		Course:     course,
-		Term:       term,
-		Enrollment: enrollment,
-		Grade:      grade,
-		Auth:       auth,
-		Report:     report,
-		User:       user,
+		Department:  department,
+		Student:     student,
+		Staff:       staff,
+		Course:      course,
+		Offering:    offering,
+		Term:        term,
+		Enrollment:  enrollment,
+		Grade:       grade,
+		GradeFlow:   gradeFlow,
+		GPA:         gpa,
+		Transcript:  transcript,
+		Certificate: certificate,
+		Auth:        auth,
+		Report:      report,
+		User:        user,
+		Import:      imports,
+		Audit:       audit,
+		Role:        role,
 	}
 }
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/lin-snow/edumgr/internal/pkg/fonts"
+)
+
+// Layout of the credentials sheet (mm): one slip per account, cut apart along the dashes
+const (
+	slipH      = 30
+	slipGap    = 6
+	slipMargin = 15
+)
+
+// renderCredentialsPDF draws the credentials sheet of provisioned accounts, a slip per
+// account with its username and one-time password for handing out. Roles are shown by
+// their label where labels has one.
+func renderCredentialsPDF(accounts []ProvisionedAccount, labels map[string]string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fonts.CJKFamily, "", fonts.CJK)
+	pdf.SetMargins(slipMargin, slipMargin, slipMargin)
+	pdf.SetAutoPageBreak(false, slipMargin)
+	pdf.SetTitle("Account Credentials", true)
+	pdf.SetCreator("EduMgr", true)
+	pdf.AddPage()
+
+	pdf.SetFont(fonts.CJKFamily, "", 16)
+	pdf.CellFormat(0, 9, "账号开通凭条", "", 1, "C", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.CellFormat(0, 5, "打印于 "+time.Now().Format("2006-01-02 15:04")+"，共 "+strconv.Itoa(len(accounts))+" 个账号", "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	pageW, pageH := pdf.GetPageSize()
+	w := pageW - 2*slipMargin
+	for _, a := range accounts {
+		if pdf.GetY()+slipH > pageH-slipMargin {
+			pdf.AddPage()
+		}
+		role := labels[a.Role]
+		if role == "" {
+			role = a.Role
+		}
+		drawCredentialSlip(pdf, a, role, w)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawCredentialSlip(pdf *fpdf.Fpdf, a ProvisionedAccount, role string, w float64) {
+	x, y := pdf.GetX(), pdf.GetY()
+	pdf.SetDrawColor(150, 150, 150)
+	pdf.SetDashPattern([]float64{1.5, 1}, 0)
+	pdf.Rect(x, y, w, slipH-slipGap, "D")
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetDrawColor(0, 0, 0)
+
+	pdf.SetXY(x+4, y+3)
+	pdf.SetFont(fonts.CJKFamily, "", 10)
+	pdf.CellFormat(70, 6, "姓名："+fitText(pdf, a.Name, 56), "", 0, "L", false, 0, "")
+	pdf.CellFormat(55, 6, "学号/工号："+a.Number, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "角色："+role, "", 1, "L", false, 0, "")
+
+	pdf.SetX(x + 4)
+	pdf.SetFont(fonts.CJKFamily, "", 13)
+	pdf.CellFormat(70, 8, "用户名："+a.Username, "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 8, "初始密码："+a.Password, "", 1, "L", false, 0, "")
+
+	pdf.SetX(x + 4)
+	pdf.SetFont(fonts.CJKFamily, "", 8)
+	pdf.SetTextColor(110, 110, 110)
+	pdf.CellFormat(0, 5, "首次登录后须修改密码。初始密码仅此一份，请妥善保管，勿转交他人。", "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+
+	pdf.SetXY(x, y+slipH)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// oneTimePasswordLength is the length of generated passwords unless the password
+// policy asks for more
+const oneTimePasswordLength = 12
+
+// Character classes of generated passwords, without look-alikes such as l/1 and O/0
+// so that they can be typed from a printed sheet
+var oneTimePasswordClasses = []string{
+	"abcdefghijkmnpqrstuvwxyz",
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"23456789",
+	"#%+=@",
+}
+
+// ProvisionedAccount is an account created for a student or staff member together with
+// its one-time password. Only the hash of the password is stored, so the response that
+// created the account, or a credentials sheet printed from it, is the only copy.
+type ProvisionedAccount struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Number   string `json:"number"`
+	Name     string `json:"name"`
+}
+
+// AccountOption asks for the account of a student or staff member being created, or
+// of each one imported. Role is the role of staff accounts, teacher when empty.
+type AccountOption struct {
+	Provision bool   `json:"provision_account"`
+	Role      string `json:"account_role,omitempty"`
+}
+
+// accountProvisioner creates the accounts of students and staff, named by their student
+// or staff number. The password is generated and must be changed at first login.
+type accountProvisioner struct {
+	roles RoleService
+	cfg   config.Config
+}
+
+// check refuses an actor who may not create accounts, or accounts of role
+func (p accountProvisioner) check(actor Actor, role string) error {
+	if !p.roles.Can(actor.Role, model.PermUserManage) {
+		return pkg.NewAppError(pkg.ErrCodeForbidden, "creating accounts requires "+model.PermUserManage)
+	}
+	if !p.roles.Exists(role) {
+		return pkg.NewAppError(pkg.ErrCodeInvalidRole, "unknown role: "+role)
+	}
+	if !p.roles.Grants(actor.Role, role) {
+		return pkg.NewAppError(pkg.ErrCodeForbidden, "cannot manage users of role "+role)
+	}
+	return nil
+}
+
+// staffRole is the role of provisioned staff accounts, teacher unless one is given
+func staffRole(role string) string {
+	if role == "" {
+		return model.RoleTeacher
+	}
+	return role
+}
+
+// usernameTaken reports whether an account already uses the username
+func usernameTaken(userRepo repository.UserRepository, username string) (bool, error) {
+	_, err := userRepo.FindByUsername(username)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+// student creates the account of a student. It covers every department, since students
+// browse and enrol in the courses of other departments.
+func (p accountProvisioner) student(userRepo repository.UserRepository, st *model.Student) (*ProvisionedAccount, error) {
+	return p.create(userRepo, st.StudentNo, st.Name, model.RoleStudent, &st.ID, nil, nil)
+}
+
+// staff creates the account of a staff member with role, limited to the department of
+// the staff member
+func (p accountProvisioner) staff(userRepo repository.UserRepository, st *model.Staff, role string) (*ProvisionedAccount, error) {
+	deptID := st.DeptID
+	return p.create(userRepo, st.StaffNo, st.Name, role, nil, &st.ID, &deptID)
+}
+
+func (p accountProvisioner) create(userRepo repository.UserRepository, number, name, role string, studentID, staffID, deptID *uint) (*ProvisionedAccount, error) {
+	taken, err := usernameTaken(userRepo, number)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if taken {
+		return nil, pkg.NewAppError(pkg.ErrCodeUsernameTaken, "username "+number+" is taken by another account")
+	}
+	password, err := p.oneTimePassword(number)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "generate password failed", err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "hash password failed", err)
+	}
+	user := &model.User{
+		Username:           number,
+		PasswordHash:       string(hash),
+		Role:               role,
+		StudentID:          studentID,
+		StaffID:            staffID,
+		DeptID:             deptID,
+		MustChangePassword: true,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create user failed", err)
+	}
+	return &ProvisionedAccount{
+		UserID:   user.ID,
+		Username: user.Username,
+		Password: password,
+		Role:     role,
+		Number:   number,
+		Name:     name,
+	}, nil
+}
+
+// oneTimePassword generates a random password holding every character class, which
+// passes the password policy whatever its class and length settings
+func (p accountProvisioner) oneTimePassword(username string) (string, error) {
+	length := max(oneTimePasswordLength, p.cfg.PasswordMinLength, len(oneTimePasswordClasses))
+	var all string
+	for _, class := range oneTimePasswordClasses {
+		all += class
+	}
+	for {
+		b := make([]byte, length)
+		for i := range b {
+			set := all
+			if i < len(oneTimePasswordClasses) {
+				set = oneTimePasswordClasses[i]
+			}
+			c, err := randomChar(set)
+			if err != nil {
+				return "", err
+			}
+			b[i] = c
+		}
+		// Move the guaranteed characters away from the front
+		for i := len(b) - 1; i > 0; i-- {
+			j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return "", err
+			}
+			b[i], b[j.Int64()] = b[j.Int64()], b[i]
+		}
+		// Practically always passes; a draw on the common password list is drawn again
+		if checkPassword(p.cfg, username, string(b)) == nil {
+			return string(b), nil
+		}
+	}
+}
+
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+func TestProvisionedAccountDepartment(t *testing.T) {
+	p := accountProvisioner{cfg: config.Config{PasswordMinLength: 8}}
+	users := &memUserRepo{}
+
+	staff, err := p.staff(users, &model.Staff{ID: 5, StaffNo: "T005", DeptID: 3}, "secretary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	student, err := p.student(users, &model.Student{ID: 9, StudentNo: "2024009", DeptID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Staff accounts are limited to the department of the staff member, students' are not
+	if u, _ := users.FindByID(staff.UserID); u.DeptID == nil || *u.DeptID != 3 || *u.StaffID != 5 {
+		t.Errorf("staff account: dept %v, staff %v", u.DeptID, u.StaffID)
+	}
+	if u, _ := users.FindByID(student.UserID); u.DeptID != nil || *u.StudentID != 9 {
+		t.Errorf("student account: dept %v, student %v", u.DeptID, u.StudentID)
+	}
+}
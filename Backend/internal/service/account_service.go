@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Kinds of records accounts are provisioned for
+const (
+	AccountsForStudents = "student"
+	AccountsForStaff    = "staff"
+)
+
+// provisionBatchMax bounds the accounts one request creates; hashing their passwords
+// takes a noticeable moment each
+const provisionBatchMax = 500
+
+// provisionStatuses are the students given accounts when no IDs are named
+var provisionStatuses = []string{"in_school", "transfer_in"}
+
+// ProvisionRequest selects the students or staff without an account to create one for.
+// Without IDs, every one in scope is selected: of the students, those in school.
+type ProvisionRequest struct {
+	Kind string `json:"kind"`
+	IDs  []uint `json:"ids,omitempty"`
+	Role string `json:"role,omitempty"` // of staff accounts, teacher when empty
+}
+
+// ProvisionSkip is a selected record no account was created for
+type ProvisionSkip struct {
+	ID      uint   `json:"id"`
+	Number  string `json:"number,omitempty"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ProvisionResult reports a batch provisioning. Remaining counts the records left over
+// beyond the batch limit, which a repeated request picks up.
+type ProvisionResult struct {
+	Created   []ProvisionedAccount `json:"created"`
+	Skipped   []ProvisionSkip      `json:"skipped"`
+	Remaining int                  `json:"remaining"`
+}
+
+// CredentialsRequest carries provisioned accounts to print on a credentials sheet
+type CredentialsRequest struct {
+	Accounts []ProvisionedAccount `json:"accounts"`
+}
+
+// AccountService defines the interface for creating the accounts of existing students
+// and staff
+type AccountService interface {
+	Provision(req ProvisionRequest, actor Actor) (*ProvisionResult, error)
+	CredentialsPDF(accounts []ProvisionedAccount) ([]byte, error)
+	WithScope(scope repository.Scope) AccountService
+}
+
+type accountService struct {
+	studentRepo repository.StudentRepository
+	staffRepo   repository.StaffRepository
+	userRepo    repository.UserRepository
+	roles       RoleService
+	provisioner accountProvisioner
+	db          *gorm.DB
+}
+
+// NewAccountService creates a new AccountService
+func NewAccountService(
+	studentRepo repository.StudentRepository,
+	staffRepo repository.StaffRepository,
+	userRepo repository.UserRepository,
+	roles RoleService,
+	db *gorm.DB,
+	cfg config.Config,
+) AccountService {
+	return &accountService{
+		studentRepo: studentRepo,
+		staffRepo:   staffRepo,
+		userRepo:    userRepo,
+		roles:       roles,
+		provisioner: accountProvisioner{roles: roles, cfg: cfg},
+		db:          db,
+	}
+}
+
+// WithScope returns the service limited to the students, staff and accounts of the
+// scope's department
+func (s *accountService) WithScope(scope repository.Scope) AccountService {
+	scoped := *s
+	scoped.studentRepo = s.studentRepo.WithScope(scope)
+	scoped.staffRepo = s.staffRepo.WithScope(scope)
+	scoped.userRepo = s.userRepo.WithScope(scope)
+	return &scoped
+}
+
+// provisionTarget is a selected record and how to create its account
+type provisionTarget struct {
+	id     uint
+	number string
+	create func(userRepo repository.UserRepository) (*ProvisionedAccount, error)
+}
+
+// Provision creates the accounts of the selected records in one transaction. Records
+// whose number is already another account's username are skipped, as are named IDs
+// that are not found or already have an account.
+func (s *accountService) Provision(req ProvisionRequest, actor Actor) (*ProvisionResult, error) {
+	var targets []provisionTarget
+	switch req.Kind {
+	case AccountsForStudents:
+		if err := s.provisioner.check(actor, model.RoleStudent); err != nil {
+			return nil, err
+		}
+		statuses := provisionStatuses
+		if len(req.IDs) > 0 {
+			statuses = nil
+		}
+		students, err := s.studentRepo.FindWithoutAccount(req.IDs, statuses)
+		if err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		for i := range students {
+			st := &students[i]
+			targets = append(targets, provisionTarget{id: st.ID, number: st.StudentNo,
+				create: func(userRepo repository.UserRepository) (*ProvisionedAccount, error) {
+					return s.provisioner.student(userRepo, st)
+				}})
+		}
+	case AccountsForStaff:
+		role := staffRole(req.Role)
+		if err := s.provisioner.check(actor, role); err != nil {
+			return nil, err
+		}
+		staff, err := s.staffRepo.FindWithoutAccount(req.IDs)
+		if err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		for i := range staff {
+			st := &staff[i]
+			targets = append(targets, provisionTarget{id: st.ID, number: st.StaffNo,
+				create: func(userRepo repository.UserRepository) (*ProvisionedAccount, error) {
+					return s.provisioner.staff(userRepo, st, role)
+				}})
+		}
+	default:
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "kind must be student or staff")
+	}
+
+	result := &ProvisionResult{Created: []ProvisionedAccount{}, Skipped: []ProvisionSkip{}}
+	found := make(map[uint]bool, len(targets))
+	for _, t := range targets {
+		found[t.id] = true
+	}
+	for _, id := range req.IDs {
+		if !found[id] {
+			result.Skipped = append(result.Skipped, ProvisionSkip{ID: id, Code: pkg.ErrCodeNotFound,
+				Message: "not found or already has an account"})
+		}
+	}
+	if len(targets) > provisionBatchMax {
+		result.Remaining = len(targets) - provisionBatchMax
+		targets = targets[:provisionBatchMax]
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		userRepo := s.userRepo.WithTx(tx)
+		for _, t := range targets {
+			account, err := t.create(userRepo)
+			var appErr *pkg.AppError
+			if errors.As(err, &appErr) && appErr.Code == pkg.ErrCodeUsernameTaken {
+				result.Skipped = append(result.Skipped, ProvisionSkip{ID: t.id, Number: t.number,
+					Code: appErr.Code, Message: appErr.Message})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result.Created = append(result.Created, *account)
+		}
+		return nil
+	})
+	if err != nil {
+		var appErr *pkg.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create accounts failed", err)
+	}
+	return result, nil
+}
+
+// CredentialsPDF prints the credentials sheet of accounts just provisioned
+func (s *accountService) CredentialsPDF(accounts []ProvisionedAccount) ([]byte, error) {
+	if len(accounts) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "accounts required")
+	}
+	roles, err := s.roles.List()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	labels := make(map[string]string, len(roles))
+	for _, r := range roles {
+		labels[r.Name] = r.Label
+	}
+	data, err := renderCredentialsPDF(accounts, labels)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeRenderFailed, "render pdf failed", err)
+	}
+	return data, nil
+}
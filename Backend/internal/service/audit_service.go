@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// auditPurgeInterval is how often entries past the retention period are purged
+const auditPurgeInterval = 24 * time.Hour
+
+// auditIgnoredFields change on every write and are left out of the recorded changes
+var auditIgnoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// AuditListResult represents a page of the audit log
+type AuditListResult struct {
+	Items    []repository.AuditRow `json:"items"`
+	Total    int64                 `json:"total"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"page_size"`
+}
+
+// AuditService records write requests and serves the audit log.
+// It implements middleware.AuditRecorder.
+type AuditService interface {
+	Snapshot(entityType, entityID string) any
+	Record(entry *model.AuditLog, before, after any)
+	List(params repository.AuditQueryParams) (*AuditListResult, error)
+	Purge() (int64, error)
+	RunRetention(ctx context.Context)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+	cfg  config.Config
+}
+
+// NewAuditService creates a new AuditService
+func NewAuditService(repo repository.AuditRepository, cfg config.Config) AuditService {
+	return &auditService{repo: repo, cfg: cfg}
+}
+
+// Snapshot loads an entity as JSON fields; nil when it is not tracked, absent or unreadable
+func (s *auditService) Snapshot(entityType, entityID string) any {
+	row, err := s.repo.FindEntity(entityType, entityID)
+	if err != nil {
+		log.Printf("audit: snapshot %s %s: %v", entityType, entityID, err)
+		return nil
+	}
+	if row == nil {
+		return nil
+	}
+	fields, err := toFields(row)
+	if err != nil {
+		return nil
+	}
+	return fields
+}
+
+// Record stores an entry. Failures are logged rather than failing the request,
+// which has already been answered.
+func (s *auditService) Record(entry *model.AuditLog, before, after any) {
+	entry.Changes = auditChanges(before, after)
+	if err := s.repo.Create(entry); err != nil {
+		log.Printf("audit: record %s %s: %v", entry.Method, entry.Route, err)
+	}
+}
+
+func (s *auditService) List(params repository.AuditQueryParams) (*AuditListResult, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.PageSize <= 0 {
+		params.PageSize = 20
+	}
+	if params.From != nil && params.To != nil && !params.From.Before(*params.To) {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidTimeRange, "from must be before to")
+	}
+
+	items, total, err := s.repo.FindAllPaginated(params)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return &AuditListResult{
+		Items:    items,
+		Total:    total,
+		Page:     params.Page,
+		PageSize: params.PageSize,
+	}, nil
+}
+
+// Purge deletes the entries older than AUDIT_RETENTION_DAYS; a retention of 0 keeps everything
+func (s *auditService) Purge() (int64, error) {
+	if s.cfg.AuditRetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -s.cfg.AuditRetentionDays)
+	n, err := s.repo.DeleteBefore(cutoff)
+	if err != nil {
+		return 0, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return n, nil
+}
+
+// RunRetention purges expired entries at start and then once a day until ctx is done
+func (s *auditService) RunRetention(ctx context.Context) {
+	ticker := time.NewTicker(auditPurgeInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.Purge(); err != nil {
+			log.Printf("audit: purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("audit: purged %d entries older than %d days", n, s.cfg.AuditRetentionDays)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// toFields turns an entity into its JSON fields, so that hidden fields such as password hashes stay out
+func toFields(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// auditChanges lists the fields that differ between two snapshots; a nil snapshot has no fields
+func auditChanges(before, after any) map[string]model.AuditChange {
+	old, _ := before.(map[string]any)
+	cur, _ := after.(map[string]any)
+	changes := map[string]model.AuditChange{}
+	for k, v := range old {
+		if !auditIgnoredFields[k] && !reflect.DeepEqual(v, cur[k]) {
+			changes[k] = model.AuditChange{Old: v, New: cur[k]}
+		}
+	}
+	for k, v := range cur {
+		if _, seen := old[k]; !seen && !auditIgnoredFields[k] {
+			changes[k] = model.AuditChange{Old: nil, New: v}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
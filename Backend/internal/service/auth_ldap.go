@@ -0,0 +1,99 @@
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+// ldapTimeout bounds connecting to and each request of the directory
+const ldapTimeout = 10 * time.Second
+
+// ldapDirectory checks passwords by binding to an LDAP directory as the user's entry
+type ldapDirectory struct {
+	cfg config.Config
+}
+
+// Authenticate finds the entry of username and binds as it with password. The entry's
+// entryUUID, or its DN where the directory has none, identifies it.
+func (d ldapDirectory) Authenticate(username, password string) (*ExternalIdentity, error) {
+	conn, err := ldap.DialURL(d.cfg.LDAPURL, ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetTimeout(ldapTimeout)
+	if d.cfg.LDAPStartTLS {
+		u, err := url.Parse(d.cfg.LDAPURL)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return nil, err
+		}
+	}
+	if d.cfg.LDAPBindDN != "" {
+		if err := conn.Bind(d.cfg.LDAPBindDN, d.cfg.LDAPBindPassword); err != nil {
+			return nil, fmt.Errorf("ldap: service bind: %w", err)
+		}
+	}
+
+	attrs := []string{"entryUUID", d.cfg.LDAPUsernameAttr}
+	for _, a := range []string{d.cfg.LDAPStudentNoAttr, d.cfg.LDAPStaffNoAttr} {
+		if a != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.cfg.LDAPBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout/time.Second), false,
+		fmt.Sprintf(d.cfg.LDAPUserFilter, ldap.EscapeFilter(username)), attrs, nil,
+	))
+	// A username matching several entries identifies nobody
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, errExternalCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, errExternalCredentials
+	}
+	entry := result.Entries[0]
+
+	// An empty password would be an unauthenticated bind, which many directories accept
+	if password == "" {
+		return nil, errExternalCredentials
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errExternalCredentials
+		}
+		return nil, fmt.Errorf("ldap: bind: %w", err)
+	}
+
+	identity := &ExternalIdentity{
+		Provider: model.IdentityLDAP,
+		Subject:  entry.GetAttributeValue("entryUUID"),
+		Username: entry.GetAttributeValue(d.cfg.LDAPUsernameAttr),
+	}
+	if identity.Subject == "" {
+		identity.Subject = entry.DN
+	}
+	if identity.Username == "" {
+		identity.Username = username
+	}
+	if d.cfg.LDAPStudentNoAttr != "" {
+		identity.StudentNo = entry.GetAttributeValue(d.cfg.LDAPStudentNoAttr)
+	}
+	if d.cfg.LDAPStaffNoAttr != "" {
+		identity.StaffNo = entry.GetAttributeValue(d.cfg.LDAPStaffNoAttr)
+	}
+	return identity, nil
+}
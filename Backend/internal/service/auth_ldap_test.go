@@ -0,0 +1,249 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+// LDAP protocol operations the fake directory answers
+const (
+	ldapBindRequest     = 0
+	ldapBindResponse    = 1
+	ldapUnbindRequest   = 2
+	ldapSearchRequest   = 3
+	ldapSearchEntry     = 4
+	ldapSearchDone      = 5
+	ldapServiceDN       = "cn=edumgr,dc=example,dc=edu"
+	ldapServicePassword = "service"
+)
+
+// ldapEntry is an entry of the fake directory and the password binding as it takes
+type ldapEntry struct {
+	dn       string
+	password string
+	attrs    map[string]string
+}
+
+// fakeDirectory is an LDAP server answering binds and searches. Searches return the
+// entries listed under their exact filter. Like many real directories it accepts a bind
+// with an empty password as an unauthenticated bind.
+type fakeDirectory struct {
+	entries map[string][]ldapEntry
+
+	mu    sync.Mutex
+	binds []string // DNs of successful binds with a password
+}
+
+// newFakeDirectory serves d on a local port and returns its ldap:// URL
+func newFakeDirectory(t *testing.T, d *fakeDirectory) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return "ldap://" + ln.Addr().String()
+}
+
+func (d *fakeDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		req, err := ber.ReadPacket(conn)
+		if err != nil || len(req.Children) < 2 {
+			return
+		}
+		id := req.Children[0].Value.(int64)
+		op := req.Children[1]
+		switch op.Tag {
+		case ldapBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			conn.Write(ldapResult(id, ldapBindResponse, d.bind(dn, password)))
+		case ldapSearchRequest:
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				conn.Write(ldapResult(id, ldapSearchDone, ldap.LDAPResultProtocolError))
+				continue
+			}
+			for _, e := range d.entries[filter] {
+				conn.Write(ldapSearchResult(id, e))
+			}
+			conn.Write(ldapResult(id, ldapSearchDone, ldap.LDAPResultSuccess))
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *fakeDirectory) bind(dn, password string) int64 {
+	if password == "" {
+		return ldap.LDAPResultSuccess
+	}
+	ok := dn == ldapServiceDN && password == ldapServicePassword
+	for _, entries := range d.entries {
+		for _, e := range entries {
+			ok = ok || (e.dn == dn && e.password == password)
+		}
+	}
+	if !ok {
+		return ldap.LDAPResultInvalidCredentials
+	}
+	d.mu.Lock()
+	d.binds = append(d.binds, dn)
+	d.mu.Unlock()
+	return ldap.LDAPResultSuccess
+}
+
+func (d *fakeDirectory) boundAs(dn string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, b := range d.binds {
+		if b == dn {
+			return true
+		}
+	}
+	return false
+}
+
+func ldapMessage(id int64, op *ber.Packet) []byte {
+	msg := ber.NewSequence("LDAPMessage")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	msg.AppendChild(op)
+	return msg.Bytes()
+}
+
+func ldapResult(id int64, tag ber.Tag, code int64) []byte {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return ldapMessage(id, op)
+}
+
+func ldapSearchResult(id int64, e ldapEntry) []byte {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchEntry, nil, "SearchResultEntry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+	attrs := ber.NewSequence("attributes")
+	for name, value := range e.attrs {
+		attr := ber.NewSequence("attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return ldapMessage(id, op)
+}
+
+// testDirectory holds alice, a student with an entryUUID, bob, a staff member without
+// one, and two entries both named "twin"
+func testDirectory() *fakeDirectory {
+	return &fakeDirectory{entries: map[string][]ldapEntry{
+		"(uid=alice)": {{dn: "uid=alice,ou=people,dc=example,dc=edu", password: "alice-pw", attrs: map[string]string{
+			"entryUUID": "5f1c2a6e-0000-4000-8000-000000000001", "uid": "alice", "studentNumber": "2023001",
+		}}},
+		"(uid=bob)": {{dn: "uid=bob,ou=people,dc=example,dc=edu", password: "bob-pw", attrs: map[string]string{
+			"uid": "bob", "employeeNumber": "T001",
+		}}},
+		"(uid=twin)": {
+			{dn: "uid=twin,ou=a,dc=example,dc=edu", password: "twin-pw", attrs: map[string]string{"uid": "twin"}},
+			{dn: "uid=twin,ou=b,dc=example,dc=edu", password: "twin-pw", attrs: map[string]string{"uid": "twin"}},
+		},
+	}}
+}
+
+func ldapTestConfig(url string) config.Config {
+	return config.Config{
+		LDAPURL:           url,
+		LDAPBindDN:        ldapServiceDN,
+		LDAPBindPassword:  ldapServicePassword,
+		LDAPBaseDN:        "dc=example,dc=edu",
+		LDAPUserFilter:    "(uid=%s)",
+		LDAPUsernameAttr:  "uid",
+		LDAPStudentNoAttr: "studentNumber",
+		LDAPStaffNoAttr:   "employeeNumber",
+	}
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	dir := testDirectory()
+	d := ldapDirectory{cfg: ldapTestConfig(newFakeDirectory(t, dir))}
+	tests := []struct {
+		username string
+		password string
+		want     ExternalIdentity
+	}{
+		{"alice", "alice-pw", ExternalIdentity{
+			Provider: model.IdentityLDAP, Subject: "5f1c2a6e-0000-4000-8000-000000000001", Username: "alice", StudentNo: "2023001",
+		}},
+		// Without an entryUUID the DN identifies the entry
+		{"bob", "bob-pw", ExternalIdentity{
+			Provider: model.IdentityLDAP, Subject: "uid=bob,ou=people,dc=example,dc=edu", Username: "bob", StaffNo: "T001",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := d.Authenticate(tt.username, tt.password)
+		if err != nil {
+			t.Errorf("Authenticate(%s): %v", tt.username, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("Authenticate(%s) = %+v, want %+v", tt.username, *got, tt.want)
+		}
+	}
+}
+
+func TestLDAPAuthenticateRejects(t *testing.T) {
+	dir := testDirectory()
+	d := ldapDirectory{cfg: ldapTestConfig(newFakeDirectory(t, dir))}
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice", "bob-pw"},
+		{"unknown user", "carol", "carol-pw"},
+		{"ambiguous username", "twin", "twin-pw"},
+		{"filter injection", "*", "alice-pw"},
+		{"filter injection in parentheses", "alice)(uid=*", "alice-pw"},
+		// The directory accepts an empty password as an unauthenticated bind
+		{"empty password", "alice", ""},
+	}
+	for _, tt := range tests {
+		identity, err := d.Authenticate(tt.username, tt.password)
+		if !errors.Is(err, errExternalCredentials) {
+			t.Errorf("%s: Authenticate = %+v, %v; want errExternalCredentials", tt.name, identity, err)
+		}
+	}
+	if dir.boundAs("uid=alice,ou=people,dc=example,dc=edu") {
+		t.Error("bound as alice without her password")
+	}
+}
+
+func TestLDAPAuthenticateServiceBind(t *testing.T) {
+	cfg := ldapTestConfig(newFakeDirectory(t, testDirectory()))
+	cfg.LDAPBindPassword = "wrong"
+	_, err := ldapDirectory{cfg: cfg}.Authenticate("alice", "alice-pw")
+	// A misconfigured directory is an outage, not a wrong password of the user
+	if err == nil || errors.Is(err, errExternalCredentials) {
+		t.Errorf("Authenticate with a wrong service password: error = %v, want a directory error", err)
+	}
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/totp"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+const (
+	// mfaChallengeTTL is how long the code may be entered after the password
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengePurpose tells challenge tokens apart from access tokens signed with the same key
+	mfaChallengePurpose = "mfa"
+	// mfaSkew is the number of 30 second steps a code may be early or late
+	mfaSkew = 1
+	// recoveryCodeCount is how many recovery codes each enrollment receives
+	recoveryCodeCount = 10
+)
+
+// MFAStatus describes the two-factor authentication of a user
+type MFAStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+// MFASetup carries a new TOTP secret: URI is the otpauth:// provisioning URI to show as
+// a QR code, Secret the same key for manual entry
+type MFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAVerifyRequest is the second login step: the challenge token from Login and a
+// TOTP code or a recovery code
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type mfaChallengeClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// mfaChallenge answers a correct password of a user with two-factor authentication.
+// The challenge token carries no session, so it is no access token.
+func (s *authService) mfaChallenge(user *model.User, now time.Time) (*LoginResponse, error) {
+	claims := mfaChallengeClaims{
+		UserID:  user.ID,
+		Purpose: mfaChallengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   fmt.Sprintf("%d", user.ID),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeSignToken, "sign token failed", err)
+	}
+	return &LoginResponse{
+		ExpiresIn:   int(mfaChallengeTTL / time.Second),
+		MFARequired: true,
+		MFAToken:    signed,
+	}, nil
+}
+
+// VerifyMFA completes a login with the code of the user's authenticator app or one of
+// their recovery codes, and opens the session. Wrong codes count as failed logins.
+func (s *authService) VerifyMFA(req MFAVerifyRequest, client ClientInfo) (*LoginResponse, error) {
+	if req.MFAToken == "" || req.Code == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "mfa_token/code required")
+	}
+	var claims mfaChallengeClaims
+	token, err := jwt.ParseWithClaims(req.MFAToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.cfg.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid || claims.Purpose != mfaChallengePurpose {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidMFAToken, "invalid or expired mfa token")
+	}
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidMFAToken, "invalid or expired mfa token")
+	}
+
+	now := time.Now()
+	if err := s.checkThrottle(mfaVerifyRoute, user.Username, client, now); err != nil {
+		return nil, err
+	}
+	mfa, err := s.mfaRepo.FindByUser(user.ID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if mfa == nil || mfa.EnabledAt == nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidMFAToken, "invalid or expired mfa token")
+	}
+	ok, err := s.checkMFACode(s.mfaRepo, mfa, req.Code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		appErr := pkg.NewAppError(pkg.ErrCodeInvalidMFACode, "invalid verification code")
+		return nil, s.loginFailed(mfaVerifyRoute, auditLoginMFAFailed, user.Username, user, client, appErr, now)
+	}
+	if err := s.throttle.store.Reset(userThrottleKey(user.Username)); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	var session *model.AuthSession
+	var refresh string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		session, refresh, err = s.openSession(s.sessionRepo.WithTx(tx), user.ID, client, now)
+		return err
+	})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return s.tokens(user, session.ID, refresh, now)
+}
+
+// MFAStatus reports whether the user has enrolled and whether their role requires it
+func (s *authService) MFAStatus(userID uint) (*MFAStatus, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUserNotFound, "user not found", err)
+	}
+	mfa, err := s.mfaRepo.FindByUser(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	status := &MFAStatus{Required: s.mfaRequired(user.Role)}
+	if mfa != nil && mfa.EnabledAt != nil {
+		status.Enabled = true
+		if status.RecoveryCodesLeft, err = s.mfaRepo.CountRecoveryCodes(userID); err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+	}
+	return status, nil
+}
+
+// SetupMFA starts an enrollment with a new secret; it replaces an earlier pending one
+// and takes effect once EnableMFA verifies a first code
+func (s *authService) SetupMFA(userID uint) (*MFASetup, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUserNotFound, "user not found", err)
+	}
+	mfa, err := s.mfaRepo.FindByUser(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if mfa != nil && mfa.EnabledAt != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeMFAState, "two-factor authentication already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "generate secret failed", err)
+	}
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "seal secret failed", err)
+	}
+	if err := s.mfaRepo.Save(&model.UserMFA{UserID: userID, Secret: sealed}); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return &MFASetup{Secret: secret, URI: totp.URI(s.cfg.MFAIssuer, user.Username, secret)}, nil
+}
+
+// EnableMFA confirms a pending enrollment with a code from the app and returns the
+// recovery codes, which are shown this once
+func (s *authService) EnableMFA(userID uint, code string) ([]string, error) {
+	if code == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "code required")
+	}
+	mfa, err := s.mfaRepo.FindByUser(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if mfa == nil || mfa.EnabledAt != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeMFAState, "no pending two-factor setup")
+	}
+	secret, err := s.openSecret(mfa.Secret)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "open secret failed", err)
+	}
+	now := time.Now()
+	step, ok := totp.Validate(secret, normalizeMFACode(code), now, mfaSkew)
+	if !ok {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidMFACode, "invalid verification code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "generate recovery codes failed", err)
+	}
+	enabled := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txMFARepo := s.mfaRepo.WithTx(tx)
+		if enabled, err = txMFARepo.Enable(userID, now, step); err != nil || !enabled {
+			return err
+		}
+		return txMFARepo.ReplaceRecoveryCodes(userID, hashes)
+	})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if !enabled {
+		return nil, pkg.NewAppError(pkg.ErrCodeMFAState, "no pending two-factor setup")
+	}
+	return codes, nil
+}
+
+// DisableMFA removes the user's enrollment after a last code; roles listed in
+// MFA_REQUIRED_ROLES cannot opt out
+func (s *authService) DisableMFA(userID uint, code string, client ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeUserNotFound, "user not found", err)
+	}
+	if s.mfaRequired(user.Role) {
+		return pkg.NewAppError(pkg.ErrCodeForbidden, "two-factor authentication is required for this role")
+	}
+	if err := s.confirmMFA(mfaDisableRoute, user, code, client); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(userID); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user after a code
+func (s *authService) RegenerateRecoveryCodes(userID uint, code string, client ClientInfo) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUserNotFound, "user not found", err)
+	}
+	if err := s.confirmMFA(mfaRecoveryRoute, user, code, client); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "generate recovery codes failed", err)
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.mfaRepo.WithTx(tx).ReplaceRecoveryCodes(userID, hashes)
+	}); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return codes, nil
+}
+
+// confirmMFA checks a code of an enabled enrollment before it is changed. Wrong codes
+// count against the username and client IP like those of a login, so a stolen access
+// token cannot be used to guess them.
+func (s *authService) confirmMFA(route string, user *model.User, code string, client ClientInfo) error {
+	if code == "" {
+		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "code required")
+	}
+	now := time.Now()
+	if err := s.checkThrottle(route, user.Username, client, now); err != nil {
+		return err
+	}
+	mfa, err := s.mfaRepo.FindByUser(user.ID)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if mfa == nil || mfa.EnabledAt == nil {
+		return pkg.NewAppError(pkg.ErrCodeMFAState, "two-factor authentication not enabled")
+	}
+	ok, err := s.checkMFACode(s.mfaRepo, mfa, code, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		appErr := pkg.NewAppError(pkg.ErrCodeInvalidMFACode, "invalid verification code")
+		return s.loginFailed(route, auditLoginMFAFailed, user.Username, user, client, appErr, now)
+	}
+	if err := s.throttle.store.Reset(userThrottleKey(user.Username)); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return nil
+}
+
+// checkMFACode accepts a TOTP code not used before, or an unused recovery code, which
+// is then spent
+func (s *authService) checkMFACode(mfaRepo repository.MFARepository, mfa *model.UserMFA, code string, now time.Time) (bool, error) {
+	code = normalizeMFACode(code)
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		secret, err := s.openSecret(mfa.Secret)
+		if err != nil {
+			return false, pkg.WrapError(pkg.ErrCodeDBError, "open secret failed", err)
+		}
+		step, ok := totp.Validate(secret, code, now, mfaSkew)
+		if !ok {
+			return false, nil
+		}
+		fresh, err := mfaRepo.AdvanceStep(mfa.UserID, step)
+		if err != nil {
+			return false, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		return fresh, nil
+	}
+	used, err := mfaRepo.UseRecoveryCode(mfa.UserID, hashToken(code), now)
+	if err != nil {
+		return false, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return used, nil
+}
+
+// mfaRequired reports whether MFA_REQUIRED_ROLES lists the role
+func (s *authService) mfaRequired(role string) bool {
+	for r := range strings.SplitSeq(s.cfg.MFARequiredRoles, ",") {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// mfaSetupRequired reports whether the user's role requires an enrollment they lack
+func (s *authService) mfaSetupRequired(user *model.User) (bool, error) {
+	if !s.mfaRequired(user.Role) {
+		return false, nil
+	}
+	mfa, err := s.mfaRepo.FindByUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return mfa == nil || mfa.EnabledAt == nil, nil
+}
+
+// normalizeMFACode drops the spaces and dashes users type into codes
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code))
+}
+
+// newRecoveryCodes returns recovery codes formatted as xxxxx-xxxxx and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(enc.EncodeToString(b)[:10])
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// mfaKey returns the AES-256 key sealing TOTP secrets: MFA_SECRET_KEY, or a key derived
+// from JWT_SECRET when that is not set
+func (s *authService) mfaKey() ([]byte, error) {
+	if s.cfg.MFASecretKey == "" {
+		sum := sha256.Sum256([]byte("edumgr-mfa:" + s.cfg.JWTSecret))
+		return sum[:], nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s.cfg.MFASecretKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("MFA_SECRET_KEY must be 32 bytes in base64")
+	}
+	return key, nil
+}
+
+func (s *authService) mfaCipher() (cipher.AEAD, error) {
+	key, err := s.mfaKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSecret encrypts a TOTP secret as base64 of nonce and ciphertext
+func (s *authService) sealSecret(secret string) (string, error) {
+	aead, err := s.mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func (s *authService) openSecret(sealed string) (string, error) {
+	aead, err := s.mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", errors.New("malformed sealed secret")
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/totp"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// memMFARepo keeps one enrollment's last accepted step, as the user_mfa row does
+type memMFARepo struct {
+	repository.MFARepository
+	mfa      *model.UserMFA
+	lastStep int64
+	recovery map[string]bool
+}
+
+// FindByUser finds mfa, which checkMFACode tests leave nil
+func (r *memMFARepo) FindByUser(userID uint) (*model.UserMFA, error) {
+	return r.mfa, nil
+}
+
+func (r *memMFARepo) AdvanceStep(userID uint, step int64) (bool, error) {
+	if step <= r.lastStep {
+		return false, nil
+	}
+	r.lastStep = step
+	return true, nil
+}
+
+func (r *memMFARepo) UseRecoveryCode(userID uint, hash string, at time.Time) (bool, error) {
+	if !r.recovery[hash] {
+		return false, nil
+	}
+	delete(r.recovery, hash)
+	return true, nil
+}
+
+func newMFATestEnrollment(t *testing.T) (*authService, *model.UserMFA, string) {
+	t.Helper()
+	s := &authService{cfg: config.Config{JWTSecret: "test"}}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, &model.UserMFA{UserID: 1, Secret: sealed}, secret
+}
+
+func TestCheckMFACodeRefusesReplay(t *testing.T) {
+	s, mfa, secret := newMFATestEnrollment(t)
+	repo := &memMFARepo{}
+	now := time.Unix(1700000000, 0)
+	code, err := totp.Code(secret, totp.Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		at   time.Time
+		code string
+		want bool
+	}{
+		{"first use", now, code, true},
+		{"same code again", now, code, false},
+		{"same code in the next step", now.Add(totp.Period), code, false},
+	}
+	for _, st := range steps {
+		ok, err := s.checkMFACode(repo, mfa, st.code, st.at)
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if ok != st.want {
+			t.Errorf("%s: checkMFACode = %v, want %v", st.name, ok, st.want)
+		}
+	}
+
+	// A code from an earlier step inside the skew window is refused once a later one was used
+	earlier, err := totp.Code(secret, totp.Step(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.checkMFACode(repo, mfa, earlier, now); ok {
+		t.Error("code of an earlier step accepted after a later one")
+	}
+	next, err := totp.Code(secret, totp.Step(now)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.checkMFACode(repo, mfa, next, now); !ok {
+		t.Error("code of the next step refused")
+	}
+}
+
+func TestCheckMFACodeRecoveryCodeOnce(t *testing.T) {
+	s, mfa, _ := newMFATestEnrollment(t)
+	const code = "abcd-efgh-ijkl"
+	repo := &memMFARepo{recovery: map[string]bool{hashToken(normalizeMFACode(code)): true}}
+	for i, want := range []bool{true, false} {
+		ok, err := s.checkMFACode(repo, mfa, code, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("use %d of recovery code: checkMFACode = %v, want %v", i+1, ok, want)
+		}
+	}
+}
+
+// Wrong codes when changing an enrollment count against the login throttle
+func TestConfirmMFAThrottlesWrongCodes(t *testing.T) {
+	s, mfa, _ := newMFATestEnrollment(t)
+	enabled := time.Now()
+	mfa.EnabledAt = &enabled
+	audit := &recordingAudit{}
+	s.cfg.LoginMaxFailures, s.cfg.LoginIPMaxFailures, s.cfg.LoginLockoutMinutes = 5, 20, 15
+	s.userRepo = &memUserRepo{users: []*model.User{{ID: 1, Username: "alice", Role: model.RoleStudent}}}
+	s.mfaRepo = &memMFARepo{mfa: mfa}
+	s.throttle = newTestThrottle()
+	s.audit = audit
+	client := ClientInfo{IP: "10.0.0.1"}
+
+	if _, err := s.RegenerateRecoveryCodes(1, "000000", client); appErrCode(err) != pkg.ErrCodeInvalidMFACode {
+		t.Fatalf("wrong code: err = %v", err)
+	}
+	if got := audit.actions(); len(got) != 1 || got[0] != auditLoginMFAFailed {
+		t.Errorf("audit actions = %v, want [%s]", got, auditLoginMFAFailed)
+	}
+	// The failure blocks the user for a second, for disabling as well
+	if err := s.DisableMFA(1, "000000", client); appErrCode(err) != pkg.ErrCodeTooManyAttempts {
+		t.Errorf("retry: err = %v, want too many attempts", err)
+	}
+}
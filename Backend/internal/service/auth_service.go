@@ -1,31 +1,59 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/oidc"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
-// LoginResponse represents the login response
+// LoginResponse represents the login and refresh response.
+// Token is the short-lived access token; RefreshToken is single-use and replaced on every refresh.
+// For users with two-factor authentication, Login returns only MFAToken, to be exchanged
+// for the tokens together with a code at VerifyMFA; ExpiresIn is then its lifetime.
 type LoginResponse struct {
-	Token string      `json:"token"`
-	User  UserSummary `json:"user"`
+	Token        string       `json:"token,omitempty"`
+	ExpiresIn    int          `json:"expires_in"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	User         *UserSummary `json:"user,omitempty"`
+	MFARequired  bool         `json:"mfa_required,omitempty"`
+	MFAToken     string       `json:"mfa_token,omitempty"`
+}
+
+// ClientInfo identifies the client a login comes from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+	RequestID string
 }
 
 // UserSummary represents user summary info
 type UserSummary struct {
-	ID        uint   `json:"id"`
-	Username  string `json:"username"`
-	Role      string `json:"role"`
-	StudentID *uint  `json:"student_id,omitempty"`
-	StaffID   *uint  `json:"staff_id,omitempty"`
+	ID                 uint   `json:"id"`
+	Username           string `json:"username"`
+	Role               string `json:"role"`
+	StudentID          *uint  `json:"student_id,omitempty"`
+	StaffID            *uint  `json:"staff_id,omitempty"`
+	DeptID             *uint  `json:"dept_id,omitempty"`
+	MustChangePassword bool   `json:"must_change_password"`
+	MFASetupRequired   bool   `json:"mfa_setup_required"`
+	// Permissions are those of the role, for the client to show what the user may do
+	Permissions []string `json:"permissions,omitempty"`
 }
 
 // SetupRequest represents the initial admin setup request
@@ -34,9 +62,28 @@ type SetupRequest struct {
 	Password string `json:"password"`
 }
 
+// ChangePasswordRequest represents a user's change of their own password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
 // AuthService defines the interface for authentication business logic
 type AuthService interface {
-	Login(username, password string) (*LoginResponse, error)
+	Login(username, password string, client ClientInfo) (*LoginResponse, error)
+	Refresh(refreshToken string) (*LoginResponse, error)
+	Logout(refreshToken string) error
+	ChangePassword(userID uint, req ChangePasswordRequest, client ClientInfo) (*LoginResponse, error)
+	VerifyMFA(req MFAVerifyRequest, client ClientInfo) (*LoginResponse, error)
+	Providers() AuthProviders
+	OIDCStart() (*OIDCStartResponse, error)
+	OIDCCallback(req OIDCCallbackRequest, client ClientInfo) (*LoginResponse, error)
+	MFAStatus(userID uint) (*MFAStatus, error)
+	SetupMFA(userID uint) (*MFASetup, error)
+	EnableMFA(userID uint, code string) ([]string, error)
+	DisableMFA(userID uint, code string, client ClientInfo) error
+	RegenerateRecoveryCodes(userID uint, code string, client ClientInfo) ([]string, error)
+	SessionActive(sessionID, userID uint) bool
 	GetCurrentUser(userID uint) (*UserSummary, error)
 	CreateUser(user *model.User, password string) error
 	Setup(req SetupRequest) (*UserSummary, error)
@@ -44,45 +91,408 @@ type AuthService interface {
 }
 
 type authService struct {
-	userRepo repository.UserRepository
-	cfg      config.Config
+	userRepo     repository.UserRepository
+	sessionRepo  repository.SessionRepository
+	mfaRepo      repository.MFARepository
+	identityRepo repository.IdentityRepository
+	studentRepo  repository.StudentRepository
+	staffRepo    repository.StaffRepository
+	throttle     loginThrottle
+	audit        AuditService
+	roles        RoleService
+	db           *gorm.DB
+	cfg          config.Config
+
+	// External identity providers; nil when not configured
+	oidc *oidc.Provider
+	ldap passwordProvider
 }
 
 // NewAuthService creates a new AuthService
-func NewAuthService(userRepo repository.UserRepository, cfg config.Config) AuthService {
-	return &authService{
-		userRepo: userRepo,
-		cfg:      cfg,
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	mfaRepo repository.MFARepository,
+	identityRepo repository.IdentityRepository,
+	studentRepo repository.StudentRepository,
+	staffRepo repository.StaffRepository,
+	throttleStore repository.LoginThrottleStore,
+	audit AuditService,
+	roles RoleService,
+	db *gorm.DB,
+	cfg config.Config,
+) AuthService {
+	s := &authService{
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		mfaRepo:      mfaRepo,
+		identityRepo: identityRepo,
+		studentRepo:  studentRepo,
+		staffRepo:    staffRepo,
+		throttle:     loginThrottle{store: throttleStore, cfg: cfg},
+		audit:        audit,
+		roles:        roles,
+		db:           db,
+		cfg:          cfg,
+	}
+	if cfg.OIDCIssuer != "" {
+		s.oidc = oidc.New(oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       strings.Fields(cfg.OIDCScopes),
+		})
 	}
+	if cfg.LDAPURL != "" {
+		s.ldap = ldapDirectory{cfg: cfg}
+	}
+	return s
 }
 
-func (s *authService) Login(username, password string) (*LoginResponse, error) {
+// Login checks the password and opens a session with its first refresh token. A password
+// the account does not have is tried at the LDAP directory when one is configured.
+// Failed attempts are throttled per username and per client IP, see loginThrottle.
+func (s *authService) Login(username, password string, client ClientInfo) (*LoginResponse, error) {
 	if username == "" || password == "" {
 		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "username/password required")
 	}
 
+	now := time.Now()
+	if err := s.checkThrottle(loginRoute, username, client, now); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByUsername(username)
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	} else {
+		user = nil
+	}
+	// Passwords the accounts table does not know may still be the directory's
+	if err != nil && s.ldap != nil {
+		identity, ldapErr := s.ldap.Authenticate(username, password)
+		switch {
+		case ldapErr == nil:
+			if user, err = s.resolveIdentity(identity, now); err != nil {
+				return nil, s.externalLoginRefused(loginRoute, username, client, err)
+			}
+		case !errors.Is(ldapErr, errExternalCredentials):
+			return nil, pkg.WrapError(pkg.ErrCodeIdPError, "directory unavailable", ldapErr)
+		}
+	}
 	if err != nil {
-		return nil, pkg.NewAppError(pkg.ErrCodeInvalidCredentials, "invalid username or password")
+		appErr := pkg.NewAppError(pkg.ErrCodeInvalidCredentials, "invalid username or password")
+		return nil, s.loginFailed(loginRoute, auditLoginFailed, username, user, client, appErr, now)
 	}
+	return s.completeLogin(user, client, now)
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, pkg.NewAppError(pkg.ErrCodeInvalidCredentials, "invalid username or password")
+// completeLogin opens a session for a user whose credentials were checked, or asks for
+// the second factor first when the user has two-factor authentication
+func (s *authService) completeLogin(user *model.User, client ClientInfo, now time.Time) (*LoginResponse, error) {
+	// The failure count is cleared only once the second factor is verified as well,
+	// so that it keeps limiting guesses of the code
+	mfa, err := s.mfaRepo.FindByUser(user.ID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if mfa != nil && mfa.EnabledAt != nil {
+		return s.mfaChallenge(user, now)
+	}
+	if err := s.throttle.store.Reset(userThrottleKey(user.Username)); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
 
-	// Generate JWT using RegisteredClaims for proper parsing
+	var session *model.AuthSession
+	var refresh string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		session, refresh, err = s.openSession(s.sessionRepo.WithTx(tx), user.ID, client, now)
+		return err
+	})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	return s.tokens(user, session.ID, refresh, now)
+}
+
+// openSession creates a session of the user with its first refresh token
+func (s *authService) openSession(sessionRepo repository.SessionRepository, userID uint, client ClientInfo, now time.Time) (*model.AuthSession, string, error) {
+	session := &model.AuthSession{
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		LastUsedAt: now,
+	}
+	if err := sessionRepo.CreateSession(session); err != nil {
+		return nil, "", err
+	}
+	refresh, err := s.newRefreshToken(sessionRepo, session.ID, now)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, refresh, nil
+}
+
+// checkThrottle refuses a login step while the username or the client IP is blocked
+func (s *authService) checkThrottle(route, username string, client ClientInfo, now time.Time) error {
+	keys := []string{userThrottleKey(username), ipThrottleKey(client.IP)}
+	wait, locked, err := s.throttle.blocked(keys, now)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if wait <= 0 {
+		return nil
+	}
+	retryAfter := int(wait.Round(time.Second) / time.Second)
+	appErr := pkg.NewAppErrorWithDetails(pkg.ErrCodeTooManyAttempts, "too many failed logins, retry later",
+		map[string]any{"retry_after": max(retryAfter, 1), "locked": locked})
+	if locked {
+		s.recordLogin(route, auditLoginRefused, username, nil, client, appErr)
+	}
+	return appErr
+}
+
+// loginFailed records a failed login step and counts it against the username and the
+// client IP; it returns appErr unless counting fails
+func (s *authService) loginFailed(route, action, username string, user *model.User, client ClientInfo, appErr *pkg.AppError, now time.Time) error {
+	s.recordLogin(route, action, username, user, client, appErr)
+	userLocked, err := s.throttle.fail(userThrottleKey(username), s.cfg.LoginMaxFailures, now)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	ipLocked, err := s.throttle.fail(ipThrottleKey(client.IP), s.cfg.LoginIPMaxFailures, now)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if userLocked || ipLocked {
+		s.recordLogin(route, auditLoginLocked, username, user, client, appErr)
+	}
+	return appErr
+}
+
+// recordLogin adds a refused login to the audit log; user is nil for unknown usernames
+func (s *authService) recordLogin(route, action, username string, user *model.User, client ClientInfo, appErr *pkg.AppError) {
+	entry := &model.AuditLog{
+		Method:     http.MethodPost,
+		Route:      route,
+		EntityType: "auth",
+		EntityID:   username,
+		Action:     action,
+		Outcome:    model.AuditFailure,
+		Status:     http.StatusUnauthorized,
+		ErrorCode:  appErr.Code,
+		Message:    appErr.Message,
+		RequestID:  client.RequestID,
+		RemoteIP:   client.IP,
+	}
+	if appErr.Code == pkg.ErrCodeTooManyAttempts {
+		entry.Status = http.StatusTooManyRequests
+	}
+	if user != nil {
+		entry.UserID = user.ID
+		entry.Role = user.Role
+	}
+	s.audit.Record(entry, nil, nil)
+}
+
+// Refresh rotates a refresh token: it is marked used and a new access and refresh token
+// are issued. A token presented again after its rotation reveals a copy in other hands,
+// so the whole session is revoked.
+func (s *authService) Refresh(refreshToken string) (*LoginResponse, error) {
+	if refreshToken == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "refresh_token required")
+	}
+	token, err := s.sessionRepo.FindRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRefresh, "invalid refresh token")
+	}
+	session, err := s.sessionRepo.FindSession(token.SessionID)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRefresh, "invalid refresh token")
+	}
+	if session.RevokedAt != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeSessionRevoked, "session revoked")
+	}
 	now := time.Now()
+	if token.UsedAt != nil {
+		return nil, s.revokeReused(session.ID, now)
+	}
+	if now.After(token.ExpiresAt) {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRefresh, "refresh token expired")
+	}
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRefresh, "invalid refresh token")
+	}
+
+	var refresh string
+	reused := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txSessionRepo := s.sessionRepo.WithTx(tx)
+		used, err := txSessionRepo.UseRefreshToken(token.ID, now)
+		if err != nil {
+			return err
+		}
+		if !used {
+			// Lost the race against another refresh with the same token
+			reused = true
+			return nil
+		}
+		if err := txSessionRepo.TouchSession(session.ID, now); err != nil {
+			return err
+		}
+		refresh, err = s.newRefreshToken(txSessionRepo, session.ID, now)
+		return err
+	})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if reused {
+		return nil, s.revokeReused(session.ID, now)
+	}
+
+	return s.tokens(user, session.ID, refresh, now)
+}
+
+func (s *authService) revokeReused(sessionID uint, now time.Time) error {
+	if _, err := s.sessionRepo.Revoke(sessionID, model.SessionReuse, now); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return pkg.NewAppError(pkg.ErrCodeRefreshReused, "refresh token reused, session revoked")
+}
+
+// Logout revokes the session of a refresh token, and with it the session's access tokens
+func (s *authService) Logout(refreshToken string) error {
+	if refreshToken == "" {
+		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "refresh_token required")
+	}
+	token, err := s.sessionRepo.FindRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return pkg.NewAppError(pkg.ErrCodeInvalidRefresh, "invalid refresh token")
+	}
+	if _, err := s.sessionRepo.Revoke(token.SessionID, model.SessionLogout, time.Now()); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return nil
+}
+
+// ChangePassword sets a user's own password after checking the current one and the
+// password policy, and clears MustChangePassword. All sessions of the user are revoked,
+// the caller's included, and the caller continues in a new session.
+func (s *authService) ChangePassword(userID uint, req ChangePasswordRequest, client ClientInfo) (*LoginResponse, error) {
+	if req.OldPassword == "" || req.NewPassword == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "old_password/new_password required")
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUserNotFound, "user not found", err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.OldPassword)); err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeWrongPassword, "current password is incorrect")
+	}
+	if req.NewPassword == req.OldPassword {
+		return nil, pkg.NewAppErrorWithDetails(pkg.ErrCodeWeakPassword, "password does not meet the policy",
+			map[string]any{"violations": []string{"must differ from the current password"}})
+	}
+	if err := checkPassword(s.cfg, user.Username, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "hash password failed", err)
+	}
+	user.PasswordHash = string(hash)
+	user.MustChangePassword = false
+
+	now := time.Now()
+	var session *model.AuthSession
+	var refresh string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.userRepo.WithTx(tx).Update(user); err != nil {
+			return err
+		}
+		txSessionRepo := s.sessionRepo.WithTx(tx)
+		if _, err := txSessionRepo.RevokeByUser(user.ID, model.SessionPasswordChanged, now); err != nil {
+			return err
+		}
+		session, refresh, err = s.openSession(txSessionRepo, user.ID, client, now)
+		return err
+	})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update password failed", err)
+	}
+
+	return s.tokens(user, session.ID, refresh, now)
+}
+
+// SessionActive reports whether an access token's session may still be used.
+// It implements middleware.SessionValidator and fails closed on database errors.
+func (s *authService) SessionActive(sessionID, userID uint) bool {
+	active, err := s.sessionRepo.IsActive(sessionID, userID)
+	return err == nil && active
+}
+
+// newRefreshToken stores the hash of a new random refresh token and returns the token
+func (s *authService) newRefreshToken(sessionRepo repository.SessionRepository, sessionID uint, now time.Time) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	err := sessionRepo.CreateRefreshToken(&model.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.AddDate(0, 0, s.cfg.RefreshTokenDays),
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// scopeDept is the department a user with deptID is limited to, or 0 for every department
+func scopeDept(deptID *uint) uint {
+	if deptID == nil {
+		return 0
+	}
+	return *deptID
+}
+
+// tokens signs an access token for the session and assembles the response
+func (s *authService) tokens(user *model.User, sessionID uint, refresh string, now time.Time) (*LoginResponse, error) {
+	mfaSetup, err := s.mfaSetupRequired(user)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	// Generate JWT using RegisteredClaims for proper parsing
 	exp := now.Add(time.Duration(s.cfg.JWTExpiresMinutes) * time.Minute)
 
 	type JWTClaims struct {
-		UserID uint   `json:"user_id"`
-		Role   string `json:"role"`
+		UserID             uint   `json:"user_id"`
+		Role               string `json:"role"`
+		SessionID          uint   `json:"sid"`
+		DeptID             uint   `json:"dept,omitempty"`
+		MustChangePassword bool   `json:"mcp,omitempty"`
+		MFASetup           bool   `json:"mfa_setup,omitempty"`
 		jwt.RegisteredClaims
 	}
 
 	claims := JWTClaims{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:             user.ID,
+		Role:               user.Role,
+		SessionID:          sessionID,
+		DeptID:             scopeDept(user.DeptID),
+		MustChangePassword: user.MustChangePassword,
+		MFASetup:           mfaSetup,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -97,13 +507,19 @@ func (s *authService) Login(username, password string) (*LoginResponse, error) {
 	}
 
 	return &LoginResponse{
-		Token: signed,
-		User: UserSummary{
-			ID:        user.ID,
-			Username:  user.Username,
-			Role:      user.Role,
-			StudentID: user.StudentID,
-			StaffID:   user.StaffID,
+		Token:        signed,
+		ExpiresIn:    s.cfg.JWTExpiresMinutes * 60,
+		RefreshToken: refresh,
+		User: &UserSummary{
+			ID:                 user.ID,
+			Username:           user.Username,
+			Role:               user.Role,
+			StudentID:          user.StudentID,
+			StaffID:            user.StaffID,
+			DeptID:             user.DeptID,
+			MustChangePassword: user.MustChangePassword,
+			MFASetupRequired:   mfaSetup,
+			Permissions:        s.roles.Permissions(user.Role),
 		},
 	}, nil
 }
@@ -115,11 +531,14 @@ func (s *authService) GetCurrentUser(userID uint) (*UserSummary, error) {
 	}
 
 	return &UserSummary{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		StudentID: user.StudentID,
-		StaffID:   user.StaffID,
+		ID:                 user.ID,
+		Username:           user.Username,
+		Role:               user.Role,
+		StudentID:          user.StudentID,
+		StaffID:            user.StaffID,
+		DeptID:             user.DeptID,
+		MustChangePassword: user.MustChangePassword,
+		Permissions:        s.roles.Permissions(user.Role),
 	}, nil
 }
 
@@ -127,6 +546,9 @@ func (s *authService) CreateUser(user *model.User, password string) error {
 	if user.Username == "" || password == "" {
 		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "username/password required")
 	}
+	if err := checkPassword(s.cfg, user.Username, password); err != nil {
+		return err
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -164,6 +586,9 @@ func (s *authService) Setup(req SetupRequest) (*UserSummary, error) {
 	if !required {
 		return nil, pkg.NewAppError(pkg.ErrCodeForbidden, "setup already completed")
 	}
+	if err := checkPassword(s.cfg, req.Username, req.Password); err != nil {
+		return nil, err
+	}
 
 	// Create admin user
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -174,7 +599,7 @@ func (s *authService) Setup(req SetupRequest) (*UserSummary, error) {
 	user := &model.User{
 		Username:     req.Username,
 		PasswordHash: string(hash),
-		Role:         "admin",
+		Role:         model.RoleAdmin,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
@@ -182,8 +607,9 @@ func (s *authService) Setup(req SetupRequest) (*UserSummary, error) {
 	}
 
 	return &UserSummary{
-		ID:       user.ID,
-		Username: user.Username,
-		Role:     user.Role,
+		ID:          user.ID,
+		Username:    user.Username,
+		Role:        user.Role,
+		Permissions: s.roles.Permissions(user.Role),
 	}, nil
 }
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/oidc"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+const (
+	// oidcFlowTTL is how long a sign-in at the identity provider may take
+	oidcFlowTTL = 10 * time.Minute
+	// oidcCallbackRoute is the route completing OIDC sign-ins, as recorded in the audit log
+	oidcCallbackRoute = "/auth/oidc/callback"
+)
+
+// errExternalCredentials is returned by password providers for a wrong username or password
+var errExternalCredentials = errors.New("invalid credentials")
+
+// ExternalIdentity is a user as an identity provider describes them. Subject is the
+// provider's stable ID; StudentNo and StaffNo, when the provider knows them, link the
+// identity to the student or staff record the account belongs to.
+type ExternalIdentity struct {
+	Provider  string
+	Subject   string
+	Username  string
+	StudentNo string
+	StaffNo   string
+}
+
+// passwordProvider checks a username and password at an external directory
+type passwordProvider interface {
+	Authenticate(username, password string) (*ExternalIdentity, error)
+}
+
+// AuthProviders tells the login page which sign-in methods are enabled
+type AuthProviders struct {
+	Password bool `json:"password"`
+	OIDC     bool `json:"oidc"`
+	LDAP     bool `json:"ldap"`
+}
+
+// OIDCStartResponse sends the browser to the identity provider. The client keeps State
+// and only completes a callback that returns the same state.
+type OIDCStartResponse struct {
+	AuthURL string `json:"auth_url"`
+	State   string `json:"state"`
+}
+
+// OIDCCallbackRequest carries the code and state the identity provider redirected with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// oidcFlow is what the callback needs of its sign-in. It travels sealed as the state,
+// so the server keeps nothing between the two steps.
+type oidcFlow struct {
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func (s *authService) Providers() AuthProviders {
+	return AuthProviders{Password: true, OIDC: s.oidc != nil, LDAP: s.ldap != nil}
+}
+
+// OIDCStart begins an authorization code sign-in with PKCE
+func (s *authService) OIDCStart() (*OIDCStartResponse, error) {
+	if s.oidc == nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeSSODisabled, "oidc sign-in is not enabled")
+	}
+	nonce, err := oidc.RandomToken()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeSignToken, "sign token failed", err)
+	}
+	verifier, err := oidc.RandomToken()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeSignToken, "sign token failed", err)
+	}
+	flow, err := json.Marshal(oidcFlow{Nonce: nonce, Verifier: verifier, ExpiresAt: time.Now().Add(oidcFlowTTL).Unix()})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeSignToken, "sign token failed", err)
+	}
+	state, err := s.sealSecret(string(flow))
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeSignToken, "sign token failed", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	authURL, err := s.oidc.AuthURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeIdPError, "identity provider unavailable", err)
+	}
+	return &OIDCStartResponse{AuthURL: authURL, State: state}, nil
+}
+
+// OIDCCallback redeems the code of a completed sign-in at the identity provider and
+// logs in as the account linked to the identity, see resolveIdentity
+func (s *authService) OIDCCallback(req OIDCCallbackRequest, client ClientInfo) (*LoginResponse, error) {
+	if s.oidc == nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeSSODisabled, "oidc sign-in is not enabled")
+	}
+	if req.Code == "" || req.State == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "code/state required")
+	}
+	now := time.Now()
+	var flow oidcFlow
+	raw, err := s.openSecret(req.State)
+	if err == nil {
+		err = json.Unmarshal([]byte(raw), &flow)
+	}
+	if err != nil || now.Unix() > flow.ExpiresAt {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidSSOState, "invalid or expired sign-in, start again")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	claims, err := s.oidc.Exchange(ctx, req.Code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		appErr := pkg.WrapError(pkg.ErrCodeSSOFailed, "sign-in at the identity provider failed", err)
+		s.recordLogin(oidcCallbackRoute, auditLoginFailed, "", nil, client, appErr)
+		return nil, appErr
+	}
+	identity := &ExternalIdentity{
+		Provider:  model.IdentityOIDC,
+		Subject:   claims.String("sub"),
+		Username:  claims.String(s.cfg.OIDCUsernameClaim),
+		StudentNo: claims.String(s.cfg.OIDCStudentNoClaim),
+		StaffNo:   claims.String(s.cfg.OIDCStaffNoClaim),
+	}
+	if identity.Subject == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeSSOFailed, "id token without subject")
+	}
+	user, err := s.resolveIdentity(identity, now)
+	if err != nil {
+		return nil, s.externalLoginRefused(oidcCallbackRoute, identity.Username, client, err)
+	}
+	return s.completeLogin(user, client, now)
+}
+
+// externalLoginRefused records an external sign-in that found no account to log in as
+func (s *authService) externalLoginRefused(route, username string, client ClientInfo, err error) error {
+	var appErr *pkg.AppError
+	if errors.As(err, &appErr) && appErr.Code == pkg.ErrCodeNoAccount {
+		s.recordLogin(route, auditLoginRefused, username, nil, client, appErr)
+	}
+	return err
+}
+
+// resolveIdentity finds the account an external identity logs in as: the one linked to
+// it, or on its first sign-in the one accountFor picks, which the identity is then
+// linked to
+func (s *authService) resolveIdentity(identity *ExternalIdentity, now time.Time) (*model.User, error) {
+	linked, err := s.identityRepo.FindBySubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if linked != nil {
+		user, err := s.userRepo.FindByID(linked.UserID)
+		if err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		if err := s.identityRepo.Touch(linked.ID, now); err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		return user, nil
+	}
+
+	var user *model.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		user, err = s.accountFor(s.userRepo.WithTx(tx), identity)
+		if err != nil {
+			return err
+		}
+		return s.identityRepo.WithTx(tx).Create(&model.UserIdentity{
+			UserID:      user.ID,
+			Provider:    identity.Provider,
+			Subject:     identity.Subject,
+			LastLoginAt: &now,
+		})
+	})
+	if err != nil {
+		var appErr *pkg.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return user, nil
+}
+
+// accountFor picks the account of an identity signing in for the first time:
+//   - with SSO_LINK_USERNAME, the account of the same username;
+//   - with SSO_AUTO_PROVISION, the account of the student or staff member whose number
+//     the identity carries, created when there is none, as a student or SSO_STAFF_ROLE.
+//
+// Identities matching none of these have no account.
+func (s *authService) accountFor(userRepo repository.UserRepository, identity *ExternalIdentity) (*model.User, error) {
+	if s.cfg.SSOLinkUsername && identity.Username != "" {
+		user, err := userRepo.FindByUsername(identity.Username)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if s.cfg.SSOAutoProvision {
+		if identity.StudentNo != "" {
+			student, err := s.studentRepo.FindByStudentNo(identity.StudentNo)
+			if err == nil {
+				user, err := userRepo.FindByStudentID(student.ID)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return s.provision(userRepo, identity, identity.StudentNo, model.RoleStudent, &student.ID, nil)
+				}
+				return user, err
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+		}
+		if identity.StaffNo != "" {
+			staff, err := s.staffRepo.FindByStaffNo(identity.StaffNo)
+			if err == nil {
+				user, err := userRepo.FindByStaffID(staff.ID)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return s.provision(userRepo, identity, identity.StaffNo, s.cfg.SSOStaffRole, nil, &staff.ID)
+				}
+				return user, err
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+		}
+	}
+	return nil, pkg.NewAppError(pkg.ErrCodeNoAccount, "no account for this identity")
+}
+
+// provision creates the account of an identity, named as at the provider or else by
+// number. It has no password; it signs in through its identity only.
+func (s *authService) provision(userRepo repository.UserRepository, identity *ExternalIdentity, number, role string, studentID, staffID *uint) (*model.User, error) {
+	if !s.roles.Exists(role) {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRole, "unknown role "+role)
+	}
+	username := identity.Username
+	if username == "" {
+		username = number
+	}
+	if _, err := userRepo.FindByUsername(username); err == nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeUsernameTaken, "username "+username+" is taken by another account")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	user := &model.User{Username: username, Role: role, StudentID: studentID, StaffID: staffID}
+	if err := userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,484 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/mockidp"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+type memUserRepo struct {
+	repository.UserRepository
+	users []*model.User
+}
+
+func (r *memUserRepo) find(match func(u *model.User) bool) (*model.User, error) {
+	for _, u := range r.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memUserRepo) FindByID(id uint) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.ID == id })
+}
+
+func (r *memUserRepo) FindByUsername(username string) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.Username == username })
+}
+
+func (r *memUserRepo) FindByStudentID(studentID uint) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.StudentID != nil && *u.StudentID == studentID })
+}
+
+func (r *memUserRepo) FindByStaffID(staffID uint) (*model.User, error) {
+	return r.find(func(u *model.User) bool { return u.StaffID != nil && *u.StaffID == staffID })
+}
+
+func (r *memUserRepo) Create(user *model.User) error {
+	user.ID = uint(1000 + len(r.users))
+	r.users = append(r.users, user)
+	return nil
+}
+
+func (r *memUserRepo) WithTx(tx *gorm.DB) repository.UserRepository { return r }
+
+type memIdentityRepo struct {
+	repository.IdentityRepository
+	identities []*model.UserIdentity
+}
+
+func (r *memIdentityRepo) FindBySubject(provider, subject string) (*model.UserIdentity, error) {
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memIdentityRepo) Create(identity *model.UserIdentity) error {
+	identity.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *memIdentityRepo) Touch(id uint, at time.Time) error { return nil }
+
+func (r *memIdentityRepo) WithTx(tx *gorm.DB) repository.IdentityRepository { return r }
+
+type memSessionRepo struct {
+	repository.SessionRepository
+	sessions uint
+}
+
+func (r *memSessionRepo) CreateSession(session *model.AuthSession) error {
+	r.sessions++
+	session.ID = r.sessions
+	return nil
+}
+
+func (r *memSessionRepo) CreateRefreshToken(token *model.RefreshToken) error { return nil }
+
+func (r *memSessionRepo) WithTx(tx *gorm.DB) repository.SessionRepository { return r }
+
+type memStudentRepo struct {
+	repository.StudentRepository
+	students []model.Student
+}
+
+func (r *memStudentRepo) FindByStudentNo(studentNo string) (*model.Student, error) {
+	for i := range r.students {
+		if r.students[i].StudentNo == studentNo {
+			return &r.students[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type memStaffRepo struct {
+	repository.StaffRepository
+	staff []model.Staff
+}
+
+func (r *memStaffRepo) FindByStaffNo(staffNo string) (*model.Staff, error) {
+	for i := range r.staff {
+		if r.staff[i].StaffNo == staffNo {
+			return &r.staff[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// testRoles knows the student and teacher roles, without permissions
+type testRoles struct {
+	RoleService
+}
+
+func (testRoles) Exists(role string) bool {
+	return role == model.RoleStudent || role == model.RoleTeacher
+}
+
+func (testRoles) Permissions(role string) []string { return nil }
+
+type recordingAudit struct {
+	AuditService
+	entries []*model.AuditLog
+}
+
+func (a *recordingAudit) Record(entry *model.AuditLog, before, after any) {
+	a.entries = append(a.entries, entry)
+}
+
+// actions lists the audit actions recorded since the last call
+func (a *recordingAudit) actions() []string {
+	var actions []string
+	for _, e := range a.entries {
+		actions = append(actions, e.Action)
+	}
+	a.entries = nil
+	return actions
+}
+
+// ssoFixture is an auth service signing in at a mock OpenID provider and a fake LDAP
+// directory. Students 2023001 and 2023002 and staff T001 and T002 exist; T002 has the
+// account t002 and the account erin belongs to nobody in particular.
+type ssoFixture struct {
+	s          *authService
+	idp        *mockidp.Provider
+	users      *memUserRepo
+	identities *memIdentityRepo
+	audit      *recordingAudit
+	db         sqlmock.Sqlmock
+}
+
+func newSSOFixture(t *testing.T, configure func(cfg *config.Config)) *ssoFixture {
+	t.Helper()
+	idp, err := mockidp.New("", "edumgr", "", mockidp.ParseUsers(
+		"alice:student_no=2023001,bob:staff_no=T001,tina:staff_no=T002,carol,dave:student_no=2099999,erin:student_no=2023002"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp)
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ldapTestConfig(newFakeDirectory(t, testDirectory()))
+	cfg.JWTSecret = "test"
+	cfg.JWTExpiresMinutes = 15
+	cfg.RefreshTokenDays = 14
+	cfg.LoginLockoutMinutes = 15
+	cfg.LoginThrottleStore = "memory"
+	cfg.OIDCIssuer = srv.URL
+	cfg.OIDCClientID = "edumgr"
+	cfg.OIDCRedirectURL = "http://localhost:3000/login/callback"
+	cfg.OIDCScopes = "openid profile"
+	cfg.OIDCUsernameClaim = "preferred_username"
+	cfg.OIDCStudentNoClaim = "student_no"
+	cfg.OIDCStaffNoClaim = "staff_no"
+	cfg.SSOAutoProvision = true
+	cfg.SSOStaffRole = model.RoleTeacher
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	staffID := uint(11)
+	f := &ssoFixture{
+		idp: idp,
+		users: &memUserRepo{users: []*model.User{
+			{ID: 100, Username: "t002", Role: model.RoleTeacher, StaffID: &staffID},
+			{ID: 101, Username: "erin", Role: model.RoleStudent},
+		}},
+		identities: &memIdentityRepo{},
+		audit:      &recordingAudit{},
+		db:         mock,
+	}
+	f.s = NewAuthService(
+		f.users,
+		&memSessionRepo{},
+		&memMFARepo{},
+		f.identities,
+		&memStudentRepo{students: []model.Student{{ID: 1, StudentNo: "2023001"}, {ID: 2, StudentNo: "2023002"}}},
+		&memStaffRepo{staff: []model.Staff{{ID: 10, StaffNo: "T001"}, {ID: 11, StaffNo: "T002"}}},
+		repository.NewLoginThrottleStore(nil, cfg),
+		f.audit,
+		testRoles{},
+		db,
+		cfg,
+	).(*authService)
+	return f
+}
+
+// expectTx expects transactions ending as outcomes, "commit" or "rollback"
+func (f *ssoFixture) expectTx(outcomes ...string) {
+	for _, o := range outcomes {
+		f.db.ExpectBegin()
+		if o == "commit" {
+			f.db.ExpectCommit()
+		} else {
+			f.db.ExpectRollback()
+		}
+	}
+}
+
+// signIn signs in as username at the mock provider and completes the callback
+func (f *ssoFixture) signIn(t *testing.T, username string) (*LoginResponse, error) {
+	t.Helper()
+	start, err := f.s.OIDCStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := mockidp.SignIn(http.DefaultClient, start.AuthURL, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.s.OIDCCallback(OIDCCallbackRequest{Code: back.Query().Get("code"), State: back.Query().Get("state")},
+		ClientInfo{IP: "10.0.0.1"})
+}
+
+func TestOIDCCallbackAccounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(cfg *config.Config)
+		username  string
+		tx        []string
+		// wantUser is the account signed in as; 0 for a provisioned one
+		wantUser  uint
+		wantRole  string
+		studentID uint
+		staffID   uint
+		code      int
+	}{
+		{name: "provisions a student", username: "alice", tx: []string{"commit", "commit"},
+			wantRole: model.RoleStudent, studentID: 1},
+		{name: "provisions staff", username: "bob", tx: []string{"commit", "commit"},
+			wantRole: model.RoleTeacher, staffID: 10},
+		{name: "links by staff number", username: "tina", tx: []string{"commit", "commit"},
+			wantUser: 100, wantRole: model.RoleTeacher, staffID: 11},
+		{name: "links by username", username: "erin", tx: []string{"commit", "commit"},
+			configure: func(cfg *config.Config) { cfg.SSOLinkUsername = true },
+			wantUser:  101, wantRole: model.RoleStudent},
+		{name: "username of another account", username: "erin", tx: []string{"rollback"},
+			code: pkg.ErrCodeUsernameTaken},
+		{name: "no number", username: "carol", tx: []string{"rollback"}, code: pkg.ErrCodeNoAccount},
+		{name: "unknown student number", username: "dave", tx: []string{"rollback"}, code: pkg.ErrCodeNoAccount},
+		{name: "provisioning off", username: "alice", tx: []string{"rollback"},
+			configure: func(cfg *config.Config) { cfg.SSOAutoProvision = false },
+			code:      pkg.ErrCodeNoAccount},
+		{name: "unknown staff role", username: "bob", tx: []string{"rollback"},
+			configure: func(cfg *config.Config) { cfg.SSOStaffRole = "lecturer" },
+			code:      pkg.ErrCodeInvalidRole},
+	}
+	for _, tt := range tests {
+		f := newSSOFixture(t, tt.configure)
+		f.expectTx(tt.tx...)
+		users := len(f.users.users)
+		resp, err := f.signIn(t, tt.username)
+		if err := f.db.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.code != 0 {
+			if appErrCode(err) != tt.code {
+				t.Errorf("%s: OIDCCallback error = %v, want code %d", tt.name, err, tt.code)
+			}
+			if len(f.users.users) != users || len(f.identities.identities) != 0 {
+				t.Errorf("%s: refused sign-in created an account or link", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: OIDCCallback: %v", tt.name, err)
+			continue
+		}
+		u := resp.User
+		if tt.wantUser != 0 && u.ID != tt.wantUser {
+			t.Errorf("%s: signed in as user %d, want %d", tt.name, u.ID, tt.wantUser)
+		}
+		if tt.wantUser == 0 && (len(f.users.users) != users+1 || u.Username != tt.username) {
+			t.Errorf("%s: signed in as %+v, want a new account %s", tt.name, u, tt.username)
+		}
+		if u.Role != tt.wantRole || uintOr0(u.StudentID) != tt.studentID || uintOr0(u.StaffID) != tt.staffID {
+			t.Errorf("%s: user = %+v, want role %s, student %d, staff %d", tt.name, u, tt.wantRole, tt.studentID, tt.staffID)
+		}
+		if len(f.identities.identities) != 1 || f.identities.identities[0].UserID != u.ID ||
+			f.identities.identities[0].Subject != "mock-"+tt.username {
+			t.Errorf("%s: identities = %+v, want mock-%s linked to user %d", tt.name, f.identities.identities, tt.username, u.ID)
+		}
+		if resp.Token == "" || resp.RefreshToken == "" {
+			t.Errorf("%s: no tokens issued", tt.name)
+		}
+	}
+}
+
+func uintOr0(v *uint) uint {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func TestOIDCCallbackLinkedIdentity(t *testing.T) {
+	f := newSSOFixture(t, nil)
+	f.expectTx("commit", "commit", "commit")
+	first, err := f.signIn(t, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second sign-in finds the link and opens a session only
+	second, err := f.signIn(t, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.User.ID != first.User.ID || len(f.users.users) != 3 || len(f.identities.identities) != 1 {
+		t.Errorf("second sign-in as user %d, %d accounts, %d links; want user %d, 3, 1",
+			second.User.ID, len(f.users.users), len(f.identities.identities), first.User.ID)
+	}
+	if err := f.db.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOIDCCallbackRejectsToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"bad nonce", map[string]any{"nonce": "from-another-sign-in"}},
+		{"bad audience", map[string]any{"aud": "other-client"}},
+		{"bad issuer", map[string]any{"iss": "https://idp.example.com"}},
+	}
+	for _, tt := range tests {
+		f := newSSOFixture(t, nil)
+		f.idp.Claims = tt.claims
+		resp, err := f.signIn(t, "alice")
+		if appErrCode(err) != pkg.ErrCodeSSOFailed {
+			t.Errorf("%s: OIDCCallback = %+v, %v; want code %d", tt.name, resp, err, pkg.ErrCodeSSOFailed)
+		}
+		if len(f.users.users) != 2 || len(f.identities.identities) != 0 {
+			t.Errorf("%s: refused token created an account or link", tt.name)
+		}
+		if got := f.audit.actions(); len(got) != 1 || got[0] != auditLoginFailed {
+			t.Errorf("%s: audit actions = %v, want [%s]", tt.name, got, auditLoginFailed)
+		}
+	}
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	f := newSSOFixture(t, nil)
+	mine, err := f.s.OIDCStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := f.s.OIDCStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := mockidp.SignIn(http.DefaultClient, mine.AuthURL, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := back.Query().Get("code")
+	client := ClientInfo{IP: "10.0.0.1"}
+
+	// A forged state does not open, and another sign-in's state carries the wrong PKCE
+	// verifier and nonce for the code
+	if _, err := f.s.OIDCCallback(OIDCCallbackRequest{Code: code, State: "forged"}, client); appErrCode(err) != pkg.ErrCodeInvalidSSOState {
+		t.Errorf("forged state: error = %v, want code %d", err, pkg.ErrCodeInvalidSSOState)
+	}
+	if _, err := f.s.OIDCCallback(OIDCCallbackRequest{Code: code, State: other.State}, client); appErrCode(err) != pkg.ErrCodeSSOFailed {
+		t.Errorf("state of another sign-in: error = %v, want code %d", err, pkg.ErrCodeSSOFailed)
+	}
+	if _, err := f.s.OIDCCallback(OIDCCallbackRequest{State: mine.State}, client); appErrCode(err) != pkg.ErrCodeMissingRequired {
+		t.Errorf("no code: error = %v, want code %d", err, pkg.ErrCodeMissingRequired)
+	}
+}
+
+func TestLoginLDAP(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		tx       []string
+		code     int
+		audit    []string
+	}{
+		{name: "provisions from the directory", username: "alice", password: "alice-pw", tx: []string{"commit", "commit"}},
+		{name: "wrong password", username: "alice", password: "bob-pw", code: pkg.ErrCodeInvalidCredentials,
+			audit: []string{auditLoginFailed}},
+		{name: "unknown user", username: "carol", password: "carol-pw", code: pkg.ErrCodeInvalidCredentials,
+			audit: []string{auditLoginFailed}},
+		// Never reaches the directory, which would take it as an unauthenticated bind
+		{name: "empty password", username: "alice", password: "", code: pkg.ErrCodeMissingRequired},
+	}
+	for _, tt := range tests {
+		f := newSSOFixture(t, nil)
+		f.expectTx(tt.tx...)
+		resp, err := f.s.Login(tt.username, tt.password, ClientInfo{IP: "10.0.0.1"})
+		if err := f.db.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if got := f.audit.actions(); !slices.Equal(got, tt.audit) {
+			t.Errorf("%s: audit actions = %v, want %v", tt.name, got, tt.audit)
+		}
+		if tt.code != 0 {
+			if appErrCode(err) != tt.code {
+				t.Errorf("%s: Login = %+v, %v; want code %d", tt.name, resp, err, tt.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Login: %v", tt.name, err)
+			continue
+		}
+		if resp.User.Username != "alice" || uintOr0(resp.User.StudentID) != 1 ||
+			len(f.identities.identities) != 1 || f.identities.identities[0].Provider != model.IdentityLDAP {
+			t.Errorf("%s: user = %+v, identities %+v; want alice linked to student 1 through ldap",
+				tt.name, resp.User, f.identities.identities)
+		}
+	}
+}
+
+func TestLoginLDAPWrongPasswordThrottled(t *testing.T) {
+	f := newSSOFixture(t, nil)
+	if _, err := f.s.Login("alice", "bob-pw", ClientInfo{IP: "10.0.0.1"}); appErrCode(err) != pkg.ErrCodeInvalidCredentials {
+		t.Fatalf("Login = %v, want code %d", err, pkg.ErrCodeInvalidCredentials)
+	}
+	// The failure blocks the next attempt, even with the right password
+	if _, err := f.s.Login("alice", "alice-pw", ClientInfo{IP: "10.0.0.1"}); appErrCode(err) != pkg.ErrCodeTooManyAttempts {
+		t.Errorf("Login right after a failure = %v, want code %d", err, pkg.ErrCodeTooManyAttempts)
+	}
+}
+
+func TestLoginLDAPUnavailable(t *testing.T) {
+	f := newSSOFixture(t, func(cfg *config.Config) { cfg.LDAPURL = "ldap://127.0.0.1:1" })
+	_, err := f.s.Login("alice", "alice-pw", ClientInfo{IP: "10.0.0.1"})
+	if appErrCode(err) != pkg.ErrCodeIdPError {
+		t.Errorf("Login with the directory down = %v, want code %d", err, pkg.ErrCodeIdPError)
+	}
+	if len(f.audit.actions()) != 0 {
+		t.Error("an unavailable directory was counted as a failed login")
+	}
+}
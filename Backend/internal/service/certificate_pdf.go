@@ -0,0 +1,72 @@
+package service
+
+import (
+	"bytes"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg/fonts"
+)
+
+// renderEnrollmentCertificatePDF draws a single A4 page: the certifying statement,
+// the student's status history, and the verification code of the issuance.
+func renderEnrollmentCertificatePDF(cert *EnrollmentCertificate, issuance *model.DocumentIssuance) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fonts.CJKFamily, "", fonts.CJK)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, transcriptMarginB)
+	pdf.SetTitle("Enrollment Certificate "+cert.StudentNo, true)
+	pdf.SetCreator("EduMgr", true)
+	pdf.AddPage()
+
+	title := "学籍证明"
+	if cert.Status == "in_school" {
+		title = "在读证明"
+	}
+	pdf.SetFont(fonts.CJKFamily, "", 20)
+	pdf.CellFormat(0, 12, title, "", 1, "C", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.CellFormat(0, 5, "Certificate of Enrollment", "", 1, "C", false, 0, "")
+	pdf.Ln(10)
+
+	status := studentStatusLabel(cert.Status)
+	pdf.SetFont(fonts.CJKFamily, "", 12)
+	pdf.MultiCell(0, 8, "    兹证明 "+cert.StudentName+"（学号 "+cert.StudentNo+"，性别 "+cert.Gender+
+		"）系本校 "+cert.DeptName+" 学生，当前学籍状态为“"+status+"”。", "", "L", false)
+	pdf.Ln(2)
+	pdf.MultiCell(0, 8, "    特此证明。", "", "L", false)
+	pdf.Ln(6)
+
+	if len(cert.History) > 0 {
+		pdf.SetFont(fonts.CJKFamily, "", 11)
+		pdf.CellFormat(0, 7, "学籍变动记录", "B", 1, "L", false, 0, "")
+		pdf.SetFont(fonts.CJKFamily, "", 9)
+		pdf.SetFillColor(235, 235, 235)
+		pdf.CellFormat(40, transcriptRowH, "时间", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(30, transcriptRowH, "原状态", "1", 0, "C", true, 0, "")
+		pdf.CellFormat(100, transcriptRowH, "变动原因", "1", 1, "C", true, 0, "")
+		for _, h := range cert.History {
+			pdf.CellFormat(40, transcriptRowH, h.At.Format("2006-01-02"), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(30, transcriptRowH, studentStatusLabel(h.Status), "1", 0, "C", false, 0, "")
+			pdf.CellFormat(100, transcriptRowH, fitText(pdf, h.Reason, 98), "1", 1, "L", false, 0, "")
+		}
+		pdf.Ln(6)
+	}
+
+	pdf.SetFont(fonts.CJKFamily, "", 11)
+	pdf.CellFormat(0, 8, "出具日期："+issuance.IssuedAt.Format("2006年01月02日"), "", 1, "R", false, 0, "")
+
+	code := FormatDocumentCode(issuance.Code)
+	pdf.SetY(-30)
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.SetTextColor(110, 110, 110)
+	pdf.CellFormat(0, 5, "验证码 "+code+"    签名密钥 "+issuance.KeyID, "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "本证明经电子签名，可凭验证码通过 /verify/"+code+" 核验真伪。", "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// StatusEvent is one archived status change of a student
+type StatusEvent struct {
+	Status string    `json:"status"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// EnrollmentCertificate certifies a student's registration status
+type EnrollmentCertificate struct {
+	StudentNo   string        `json:"student_no"`
+	StudentName string        `json:"student_name"`
+	Gender      string        `json:"gender"`
+	DeptNo      string        `json:"dept_no"`
+	DeptName    string        `json:"dept_name"`
+	Status      string        `json:"status"`
+	History     []StatusEvent `json:"history"`
+}
+
+// VerifyResult is the public answer for a verification code
+type VerifyResult struct {
+	Code         string          `json:"code"`
+	Status       string          `json:"status"`
+	DocType      string          `json:"doc_type"`
+	IssuedAt     time.Time       `json:"issued_at"`
+	RevokedAt    *time.Time      `json:"revoked_at,omitempty"`
+	RevokeReason string          `json:"revoke_reason,omitempty"`
+	KeyID        string          `json:"key_id"`
+	PublicKey    string          `json:"public_key"`
+	Signature    string          `json:"signature"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// CertificateService defines the interface for enrollment certificates and document verification
+type CertificateService interface {
+	MyEnrollmentCertificatePDF(userID uint) ([]byte, *model.DocumentIssuance, error)
+	EnrollmentCertificatePDF(studentNo string, issuedBy uint) ([]byte, *model.DocumentIssuance, error)
+	Verify(code string) (*VerifyResult, error)
+	ListIssuances(params repository.IssuanceQueryParams) ([]repository.IssuanceRow, error)
+	Revoke(code, reason string) error
+	WithScope(scope repository.Scope) CertificateService
+}
+
+type certificateService struct {
+	issuer       *DocumentIssuer
+	issuanceRepo repository.IssuanceRepository
+	studentRepo  repository.StudentRepository
+	userRepo     repository.UserRepository
+}
+
+// NewCertificateService creates a new CertificateService
+func NewCertificateService(
+	issuer *DocumentIssuer,
+	issuanceRepo repository.IssuanceRepository,
+	studentRepo repository.StudentRepository,
+	userRepo repository.UserRepository,
+) CertificateService {
+	return &certificateService{issuer: issuer, issuanceRepo: issuanceRepo, studentRepo: studentRepo, userRepo: userRepo}
+}
+
+// WithScope returns the service limited to the students of the scope's department
+func (s *certificateService) WithScope(scope repository.Scope) CertificateService {
+	return &certificateService{
+		issuer:       s.issuer,
+		issuanceRepo: s.issuanceRepo.WithScope(scope),
+		studentRepo:  s.studentRepo.WithScope(scope),
+		userRepo:     s.userRepo,
+	}
+}
+
+func (s *certificateService) MyEnrollmentCertificatePDF(userID uint) ([]byte, *model.DocumentIssuance, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user.StudentID == nil {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeStudentNotBound, "student not bound")
+	}
+	return s.issueEnrollmentCertificate(*user.StudentID, userID)
+}
+
+func (s *certificateService) EnrollmentCertificatePDF(studentNo string, issuedBy uint) ([]byte, *model.DocumentIssuance, error) {
+	if studentNo == "" {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no required")
+	}
+	student, err := s.studentRepo.FindByStudentNo(studentNo)
+	if err != nil {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	return s.issueEnrollmentCertificate(student.ID, issuedBy)
+}
+
+func (s *certificateService) issueEnrollmentCertificate(studentID, issuedBy uint) ([]byte, *model.DocumentIssuance, error) {
+	student, err := s.studentRepo.FindWithDeptByID(studentID)
+	if err != nil {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	history, err := s.studentRepo.FindHistoryByStudentNo(student.StudentNo)
+	if err != nil {
+		return nil, nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	cert := &EnrollmentCertificate{
+		StudentNo:   student.StudentNo,
+		StudentName: student.Name,
+		Gender:      student.Gender,
+		DeptNo:      student.DeptNo,
+		DeptName:    student.DeptName,
+		Status:      student.Status,
+		History:     make([]StatusEvent, 0, len(history)),
+	}
+	for _, h := range history {
+		cert.History = append(cert.History, StatusEvent{Status: h.Status, Reason: h.ArchiveReason, At: h.ArchivedAt.UTC()})
+	}
+
+	issuance, err := s.issuer.Issue(model.DocTypeEnrollmentCertificate, studentID, issuedBy, cert)
+	if err != nil {
+		return nil, nil, pkg.WrapError(pkg.ErrCodeIssueFailed, "issue failed", err)
+	}
+	data, err := renderEnrollmentCertificatePDF(cert, issuance)
+	if err != nil {
+		return nil, nil, pkg.WrapError(pkg.ErrCodeRenderFailed, "render certificate failed", err)
+	}
+	return data, issuance, nil
+}
+
+// Verify returns the signed payload behind a code together with its status
+func (s *certificateService) Verify(code string) (*VerifyResult, error) {
+	issuance, err := s.issuanceRepo.FindByCode(NormalizeDocumentCode(code))
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeNotFound, "document not found")
+	}
+
+	// Report the key the document was signed with, which may be a retired one
+	pub, ok := s.issuer.VerificationKey(issuance.KeyID)
+	if !ok {
+		pub = s.issuer.PublicKey()
+	}
+	result := &VerifyResult{
+		Code:         FormatDocumentCode(issuance.Code),
+		Status:       s.issuer.Status(issuance),
+		DocType:      issuance.DocType,
+		IssuedAt:     issuance.IssuedAt,
+		RevokedAt:    issuance.RevokedAt,
+		RevokeReason: issuance.RevokeReason,
+		KeyID:        issuance.KeyID,
+		PublicKey:    base64.StdEncoding.EncodeToString(pub),
+		Signature:    issuance.Signature,
+	}
+	// A tampered payload may no longer be valid JSON; only embed it when it parses
+	if json.Valid([]byte(issuance.Payload)) {
+		result.Payload = json.RawMessage(issuance.Payload)
+	}
+	return result, nil
+}
+
+func (s *certificateService) ListIssuances(params repository.IssuanceQueryParams) ([]repository.IssuanceRow, error) {
+	rows, err := s.issuanceRepo.FindAll(params)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return rows, nil
+}
+
+func (s *certificateService) Revoke(code, reason string) error {
+	issuance, err := s.issuanceRepo.FindByCode(NormalizeDocumentCode(code))
+	if err != nil {
+		return pkg.NewAppError(pkg.ErrCodeNotFound, "document not found")
+	}
+	if issuance.RevokedAt != nil {
+		return pkg.NewAppError(pkg.ErrCodeAlreadyRevoked, "document already revoked")
+	}
+	if err := s.issuanceRepo.Revoke(issuance.ID, reason, time.Now()); err != nil {
+		return pkg.WrapError(pkg.ErrCodeUpdateFailed, "update failed", err)
+	}
+	return nil
+}
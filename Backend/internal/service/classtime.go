@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+// Default teaching-week range used when a class_time segment does not name one
+const (
+	DefaultStartWeek = 1
+	DefaultEndWeek   = 16
+	MaxPeriod        = 14
+	MaxWeek          = 30
+)
+
+var weekdayNames = []string{"", "周一", "周二", "周三", "周四", "周五", "周六", "周日"}
+
+var weekdayByChar = map[string]int{
+	"一": 1, "二": 2, "三": 3, "四": 4, "五": 5, "六": 6, "日": 7, "天": 7,
+	"1": 1, "2": 2, "3": 3, "4": 4, "5": 5, "6": 6, "7": 7,
+}
+
+// classTimeSegment matches one meeting such as "周一 1-2节", "星期三 3节" or "周五 5-6节(1-8周)".
+// The week range may also precede the weekday: "1-8周 周五 5-6节".
+var classTimeSegment = regexp.MustCompile(
+	`^(?:第?(\d+)(?:-(\d+))?周\s*)?(?:周|星期)([一二三四五六日天1-7])\s*第?(\d+)(?:-(\d+))?节\s*(?:[(（\[【]\s*第?(\d+)(?:-(\d+))?周\s*[)）\]】])?$`,
+)
+
+var classTimeSeparators = strings.NewReplacer("，", ",", "；", ",", ";", ",", "、", ",", "\n", ",")
+
+// ParseClassTime converts a free-text class_time like "周一 1-2节, 周三 3-4节" into structured meetings.
+// An empty string yields no meetings; any segment that cannot be understood is an error.
+func ParseClassTime(s string) ([]model.ClassMeeting, error) {
+	s = strings.TrimSpace(classTimeSeparators.Replace(s))
+	if s == "" {
+		return []model.ClassMeeting{}, nil
+	}
+
+	var meetings []model.ClassMeeting
+	for _, seg := range strings.Split(s, ",") {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			continue
+		}
+		m := classTimeSegment.FindStringSubmatch(seg)
+		if m == nil {
+			return nil, fmt.Errorf("unrecognized class time %q", seg)
+		}
+
+		meeting := model.ClassMeeting{
+			Weekday:     weekdayByChar[m[3]],
+			StartPeriod: atoi(m[4]),
+			EndPeriod:   atoiOr(m[5], atoi(m[4])),
+			StartWeek:   DefaultStartWeek,
+			EndWeek:     DefaultEndWeek,
+		}
+		// A trailing week range wins over a leading one
+		switch {
+		case m[6] != "":
+			meeting.StartWeek = atoi(m[6])
+			meeting.EndWeek = atoiOr(m[7], meeting.StartWeek)
+		case m[1] != "":
+			meeting.StartWeek = atoi(m[1])
+			meeting.EndWeek = atoiOr(m[2], meeting.StartWeek)
+		}
+
+		if err := ValidateClassMeeting(meeting); err != nil {
+			return nil, fmt.Errorf("%q: %w", seg, err)
+		}
+		meetings = append(meetings, meeting)
+	}
+	if len(meetings) == 0 {
+		return []model.ClassMeeting{}, nil
+	}
+	return meetings, nil
+}
+
+// ValidateClassMeeting checks that weekday, periods and weeks are within range
+func ValidateClassMeeting(m model.ClassMeeting) error {
+	if m.Weekday < 1 || m.Weekday > 7 {
+		return fmt.Errorf("weekday must be 1-7")
+	}
+	if m.StartPeriod < 1 || m.EndPeriod < m.StartPeriod || m.EndPeriod > MaxPeriod {
+		return fmt.Errorf("periods must satisfy 1 <= start <= end <= %d", MaxPeriod)
+	}
+	if m.StartWeek < 1 || m.EndWeek < m.StartWeek || m.EndWeek > MaxWeek {
+		return fmt.Errorf("weeks must satisfy 1 <= start <= end <= %d", MaxWeek)
+	}
+	return nil
+}
+
+// FormatClassMeeting renders a meeting in the canonical class_time form.
+// The week range is omitted when it is the default one.
+func FormatClassMeeting(m model.ClassMeeting) string {
+	var b strings.Builder
+	if m.Weekday >= 1 && m.Weekday <= 7 {
+		b.WriteString(weekdayNames[m.Weekday])
+	}
+	b.WriteString(" ")
+	if m.StartPeriod == m.EndPeriod {
+		fmt.Fprintf(&b, "%d节", m.StartPeriod)
+	} else {
+		fmt.Fprintf(&b, "%d-%d节", m.StartPeriod, m.EndPeriod)
+	}
+	if m.StartWeek != DefaultStartWeek || m.EndWeek != DefaultEndWeek {
+		if m.StartWeek == m.EndWeek {
+			fmt.Fprintf(&b, "(%d周)", m.StartWeek)
+		} else {
+			fmt.Fprintf(&b, "(%d-%d周)", m.StartWeek, m.EndWeek)
+		}
+	}
+	return b.String()
+}
+
+// FormatClassTime renders meetings as a class_time string, e.g. "周一 1-2节, 周三 3-4节"
+func FormatClassTime(meetings []model.ClassMeeting) string {
+	parts := make([]string, 0, len(meetings))
+	for _, m := range meetings {
+		parts = append(parts, FormatClassMeeting(m))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func atoiOr(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	return atoi(s)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lin-snow/edumgr/internal/model"
+)
+
+func meeting(weekday, startPeriod, endPeriod, startWeek, endWeek int) model.ClassMeeting {
+	return model.ClassMeeting{
+		Weekday:     weekday,
+		StartPeriod: startPeriod,
+		EndPeriod:   endPeriod,
+		StartWeek:   startWeek,
+		EndWeek:     endWeek,
+	}
+}
+
+func TestParseClassTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []model.ClassMeeting
+	}{
+		{"", []model.ClassMeeting{}},
+		{"   ", []model.ClassMeeting{}},
+		{"周一 1-2节", []model.ClassMeeting{meeting(1, 1, 2, 1, 16)}},
+		{"星期三 3节", []model.ClassMeeting{meeting(3, 3, 3, 1, 16)}},
+		{"周五 5-6节(1-8周)", []model.ClassMeeting{meeting(5, 5, 6, 1, 8)}},
+		{"1-8周 周五 5-6节", []model.ClassMeeting{meeting(5, 5, 6, 1, 8)}},
+		{"第3周 周二 第1-2节", []model.ClassMeeting{meeting(2, 1, 2, 3, 3)}},
+		{"周四 7-8节（第9-16周）", []model.ClassMeeting{meeting(4, 7, 8, 9, 16)}},
+		{"周六 1-4节[2-4周]", []model.ClassMeeting{meeting(6, 1, 4, 2, 4)}},
+		{"周日 9-10节【10周】", []model.ClassMeeting{meeting(7, 9, 10, 10, 10)}},
+		{"周天 1-2节", []model.ClassMeeting{meeting(7, 1, 2, 1, 16)}},
+		{"星期5 5-6节", []model.ClassMeeting{meeting(5, 5, 6, 1, 16)}},
+		{"周7 1节", []model.ClassMeeting{meeting(7, 1, 1, 1, 16)}},
+		// A trailing week range wins over a leading one
+		{"1-4周 周一 1-2节(5-8周)", []model.ClassMeeting{meeting(1, 1, 2, 5, 8)}},
+		{"周一 1-2节, 周三 3-4节", []model.ClassMeeting{meeting(1, 1, 2, 1, 16), meeting(3, 3, 4, 1, 16)}},
+		{"周一 1-2节，周三 3-4节；周五 5节;周六 6节、周日 7节\n周二 8节", []model.ClassMeeting{
+			meeting(1, 1, 2, 1, 16), meeting(3, 3, 4, 1, 16), meeting(5, 5, 5, 1, 16),
+			meeting(6, 6, 6, 1, 16), meeting(7, 7, 7, 1, 16), meeting(2, 8, 8, 1, 16),
+		}},
+		{"周一 1-2节,, 周三 3-4节,", []model.ClassMeeting{meeting(1, 1, 2, 1, 16), meeting(3, 3, 4, 1, 16)}},
+		{"周一 1-14节(1-30周)", []model.ClassMeeting{meeting(1, 1, 14, 1, 30)}},
+	}
+	for _, tt := range tests {
+		got, err := ParseClassTime(tt.in)
+		if err != nil {
+			t.Errorf("ParseClassTime(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseClassTime(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseClassTimeRejects(t *testing.T) {
+	tests := []string{
+		"周一 4-2节",         // inverted periods
+		"周一 1-2节(8-1周)",   // inverted weeks
+		"8-1周 周一 1-2节",    // inverted leading weeks
+		"周八 1-2节",         // bad weekday
+		"星期0 1-2节",        // bad numeric weekday
+		"周一 0节",           // period below 1
+		"周一 13-15节",       // period above the maximum
+		"周一 1-2节(0-4周)",   // week below 1
+		"周一 1-2节(1-31周)",  // week above the maximum
+		"周一",              // no periods
+		"1-2节",            // no weekday
+		"每周一 1-2节",        // unanchored text before
+		"周一 1-2节 单周",      // unanchored text after
+		"周一 1-2节, 周三 3-4", // a bad segment after a good one
+	}
+	for _, in := range tests {
+		if got, err := ParseClassTime(in); err == nil {
+			t.Errorf("ParseClassTime(%q) = %+v, want error", in, got)
+		}
+	}
+}
+
+func TestFormatClassTimeRoundTrip(t *testing.T) {
+	for _, in := range []string{"周一 1-2节, 周三 3节", "周五 5-6节(1-8周)", "周日 9节(10周)"} {
+		meetings, err := ParseClassTime(in)
+		if err != nil {
+			t.Fatalf("ParseClassTime(%q) error: %v", in, err)
+		}
+		if got := FormatClassTime(meetings); got != in {
+			t.Errorf("FormatClassTime(ParseClassTime(%q)) = %q", in, got)
+		}
+	}
+}
@@ -1,47 +1,93 @@
 package service
 
 import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
 // CourseListResult represents paginated course list
 type CourseListResult struct {
-	Items    []repository.CourseWithTeacher `json:"items"`
-	Total    int64                          `json:"total"`
-	Page     int                            `json:"page"`
-	PageSize int                            `json:"page_size"`
+	Items    []model.Course `json:"items"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+// PrereqCourse identifies a prerequisite course
+type PrereqCourse struct {
+	CourseID   uint   `json:"course_id"`
+	CourseNo   string `json:"course_no"`
+	CourseName string `json:"course_name"`
+}
+
+// PrereqGroup is a set of alternatives; passing any one of them satisfies the group.
+// A course's prerequisites are met when every group is satisfied.
+type PrereqGroup struct {
+	GroupNo int            `json:"group_no"`
+	Courses []PrereqCourse `json:"courses"`
 }
 
 // CourseService defines the interface for course business logic
 type CourseService interface {
-	List(courseNo, name, teacherName string) ([]repository.CourseWithTeacher, error)
-	ListPaginated(courseNo, name, teacherName string, page, pageSize int) (*CourseListResult, error)
+	List(courseNo, name string) ([]model.Course, error)
+	ListPaginated(courseNo, name string, page, pageSize int) (*CourseListResult, error)
+	Export(w export.Writer, courseNo, name string) error
 	GetByID(id uint) (*model.Course, error)
 	Create(course *model.Course) error
 	Update(id uint, input *model.Course) (*model.Course, error)
 	Delete(id uint) error
+	GetPrerequisites(id uint) ([]PrereqGroup, error)
+	SetPrerequisites(id uint, groups [][]string) ([]PrereqGroup, error)
+	GetGradingScheme(id uint) (*CourseGradingScheme, error)
+	SetGradingScheme(id uint, scheme *model.GradingScheme) (*CourseGradingScheme, error)
+	ResetGradingScheme(id uint) (*CourseGradingScheme, error)
+	WithScope(scope repository.Scope) CourseService
 }
 
 type courseService struct {
 	repo repository.CourseRepository
+	// catalog resolves prerequisites, which may be courses of any department
+	catalog    repository.CourseRepository
+	prereqRepo repository.PrerequisiteRepository
+	schemeRepo repository.GradingSchemeRepository
+	db         *gorm.DB
+	cfg        config.Config
 }
 
 // NewCourseService creates a new CourseService
-func NewCourseService(repo repository.CourseRepository) CourseService {
-	return &courseService{repo: repo}
+func NewCourseService(
+	repo repository.CourseRepository,
+	prereqRepo repository.PrerequisiteRepository,
+	schemeRepo repository.GradingSchemeRepository,
+	db *gorm.DB,
+	cfg config.Config,
+) CourseService {
+	return &courseService{repo: repo, catalog: repo, prereqRepo: prereqRepo, schemeRepo: schemeRepo, db: db, cfg: cfg}
+}
+
+// WithScope returns the service limited to the courses of the scope's department
+func (s *courseService) WithScope(scope repository.Scope) CourseService {
+	scoped := *s
+	scoped.repo = s.repo.WithScope(scope)
+	return &scoped
 }
 
-func (s *courseService) List(courseNo, name, teacherName string) ([]repository.CourseWithTeacher, error) {
-	items, err := s.repo.FindAll(courseNo, name, teacherName)
+func (s *courseService) List(courseNo, name string) ([]model.Course, error) {
+	items, err := s.repo.FindAll(courseNo, name)
 	if err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
 	return items, nil
 }
 
-func (s *courseService) ListPaginated(courseNo, name, teacherName string, page, pageSize int) (*CourseListResult, error) {
+func (s *courseService) ListPaginated(courseNo, name string, page, pageSize int) (*CourseListResult, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -50,11 +96,10 @@ func (s *courseService) ListPaginated(courseNo, name, teacherName string, page,
 	}
 
 	params := repository.CourseQueryParams{
-		CourseNo:    courseNo,
-		Name:        name,
-		TeacherName: teacherName,
-		Page:        page,
-		PageSize:    pageSize,
+		CourseNo: courseNo,
+		Name:     name,
+		Page:     page,
+		PageSize: pageSize,
 	}
 
 	items, total, err := s.repo.FindAllPaginated(params)
@@ -70,6 +115,21 @@ func (s *courseService) ListPaginated(courseNo, name, teacherName string, page,
 	}, nil
 }
 
+var courseExportHeader = []string{"课程号", "课程名称", "学时", "学分"}
+
+// Export writes every catalog course matching the list filters
+func (s *courseService) Export(w export.Writer, courseNo, name string) error {
+	if err := w.Sheet("课程", courseExportHeader); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	if err := s.repo.Each(courseNo, name, func(c *model.Course) error {
+		return w.Row(c.CourseNo, c.Name, c.Hours, c.Credits)
+	}); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
+}
+
 func (s *courseService) GetByID(id uint) (*model.Course, error) {
 	course, err := s.repo.FindByID(id)
 	if err != nil {
@@ -79,8 +139,8 @@ func (s *courseService) GetByID(id uint) (*model.Course, error) {
 }
 
 func (s *courseService) Create(course *model.Course) error {
-	if course.CourseNo == "" || course.Name == "" || course.TeacherID == 0 {
-		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "course_no/name/teacher_id required")
+	if course.CourseNo == "" || course.Name == "" {
+		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "course_no/name required")
 	}
 	course.ID = 0
 	if err := s.repo.Create(course); err != nil {
@@ -99,14 +159,11 @@ func (s *courseService) Update(id uint, input *model.Course) (*model.Course, err
 	if input.Name != "" {
 		current.Name = input.Name
 	}
-	if input.TeacherID != 0 {
-		current.TeacherID = input.TeacherID
-	}
 	current.Hours = input.Hours
 	current.Credits = input.Credits
-	current.ClassTime = input.ClassTime
-	current.ClassLocation = input.ClassLocation
-	current.ExamTime = input.ExamTime
+	if input.DeptID != nil {
+		current.DeptID = input.DeptID
+	}
 
 	if err := s.repo.Update(current); err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update failed", err)
@@ -115,25 +172,201 @@ func (s *courseService) Update(id uint, input *model.Course) (*model.Course, err
 }
 
 func (s *courseService) Delete(id uint) error {
-	// 避免破坏选课/成绩一致性
-	enrCnt, err := s.repo.CountEnrollments(id)
+	// 避免破坏选课/成绩一致性: offerings carry the enrollments and grades
+	offCnt, err := s.repo.CountOfferings(id)
 	if err != nil {
 		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
-	if enrCnt > 0 {
-		return pkg.NewAppError(pkg.ErrCodeCourseHasEnroll, "course has enrollments")
+	if offCnt > 0 {
+		return pkg.NewAppError(pkg.ErrCodeCourseOffered, "course has offerings")
 	}
 
-	grdCnt, err := s.repo.CountGrades(id)
+	if err := s.repo.Delete(id); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete failed", err)
+	}
+	return nil
+}
+
+func (s *courseService) GetPrerequisites(id uint) ([]PrereqGroup, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "course not found", err)
+	}
+	rows, err := s.prereqRepo.FindByCourseIDs([]uint{id})
 	if err != nil {
-		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
-	if grdCnt > 0 {
-		return pkg.NewAppError(pkg.ErrCodeCourseHasGrades, "course has grades")
+	return groupPrerequisites(rows)[id], nil
+}
+
+// SetPrerequisites replaces the prerequisite groups of a course.
+// Each inner slice lists course numbers that are alternatives for one another.
+func (s *courseService) SetPrerequisites(id uint, groups [][]string) ([]PrereqGroup, error) {
+	course, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "course not found", err)
 	}
 
-	if err := s.repo.Delete(id); err != nil {
-		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete failed", err)
+	// Resolve course numbers into edges, dropping empty groups and repeats
+	var edges []model.CoursePrerequisite
+	groupNo := 0
+	for _, group := range groups {
+		seen := make(map[uint]struct{})
+		var groupEdges []model.CoursePrerequisite
+		for _, no := range group {
+			prereq, err := s.catalog.FindByCourseNo(no)
+			if err != nil {
+				return nil, pkg.NewAppError(pkg.ErrCodeCourseNotFound, "course not found: "+no)
+			}
+			if prereq.ID == course.ID {
+				return nil, pkg.NewAppError(pkg.ErrCodeInvalidPrereq, "a course cannot be its own prerequisite")
+			}
+			if _, ok := seen[prereq.ID]; ok {
+				continue
+			}
+			seen[prereq.ID] = struct{}{}
+			groupEdges = append(groupEdges, model.CoursePrerequisite{PrereqCourseID: prereq.ID})
+		}
+		if len(groupEdges) == 0 {
+			continue
+		}
+		groupNo++
+		for i := range groupEdges {
+			groupEdges[i].GroupNo = groupNo
+		}
+		edges = append(edges, groupEdges...)
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		txPrereqRepo := s.prereqRepo.WithTx(tx)
+		existing, err := txPrereqRepo.FindAllEdges()
+		if err != nil {
+			return err
+		}
+		if cycle := findPrereqCycle(course.ID, edges, existing); cycle != nil {
+			return s.prereqCycleError(cycle)
+		}
+		return txPrereqRepo.Replace(course.ID, edges)
+	}); err != nil {
+		if appErr, ok := err.(*pkg.AppError); ok {
+			return nil, appErr
+		}
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update failed", err)
+	}
+
+	return s.GetPrerequisites(course.ID)
+}
+
+func (s *courseService) GetGradingScheme(id uint) (*CourseGradingScheme, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "course not found", err)
+	}
+	scheme, err := loadGradingScheme(s.schemeRepo, s.cfg, id)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return scheme, nil
+}
+
+// SetGradingScheme replaces the grading scheme of a course.
+// Existing final scores are not recomputed; they change the next time grades are saved.
+func (s *courseService) SetGradingScheme(id uint, scheme *model.GradingScheme) (*CourseGradingScheme, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "course not found", err)
+	}
+	scheme.CourseID = id
+	if scheme.Rounding == "" {
+		scheme.Rounding = RoundHalfUp
+	}
+	if err := validateGradingScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.schemeRepo.WithTx(tx).Save(scheme)
+	}); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update failed", err)
+	}
+	return s.GetGradingScheme(id)
+}
+
+// ResetGradingScheme drops a course's own scheme so the configured default applies again
+func (s *courseService) ResetGradingScheme(id uint) (*CourseGradingScheme, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "course not found", err)
+	}
+	if err := s.schemeRepo.Delete(id); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete failed", err)
+	}
+	return s.GetGradingScheme(id)
+}
+
+// findPrereqCycle reports a path courseID -> ... -> courseID if the proposed edges close a loop.
+// Any alternative inside an OR group counts, since taking it would require the course itself.
+func findPrereqCycle(courseID uint, proposed, existing []model.CoursePrerequisite) []uint {
+	adj := make(map[uint][]uint)
+	for _, e := range existing {
+		if e.CourseID == courseID {
+			continue
+		}
+		adj[e.CourseID] = append(adj[e.CourseID], e.PrereqCourseID)
+	}
+	for _, e := range proposed {
+		adj[courseID] = append(adj[courseID], e.PrereqCourseID)
+	}
+
+	visited := make(map[uint]bool)
+	var path []uint
+	var dfs func(n uint) bool
+	dfs = func(n uint) bool {
+		path = append(path, n)
+		for _, next := range adj[n] {
+			if next == courseID {
+				path = append(path, next)
+				return true
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if dfs(next) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if dfs(courseID) {
+		return path
 	}
 	return nil
 }
+
+func (s *courseService) prereqCycleError(cycle []uint) *pkg.AppError {
+	nos := make([]string, 0, len(cycle))
+	for _, id := range cycle {
+		if c, err := s.repo.FindByID(id); err == nil {
+			nos = append(nos, c.CourseNo)
+		}
+	}
+	return pkg.NewAppErrorWithDetails(pkg.ErrCodePrereqCycle,
+		"prerequisite cycle: "+strings.Join(nos, " -> "), nos)
+}
+
+// groupPrerequisites folds prerequisite rows into AND-of-OR groups keyed by course ID
+func groupPrerequisites(rows []repository.PrerequisiteRow) map[uint][]PrereqGroup {
+	result := make(map[uint][]PrereqGroup)
+	for _, r := range rows {
+		groups := result[r.CourseID]
+		if len(groups) == 0 || groups[len(groups)-1].GroupNo != r.GroupNo {
+			groups = append(groups, PrereqGroup{GroupNo: r.GroupNo})
+		}
+		last := &groups[len(groups)-1]
+		last.Courses = append(last.Courses, PrereqCourse{
+			CourseID:   r.PrereqCourseID,
+			CourseNo:   r.PrereqCourseNo,
+			CourseName: r.PrereqCourseName,
+		})
+		result[r.CourseID] = groups
+	}
+	return result
+}
@@ -13,6 +13,7 @@ type DepartmentService interface {
 	Create(dept *model.Department) error
 	Update(id uint, input *model.Department) (*model.Department, error)
 	Delete(id uint) error
+	WithScope(scope repository.Scope) DepartmentService
 }
 
 type departmentService struct {
@@ -24,6 +25,11 @@ func NewDepartmentService(repo repository.DepartmentRepository) DepartmentServic
 	return &departmentService{repo: repo}
 }
 
+// WithScope returns the service limited to changing the scope's own department
+func (s *departmentService) WithScope(scope repository.Scope) DepartmentService {
+	return &departmentService{repo: s.repo.WithScope(scope)}
+}
+
 func (s *departmentService) List(deptNo, name string) ([]model.Department, error) {
 	items, err := s.repo.FindAll(deptNo, name)
 	if err != nil {
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Verification outcomes of an issued document
+const (
+	DocStatusValid        = "valid"
+	DocStatusRevoked      = "revoked"
+	DocStatusTampered     = "tampered"
+	DocStatusUnverifiable = "unverifiable"
+)
+
+// signedDocument is the canonical envelope that gets signed. Field order is fixed by the
+// struct, and the marshalled bytes are stored verbatim so verification never re-encodes.
+type signedDocument struct {
+	Type     string    `json:"type"`
+	Code     string    `json:"code"`
+	KeyID    string    `json:"key_id"`
+	IssuedAt time.Time `json:"issued_at"`
+	Subject  any       `json:"subject"`
+}
+
+// DocumentIssuer signs documents with the server's Ed25519 key and records each issuance
+type DocumentIssuer struct {
+	repo  repository.IssuanceRepository
+	key   ed25519.PrivateKey
+	keyID string
+	// retired holds the public keys of replaced signing keys by key ID
+	retired map[string]ed25519.PublicKey
+}
+
+// NewDocumentIssuer creates a DocumentIssuer, loading or generating the signing key
+func NewDocumentIssuer(repo repository.IssuanceRepository, cfg config.Config) (*DocumentIssuer, error) {
+	key, err := loadSigningKey(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("load document signing key: %w", err)
+	}
+	retired, err := loadRetiredKeys(cfg.DocRetiredKeys)
+	if err != nil {
+		return nil, fmt.Errorf("load retired document keys: %w", err)
+	}
+	return &DocumentIssuer{
+		repo:    repo,
+		key:     key,
+		keyID:   documentKeyID(key.Public().(ed25519.PublicKey)),
+		retired: retired,
+	}, nil
+}
+
+// PublicKey returns the verification key
+func (d *DocumentIssuer) PublicKey() ed25519.PublicKey {
+	return d.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID identifies the current key: the first 8 bytes of its SHA-256, hex encoded
+func (d *DocumentIssuer) KeyID() string {
+	return d.keyID
+}
+
+// VerificationKey returns the public key with keyID, the current key or a retired one
+func (d *DocumentIssuer) VerificationKey(keyID string) (ed25519.PublicKey, bool) {
+	if keyID == d.keyID {
+		return d.PublicKey(), true
+	}
+	pub, ok := d.retired[keyID]
+	return pub, ok
+}
+
+func documentKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Issue signs subject as a document of docType and records it; the returned issuance
+// carries the code to print on the document
+func (d *DocumentIssuer) Issue(docType string, studentID, issuedBy uint, subject any) (*model.DocumentIssuance, error) {
+	code, err := newDocumentCode()
+	if err != nil {
+		return nil, err
+	}
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	payload, err := json.Marshal(signedDocument{
+		Type:     docType,
+		Code:     code,
+		KeyID:    d.keyID,
+		IssuedAt: issuedAt,
+		Subject:  subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	issuance := &model.DocumentIssuance{
+		Code:      code,
+		DocType:   docType,
+		StudentID: studentID,
+		Payload:   string(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(d.key, payload)),
+		KeyID:     d.keyID,
+		IssuedBy:  issuedBy,
+		IssuedAt:  issuedAt,
+	}
+	if err := d.repo.Create(issuance); err != nil {
+		return nil, err
+	}
+	return issuance, nil
+}
+
+// Status checks the stored signature and payload of an issuance against the key that
+// signed it. Documents of a replaced key that is not among the retired keys are unverifiable.
+func (d *DocumentIssuer) Status(issuance *model.DocumentIssuance) string {
+	pub, ok := d.VerificationKey(issuance.KeyID)
+	if !ok {
+		return DocStatusUnverifiable
+	}
+	sig, err := base64.StdEncoding.DecodeString(issuance.Signature)
+	if err != nil || !ed25519.Verify(pub, []byte(issuance.Payload), sig) {
+		return DocStatusTampered
+	}
+
+	// The signed envelope must still describe this record
+	var doc struct {
+		Type  string `json:"type"`
+		Code  string `json:"code"`
+		KeyID string `json:"key_id"`
+	}
+	if err := json.Unmarshal([]byte(issuance.Payload), &doc); err != nil ||
+		doc.Type != issuance.DocType || doc.Code != issuance.Code || doc.KeyID != issuance.KeyID {
+		return DocStatusTampered
+	}
+	if issuance.RevokedAt != nil {
+		return DocStatusRevoked
+	}
+	return DocStatusValid
+}
+
+// FormatDocumentCode groups a code for printing, e.g. ABCD-EFGH-JKLM-NPQR
+func FormatDocumentCode(code string) string {
+	var b strings.Builder
+	for i, r := range code {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NormalizeDocumentCode undoes FormatDocumentCode and case differences in user input
+func NormalizeDocumentCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// newDocumentCode returns 16 random base32 characters (80 bits)
+func newDocumentCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(buf), nil
+}
+
+// loadRetiredKeys parses the comma separated base64 public keys of DOC_RETIRED_KEYS
+func loadRetiredKeys(list string) (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(s)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, errors.New("DOC_RETIRED_KEYS must hold base64 encoded 32-byte public keys")
+		}
+		pub := ed25519.PublicKey(raw)
+		keys[documentKeyID(pub)] = pub
+	}
+	return keys, nil
+}
+
+// loadSigningKey reads the key from DOC_SIGNING_KEY, or from the PEM file, creating
+// the file with a fresh key when it does not exist yet
+func loadSigningKey(cfg config.Config) (ed25519.PrivateKey, error) {
+	if cfg.DocSigningKey != "" {
+		seed, err := base64.StdEncoding.DecodeString(cfg.DocSigningKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("DOC_SIGNING_KEY must be a base64 encoded 32-byte seed")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+
+	data, err := os.ReadFile(cfg.DocSigningKeyFile)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM block in " + cfg.DocSigningKeyFile)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New(cfg.DocSigningKeyFile + " is not an Ed25519 key")
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.DocSigningKeyFile), 0o700); err != nil {
+		return nil, err
+	}
+	out := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(cfg.DocSigningKeyFile, out, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+type memIssuanceRepo struct {
+	repository.IssuanceRepository
+}
+
+func (memIssuanceRepo) Create(*model.DocumentIssuance) error { return nil }
+
+func testIssuer(t *testing.T, seed byte, retired ...ed25519.PublicKey) *DocumentIssuer {
+	t.Helper()
+	var keys []string
+	for _, pub := range retired {
+		keys = append(keys, base64.StdEncoding.EncodeToString(pub))
+	}
+	cfg := config.Config{
+		DocSigningKey:  base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(seed)), ed25519.SeedSize))),
+		DocRetiredKeys: strings.Join(keys, ", "),
+	}
+	d, err := NewDocumentIssuer(memIssuanceRepo{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDocumentStatusAfterKeyRotation(t *testing.T) {
+	old := testIssuer(t, 'a')
+	issuance, err := old.Issue(model.DocTypeTranscript, 1, 1, map[string]string{"student_no": "2024001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := *issuance
+	tampered.Payload = strings.Replace(tampered.Payload, "2024001", "2024002", 1)
+
+	tests := []struct {
+		name     string
+		issuer   *DocumentIssuer
+		issuance *model.DocumentIssuance
+		want     string
+	}{
+		{"current key", old, issuance, DocStatusValid},
+		{"retired key", testIssuer(t, 'b', old.PublicKey()), issuance, DocStatusValid},
+		{"retired key, tampered", testIssuer(t, 'b', old.PublicKey()), &tampered, DocStatusTampered},
+		{"unknown key", testIssuer(t, 'b'), issuance, DocStatusUnverifiable},
+	}
+	for _, tt := range tests {
+		if got := tt.issuer.Status(tt.issuance); got != tt.want {
+			t.Errorf("%s: Status = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRetiredKeysRejectsMalformed(t *testing.T) {
+	for _, list := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := loadRetiredKeys(list); err == nil {
+			t.Errorf("loadRetiredKeys(%q) accepted a malformed key", list)
+		}
+	}
+}
@@ -1,28 +1,59 @@
 package service
 
 import (
+	"fmt"
+	"strings"
+
 	"gorm.io/gorm"
 
+	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
 const MaxCreditsPerTerm = 15
 
-// EnrollRequest represents the enrollment request
+// EnrollRequest represents the enrollment request.
+// Offerings are picked by OfferingIDs, or by CourseNos within TermCode when each course has a single section.
 type EnrollRequest struct {
-	TermCode   string
-	StudentNo  string
-	StudentNos []string
-	CourseNos  []string
+	TermCode    string
+	StudentNo   string
+	StudentNos  []string
+	CourseNos   []string
+	OfferingIDs []uint
 }
 
 // EnrollResult represents the result of an enrollment
 type EnrollResult struct {
-	StudentID uint   `json:"student_id"`
-	TermID    uint   `json:"term_id"`
-	CourseIDs []uint `json:"course_ids"`
+	StudentID             uint   `json:"student_id"`
+	TermID                uint   `json:"term_id"`
+	OfferingIDs           []uint `json:"offering_ids"`
+	WaitlistedOfferingIDs []uint `json:"waitlisted_offering_ids,omitempty"`
+}
+
+// ScheduleConflict describes two courses whose class meetings overlap
+type ScheduleConflict struct {
+	CourseNo           string `json:"course_no"`
+	CourseName         string `json:"course_name"`
+	ConflictCourseNo   string `json:"conflict_course_no"`
+	ConflictCourseName string `json:"conflict_course_name"`
+	Slot               string `json:"slot"`
+}
+
+// UnmetPrerequisite lists the prerequisite groups a student has not yet passed for a course
+type UnmetPrerequisite struct {
+	StudentID uint          `json:"student_id"`
+	CourseNo  string        `json:"course_no"`
+	Missing   []PrereqGroup `json:"missing"`
+}
+
+// CreateOverrideRequest represents a request to waive prerequisites for one student
+type CreateOverrideRequest struct {
+	StudentNo string `json:"student_no"`
+	CourseNo  string `json:"course_no"`
+	Reason    string `json:"reason"`
 }
 
 // EnrollmentListResult represents paginated enrollment list
@@ -33,43 +64,114 @@ type EnrollmentListResult struct {
 	Size  int                        `json:"size"`
 }
 
-// EnrollmentService defines the interface for enrollment business logic
+// EnrollmentService defines the interface for enrollment business logic. Enrollment and
+// waitlist listings of roles holding enrollment:read:own_course instead of enrollment:read
+// only cover the offerings they teach.
 type EnrollmentService interface {
-	List(studentNo, courseNo, termCode string, page, pageSize int) (*EnrollmentListResult, error)
+	List(studentNo, courseNo, termCode string, page, pageSize int, role string, userID uint) (*EnrollmentListResult, error)
+	Export(w export.Writer, studentNo, courseNo, termCode string, role string, userID uint) error
 	ListByStudent(role string, userID uint, studentNo string) ([]repository.EnrollmentRow, error)
 	Enroll(req EnrollRequest, role string, userID uint) ([]EnrollResult, error)
-	Delete(id uint, role string, userID uint) error
+	Delete(id uint, actor Actor) error
+	ListWaitlist(params repository.WaitlistQueryParams, role string, userID uint) ([]repository.WaitlistRow, error)
+	ListWaitlistByStudent(role string, userID uint, studentNo string) ([]repository.WaitlistRow, error)
+	LeaveWaitlist(id uint, role string, userID uint) error
+	ListPrerequisiteOverrides(params repository.OverrideQueryParams) ([]repository.OverrideRow, error)
+	CreatePrerequisiteOverride(req CreateOverrideRequest, userID uint) (*model.PrerequisiteOverride, error)
+	DeletePrerequisiteOverride(id uint) error
+	WithScope(scope repository.Scope) EnrollmentService
 }
 
 type enrollmentService struct {
-	enrollRepo  repository.EnrollmentRepository
-	termRepo    repository.TermRepository
-	courseRepo  repository.CourseRepository
-	studentRepo repository.StudentRepository
-	userRepo    repository.UserRepository
-	db          *gorm.DB
+	enrollRepo   repository.EnrollmentRepository
+	waitlistRepo repository.WaitlistRepository
+	prereqRepo   repository.PrerequisiteRepository
+	offeringRepo repository.OfferingRepository
+	termRepo     repository.TermRepository
+	courseRepo   repository.CourseRepository
+	studentRepo  repository.StudentRepository
+	userRepo     repository.UserRepository
+	auditRepo    repository.GradeAuditRepository
+	roles        RoleService
+	promoter     waitlistPromoter
+	db           *gorm.DB
+	cfg          config.Config
 }
 
 // NewEnrollmentService creates a new EnrollmentService
 func NewEnrollmentService(
 	enrollRepo repository.EnrollmentRepository,
+	waitlistRepo repository.WaitlistRepository,
+	prereqRepo repository.PrerequisiteRepository,
+	offeringRepo repository.OfferingRepository,
 	termRepo repository.TermRepository,
 	courseRepo repository.CourseRepository,
 	studentRepo repository.StudentRepository,
 	userRepo repository.UserRepository,
+	auditRepo repository.GradeAuditRepository,
+	roles RoleService,
 	db *gorm.DB,
+	cfg config.Config,
 ) EnrollmentService {
 	return &enrollmentService{
-		enrollRepo:  enrollRepo,
-		termRepo:    termRepo,
-		courseRepo:  courseRepo,
-		studentRepo: studentRepo,
-		userRepo:    userRepo,
-		db:          db,
+		enrollRepo:   enrollRepo,
+		waitlistRepo: waitlistRepo,
+		prereqRepo:   prereqRepo,
+		offeringRepo: offeringRepo,
+		termRepo:     termRepo,
+		courseRepo:   courseRepo,
+		studentRepo:  studentRepo,
+		userRepo:     userRepo,
+		auditRepo:    auditRepo,
+		roles:        roles,
+		promoter:     waitlistPromoter{enrollRepo: enrollRepo, waitlistRepo: waitlistRepo, offeringRepo: offeringRepo, cfg: cfg},
+		db:           db,
+		cfg:          cfg,
+	}
+}
+
+// WithScope returns the service limited to the enrollments and waitlist entries of the
+// scope's department's students. Offerings stay unscoped, since students also take
+// courses of other departments.
+func (s *enrollmentService) WithScope(scope repository.Scope) EnrollmentService {
+	scoped := *s
+	scoped.enrollRepo = s.enrollRepo.WithScope(scope)
+	scoped.waitlistRepo = s.waitlistRepo.WithScope(scope)
+	scoped.studentRepo = s.studentRepo.WithScope(scope)
+	return &scoped
+}
+
+var enrollmentExportHeader = []string{"学号", "姓名", "教学班", "课程号", "课程名称", "学分", "学期", "学期名称", "选课时间"}
+
+// Export writes every enrollment matching the list filters, without pagination
+func (s *enrollmentService) Export(w export.Writer, studentNo, courseNo, termCode string, role string, userID uint) error {
+	teaching, err := s.readScope(role, userID)
+	if err != nil {
+		return err
+	}
+	params := repository.EnrollmentQueryParams{
+		StudentNo: studentNo,
+		CourseNo:  courseNo,
+		TermCode:  termCode,
+		Teaching:  teaching,
+	}
+	if err := w.Sheet("选课", enrollmentExportHeader); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
 	}
+	if err := s.enrollRepo.EachByFilters(params, func(r *repository.EnrollmentRow) error {
+		return w.Row(r.StudentNo, r.StudentName, r.SectionNo, r.CourseNo, r.CourseName,
+			r.Credits, r.TermCode, r.TermName, r.CreatedAt)
+	}); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
 }
 
-func (s *enrollmentService) List(studentNo, courseNo, termCode string, page, pageSize int) (*EnrollmentListResult, error) {
+func (s *enrollmentService) List(studentNo, courseNo, termCode string, page, pageSize int, role string, userID uint) (*EnrollmentListResult, error) {
+	teaching, err := s.readScope(role, userID)
+	if err != nil {
+		return nil, err
+	}
 	if page <= 0 {
 		page = 1
 	}
@@ -81,6 +183,7 @@ func (s *enrollmentService) List(studentNo, courseNo, termCode string, page, pag
 		StudentNo: studentNo,
 		CourseNo:  courseNo,
 		TermCode:  termCode,
+		Teaching:  teaching,
 		Page:      page,
 		PageSize:  pageSize,
 	}
@@ -99,66 +202,106 @@ func (s *enrollmentService) List(studentNo, courseNo, termCode string, page, pag
 }
 
 func (s *enrollmentService) ListByStudent(role string, userID uint, studentNo string) ([]repository.EnrollmentRow, error) {
-	var studentID uint
+	// Without enrollment:read students only view their own enrollments
+	studentID, err := s.resolveStudent(role, userID, studentNo, model.PermEnrollmentRead, model.PermEnrollmentReadSelf)
+	if err != nil {
+		return nil, err
+	}
 
-	if role == "student" {
-		// Student can only view own enrollments
-		user, err := s.userRepo.FindByID(userID)
-		if err != nil || user.StudentID == nil {
-			return nil, pkg.NewAppError(pkg.ErrCodeStudentNotBound, "student not bound")
-		}
-		studentID = *user.StudentID
-	} else if role == "admin" || role == "teacher" {
+	items, err := s.enrollRepo.FindByStudentID(studentID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return items, nil
+}
+
+// readScope limits enrollment and waitlist listings to the offerings the caller teaches
+// unless it holds enrollment:read
+func (s *enrollmentService) readScope(role string, userID uint) (repository.Teaching, error) {
+	return readScope(s.roles, s.userRepo, s.cfg, role, userID, model.PermEnrollmentRead, model.PermEnrollmentReadOwnCourse)
+}
+
+// resolveStudent finds the student a listing is for: the one named by studentNo for roles
+// with the any permission, else the caller's own record for roles with the self permission
+func (s *enrollmentService) resolveStudent(role string, userID uint, studentNo, anyPerm, selfPerm string) (uint, error) {
+	switch {
+	case s.roles.Can(role, anyPerm):
 		if studentNo == "" {
-			return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no required")
+			return 0, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no required")
 		}
 		student, err := s.studentRepo.FindByStudentNo(studentNo)
 		if err != nil {
-			return nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+			return 0, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
 		}
-		studentID = student.ID
-	} else {
-		return nil, pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
+		return student.ID, nil
+	case s.roles.Can(role, selfPerm):
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil || user.StudentID == nil {
+			return 0, pkg.NewAppError(pkg.ErrCodeStudentNotBound, "student not bound")
+		}
+		return *user.StudentID, nil
+	default:
+		return 0, pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
 	}
+}
 
-	items, err := s.enrollRepo.FindByStudentID(studentID)
-	if err != nil {
-		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+// checkOwnStudent allows a change to a student's enrollment with enrollment:write, or with
+// enrollment:write:self when the student is the caller
+func (s *enrollmentService) checkOwnStudent(role string, userID, studentID uint) error {
+	if s.roles.Can(role, model.PermEnrollmentWrite) {
+		return nil
 	}
-	return items, nil
+	if s.roles.Can(role, model.PermEnrollmentWriteSelf) {
+		user, err := s.userRepo.FindByID(userID)
+		if err == nil && user.StudentID != nil && *user.StudentID == studentID {
+			return nil
+		}
+	}
+	return pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
 }
 
 func (s *enrollmentService) Enroll(req EnrollRequest, role string, userID uint) ([]EnrollResult, error) {
-	if req.TermCode == "" || len(req.CourseNos) == 0 {
-		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "term_code/course_nos required")
+	offerings, err := s.resolveOfferings(req)
+	if err != nil {
+		return nil, err
+	}
+	termID := offerings[0].TermID
+
+	offeringIDs := make([]uint, 0, len(offerings))
+	courseIDs := make([]uint, 0, len(offerings))
+	offeringCredit := make(map[uint]int)
+	courseNo := make(map[uint]string)
+	seenCourse := make(map[uint]struct{})
+	for _, o := range offerings {
+		if _, ok := seenCourse[o.CourseID]; ok {
+			return nil, pkg.NewAppError(pkg.ErrCodeDuplicateEnroll, "duplicate course in request: "+o.CourseNo)
+		}
+		seenCourse[o.CourseID] = struct{}{}
+		offeringIDs = append(offeringIDs, o.ID)
+		courseIDs = append(courseIDs, o.CourseID)
+		offeringCredit[o.ID] = o.Credits
+		courseNo[o.CourseID] = o.CourseNo
 	}
 
-	// Resolve term
-	term, err := s.termRepo.FindByTermCode(req.TermCode)
+	prereqRows, err := s.prereqRepo.FindByCourseIDs(courseIDs)
 	if err != nil {
-		return nil, pkg.NewAppError(pkg.ErrCodeTermNotFound, "term not found")
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
+	prereqs := groupPrerequisites(prereqRows)
 
-	// Resolve courses
-	courseRows, err := s.courseRepo.FindByCourseNos(req.CourseNos)
+	// Offerings in the same batch must not clash with each other
+	newMeetings, err := s.offeringRepo.FindMeetingRowsByOfferingIDs(offeringIDs)
 	if err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
-	if len(courseRows) != len(req.CourseNos) {
-		return nil, pkg.NewAppError(pkg.ErrCodeCourseNotFound, "some courses not found")
+	if conflicts := findScheduleConflicts(newMeetings, newMeetings); len(conflicts) > 0 {
+		return nil, scheduleConflictError(conflicts)
 	}
 
-	courseIDs := make([]uint, 0, len(courseRows))
-	courseCredit := make(map[uint]int)
-	for _, r := range courseRows {
-		courseIDs = append(courseIDs, r.ID)
-		courseCredit[r.ID] = r.Credits
-	}
-
-	// Resolve students based on role
+	// Resolve students based on permission
 	var studentIDs []uint
-	switch role {
-	case "admin":
+	switch {
+	case s.roles.Can(role, model.PermEnrollmentWrite):
 		studentNos := req.StudentNos
 		if len(studentNos) == 0 {
 			if req.StudentNo == "" {
@@ -176,7 +319,7 @@ func (s *enrollmentService) Enroll(req EnrollRequest, role string, userID uint)
 		for _, stu := range students {
 			studentIDs = append(studentIDs, stu.ID)
 		}
-	case "student":
+	case s.roles.Can(role, model.PermEnrollmentWriteSelf):
 		// Student can only enroll self
 		user, err := s.userRepo.FindByID(userID)
 		if err != nil || user.StudentID == nil {
@@ -192,48 +335,113 @@ func (s *enrollmentService) Enroll(req EnrollRequest, role string, userID uint)
 	for _, sid := range studentIDs {
 		err := s.db.Transaction(func(tx *gorm.DB) error {
 			txEnrollRepo := s.enrollRepo.WithTx(tx)
+			txWaitlistRepo := s.waitlistRepo.WithTx(tx)
 
 			// Get current credits
-			currentCredits, err := txEnrollRepo.GetCurrentCredits(sid, term.ID)
+			currentCredits, err := txEnrollRepo.GetCurrentCredits(sid, termID)
 			if err != nil {
 				return err
 			}
 
-			// Check for duplicates
-			dupCnt, err := txEnrollRepo.CountDuplicates(sid, courseIDs)
+			// Check for duplicates (courses failed with published grades may be retaken)
+			dupCnt, err := txEnrollRepo.CountDuplicates(sid, courseIDs, s.cfg.GradePassScore)
 			if err != nil {
 				return err
 			}
 			if dupCnt > 0 {
 				return pkg.NewAppError(pkg.ErrCodeDuplicateEnroll, "duplicate enrollment")
 			}
+			waitCnt, err := txWaitlistRepo.CountByStudentAndCourses(sid, courseIDs)
+			if err != nil {
+				return err
+			}
+			if waitCnt > 0 {
+				return pkg.NewAppError(pkg.ErrCodeOnWaitlist, "already on the waitlist")
+			}
+
+			// Check prerequisites against passed grades, skipping admin overrides
+			if len(prereqs) > 0 {
+				unmet, err := s.findUnmetPrerequisites(tx, sid, courseIDs, courseNo, prereqs)
+				if err != nil {
+					return err
+				}
+				if len(unmet) > 0 {
+					return unmetPrerequisiteError(unmet)
+				}
+			}
+
+			// Lock the offerings and split the batch into seated and waitlisted offerings
+			seats, err := txEnrollRepo.LockOfferingSeats(offeringIDs)
+			if err != nil {
+				return err
+			}
+			taken, err := txEnrollRepo.CountByOfferings(offeringIDs)
+			if err != nil {
+				return err
+			}
+			var seated, waitlisted []uint
+			for _, seat := range seats {
+				if seat.Capacity > 0 && taken[seat.ID] >= int64(seat.Capacity) {
+					waitlisted = append(waitlisted, seat.ID)
+				} else {
+					seated = append(seated, seat.ID)
+				}
+			}
 
-			// Calculate additional credits
+			// Calculate additional credits (waitlisted offerings are checked again on promotion)
 			addCredits := 0
-			for _, cid := range courseIDs {
-				addCredits += courseCredit[cid]
+			for _, oid := range seated {
+				addCredits += offeringCredit[oid]
 			}
 			if currentCredits+addCredits > MaxCreditsPerTerm {
 				return pkg.NewAppError(pkg.ErrCodeCreditExceeded, "credit limit exceeded")
 			}
 
+			// Check for timetable clashes with this term's existing enrollments
+			existing, err := txEnrollRepo.FindMeetingsByStudentAndTerm(sid, termID)
+			if err != nil {
+				return err
+			}
+			if conflicts := findScheduleConflicts(newMeetings, existing); len(conflicts) > 0 {
+				return scheduleConflictError(conflicts)
+			}
+
 			// Create enrollments
-			enrollments := make([]model.Enrollment, 0, len(courseIDs))
-			for _, cid := range courseIDs {
-				enrollments = append(enrollments, model.Enrollment{
-					StudentID: sid,
-					CourseID:  cid,
-					TermID:    term.ID,
-				})
+			if len(seated) > 0 {
+				enrollments := make([]model.Enrollment, 0, len(seated))
+				for _, oid := range seated {
+					enrollments = append(enrollments, model.Enrollment{
+						StudentID:  sid,
+						OfferingID: oid,
+					})
+				}
+				if err := txEnrollRepo.CreateBatch(enrollments); err != nil {
+					return err
+				}
 			}
-			if err := txEnrollRepo.CreateBatch(enrollments); err != nil {
-				return err
+
+			// Queue the rest
+			if len(waitlisted) > 0 {
+				entries := make([]model.WaitlistEntry, 0, len(waitlisted))
+				for _, oid := range waitlisted {
+					entries = append(entries, model.WaitlistEntry{
+						StudentID:  sid,
+						OfferingID: oid,
+					})
+				}
+				if err := txWaitlistRepo.CreateBatch(entries); err != nil {
+					return err
+				}
 			}
 
+			if seated == nil {
+				seated = []uint{}
+			}
 			results = append(results, EnrollResult{
-				StudentID: sid,
-				TermID:    term.ID,
-				CourseIDs: courseIDs,
+				StudentID:             sid,
+				TermID:                termID,
+				OfferingIDs:           seated,
+				WaitlistedOfferingIDs: waitlisted,
 			})
 			return nil
 		})
@@ -248,36 +456,302 @@ func (s *enrollmentService) Enroll(req EnrollRequest, role string, userID uint)
 	return results, nil
 }
 
-func (s *enrollmentService) Delete(id uint, role string, userID uint) error {
+// resolveOfferings picks the offerings of an enrollment request; all must belong to one term
+func (s *enrollmentService) resolveOfferings(req EnrollRequest) ([]repository.OfferingRow, error) {
+	if len(req.OfferingIDs) > 0 {
+		rows, err := s.offeringRepo.FindRowsByIDs(req.OfferingIDs)
+		if err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		if len(rows) != len(req.OfferingIDs) {
+			return nil, pkg.NewAppError(pkg.ErrCodeOfferingNF, "some offerings not found")
+		}
+		for _, r := range rows {
+			if r.TermID != rows[0].TermID {
+				return nil, pkg.NewAppError(pkg.ErrCodeMixedTerms, "offerings must belong to the same term")
+			}
+		}
+		return rows, nil
+	}
+
+	if req.TermCode == "" || len(req.CourseNos) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "offering_ids or term_code/course_nos required")
+	}
+	term, err := s.termRepo.FindByTermCode(req.TermCode)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeTermNotFound, "term not found")
+	}
+	rows, err := s.offeringRepo.FindRowsByTermAndCourseNos(term.ID, req.CourseNos)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	byCourseNo := make(map[string][]repository.OfferingRow)
+	for _, r := range rows {
+		byCourseNo[r.CourseNo] = append(byCourseNo[r.CourseNo], r)
+	}
+	result := make([]repository.OfferingRow, 0, len(req.CourseNos))
+	for _, no := range req.CourseNos {
+		sections := byCourseNo[no]
+		switch len(sections) {
+		case 0:
+			return nil, pkg.NewAppError(pkg.ErrCodeCourseNotFound, "course not offered in term: "+no)
+		case 1:
+			result = append(result, sections[0])
+		default:
+			return nil, pkg.NewAppError(pkg.ErrCodeSectionRequired, "several sections of "+no+", pass offering_ids")
+		}
+	}
+	return result, nil
+}
+
+func (s *enrollmentService) Delete(id uint, actor Actor) error {
 	// Load enrollment
 	enrollment, err := s.enrollRepo.FindByID(id)
 	if err != nil {
 		return pkg.WrapError(pkg.ErrCodeNotFound, "enrollment not found", err)
 	}
 
-	// Permission check
-	if role == "student" {
-		user, err := s.userRepo.FindByID(userID)
-		if err != nil || user.StudentID == nil || *user.StudentID != enrollment.StudentID {
-			return pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
-		}
-	} else if role != "admin" {
-		return pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
+	if err := s.checkOwnStudent(actor.Role, actor.UserID, enrollment.StudentID); err != nil {
+		return err
 	}
 
 	// PRD: 删除选课记录时需同步处理成绩数据
 	if err := s.db.Transaction(func(tx *gorm.DB) error {
 		txEnrollRepo := s.enrollRepo.WithTx(tx)
-		if err := txEnrollRepo.DeleteGradesByStudentAndCourse(enrollment.StudentID, enrollment.CourseID); err != nil {
+		txAuditRepo := s.auditRepo.WithTx(tx)
+		// The offering is locked before the grade, in the order grade writes take them
+		statuses, err := s.offeringRepo.WithTx(tx).LockGradeStatuses([]uint{enrollment.OfferingID})
+		if err != nil {
+			return err
+		}
+		grade, before, err := txAuditRepo.Snapshot(enrollment.StudentID, enrollment.OfferingID)
+		if err != nil {
+			return err
+		}
+		// Grades past draft are changed by amendment; only reviewers may drop them with the enrollment
+		status := statuses[enrollment.OfferingID]
+		if grade != nil && status != model.GradeStatusDraft && !s.roles.Can(actor.Role, model.PermGradeApprove) {
+			return pkg.NewAppError(pkg.ErrCodeGradeLocked, "the grade of this enrollment is "+status+" and cannot be dropped with it")
+		}
+		if err := txEnrollRepo.DeleteGradesByStudentAndOffering(enrollment.StudentID, enrollment.OfferingID); err != nil {
 			return err
 		}
+		if grade != nil {
+			if err := recordGradeChange(txAuditRepo, grade, before, nil, actor); err != nil {
+				return err
+			}
+		}
 		if err := txEnrollRepo.Delete(enrollment.ID); err != nil {
 			return err
 		}
-		return nil
+		// The freed seat goes to the next eligible student in the queue
+		_, err = s.promoter.promote(tx, enrollment.OfferingID)
+		return err
 	}); err != nil {
+		if appErr, ok := err.(*pkg.AppError); ok {
+			return appErr
+		}
 		return pkg.WrapError(pkg.ErrCodeEnrollDelFailed, "delete failed", err)
 	}
 
 	return nil
 }
+
+func (s *enrollmentService) ListWaitlist(params repository.WaitlistQueryParams, role string, userID uint) ([]repository.WaitlistRow, error) {
+	teaching, err := s.readScope(role, userID)
+	if err != nil {
+		return nil, err
+	}
+	params.Teaching = teaching
+	items, err := s.waitlistRepo.FindByFilters(params)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return items, nil
+}
+
+func (s *enrollmentService) ListWaitlistByStudent(role string, userID uint, studentNo string) ([]repository.WaitlistRow, error) {
+	// Without enrollment:read students only view their own waitlist positions
+	studentID, err := s.resolveStudent(role, userID, studentNo, model.PermEnrollmentRead, model.PermEnrollmentReadSelf)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.waitlistRepo.FindByStudentID(studentID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return items, nil
+}
+
+func (s *enrollmentService) LeaveWaitlist(id uint, role string, userID uint) error {
+	entry, err := s.waitlistRepo.FindByID(id)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeNotFound, "waitlist entry not found", err)
+	}
+
+	if err := s.checkOwnStudent(role, userID, entry.StudentID); err != nil {
+		return err
+	}
+
+	if err := s.waitlistRepo.Delete(entry.ID); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete failed", err)
+	}
+	return nil
+}
+
+func (s *enrollmentService) ListPrerequisiteOverrides(params repository.OverrideQueryParams) ([]repository.OverrideRow, error) {
+	items, err := s.prereqRepo.FindOverrides(params)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return items, nil
+}
+
+func (s *enrollmentService) CreatePrerequisiteOverride(req CreateOverrideRequest, userID uint) (*model.PrerequisiteOverride, error) {
+	if req.StudentNo == "" || req.CourseNo == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no/course_no required")
+	}
+	student, err := s.studentRepo.FindByStudentNo(req.StudentNo)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	course, err := s.courseRepo.FindByCourseNo(req.CourseNo)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeCourseNotFound, "course not found")
+	}
+
+	existing, err := s.prereqRepo.FindOverriddenCourseIDs(student.ID, []uint{course.ID})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if len(existing) > 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeOverrideExists, "override already exists")
+	}
+
+	override := &model.PrerequisiteOverride{
+		StudentID: student.ID,
+		CourseID:  course.ID,
+		Reason:    req.Reason,
+		CreatedBy: userID,
+	}
+	if err := s.prereqRepo.CreateOverride(override); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+	}
+	return override, nil
+}
+
+func (s *enrollmentService) DeletePrerequisiteOverride(id uint) error {
+	if _, err := s.prereqRepo.FindOverrideByID(id); err != nil {
+		return pkg.WrapError(pkg.ErrCodeNotFound, "override not found", err)
+	}
+	if err := s.prereqRepo.DeleteOverride(id); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete failed", err)
+	}
+	return nil
+}
+
+// findUnmetPrerequisites returns, per requested course, the groups in which the student
+// has passed none of the alternatives. Courses with an override are skipped.
+func (s *enrollmentService) findUnmetPrerequisites(tx *gorm.DB, studentID uint, courseIDs []uint,
+	courseNo map[uint]string, prereqs map[uint][]PrereqGroup) ([]UnmetPrerequisite, error) {
+	passedIDs, err := s.enrollRepo.WithTx(tx).FindPassedCourseIDs(studentID, s.cfg.GradePassScore)
+	if err != nil {
+		return nil, err
+	}
+	overriddenIDs, err := s.prereqRepo.WithTx(tx).FindOverriddenCourseIDs(studentID, courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	passed := make(map[uint]bool, len(passedIDs))
+	for _, id := range passedIDs {
+		passed[id] = true
+	}
+	overridden := make(map[uint]bool, len(overriddenIDs))
+	for _, id := range overriddenIDs {
+		overridden[id] = true
+	}
+
+	var unmet []UnmetPrerequisite
+	for _, cid := range courseIDs {
+		if overridden[cid] {
+			continue
+		}
+		var missing []PrereqGroup
+		for _, group := range prereqs[cid] {
+			satisfied := false
+			for _, pc := range group.Courses {
+				if passed[pc.CourseID] {
+					satisfied = true
+					break
+				}
+			}
+			if !satisfied {
+				missing = append(missing, group)
+			}
+		}
+		if len(missing) > 0 {
+			unmet = append(unmet, UnmetPrerequisite{StudentID: studentID, CourseNo: courseNo[cid], Missing: missing})
+		}
+	}
+	return unmet, nil
+}
+
+func unmetPrerequisiteError(unmet []UnmetPrerequisite) *pkg.AppError {
+	parts := make([]string, 0, len(unmet))
+	for _, u := range unmet {
+		groups := make([]string, 0, len(u.Missing))
+		for _, g := range u.Missing {
+			nos := make([]string, 0, len(g.Courses))
+			for _, c := range g.Courses {
+				nos = append(nos, c.CourseNo)
+			}
+			groups = append(groups, strings.Join(nos, " or "))
+		}
+		parts = append(parts, fmt.Sprintf("%s requires %s", u.CourseNo, strings.Join(groups, " and ")))
+	}
+	return pkg.NewAppErrorWithDetails(pkg.ErrCodePrereqUnmet,
+		"prerequisites not met: "+strings.Join(parts, "; "), unmet)
+}
+
+// findScheduleConflicts pairs every meeting in a with overlapping meetings of other offerings in b.
+// When a and b are the same slice each clashing pair is reported once.
+func findScheduleConflicts(a, b []repository.OfferingMeetingRow) []ScheduleConflict {
+	var conflicts []ScheduleConflict
+	seen := make(map[string]struct{})
+	for _, x := range a {
+		for _, y := range b {
+			if x.OfferingID == y.OfferingID || !x.ClassMeeting.Overlaps(y.ClassMeeting) {
+				continue
+			}
+			first, second := x, y
+			if first.CourseNo > second.CourseNo {
+				first, second = second, first
+			}
+			key := first.CourseNo + "|" + second.CourseNo
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			conflicts = append(conflicts, ScheduleConflict{
+				CourseNo:           x.CourseNo,
+				CourseName:         x.CourseName,
+				ConflictCourseNo:   y.CourseNo,
+				ConflictCourseName: y.CourseName,
+				Slot:               FormatClassMeeting(x.ClassMeeting),
+			})
+		}
+	}
+	return conflicts
+}
+
+func scheduleConflictError(conflicts []ScheduleConflict) *pkg.AppError {
+	pairs := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		pairs = append(pairs, fmt.Sprintf("%s %s / %s %s (%s)",
+			c.CourseNo, c.CourseName, c.ConflictCourseNo, c.ConflictCourseName, c.Slot))
+	}
+	return pkg.NewAppErrorWithDetails(pkg.ErrCodeScheduleConflict,
+		"schedule conflict: "+strings.Join(pairs, "; "), conflicts)
+}
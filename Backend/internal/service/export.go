@@ -0,0 +1,9 @@
+package service
+
+// exportYesNo renders a flag in spreadsheet exports
+func exportYesNo(v bool) string {
+	if v {
+		return "是"
+	}
+	return "否"
+}
@@ -0,0 +1,84 @@
+package service
+
+import "sort"
+
+// GPA scale names accepted by the summary endpoints
+const (
+	GPAScale4  = "4.0"
+	GPAScale5  = "5.0"
+	GPAScaleCN = "cn"
+)
+
+// GPAScale converts a final score into grade points
+type GPAScale interface {
+	Name() string
+	Max() float64
+	Points(score float64) float64
+}
+
+// gpaBand maps scores at or above Min to Points
+type gpaBand struct {
+	Min    float64
+	Points float64
+}
+
+// tableScale looks a score up in bands ordered from the highest Min down
+type tableScale struct {
+	name  string
+	bands []gpaBand
+}
+
+func (t tableScale) Name() string { return t.name }
+
+func (t tableScale) Max() float64 { return t.bands[0].Points }
+
+func (t tableScale) Points(score float64) float64 {
+	for _, b := range t.bands {
+		if score >= b.Min {
+			return b.Points
+		}
+	}
+	return 0
+}
+
+// formulaScale computes points directly from the score
+type formulaScale struct {
+	name string
+	max  float64
+	fn   func(score float64) float64
+}
+
+func (f formulaScale) Name() string { return f.name }
+
+func (f formulaScale) Max() float64 { return f.max }
+
+func (f formulaScale) Points(score float64) float64 { return f.fn(score) }
+
+// gpaScales holds the available conversion tables; add an entry to support another scale
+var gpaScales = map[string]GPAScale{
+	// Standard 4.0 table used by most Chinese universities for transcripts abroad
+	GPAScale4: tableScale{name: GPAScale4, bands: []gpaBand{
+		{90, 4.0}, {85, 3.7}, {82, 3.3}, {78, 3.0}, {75, 2.7},
+		{72, 2.3}, {68, 2.0}, {64, 1.5}, {60, 1.0},
+	}},
+	GPAScale5: tableScale{name: GPAScale5, bands: []gpaBand{
+		{90, 5.0}, {80, 4.0}, {70, 3.0}, {60, 2.0},
+	}},
+	// 绩点 = (成绩 - 50) / 10，不及格为 0
+	GPAScaleCN: formulaScale{name: GPAScaleCN, max: 5.0, fn: func(score float64) float64 {
+		if score < 60 {
+			return 0
+		}
+		return (score - 50) / 10
+	}},
+}
+
+// GPAScaleNames lists the registered scales in a stable order
+func GPAScaleNames() []string {
+	names := make([]string, 0, len(gpaScales))
+	for name := range gpaScales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+func TestGPAScalePoints(t *testing.T) {
+	tests := []struct {
+		scale string
+		score float64
+		want  float64
+	}{
+		{GPAScale4, 100, 4.0},
+		{GPAScale4, 90, 4.0},
+		{GPAScale4, 89.99, 3.7},
+		{GPAScale4, 85, 3.7},
+		{GPAScale4, 84.99, 3.3},
+		{GPAScale4, 82, 3.3},
+		{GPAScale4, 81.99, 3.0},
+		{GPAScale4, 78, 3.0},
+		{GPAScale4, 77.99, 2.7},
+		{GPAScale4, 75, 2.7},
+		{GPAScale4, 74.99, 2.3},
+		{GPAScale4, 72, 2.3},
+		{GPAScale4, 71.99, 2.0},
+		{GPAScale4, 68, 2.0},
+		{GPAScale4, 67.99, 1.5},
+		{GPAScale4, 64, 1.5},
+		{GPAScale4, 63.99, 1.0},
+		{GPAScale4, 60, 1.0},
+		{GPAScale4, 59.99, 0},
+		{GPAScale4, 0, 0},
+
+		{GPAScale5, 100, 5.0},
+		{GPAScale5, 90, 5.0},
+		{GPAScale5, 89.99, 4.0},
+		{GPAScale5, 80, 4.0},
+		{GPAScale5, 79.99, 3.0},
+		{GPAScale5, 70, 3.0},
+		{GPAScale5, 69.99, 2.0},
+		{GPAScale5, 60, 2.0},
+		{GPAScale5, 59.99, 0},
+		{GPAScale5, 0, 0},
+
+		{GPAScaleCN, 100, 5.0},
+		{GPAScaleCN, 85, 3.5},
+		{GPAScaleCN, 72.5, 2.25},
+		{GPAScaleCN, 60, 1.0},
+		{GPAScaleCN, 59.99, 0},
+		{GPAScaleCN, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := gpaScales[tt.scale].Points(tt.score); got != tt.want {
+			t.Errorf("scale %s: Points(%v) = %v, want %v", tt.scale, tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestGPAScaleMax(t *testing.T) {
+	for name, want := range map[string]float64{GPAScale4: 4.0, GPAScale5: 5.0, GPAScaleCN: 5.0} {
+		if got := gpaScales[name].Max(); got != want {
+			t.Errorf("scale %s: Max = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestGPAScaleNames(t *testing.T) {
+	want := []string{GPAScale4, GPAScale5, GPAScaleCN}
+	if got := GPAScaleNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GPAScaleNames = %v, want %v", got, want)
+	}
+}
+
+func TestBuildGPASummaryCreditWeighted(t *testing.T) {
+	row := func(courseID uint, credits int, term string, final *float64) repository.StudentGradeRow {
+		return repository.StudentGradeRow{CourseID: courseID, OfferingID: courseID, Credits: credits, TermCode: term, FinalScore: final}
+	}
+	cfg := config.Config{GradePassScore: 60, GradeAttemptPolicy: AttemptPolicyHighest}
+	tests := []struct {
+		name string
+		rows []repository.StudentGradeRow
+		want GPATotals
+	}{
+		{"weighted by credits", []repository.StudentGradeRow{
+			row(1, 4, "2024-1", score(90)), row(2, 2, "2024-1", score(75)),
+		}, GPATotals{AttemptedCredits: 6, EarnedCredits: 6, GPACredits: 6, GPA: 3.57, AverageScore: 85}},
+		{"failed course counts towards GPA", []repository.StudentGradeRow{
+			row(1, 3, "2024-1", score(90)), row(2, 1, "2024-1", score(50)),
+		}, GPATotals{AttemptedCredits: 4, EarnedCredits: 3, GPACredits: 4, GPA: 3, AverageScore: 80, FailedCount: 1}},
+		{"in progress only", []repository.StudentGradeRow{
+			row(1, 3, "2024-1", nil),
+		}, GPATotals{InProgressCredits: 3}},
+		// Zero total credits must not divide by zero
+		{"zero credits", []repository.StudentGradeRow{
+			row(1, 0, "2024-1", score(95)), row(2, 0, "2024-1", score(70)),
+		}, GPATotals{}},
+		{"no grades", nil, GPATotals{}},
+	}
+	for _, tt := range tests {
+		summary, _ := buildGPASummary(&model.Student{}, tt.rows, gpaScales[GPAScale4], cfg)
+		got := summary.Cumulative
+		if got != tt.want {
+			t.Errorf("%s: cumulative = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildGPASummaryTermOrder(t *testing.T) {
+	rows := []repository.StudentGradeRow{
+		{CourseID: 1, OfferingID: 1, Credits: 2, TermCode: "2024-FALL", TermStart: fall2024, FinalScore: score(80)},
+		{CourseID: 2, OfferingID: 2, Credits: 2, TermCode: "2024-SPRING", TermStart: spring2024, FinalScore: score(80)},
+		{CourseID: 3, OfferingID: 3, Credits: 2, TermCode: "2023-FALL", TermStart: fall2023, FinalScore: score(80)},
+	}
+	cfg := config.Config{GradePassScore: 60, GradeAttemptPolicy: AttemptPolicyHighest}
+	summary, _ := buildGPASummary(&model.Student{}, rows, gpaScales[GPAScale4], cfg)
+	var got []string
+	for _, term := range summary.Terms {
+		got = append(got, term.TermCode)
+	}
+	if want := []string{"2023-FALL", "2024-SPRING", "2024-FALL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("terms = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// GPATotals holds credit and GPA figures for a term or the whole record.
+//   - AttemptedCredits: credits of every graded attempt
+//   - EarnedCredits: credits of counted attempts at or above the pass score
+//   - GPA / AverageScore: credit-weighted over counted attempts
+//   - FailedCount: graded attempts below the pass score (cumulative: courses not yet passed)
+type GPATotals struct {
+	AttemptedCredits  int     `json:"attempted_credits"`
+	EarnedCredits     int     `json:"earned_credits"`
+	InProgressCredits int     `json:"in_progress_credits"`
+	GPACredits        int     `json:"gpa_credits"`
+	GPA               float64 `json:"gpa"`
+	AverageScore      float64 `json:"average_score"`
+	FailedCount       int     `json:"failed_count"`
+}
+
+// TermGPA is the summary of one term
+type TermGPA struct {
+	TermCode string `json:"term_code"`
+	GPATotals
+}
+
+// GPASummary is a student's per-term and cumulative GPA under one scale.
+// Which attempts of a retaken course count follows the configured attempt policy.
+type GPASummary struct {
+	StudentNo   string    `json:"student_no"`
+	StudentName string    `json:"student_name"`
+	Scale       string    `json:"scale"`
+	ScaleMax    float64   `json:"scale_max"`
+	Policy      string    `json:"attempt_policy"`
+	Terms       []TermGPA `json:"terms"`
+	Cumulative  GPATotals `json:"cumulative"`
+}
+
+// GPAService defines the interface for GPA and credit summaries
+type GPAService interface {
+	MySummary(userID uint, scale string) (*GPASummary, error)
+	StudentSummary(studentNo, scale, role string, userID uint) (*GPASummary, error)
+	WithScope(scope repository.Scope) GPAService
+}
+
+type gpaService struct {
+	gradeRepo   repository.GradeRepository
+	studentRepo repository.StudentRepository
+	userRepo    repository.UserRepository
+	roles       RoleService
+	cfg         config.Config
+}
+
+// NewGPAService creates a new GPAService
+func NewGPAService(
+	gradeRepo repository.GradeRepository,
+	studentRepo repository.StudentRepository,
+	userRepo repository.UserRepository,
+	roles RoleService,
+	cfg config.Config,
+) GPAService {
+	return &gpaService{gradeRepo: gradeRepo, studentRepo: studentRepo, userRepo: userRepo, roles: roles, cfg: cfg}
+}
+
+// WithScope returns the service limited to the students of the scope's department
+func (s *gpaService) WithScope(scope repository.Scope) GPAService {
+	scoped := *s
+	scoped.studentRepo = s.studentRepo.WithScope(scope)
+	return &scoped
+}
+
+func (s *gpaService) MySummary(userID uint, scale string) (*GPASummary, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user.StudentID == nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeStudentNotBound, "student not bound")
+	}
+	student, err := s.studentRepo.FindByID(*user.StudentID)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	return s.summarize(student, scale, true)
+}
+
+// StudentSummary summarizes any student's grades, or with grade:read:own_course those of
+// a student enrolled in an offering the caller teaches
+func (s *gpaService) StudentSummary(studentNo, scale, role string, userID uint) (*GPASummary, error) {
+	if studentNo == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no required")
+	}
+	teaching, err := readScope(s.roles, s.userRepo, s.cfg, role, userID, model.PermGradeRead, model.PermGradeReadOwnCourse)
+	if err != nil {
+		return nil, err
+	}
+	student, err := s.studentRepo.WithTeaching(teaching).FindByStudentNo(studentNo)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	return s.summarize(student, scale, false)
+}
+
+// summarize computes the summary of a student; students themselves only count published grades
+func (s *gpaService) summarize(student *model.Student, scaleName string, publishedOnly bool) (*GPASummary, error) {
+	if scaleName == "" {
+		scaleName = s.cfg.GPAScale
+	}
+	scale, ok := gpaScales[scaleName]
+	if !ok {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidGPAScale,
+			"unknown gpa scale, expected one of: "+strings.Join(GPAScaleNames(), ", "))
+	}
+
+	rows, err := s.gradeRepo.FindByStudentID(student.ID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if publishedOnly {
+		rows = publishedGrades(rows)
+	}
+	summary, _ := buildGPASummary(student, rows, scale, s.cfg)
+	return summary, nil
+}
+
+// gpaAccumulator sums one term or the whole record
+type gpaAccumulator struct {
+	totals   GPATotals
+	pointSum float64
+	scoreSum float64
+}
+
+func (a *gpaAccumulator) addCounted(credits int, score, points float64) {
+	a.totals.GPACredits += credits
+	a.pointSum += points * float64(credits)
+	a.scoreSum += score * float64(credits)
+}
+
+func (a *gpaAccumulator) result() GPATotals {
+	t := a.totals
+	if t.GPACredits > 0 {
+		t.GPA = round2(a.pointSum / float64(t.GPACredits))
+		t.AverageScore = round2(a.scoreSum / float64(t.GPACredits))
+	}
+	return t
+}
+
+// buildGPASummary computes per-term and cumulative figures from a student's grade rows.
+// It also returns the attempts that counted under the configured policy.
+func buildGPASummary(student *model.Student, rows []repository.StudentGradeRow, scale GPAScale, cfg config.Config) (*GPASummary, map[attemptKey]bool) {
+	attempts := make([]repository.GradeAttempt, len(rows))
+	for i, r := range rows {
+		attempts[i] = gradeAttempt(student.ID, r)
+	}
+	counted := countedAttempts(cfg.GradeAttemptPolicy, attempts)
+
+	terms := make(map[string]*gpaAccumulator)
+	termStarts := make(map[string]*time.Time)
+	var cumulative gpaAccumulator
+	passedCourses := make(map[uint]bool)
+	failedCourses := make(map[uint]bool)
+	for _, r := range rows {
+		term, ok := terms[r.TermCode]
+		if !ok {
+			term = &gpaAccumulator{}
+			terms[r.TermCode] = term
+			termStarts[r.TermCode] = r.TermStart
+		}
+		if r.FinalScore == nil {
+			term.totals.InProgressCredits += r.Credits
+			cumulative.totals.InProgressCredits += r.Credits
+			continue
+		}
+
+		score := *r.FinalScore
+		passed := score >= cfg.GradePassScore
+		term.totals.AttemptedCredits += r.Credits
+		cumulative.totals.AttemptedCredits += r.Credits
+		if passed {
+			passedCourses[r.CourseID] = true
+		} else {
+			term.totals.FailedCount++
+			failedCourses[r.CourseID] = true
+		}
+
+		if !counted[attemptKey{student.ID, r.OfferingID}] {
+			continue
+		}
+		points := scale.Points(score)
+		term.addCounted(r.Credits, score, points)
+		cumulative.addCounted(r.Credits, score, points)
+		if passed {
+			term.totals.EarnedCredits += r.Credits
+			cumulative.totals.EarnedCredits += r.Credits
+		}
+	}
+	for courseID := range failedCourses {
+		if !passedCourses[courseID] {
+			cumulative.totals.FailedCount++
+		}
+	}
+
+	termCodes := make([]string, 0, len(terms))
+	for code := range terms {
+		termCodes = append(termCodes, code)
+	}
+	// Oldest term first
+	sort.Slice(termCodes, func(i, j int) bool {
+		a, b := termCodes[i], termCodes[j]
+		return termAfter(termStarts[b], b, termStarts[a], a)
+	})
+	result := &GPASummary{
+		StudentNo:   student.StudentNo,
+		StudentName: student.Name,
+		Scale:       scale.Name(),
+		ScaleMax:    scale.Max(),
+		Policy:      cfg.GradeAttemptPolicy,
+		Terms:       make([]TermGPA, 0, len(termCodes)),
+		Cumulative:  cumulative.result(),
+	}
+	for _, code := range termCodes {
+		result.Terms = append(result.Terms, TermGPA{TermCode: code, GPATotals: terms[code].result()})
+	}
+	return result, counted
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"reflect"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Actor identifies who makes a change, and in which request, for the audit trail
+type Actor struct {
+	UserID    uint
+	Role      string
+	RequestID string
+}
+
+// snapshotOf is the state a pending grade will have once written
+func snapshotOf(pg pendingGrade) *model.GradeSnapshot {
+	snap := &model.GradeSnapshot{
+		UsualScore:      pg.grade.UsualScore,
+		ExamScore:       pg.grade.ExamScore,
+		FinalScore:      pg.grade.FinalScore,
+		FinalOverridden: pg.grade.FinalOverridden,
+		Components:      make(map[string]*float64, len(pg.scores)),
+	}
+	for _, sc := range pg.scores {
+		snap.Components[sc.Code] = sc.Score
+	}
+	return snap
+}
+
+// recordGradeChange appends an audit entry for a grade going from before to after;
+// a nil before is an insert and a nil after a delete. Rewrites that change nothing
+// are not recorded.
+func recordGradeChange(auditRepo repository.GradeAuditRepository, grade *model.Grade, before, after *model.GradeSnapshot, actor Actor) error {
+	action := model.GradeAuditUpdate
+	switch {
+	case before == nil:
+		action = model.GradeAuditInsert
+	case after == nil:
+		action = model.GradeAuditDelete
+	case reflect.DeepEqual(before, after):
+		return nil
+	}
+	err := auditRepo.Create(&model.GradeAudit{
+		GradeID:    grade.ID,
+		StudentID:  grade.StudentID,
+		OfferingID: grade.OfferingID,
+		Action:     action,
+		OldValue:   before,
+		NewValue:   after,
+		UserID:     actor.UserID,
+		Role:       actor.Role,
+		RequestID:  actor.RequestID,
+	})
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return nil
+}
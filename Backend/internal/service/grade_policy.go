@@ -0,0 +1,108 @@
+package service
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Attempt policies decide which graded attempts of a retaken course count towards
+// credits, GPA and reports.
+const (
+	AttemptPolicyHighest = "highest"
+	AttemptPolicyLatest  = "latest"
+	AttemptPolicyAll     = "all"
+)
+
+// attemptKey identifies one attempt: a student's grade in one offering
+type attemptKey struct {
+	StudentID  uint
+	OfferingID uint
+}
+
+// countedAttempts returns the attempts that count under policy. Ungraded attempts never count;
+// ties under "highest" go to the later term. Unknown policies fall back to "highest".
+func countedAttempts(policy string, attempts []repository.GradeAttempt) map[attemptKey]bool {
+	counted := make(map[attemptKey]bool)
+
+	type courseKey struct{ StudentID, CourseID uint }
+	best := make(map[courseKey]repository.GradeAttempt)
+	for _, a := range attempts {
+		if a.FinalScore == nil {
+			continue
+		}
+		if policy == AttemptPolicyAll {
+			counted[attemptKey{a.StudentID, a.OfferingID}] = true
+			continue
+		}
+
+		key := courseKey{a.StudentID, a.CourseID}
+		cur, ok := best[key]
+		if !ok || betterAttempt(policy, a, cur) {
+			best[key] = a
+		}
+	}
+	for _, a := range best {
+		counted[attemptKey{a.StudentID, a.OfferingID}] = true
+	}
+	return counted
+}
+
+// betterAttempt reports whether a should replace cur as the counted attempt
+func betterAttempt(policy string, a, cur repository.GradeAttempt) bool {
+	if policy == AttemptPolicyLatest {
+		return termAfter(a.TermStart, a.TermCode, cur.TermStart, cur.TermCode)
+	}
+	if *a.FinalScore != *cur.FinalScore {
+		return *a.FinalScore > *cur.FinalScore
+	}
+	return termAfter(a.TermStart, a.TermCode, cur.TermStart, cur.TermCode)
+}
+
+// termAfter reports whether term a comes after term b. Terms order by start date, terms
+// without one before all others, and by code when the dates are equal. Codes alone do
+// not order: 2024-SPRING sorts after 2024-FALL.
+func termAfter(aStart *time.Time, aCode string, bStart *time.Time, bCode string) bool {
+	switch {
+	case aStart == nil && bStart == nil:
+	case aStart == nil || bStart == nil:
+		return bStart == nil
+	case !aStart.Equal(*bStart):
+		return aStart.After(*bStart)
+	}
+	return aCode > bCode
+}
+
+// gradeAttempt is the attempt of a student's grade row
+func gradeAttempt(studentID uint, r repository.StudentGradeRow) repository.GradeAttempt {
+	return repository.GradeAttempt{
+		StudentID:  studentID,
+		CourseID:   r.CourseID,
+		OfferingID: r.OfferingID,
+		TermCode:   r.TermCode,
+		TermStart:  r.TermStart,
+		FinalScore: r.FinalScore,
+	}
+}
+
+// loadCountedAttempts looks up every attempt of the given students at the given courses
+// and applies policy to them
+func loadCountedAttempts(gradeRepo repository.GradeRepository, policy string, studentIDs, courseIDs []uint) (map[attemptKey]bool, error) {
+	attempts, err := gradeRepo.FindAttempts(uniqueIDs(studentIDs), uniqueIDs(courseIDs))
+	if err != nil {
+		return nil, err
+	}
+	return countedAttempts(policy, attempts), nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
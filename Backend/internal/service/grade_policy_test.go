@@ -0,0 +1,119 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+func date(s string) *time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+// Terms of the seed data: the spring term of 2024 starts before its fall term, although
+// its code sorts after it
+var (
+	fall2023   = date("2023-09-04")
+	spring2024 = date("2024-02-26")
+	fall2024   = date("2024-09-02")
+)
+
+func attempt(offeringID uint, termCode string, termStart *time.Time, final *float64) repository.GradeAttempt {
+	return repository.GradeAttempt{StudentID: 1, CourseID: 7, OfferingID: offeringID, TermCode: termCode, TermStart: termStart, FinalScore: final}
+}
+
+func TestCountedAttempts(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		attempts []repository.GradeAttempt
+		want     []uint
+	}{
+		{"latest is the fall term", AttemptPolicyLatest, []repository.GradeAttempt{
+			attempt(1, "2024-SPRING", spring2024, score(55)),
+			attempt(2, "2024-FALL", fall2024, score(70)),
+		}, []uint{2}},
+		{"latest in any order", AttemptPolicyLatest, []repository.GradeAttempt{
+			attempt(2, "2024-FALL", fall2024, score(50)),
+			attempt(1, "2024-SPRING", spring2024, score(90)),
+			attempt(3, "2023-FALL", fall2023, score(40)),
+		}, []uint{2}},
+		{"highest", AttemptPolicyHighest, []repository.GradeAttempt{
+			attempt(1, "2024-SPRING", spring2024, score(90)),
+			attempt(2, "2024-FALL", fall2024, score(70)),
+		}, []uint{1}},
+		{"highest tie goes to the fall term", AttemptPolicyHighest, []repository.GradeAttempt{
+			attempt(2, "2024-FALL", fall2024, score(75)),
+			attempt(1, "2024-SPRING", spring2024, score(75)),
+		}, []uint{2}},
+		{"unknown policy is highest", "best", []repository.GradeAttempt{
+			attempt(1, "2024-SPRING", spring2024, score(75)),
+			attempt(2, "2024-FALL", fall2024, score(75)),
+		}, []uint{2}},
+		{"all", AttemptPolicyAll, []repository.GradeAttempt{
+			attempt(1, "2024-SPRING", spring2024, score(55)),
+			attempt(2, "2024-FALL", fall2024, score(70)),
+		}, []uint{1, 2}},
+		{"ungraded never counts", AttemptPolicyLatest, []repository.GradeAttempt{
+			attempt(1, "2024-SPRING", spring2024, score(55)),
+			attempt(2, "2024-FALL", fall2024, nil),
+		}, []uint{1}},
+		{"terms without a start date are the oldest", AttemptPolicyLatest, []repository.GradeAttempt{
+			attempt(1, "2023-LEGACY", nil, score(80)),
+			attempt(2, "2023-FALL", fall2023, score(60)),
+		}, []uint{2}},
+		{"codes order terms without a start date", AttemptPolicyLatest, []repository.GradeAttempt{
+			attempt(2, "2022-B", nil, score(60)),
+			attempt(1, "2022-A", nil, score(80)),
+		}, []uint{2}},
+		{"other students and courses count separately", AttemptPolicyLatest, []repository.GradeAttempt{
+			attempt(1, "2024-SPRING", spring2024, score(55)),
+			{StudentID: 2, CourseID: 7, OfferingID: 2, TermCode: "2024-FALL", TermStart: fall2024, FinalScore: score(70)},
+			{StudentID: 1, CourseID: 8, OfferingID: 3, TermCode: "2024-FALL", TermStart: fall2024, FinalScore: score(70)},
+		}, []uint{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		got := countedAttempts(tt.policy, tt.attempts)
+		want := make(map[attemptKey]bool)
+		for _, a := range tt.attempts {
+			for _, id := range tt.want {
+				if a.OfferingID == id {
+					want[attemptKey{a.StudentID, a.OfferingID}] = true
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: countedAttempts = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestTermAfter(t *testing.T) {
+	tests := []struct {
+		aStart *time.Time
+		aCode  string
+		bStart *time.Time
+		bCode  string
+		want   bool
+	}{
+		{fall2024, "2024-FALL", spring2024, "2024-SPRING", true},
+		{spring2024, "2024-SPRING", fall2024, "2024-FALL", false},
+		{spring2024, "2024-SPRING", fall2023, "2023-FALL", true},
+		{fall2024, "2024-FALL-B", fall2024, "2024-FALL-A", true},
+		{fall2024, "2024-FALL", fall2024, "2024-FALL", false},
+		{fall2023, "2023-FALL", nil, "2099-X", true},
+		{nil, "2099-X", fall2023, "2023-FALL", false},
+		{nil, "B", nil, "A", true},
+	}
+	for _, tt := range tests {
+		if got := termAfter(tt.aStart, tt.aCode, tt.bStart, tt.bCode); got != tt.want {
+			t.Errorf("termAfter(%s, %s) = %v, want %v", tt.aCode, tt.bCode, got, tt.want)
+		}
+	}
+}
@@ -1,36 +1,52 @@
 package service
 
 import (
+	"io"
 	"sort"
 
 	"gorm.io/gorm"
 
+	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
-// GradeItem represents a single grade item for input
+// GradeItem represents a single grade item for input.
+// TermCode picks the attempt when the student took the course more than once; the latest is used otherwise.
+// Components holds scores keyed by grading scheme component code; FinalScore is computed from them
+// and may only be entered by hand together with OverrideFinal.
 type GradeItem struct {
-	StudentNo  string   `json:"student_no"`
-	CourseNo   string   `json:"course_no"`
-	UsualScore *float64 `json:"usual_score"`
-	ExamScore  *float64 `json:"exam_score"`
-	FinalScore *float64 `json:"final_score"`
+	StudentNo     string              `json:"student_no"`
+	CourseNo      string              `json:"course_no"`
+	TermCode      string              `json:"term_code,omitempty"`
+	UsualScore    *float64            `json:"usual_score"`
+	ExamScore     *float64            `json:"exam_score"`
+	Components    map[string]*float64 `json:"components,omitempty"`
+	FinalScore    *float64            `json:"final_score"`
+	OverrideFinal bool                `json:"override_final,omitempty"`
 }
 
-// GradeRow represents a grade row in the response
+// GradeRow represents a grade row in the response.
+// Counted tells whether this attempt counts under the configured attempt policy.
 type GradeRow struct {
-	StudentNo   string   `json:"student_no"`
-	StudentName string   `json:"student_name"`
-	Gender      string   `json:"gender"`
-	UsualScore  *float64 `json:"usual_score"`
-	ExamScore   *float64 `json:"exam_score"`
-	FinalScore  *float64 `json:"final_score"`
+	StudentNo       string              `json:"student_no"`
+	StudentName     string              `json:"student_name"`
+	Gender          string              `json:"gender"`
+	UsualScore      *float64            `json:"usual_score"`
+	ExamScore       *float64            `json:"exam_score"`
+	Components      map[string]*float64 `json:"components"`
+	FinalScore      *float64            `json:"final_score"`
+	FinalOverridden bool                `json:"final_overridden"`
+	Counted         bool                `json:"counted"`
 }
 
-// CourseGradeGroup represents a course with its grades
+// CourseGradeGroup represents a course offering with its grades
 type CourseGradeGroup struct {
+	OfferingID  uint       `json:"offering_id"`
+	TermCode    string     `json:"term_code"`
+	SectionNo   string     `json:"section_no"`
 	CourseNo    string     `json:"course_no"`
 	CourseName  string     `json:"course_name"`
 	TeacherNo   string     `json:"teacher_no"`
@@ -52,56 +68,95 @@ type GradeQueryParams struct {
 	CourseName  string
 	TeacherName string
 	DeptNo      string
+	TermCode    string
 }
 
-// MyGradeItem represents a student's own grade (flat structure for frontend)
+// MyGradeItem represents a student's own grade (flat structure for frontend).
+// Every attempt is listed; Attempt numbers them per course in term order.
 type MyGradeItem struct {
-	CourseNo   string   `json:"course_no"`
-	CourseName string   `json:"course_name"`
-	Credits    int      `json:"credits"`
-	TermCode   string   `json:"term_code"`
-	UsualScore *float64 `json:"usual_score"`
-	ExamScore  *float64 `json:"exam_score"`
-	FinalScore *float64 `json:"final_score"`
+	CourseNo   string              `json:"course_no"`
+	CourseName string              `json:"course_name"`
+	Credits    int                 `json:"credits"`
+	TermCode   string              `json:"term_code"`
+	SectionNo  string              `json:"section_no"`
+	Attempt    int                 `json:"attempt"`
+	Counted    bool                `json:"counted"`
+	UsualScore *float64            `json:"usual_score"`
+	ExamScore  *float64            `json:"exam_score"`
+	Components map[string]*float64 `json:"components"`
+	FinalScore *float64            `json:"final_score"`
 }
 
-// GradeService defines the interface for grade business logic
+// GradeService defines the interface for grade business logic. Grade queries of roles
+// holding grade:read:own_course instead of grade:read only cover the offerings they teach.
 type GradeService interface {
-	Query(params GradeQueryParams) ([]CourseGradeGroup, error)
+	Query(params GradeQueryParams, role string, userID uint) ([]CourseGradeGroup, error)
+	ExportQuery(w export.Writer, params GradeQueryParams, role string, userID uint) error
 	QueryMyGrades(userID uint) ([]MyGradeItem, error)
-	UpsertByCourse(courseNo string, items []GradeItem, role string, userID uint) error
-	UpsertByStudent(studentNo string, items []GradeItem, role string, userID uint) error
+	UpsertByCourse(courseNo, termCode string, items []GradeItem, actor Actor) error
+	UpsertByStudent(studentNo string, items []GradeItem, actor Actor) error
+	GradeTemplate(w export.Writer, offeringID uint, role string, userID uint) error
+	UploadGradeTemplate(offeringID uint, filename string, r io.Reader, commit bool, actor Actor) (*GradeUploadResult, error)
+	WithScope(scope repository.Scope) GradeService
 }
 
 type gradeService struct {
-	gradeRepo   repository.GradeRepository
-	courseRepo  repository.CourseRepository
-	studentRepo repository.StudentRepository
-	userRepo    repository.UserRepository
-	staffRepo   repository.StaffRepository
-	db          *gorm.DB
+	gradeRepo    repository.GradeRepository
+	courseRepo   repository.CourseRepository
+	offeringRepo repository.OfferingRepository
+	studentRepo  repository.StudentRepository
+	userRepo     repository.UserRepository
+	staffRepo    repository.StaffRepository
+	schemeRepo   repository.GradingSchemeRepository
+	auditRepo    repository.GradeAuditRepository
+	roles        RoleService
+	db           *gorm.DB
+	cfg          config.Config
 }
 
 // NewGradeService creates a new GradeService
 func NewGradeService(
 	gradeRepo repository.GradeRepository,
 	courseRepo repository.CourseRepository,
+	offeringRepo repository.OfferingRepository,
 	studentRepo repository.StudentRepository,
 	userRepo repository.UserRepository,
 	staffRepo repository.StaffRepository,
+	schemeRepo repository.GradingSchemeRepository,
+	auditRepo repository.GradeAuditRepository,
+	roles RoleService,
 	db *gorm.DB,
+	cfg config.Config,
 ) GradeService {
 	return &gradeService{
-		gradeRepo:   gradeRepo,
-		courseRepo:  courseRepo,
-		studentRepo: studentRepo,
-		userRepo:    userRepo,
-		staffRepo:   staffRepo,
-		db:          db,
+		gradeRepo:    gradeRepo,
+		courseRepo:   courseRepo,
+		offeringRepo: offeringRepo,
+		studentRepo:  studentRepo,
+		userRepo:     userRepo,
+		staffRepo:    staffRepo,
+		schemeRepo:   schemeRepo,
+		auditRepo:    auditRepo,
+		roles:        roles,
+		db:           db,
+		cfg:          cfg,
 	}
 }
 
-func (s *gradeService) Query(params GradeQueryParams) ([]CourseGradeGroup, error) {
+// WithScope returns the service limited to the grades of the scope's department's students.
+// Courses stay unscoped, since students also take courses of other departments.
+func (s *gradeService) WithScope(scope repository.Scope) GradeService {
+	scoped := *s
+	scoped.gradeRepo = s.gradeRepo.WithScope(scope)
+	scoped.studentRepo = s.studentRepo.WithScope(scope)
+	return &scoped
+}
+
+func (s *gradeService) Query(params GradeQueryParams, role string, userID uint) ([]CourseGradeGroup, error) {
+	teaching, err := readScope(s.roles, s.userRepo, s.cfg, role, userID, model.PermGradeRead, model.PermGradeReadOwnCourse)
+	if err != nil {
+		return nil, err
+	}
 	repoParams := repository.GradeQueryParams{
 		StudentNo:   params.StudentNo,
 		StudentName: params.StudentName,
@@ -109,6 +164,8 @@ func (s *gradeService) Query(params GradeQueryParams) ([]CourseGradeGroup, error
 		CourseName:  params.CourseName,
 		TeacherName: params.TeacherName,
 		DeptNo:      params.DeptNo,
+		TermCode:    params.TermCode,
+		Teaching:    teaching,
 	}
 
 	rows, err := s.gradeRepo.FindByFilters(repoParams)
@@ -116,14 +173,34 @@ func (s *gradeService) Query(params GradeQueryParams) ([]CourseGradeGroup, error
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
 
-	// Group by course
+	studentIDs := make([]uint, 0, len(rows))
+	courseIDs := make([]uint, 0, len(rows))
+	gradeIDs := make([]uint, 0, len(rows))
+	for _, r := range rows {
+		studentIDs = append(studentIDs, r.StudentID)
+		courseIDs = append(courseIDs, r.CourseID)
+		gradeIDs = append(gradeIDs, r.GradeID)
+	}
+	counted, err := loadCountedAttempts(s.gradeRepo, s.cfg.GradeAttemptPolicy, studentIDs, courseIDs)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	components, err := s.loadComponentScores(gradeIDs)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	// Group by course offering
 	m := make(map[string]*CourseGradeGroup)
 	order := make([]string, 0)
 	for _, r := range rows {
-		key := r.CourseNo
+		key := r.CourseNo + "|" + r.TermCode + "|" + r.SectionNo
 		gp, ok := m[key]
 		if !ok {
 			gp = &CourseGradeGroup{
+				OfferingID:  r.OfferingID,
+				TermCode:    r.TermCode,
+				SectionNo:   r.SectionNo,
 				CourseNo:    r.CourseNo,
 				CourseName:  r.CourseName,
 				TeacherNo:   r.TeacherNo,
@@ -139,16 +216,19 @@ func (s *gradeService) Query(params GradeQueryParams) ([]CourseGradeGroup, error
 			order = append(order, key)
 		}
 		gp.Rows = append(gp.Rows, GradeRow{
-			StudentNo:   r.StudentNo,
-			StudentName: r.StudentName,
-			Gender:      r.Gender,
-			UsualScore:  r.UsualScore,
-			ExamScore:   r.ExamScore,
-			FinalScore:  r.FinalScore,
+			StudentNo:       r.StudentNo,
+			StudentName:     r.StudentName,
+			Gender:          r.Gender,
+			UsualScore:      r.UsualScore,
+			ExamScore:       r.ExamScore,
+			Components:      components[r.GradeID],
+			FinalScore:      r.FinalScore,
+			FinalOverridden: r.Overridden,
+			Counted:         counted[attemptKey{r.StudentID, r.OfferingID}],
 		})
 	}
 
-	sort.Strings(order)
+	// Groups keep the order of the query: by course, the newest term first
 	result := make([]CourseGradeGroup, 0, len(order))
 	for _, k := range order {
 		result = append(result, *m[k])
@@ -156,6 +236,75 @@ func (s *gradeService) Query(params GradeQueryParams) ([]CourseGradeGroup, error
 	return result, nil
 }
 
+var gradeExportHeader = []string{
+	"学期", "教学班", "课程号", "课程名称", "教师工号", "教师", "学时", "学分", "上课时间", "上课地点", "考试时间",
+	"院系号", "学号", "姓名", "性别", "平时成绩", "考试成绩", "总评成绩", "手动总评", "计入",
+}
+
+// ExportQuery writes the grade query as one flat table, a row per grade. Rows arrive
+// grouped by offering; only one offering is held at a time to resolve counted attempts.
+func (s *gradeService) ExportQuery(w export.Writer, params GradeQueryParams, role string, userID uint) error {
+	teaching, err := readScope(s.roles, s.userRepo, s.cfg, role, userID, model.PermGradeRead, model.PermGradeReadOwnCourse)
+	if err != nil {
+		return err
+	}
+	repoParams := repository.GradeQueryParams{
+		StudentNo:   params.StudentNo,
+		StudentName: params.StudentName,
+		CourseNo:    params.CourseNo,
+		CourseName:  params.CourseName,
+		TeacherName: params.TeacherName,
+		DeptNo:      params.DeptNo,
+		TermCode:    params.TermCode,
+		Teaching:    teaching,
+	}
+	if err := w.Sheet("成绩", gradeExportHeader); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+
+	var group []repository.GradeQueryRow
+	flush := func() error {
+		if len(group) == 0 {
+			return nil
+		}
+		studentIDs := make([]uint, len(group))
+		for i, r := range group {
+			studentIDs[i] = r.StudentID
+		}
+		counted, err := loadCountedAttempts(s.gradeRepo, s.cfg.GradeAttemptPolicy, studentIDs, []uint{group[0].CourseID})
+		if err != nil {
+			return err
+		}
+		for _, r := range group {
+			if err := w.Row(r.TermCode, r.SectionNo, r.CourseNo, r.CourseName, r.TeacherNo, r.TeacherName,
+				r.Hours, r.Credits, r.ClassTime, r.ClassLoc, r.ExamTime,
+				r.DeptNo, r.StudentNo, r.StudentName, r.Gender, r.UsualScore, r.ExamScore, r.FinalScore,
+				exportYesNo(r.Overridden), exportYesNo(counted[attemptKey{r.StudentID, r.OfferingID}])); err != nil {
+				return err
+			}
+		}
+		group = group[:0]
+		return nil
+	}
+
+	err = s.gradeRepo.EachByFilters(repoParams, func(r *repository.GradeQueryRow) error {
+		if len(group) > 0 && group[0].OfferingID != r.OfferingID {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		group = append(group, *r)
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
+}
+
 // QueryMyGrades returns grades for the current student user (flat structure)
 func (s *gradeService) QueryMyGrades(userID uint) ([]MyGradeItem, error) {
 	user, err := s.userRepo.FindByID(userID)
@@ -167,6 +316,35 @@ func (s *gradeService) QueryMyGrades(userID uint) ([]MyGradeItem, error) {
 	if err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
+	rows = publishedGrades(rows)
+
+	gradeIDs := make([]uint, len(rows))
+	attempts := make([]repository.GradeAttempt, len(rows))
+	for i, r := range rows {
+		gradeIDs[i] = r.GradeID
+		attempts[i] = gradeAttempt(*user.StudentID, r)
+	}
+	counted := countedAttempts(s.cfg.GradeAttemptPolicy, attempts)
+	components, err := s.loadComponentScores(gradeIDs)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	// Number attempts per course, oldest term first
+	attemptNo := make(map[uint]int, len(rows))
+	byTerm := make([]int, len(rows))
+	for i := range byTerm {
+		byTerm[i] = i
+	}
+	sort.SliceStable(byTerm, func(a, b int) bool {
+		ra, rb := rows[byTerm[a]], rows[byTerm[b]]
+		return termAfter(rb.TermStart, rb.TermCode, ra.TermStart, ra.TermCode)
+	})
+	seq := make(map[uint]int)
+	for _, i := range byTerm {
+		seq[rows[i].CourseID]++
+		attemptNo[rows[i].OfferingID] = seq[rows[i].CourseID]
+	}
 
 	result := make([]MyGradeItem, len(rows))
 	for i, r := range rows {
@@ -175,33 +353,113 @@ func (s *gradeService) QueryMyGrades(userID uint) ([]MyGradeItem, error) {
 			CourseName: r.CourseName,
 			Credits:    r.Credits,
 			TermCode:   r.TermCode,
+			SectionNo:  r.SectionNo,
+			Attempt:    attemptNo[r.OfferingID],
+			Counted:    counted[attemptKey{*user.StudentID, r.OfferingID}],
 			UsualScore: r.UsualScore,
 			ExamScore:  r.ExamScore,
+			Components: components[r.GradeID],
 			FinalScore: r.FinalScore,
 		}
 	}
 	return result, nil
 }
 
-// checkTeacherCoursePermission checks if teacher can modify grades for the course
-func (s *gradeService) checkTeacherCoursePermission(userID uint, courseID uint) error {
-	user, err := s.userRepo.FindByID(userID)
+// publishedGrades drops grades of offerings whose grades have not been published yet
+func publishedGrades(rows []repository.StudentGradeRow) []repository.StudentGradeRow {
+	published := rows[:0:0]
+	for _, r := range rows {
+		if r.Published {
+			published = append(published, r)
+		}
+	}
+	return published
+}
+
+// loadComponentScores returns the component scores of each grade keyed by code
+func (s *gradeService) loadComponentScores(gradeIDs []uint) (map[uint]map[string]*float64, error) {
+	scores, err := s.schemeRepo.FindComponentScores(gradeIDs)
+	if err != nil {
+		return nil, err
+	}
+	byGrade := make(map[uint]map[string]*float64)
+	for _, sc := range scores {
+		if byGrade[sc.GradeID] == nil {
+			byGrade[sc.GradeID] = make(map[string]*float64)
+		}
+		byGrade[sc.GradeID][sc.Code] = sc.Score
+	}
+	return byGrade, nil
+}
+
+// courseScope limits the offerings a role may work on. It returns 0 when the role holds one
+// of the all permissions, and otherwise the staff record bound to the account when the role
+// holds grade:write:own_course.
+func courseScope(roles RoleService, userRepo repository.UserRepository, role string, userID uint, all ...string) (uint, error) {
+	for _, perm := range all {
+		if roles.Can(role, perm) {
+			return 0, nil
+		}
+	}
+	if !roles.Can(role, model.PermGradeWriteOwnCourse) {
+		return 0, pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
+	}
+	user, err := userRepo.FindByID(userID)
 	if err != nil || user.StaffID == nil {
-		return pkg.NewAppError(pkg.ErrCodeForbidden, "teacher not bound")
+		return 0, pkg.NewAppError(pkg.ErrCodeForbidden, "teacher not bound")
 	}
+	return *user.StaffID, nil
+}
 
-	course, err := s.courseRepo.FindByID(courseID)
+// readScope limits what a role reads. Holders of all read everything; holders of ownCourse
+// only the offerings they teach, or with TEACHER_READ_SCOPE=department those taught by anyone
+// of their department.
+func readScope(roles RoleService, userRepo repository.UserRepository, cfg config.Config, role string, userID uint, all, ownCourse string) (repository.Teaching, error) {
+	if roles.Can(role, all) {
+		return repository.Teaching{}, nil
+	}
+	if !roles.Can(role, ownCourse) {
+		return repository.Teaching{}, pkg.NewAppError(pkg.ErrCodeForbidden, "forbidden")
+	}
+	user, err := userRepo.FindByID(userID)
+	if err != nil || user.StaffID == nil {
+		return repository.Teaching{}, pkg.NewAppError(pkg.ErrCodeForbidden, "teacher not bound")
+	}
+	return repository.Teaching{StaffID: *user.StaffID, Dept: cfg.TeacherReadScope == "department"}, nil
+}
+
+// resolveGradeOffering finds the offering a grade belongs to: the student's enrollment in the course,
+// limited to termCode when given. Callers limited to their own courses (staffID != 0) may only
+// grade those, and only while its grades are a draft; grade:write also corrects submitted grades.
+func (s *gradeService) resolveGradeOffering(student *model.Student, course *model.Course, termCode string, staffID uint) (*model.CourseOffering, error) {
+	offering, err := s.offeringRepo.FindEnrolled(student.ID, course.ID, termCode)
 	if err != nil {
-		return pkg.NewAppError(pkg.ErrCodeGradeCourseNF, "course not found")
+		return nil, pkg.NewAppError(pkg.ErrCodeGradeNotEnrolled,
+			"student "+student.StudentNo+" not enrolled in "+course.CourseNo)
 	}
+	if staffID != 0 && offering.TeacherID != staffID {
+		return nil, pkg.NewAppError(pkg.ErrCodeForbidden, "can only modify grades for own courses")
+	}
+	if err := checkGradeEditable(offering.GradeStatus, course.CourseNo, staffID); err != nil {
+		return nil, err
+	}
+	return offering, nil
+}
 
-	if course.TeacherID != *user.StaffID {
-		return pkg.NewAppError(pkg.ErrCodeForbidden, "can only modify grades for own courses")
+// checkGradeEditable refuses grades that a caller may no longer edit in status
+func checkGradeEditable(status, courseNo string, staffID uint) error {
+	switch {
+	case status == model.GradeStatusDraft:
+	case status == model.GradeStatusSubmitted && staffID == 0:
+	case status == model.GradeStatusSubmitted:
+		return pkg.NewAppError(pkg.ErrCodeGradeLocked, "grades of "+courseNo+" were submitted and can no longer be edited")
+	default:
+		return pkg.NewAppError(pkg.ErrCodeGradeLocked, "grades of "+courseNo+" are "+status+", request an amendment instead")
 	}
 	return nil
 }
 
-func (s *gradeService) UpsertByCourse(courseNo string, items []GradeItem, role string, userID uint) error {
+func (s *gradeService) UpsertByCourse(courseNo, termCode string, items []GradeItem, actor Actor) error {
 	if courseNo == "" || len(items) == 0 {
 		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "course_no/items required")
 	}
@@ -211,37 +469,45 @@ func (s *gradeService) UpsertByCourse(courseNo string, items []GradeItem, role s
 		return pkg.NewAppError(pkg.ErrCodeGradeCourseNF, "course not found")
 	}
 
-	// Teacher can only modify grades for own courses
-	if role == "teacher" {
-		if err := s.checkTeacherCoursePermission(userID, course.ID); err != nil {
-			return err
-		}
+	// Without grade:write only the caller's own offerings can be graded
+	staffID, err := courseScope(s.roles, s.userRepo, actor.Role, actor.UserID, model.PermGradeWrite)
+	if err != nil {
+		return err
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		txGradeRepo := s.gradeRepo.WithTx(tx)
-		for _, item := range items {
-			student, err := s.studentRepo.FindByStudentNo(item.StudentNo)
-			if err != nil {
-				return pkg.NewAppError(pkg.ErrCodeGradeStudentNF, "student not found: "+item.StudentNo)
-			}
+	scheme, err := loadGradingScheme(s.schemeRepo, s.cfg, course.ID)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
 
-			grade := &model.Grade{
-				StudentID:  student.ID,
-				CourseID:   course.ID,
-				UsualScore: item.UsualScore,
-				ExamScore:  item.ExamScore,
-				FinalScore: item.FinalScore,
-			}
-			if err := txGradeRepo.Upsert(grade); err != nil {
-				return pkg.WrapError(pkg.ErrCodeGradeUpsertFail, "upsert failed", err)
-			}
+	grades := make([]pendingGrade, 0, len(items))
+	for _, item := range items {
+		student, err := s.studentRepo.FindByStudentNo(item.StudentNo)
+		if err != nil {
+			return pkg.NewAppError(pkg.ErrCodeGradeStudentNF, "student not found: "+item.StudentNo)
 		}
-		return nil
-	})
+		itemTerm := termCode
+		if item.TermCode != "" {
+			itemTerm = item.TermCode
+		}
+		offering, err := s.resolveGradeOffering(student, course, itemTerm, staffID)
+		if err != nil {
+			return err
+		}
+		item.StudentNo = student.StudentNo
+		grade, scores, err := buildGrade(&scheme.GradingScheme, item)
+		if err != nil {
+			return err
+		}
+		grade.StudentID = student.ID
+		grade.OfferingID = offering.ID
+		grades = append(grades, pendingGrade{grade: grade, scores: scores, courseNo: course.CourseNo})
+	}
+
+	return s.upsertGrades(grades, staffID, actor)
 }
 
-func (s *gradeService) UpsertByStudent(studentNo string, items []GradeItem, role string, userID uint) error {
+func (s *gradeService) UpsertByStudent(studentNo string, items []GradeItem, actor Actor) error {
 	if studentNo == "" || len(items) == 0 {
 		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no/items required")
 	}
@@ -251,38 +517,91 @@ func (s *gradeService) UpsertByStudent(studentNo string, items []GradeItem, role
 		return pkg.NewAppError(pkg.ErrCodeGradeStudentNF, "student not found")
 	}
 
-	// If teacher, check permission for each course
-	if role == "teacher" {
-		for _, item := range items {
-			course, err := s.courseRepo.FindByCourseNo(item.CourseNo)
-			if err != nil {
-				return pkg.NewAppError(pkg.ErrCodeGradeCourseNF, "course not found: "+item.CourseNo)
-			}
-			if err := s.checkTeacherCoursePermission(userID, course.ID); err != nil {
-				return err
-			}
+	// Without grade:write each offering must be the caller's own
+	staffID, err := courseScope(s.roles, s.userRepo, actor.Role, actor.UserID, model.PermGradeWrite)
+	if err != nil {
+		return err
+	}
+
+	grades := make([]pendingGrade, 0, len(items))
+	for _, item := range items {
+		course, err := s.courseRepo.FindByCourseNo(item.CourseNo)
+		if err != nil {
+			return pkg.NewAppError(pkg.ErrCodeGradeCourseNF, "course not found: "+item.CourseNo)
+		}
+		offering, err := s.resolveGradeOffering(student, course, item.TermCode, staffID)
+		if err != nil {
+			return err
+		}
+		scheme, err := loadGradingScheme(s.schemeRepo, s.cfg, course.ID)
+		if err != nil {
+			return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 		}
+		item.StudentNo = student.StudentNo
+		grade, scores, err := buildGrade(&scheme.GradingScheme, item)
+		if err != nil {
+			return err
+		}
+		grade.StudentID = student.ID
+		grade.OfferingID = offering.ID
+		grades = append(grades, pendingGrade{grade: grade, scores: scores, courseNo: course.CourseNo})
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		txGradeRepo := s.gradeRepo.WithTx(tx)
-		for _, item := range items {
-			course, err := s.courseRepo.FindByCourseNo(item.CourseNo)
-			if err != nil {
-				return pkg.NewAppError(pkg.ErrCodeGradeCourseNF, "course not found: "+item.CourseNo)
-			}
+	return s.upsertGrades(grades, staffID, actor)
+}
 
-			grade := &model.Grade{
-				StudentID:  student.ID,
-				CourseID:   course.ID,
-				UsualScore: item.UsualScore,
-				ExamScore:  item.ExamScore,
-				FinalScore: item.FinalScore,
-			}
-			if err := txGradeRepo.Upsert(grade); err != nil {
-				return pkg.WrapError(pkg.ErrCodeGradeUpsertFail, "upsert failed", err)
+// pendingGrade is a grade ready to be written together with its component scores
+type pendingGrade struct {
+	grade    *model.Grade
+	scores   []model.GradeComponentScore
+	courseNo string
+}
+
+// upsertGrades writes grades after locking their offerings and checking their grade status
+// again, so that a submit or approval racing the write cannot be overtaken by it
+func (s *gradeService) upsertGrades(grades []pendingGrade, staffID uint, actor Actor) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, 0, len(grades))
+		for _, pg := range grades {
+			ids = append(ids, pg.grade.OfferingID)
+		}
+		statuses, err := s.offeringRepo.WithTx(tx).LockGradeStatuses(ids)
+		if err != nil {
+			return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		for _, pg := range grades {
+			if err := checkGradeEditable(statuses[pg.grade.OfferingID], pg.courseNo, staffID); err != nil {
+				return err
 			}
 		}
-		return nil
+		w := gradeWriter{s.gradeRepo.WithTx(tx), s.schemeRepo.WithTx(tx), s.auditRepo.WithTx(tx)}
+		return w.save(grades, actor)
 	})
 }
+
+// gradeWriter writes grades inside a transaction and records each change in the audit trail
+type gradeWriter struct {
+	gradeRepo  repository.GradeRepository
+	schemeRepo repository.GradingSchemeRepository
+	auditRepo  repository.GradeAuditRepository
+}
+
+// save writes grades, replaces their component scores and audits the change of each
+func (w gradeWriter) save(grades []pendingGrade, actor Actor) error {
+	for _, pg := range grades {
+		_, before, err := w.auditRepo.Snapshot(pg.grade.StudentID, pg.grade.OfferingID)
+		if err != nil {
+			return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		if err := w.gradeRepo.Upsert(pg.grade); err != nil {
+			return pkg.WrapError(pkg.ErrCodeGradeUpsertFail, "upsert failed", err)
+		}
+		if err := w.schemeRepo.ReplaceComponentScores(pg.grade.ID, pg.scores); err != nil {
+			return pkg.WrapError(pkg.ErrCodeGradeUpsertFail, "upsert failed", err)
+		}
+		if err := recordGradeChange(w.auditRepo, pg.grade, before, snapshotOf(pg), actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Grade sheet columns besides the grading components
+const (
+	gradeSheetStudentNo = "学号"
+	gradeSheetName      = "姓名"
+	gradeSheetOverride  = "override_final"
+)
+
+// Grade upload row statuses
+const (
+	GradeUploadNew       = "new"
+	GradeUploadChanged   = "changed"
+	GradeUploadUnchanged = "unchanged"
+)
+
+// GradeUploadScores is one side of a grade diff, with every component of the scheme
+type GradeUploadScores struct {
+	Components      map[string]*float64 `json:"components"`
+	FinalScore      *float64            `json:"final_score"`
+	FinalOverridden bool                `json:"final_overridden"`
+}
+
+// GradeUploadRow compares an uploaded row with the stored grade; Before is nil when
+// the student has no grade yet
+type GradeUploadRow struct {
+	Row         int                `json:"row"`
+	StudentNo   string             `json:"student_no"`
+	StudentName string             `json:"student_name"`
+	Status      string             `json:"status"`
+	Before      *GradeUploadScores `json:"before,omitempty"`
+	After       GradeUploadScores  `json:"after"`
+}
+
+// GradeUploadResult reports a previewed or applied grade sheet
+type GradeUploadResult struct {
+	OfferingID uint             `json:"offering_id"`
+	CourseNo   string           `json:"course_no"`
+	TermCode   string           `json:"term_code"`
+	SectionNo  string           `json:"section_no"`
+	Committed  bool             `json:"committed"`
+	New        int              `json:"new"`
+	Changed    int              `json:"changed"`
+	Unchanged  int              `json:"unchanged"`
+	Rows       []GradeUploadRow `json:"rows"`
+	Errors     []ImportRowError `json:"errors"`
+}
+
+// gradeSheet is an offering with its grading scheme and the current grades of its roster
+type gradeSheet struct {
+	offering repository.OfferingRow
+	scheme   *model.GradingScheme
+	roster   []repository.OfferingGradeRow
+	scores   map[uint]map[string]*float64
+}
+
+// current returns the stored grade of a roster row, or nil when there is none
+func (sh *gradeSheet) current(r *repository.OfferingGradeRow) *GradeUploadScores {
+	if r.GradeID == nil {
+		return nil
+	}
+	components := make(map[string]*float64, len(sh.scheme.Components))
+	for _, c := range sh.scheme.Components {
+		components[c.Code] = sh.scores[*r.GradeID][c.Code]
+	}
+	return &GradeUploadScores{Components: components, FinalScore: r.FinalScore, FinalOverridden: r.Overridden}
+}
+
+// loadGradeSheet loads an offering for grade entry; without grade:write only the caller's own
+func (s *gradeService) loadGradeSheet(offeringID uint, role string, userID uint) (*gradeSheet, error) {
+	staffID, err := courseScope(s.roles, s.userRepo, role, userID, model.PermGradeWrite)
+	if err != nil {
+		return nil, err
+	}
+	offerings, err := s.offeringRepo.FindRowsByIDs([]uint{offeringID})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if len(offerings) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeOfferingNF, "offering not found")
+	}
+	offering := offerings[0]
+	if staffID != 0 && offering.TeacherID != staffID {
+		return nil, pkg.NewAppError(pkg.ErrCodeForbidden, "can only modify grades for own courses")
+	}
+
+	scheme, err := loadGradingScheme(s.schemeRepo, s.cfg, offering.CourseID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	roster, err := s.gradeRepo.FindOfferingRoster(offeringID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	gradeIDs := make([]uint, 0, len(roster))
+	for _, r := range roster {
+		if r.GradeID != nil {
+			gradeIDs = append(gradeIDs, *r.GradeID)
+		}
+	}
+	scores, err := s.loadComponentScores(gradeIDs)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return &gradeSheet{offering: offering, scheme: &scheme.GradingScheme, roster: roster, scores: scores}, nil
+}
+
+// componentHeader titles a component column, e.g. "平时 30%(usual)"; the code in
+// parentheses is what an upload is matched on
+func componentHeader(c model.GradingComponent) string {
+	name := c.Name
+	if name == "" {
+		name = c.Code
+	}
+	return fmt.Sprintf("%s %s%%(%s)", name, strconv.FormatFloat(c.Weight, 'f', -1, 64), c.Code)
+}
+
+// GradeTemplate writes the grade sheet of an offering: one row per enrolled student,
+// prefilled with the current component scores and any hand-entered final score
+func (s *gradeService) GradeTemplate(w export.Writer, offeringID uint, role string, userID uint) error {
+	sheet, err := s.loadGradeSheet(offeringID, role, userID)
+	if err != nil {
+		return err
+	}
+
+	header := []string{gradeSheetStudentNo, gradeSheetName}
+	for _, c := range sheet.scheme.Components {
+		header = append(header, componentHeader(c))
+	}
+	header = append(header, "总评覆盖("+gradeSheetOverride+")")
+
+	err = w.Sheet("成绩录入", header)
+	for i := 0; err == nil && i < len(sheet.roster); i++ {
+		r := &sheet.roster[i]
+		values := []any{r.StudentNo, r.StudentName}
+		var components map[string]*float64
+		if r.GradeID != nil {
+			components = sheet.scores[*r.GradeID]
+		}
+		for _, c := range sheet.scheme.Components {
+			values = append(values, components[c.Code])
+		}
+		var override *float64
+		if r.Overridden {
+			override = r.FinalScore
+		}
+		err = w.Row(append(values, override)...)
+	}
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
+}
+
+// gradeSheetLayout maps the uploaded header to the sheet's columns
+type gradeSheetLayout struct {
+	studentNo  string
+	override   string
+	components map[string]string
+}
+
+// headerKey returns the text inside trailing parentheses, full or half width, or the header itself
+func headerKey(h string) string {
+	for _, p := range [][2]string{{"(", ")"}, {"（", "）"}} {
+		if strings.HasSuffix(h, p[1]) {
+			if i := strings.LastIndex(h, p[0]); i >= 0 {
+				return strings.TrimSpace(h[i+len(p[0]) : len(h)-len(p[1])])
+			}
+		}
+	}
+	return h
+}
+
+// readGradeSheetLayout matches the header against the grading scheme. Every component
+// column is required so that a missing column is not mistaken for cleared scores.
+func readGradeSheetLayout(table *importTable, scheme *model.GradingScheme) (*gradeSheetLayout, []ImportRowError) {
+	codes := make(map[string]string, len(scheme.Components))
+	for _, c := range scheme.Components {
+		codes[strings.ToLower(c.Code)] = c.Code
+	}
+
+	layout := &gradeSheetLayout{components: make(map[string]string)}
+	var errs []ImportRowError
+	fail := func(column, message string) {
+		errs = append(errs, ImportRowError{Row: table.headerLine, Column: column, Message: message})
+	}
+	for _, h := range table.header {
+		key := headerKey(h)
+		var slot *string
+		switch {
+		case h == "":
+			continue
+		case h == gradeSheetStudentNo || key == "student_no":
+			slot = &layout.studentNo
+		case h == gradeSheetName || key == "name":
+			continue
+		case key == gradeSheetOverride:
+			slot = &layout.override
+		default:
+			code, ok := codes[key]
+			if !ok {
+				fail(h, "unknown column")
+				continue
+			}
+			if _, dup := layout.components[code]; dup {
+				fail(h, "duplicate column")
+				continue
+			}
+			layout.components[code] = h
+			continue
+		}
+		if *slot != "" {
+			fail(h, "duplicate column")
+			continue
+		}
+		*slot = h
+	}
+
+	if layout.studentNo == "" {
+		fail(gradeSheetStudentNo, "missing required column")
+	}
+	for _, c := range scheme.Components {
+		if _, ok := layout.components[c.Code]; !ok {
+			fail(componentHeader(c), "missing required column")
+		}
+	}
+	return layout, errs
+}
+
+// UploadGradeTemplate compares a filled-in grade sheet with the stored grades. With commit
+// set and no errors, new and changed grades are saved through UpsertByCourse in one transaction.
+func (s *gradeService) UploadGradeTemplate(offeringID uint, filename string, r io.Reader, commit bool, actor Actor) (*GradeUploadResult, error) {
+	sheet, err := s.loadGradeSheet(offeringID, actor.Role, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	table, err := readImportTable(filename, r, s.cfg.ImportMaxRows)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeImportFormat, err.Error(), err)
+	}
+
+	result := &GradeUploadResult{
+		OfferingID: sheet.offering.ID,
+		CourseNo:   sheet.offering.CourseNo,
+		TermCode:   sheet.offering.TermCode,
+		SectionNo:  sheet.offering.SectionNo,
+		Rows:       []GradeUploadRow{},
+		Errors:     []ImportRowError{},
+	}
+	layout, errs := readGradeSheetLayout(table, sheet.scheme)
+	if len(errs) > 0 {
+		result.Errors = errs
+		return finishGradeUpload(result, commit)
+	}
+
+	roster := make(map[string]*repository.OfferingGradeRow, len(sheet.roster))
+	for i := range sheet.roster {
+		roster[sheet.roster[i].StudentNo] = &sheet.roster[i]
+	}
+	seen := make(map[string]int)
+	var items []GradeItem
+	for _, row := range table.rows {
+		c := &importCheck{row: row}
+		studentNo := c.required(layout.studentNo)
+		enrolled := roster[studentNo]
+		if studentNo != "" {
+			if prev, dup := seen[studentNo]; dup {
+				c.fail(layout.studentNo, fmt.Sprintf("duplicate of row %d", prev))
+			} else if enrolled == nil {
+				c.fail(layout.studentNo, "student not enrolled in this offering")
+			}
+			seen[studentNo] = row.line
+		}
+		components := make(map[string]*float64, len(sheet.scheme.Components))
+		for _, comp := range sheet.scheme.Components {
+			components[comp.Code] = c.score(layout.components[comp.Code])
+		}
+		var override *float64
+		if layout.override != "" {
+			override = c.score(layout.override)
+		}
+		if len(c.errs) > 0 {
+			result.Errors = append(result.Errors, c.errs...)
+			continue
+		}
+
+		item := GradeItem{
+			StudentNo:     studentNo,
+			TermCode:      sheet.offering.TermCode,
+			Components:    components,
+			FinalScore:    override,
+			OverrideFinal: override != nil,
+		}
+		grade, _, err := buildGrade(sheet.scheme, item)
+		if err != nil {
+			var appErr *pkg.AppError
+			if !errors.As(err, &appErr) {
+				return nil, err
+			}
+			result.Errors = append(result.Errors, ImportRowError{Row: row.line, Message: appErr.Message})
+			continue
+		}
+
+		diff := GradeUploadRow{
+			Row:         row.line,
+			StudentNo:   studentNo,
+			StudentName: enrolled.StudentName,
+			Before:      sheet.current(enrolled),
+			After:       GradeUploadScores{Components: components, FinalScore: grade.FinalScore, FinalOverridden: grade.FinalOverridden},
+		}
+		switch {
+		case diff.Before == nil && (grade.FinalOverridden || anyScore(components)):
+			diff.Status = GradeUploadNew
+			result.New++
+		case diff.Before != nil && !sameGradeScores(diff.Before, &diff.After):
+			diff.Status = GradeUploadChanged
+			result.Changed++
+		default:
+			diff.Status = GradeUploadUnchanged
+			result.Unchanged++
+		}
+		if diff.Status != GradeUploadUnchanged {
+			items = append(items, item)
+		}
+		result.Rows = append(result.Rows, diff)
+	}
+
+	if !commit || len(result.Errors) > 0 {
+		return finishGradeUpload(result, commit)
+	}
+	if len(items) > 0 {
+		if err := s.UpsertByCourse(sheet.offering.CourseNo, sheet.offering.TermCode, items, actor); err != nil {
+			return nil, err
+		}
+	}
+	result.Committed = true
+	return result, nil
+}
+
+func finishGradeUpload(result *GradeUploadResult, commit bool) (*GradeUploadResult, error) {
+	if commit && len(result.Errors) > 0 {
+		return nil, pkg.NewAppErrorWithDetails(pkg.ErrCodeImportInvalid,
+			"grade sheet has invalid rows, no grade was changed", result)
+	}
+	return result, nil
+}
+
+func anyScore(scores map[string]*float64) bool {
+	for _, v := range scores {
+		if v != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func sameGradeScores(a, b *GradeUploadScores) bool {
+	if a.FinalOverridden != b.FinalOverridden || !sameScore(a.FinalScore, b.FinalScore) {
+		return false
+	}
+	for code, v := range b.Components {
+		if !sameScore(a.Components[code], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameScore(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) <= 1e-9
+}
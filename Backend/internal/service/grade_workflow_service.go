@@ -0,0 +1,412 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Grade status actions
+const (
+	GradeActionSubmit  = "submit"
+	GradeActionApprove = "approve"
+	GradeActionReturn  = "return"
+	GradeActionLock    = "lock"
+)
+
+// gradeTransition is the status change made by an action; review actions need grade:approve
+type gradeTransition struct {
+	from, to  string
+	review    bool
+	needsNote bool
+}
+
+var gradeTransitions = map[string]gradeTransition{
+	GradeActionSubmit:  {from: model.GradeStatusDraft, to: model.GradeStatusSubmitted},
+	GradeActionApprove: {from: model.GradeStatusSubmitted, to: model.GradeStatusPublished, review: true},
+	GradeActionReturn:  {from: model.GradeStatusSubmitted, to: model.GradeStatusDraft, review: true, needsNote: true},
+	GradeActionLock:    {from: model.GradeStatusPublished, to: model.GradeStatusLocked, review: true},
+}
+
+// GradeSheetStatus is the grade status of an offering with its transition history
+type GradeSheetStatus struct {
+	OfferingID uint                   `json:"offering_id"`
+	CourseNo   string                 `json:"course_no"`
+	TermCode   string                 `json:"term_code"`
+	SectionNo  string                 `json:"section_no"`
+	Status     string                 `json:"status"`
+	History    []model.GradeStatusLog `json:"history"`
+}
+
+// AmendmentRequest asks to change a published grade. The scores are given as in a grade
+// entry and merged over the student's current component scores.
+type AmendmentRequest struct {
+	GradeItem
+	Reason string `json:"reason"`
+}
+
+// AmendmentQueryParams represents query parameters for grade amendments
+type AmendmentQueryParams struct {
+	Status     string
+	OfferingID uint
+}
+
+// GradeWorkflowService defines the grade submission, publication and amendment workflow
+type GradeWorkflowService interface {
+	Status(offeringID uint, role string, userID uint) (*GradeSheetStatus, error)
+	Transition(offeringID uint, action, note, role string, userID uint) (*GradeSheetStatus, error)
+	RequestAmendment(offeringID uint, req AmendmentRequest, role string, userID uint) (*model.GradeAmendment, error)
+	ListAmendments(params AmendmentQueryParams, role string, userID uint) ([]repository.AmendmentRow, error)
+	ReviewAmendment(id uint, approve bool, note string, actor Actor) (*model.GradeAmendment, error)
+	GradeHistory(gradeID uint, role string, userID uint) ([]repository.GradeAuditRow, error)
+	OfferingHistory(offeringID uint, role string, userID uint) ([]repository.GradeAuditRow, error)
+	WithScope(scope repository.Scope) GradeWorkflowService
+}
+
+type gradeWorkflowService struct {
+	workflowRepo repository.GradeWorkflowRepository
+	offeringRepo repository.OfferingRepository
+	gradeRepo    repository.GradeRepository
+	schemeRepo   repository.GradingSchemeRepository
+	auditRepo    repository.GradeAuditRepository
+	studentRepo  repository.StudentRepository
+	userRepo     repository.UserRepository
+	roles        RoleService
+	db           *gorm.DB
+	cfg          config.Config
+}
+
+// NewGradeWorkflowService creates a new GradeWorkflowService
+func NewGradeWorkflowService(
+	workflowRepo repository.GradeWorkflowRepository,
+	offeringRepo repository.OfferingRepository,
+	gradeRepo repository.GradeRepository,
+	schemeRepo repository.GradingSchemeRepository,
+	auditRepo repository.GradeAuditRepository,
+	studentRepo repository.StudentRepository,
+	userRepo repository.UserRepository,
+	roles RoleService,
+	db *gorm.DB,
+	cfg config.Config,
+) GradeWorkflowService {
+	return &gradeWorkflowService{
+		workflowRepo: workflowRepo,
+		offeringRepo: offeringRepo,
+		gradeRepo:    gradeRepo,
+		schemeRepo:   schemeRepo,
+		auditRepo:    auditRepo,
+		studentRepo:  studentRepo,
+		userRepo:     userRepo,
+		roles:        roles,
+		db:           db,
+		cfg:          cfg,
+	}
+}
+
+// WithScope returns the service limited to the offerings of the scope's department's
+// courses and to the grades and amendments of its students
+func (s *gradeWorkflowService) WithScope(scope repository.Scope) GradeWorkflowService {
+	scoped := *s
+	scoped.workflowRepo = s.workflowRepo.WithScope(scope)
+	scoped.offeringRepo = s.offeringRepo.WithScope(scope)
+	scoped.gradeRepo = s.gradeRepo.WithScope(scope)
+	scoped.studentRepo = s.studentRepo.WithScope(scope)
+	return &scoped
+}
+
+// loadOffering finds an offering; without grade:write or grade:approve only the caller's own
+func (s *gradeWorkflowService) loadOffering(offeringID uint, role string, userID uint) (*repository.OfferingRow, error) {
+	staffID, err := s.scope(role, userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.offeringRepo.FindRowsByIDs([]uint{offeringID})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if len(rows) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeOfferingNF, "offering not found")
+	}
+	if staffID != 0 && rows[0].TeacherID != staffID {
+		return nil, pkg.NewAppError(pkg.ErrCodeForbidden, "can only manage grades of own courses")
+	}
+	return &rows[0], nil
+}
+
+// scope returns the staff record the caller is limited to, or 0 for every offering
+func (s *gradeWorkflowService) scope(role string, userID uint) (uint, error) {
+	return courseScope(s.roles, s.userRepo, role, userID, model.PermGradeWrite, model.PermGradeApprove)
+}
+
+func (s *gradeWorkflowService) status(offering *repository.OfferingRow) (*GradeSheetStatus, error) {
+	logs, err := s.workflowRepo.FindStatusLogs(offering.ID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return &GradeSheetStatus{
+		OfferingID: offering.ID,
+		CourseNo:   offering.CourseNo,
+		TermCode:   offering.TermCode,
+		SectionNo:  offering.SectionNo,
+		Status:     offering.GradeStatus,
+		History:    logs,
+	}, nil
+}
+
+func (s *gradeWorkflowService) Status(offeringID uint, role string, userID uint) (*GradeSheetStatus, error) {
+	offering, err := s.loadOffering(offeringID, role, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.status(offering)
+}
+
+// Transition applies a status action. Teachers may only submit their own offerings;
+// returning grades to the teacher needs a note saying why.
+func (s *gradeWorkflowService) Transition(offeringID uint, action, note, role string, userID uint) (*GradeSheetStatus, error) {
+	t, ok := gradeTransitions[action]
+	if !ok {
+		return nil, pkg.NewAppError(pkg.ErrCodeGradeStatus, "action must be submit, approve, return or lock")
+	}
+	if t.review && !s.roles.Can(role, model.PermGradeApprove) {
+		return nil, pkg.NewAppError(pkg.ErrCodeForbidden, "only reviewers can "+action+" grades")
+	}
+	if t.needsNote && note == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "note required")
+	}
+	offering, err := s.loadOffering(offeringID, role, userID)
+	if err != nil {
+		return nil, err
+	}
+	if offering.GradeStatus != t.from {
+		return nil, pkg.NewAppError(pkg.ErrCodeGradeStatus,
+			"cannot "+action+" grades that are "+offering.GradeStatus)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		changed, err := s.offeringRepo.WithTx(tx).SetGradeStatus(offering.ID, t.from, t.to)
+		if err != nil {
+			return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		if !changed {
+			return pkg.NewAppError(pkg.ErrCodeGradeStatus, "grade status changed meanwhile, reload and retry")
+		}
+		log := &model.GradeStatusLog{OfferingID: offering.ID, FromStatus: t.from, ToStatus: t.to, UserID: userID, Note: note}
+		if err := s.workflowRepo.WithTx(tx).CreateStatusLog(log); err != nil {
+			return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	offering.GradeStatus = t.to
+	return s.status(offering)
+}
+
+// RequestAmendment files a change to one student's grade in a published or locked offering.
+// The request is validated against the grading scheme now and again when it is approved.
+func (s *gradeWorkflowService) RequestAmendment(offeringID uint, req AmendmentRequest, role string, userID uint) (*model.GradeAmendment, error) {
+	if req.StudentNo == "" || req.Reason == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no/reason required")
+	}
+	offering, err := s.loadOffering(offeringID, role, userID)
+	if err != nil {
+		return nil, err
+	}
+	if offering.GradeStatus != model.GradeStatusPublished && offering.GradeStatus != model.GradeStatusLocked {
+		return nil, pkg.NewAppError(pkg.ErrCodeGradeStatus, "grades are "+offering.GradeStatus+" and are edited directly, not amended")
+	}
+	student, err := s.studentRepo.FindByStudentNo(req.StudentNo)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeGradeStudentNF, "student not found")
+	}
+	enrolled, err := s.offeringRepo.FindEnrolled(student.ID, offering.CourseID, offering.TermCode)
+	if err != nil || enrolled.ID != offering.ID {
+		return nil, pkg.NewAppError(pkg.ErrCodeGradeNotEnrolled, "student "+student.StudentNo+" not enrolled in this offering")
+	}
+	pending, err := s.workflowRepo.CountPendingAmendments(offering.ID, student.ID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if pending > 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeAmendmentExists, "an amendment for this student is already pending")
+	}
+
+	// Start from the current component scores so that the request only changes what it names
+	components := make(map[string]*float64)
+	if grade, err := s.gradeRepo.FindByStudentAndOffering(student.ID, offering.ID); err == nil {
+		scores, err := s.schemeRepo.FindComponentScores([]uint{grade.ID})
+		if err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+		for _, sc := range scores {
+			components[sc.Code] = sc.Score
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if req.UsualScore != nil {
+		components[ComponentUsual] = req.UsualScore
+	}
+	if req.ExamScore != nil {
+		components[ComponentExam] = req.ExamScore
+	}
+	for code, v := range req.Components {
+		components[code] = v
+	}
+
+	amendment := &model.GradeAmendment{
+		OfferingID:    offering.ID,
+		StudentID:     student.ID,
+		Components:    components,
+		FinalScore:    req.FinalScore,
+		OverrideFinal: req.OverrideFinal,
+		Reason:        req.Reason,
+		Status:        model.AmendmentPending,
+		RequestedBy:   userID,
+	}
+	if _, err := s.buildAmendedGrade(amendment, offering.CourseID, student.StudentNo); err != nil {
+		return nil, err
+	}
+	if err := s.workflowRepo.CreateAmendment(amendment); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+	}
+	return amendment, nil
+}
+
+// buildAmendedGrade turns an amendment into the grade it would write under the current scheme
+func (s *gradeWorkflowService) buildAmendedGrade(a *model.GradeAmendment, courseID uint, studentNo string) (*pendingGrade, error) {
+	scheme, err := loadGradingScheme(s.schemeRepo, s.cfg, courseID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	// Codes dropped from the scheme since the scores were entered are left out
+	known := make(map[string]*float64, len(a.Components))
+	for _, c := range scheme.Components {
+		if v, ok := a.Components[c.Code]; ok {
+			known[c.Code] = v
+		}
+	}
+	item := GradeItem{
+		StudentNo:     studentNo,
+		Components:    known,
+		FinalScore:    a.FinalScore,
+		OverrideFinal: a.OverrideFinal,
+	}
+	grade, scores, err := buildGrade(&scheme.GradingScheme, item)
+	if err != nil {
+		return nil, err
+	}
+	grade.StudentID = a.StudentID
+	grade.OfferingID = a.OfferingID
+	return &pendingGrade{grade: grade, scores: scores}, nil
+}
+
+// ListAmendments lists amendment requests; teachers only see those of their own offerings
+func (s *gradeWorkflowService) ListAmendments(params AmendmentQueryParams, role string, userID uint) ([]repository.AmendmentRow, error) {
+	staffID, err := s.scope(role, userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.workflowRepo.FindAmendments(repository.AmendmentQueryParams{
+		Status:     params.Status,
+		OfferingID: params.OfferingID,
+		TeacherID:  staffID,
+	})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return rows, nil
+}
+
+// ReviewAmendment approves or rejects a pending amendment. An approved amendment writes
+// the grade in the same transaction, bypassing the edit lock of the offering.
+func (s *gradeWorkflowService) ReviewAmendment(id uint, approve bool, note string, actor Actor) (*model.GradeAmendment, error) {
+	amendment, err := s.workflowRepo.FindAmendmentByID(id)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "amendment not found", err)
+	}
+	if amendment.Status != model.AmendmentPending {
+		return nil, pkg.NewAppError(pkg.ErrCodeAmendmentClosed, "amendment already "+amendment.Status)
+	}
+	if !approve && note == "" {
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "note required")
+	}
+
+	status := model.AmendmentRejected
+	var pg *pendingGrade
+	if approve {
+		status = model.AmendmentApproved
+		offering, err := s.offeringRepo.FindByID(amendment.OfferingID)
+		if err != nil {
+			return nil, pkg.NewAppError(pkg.ErrCodeOfferingNF, "offering not found")
+		}
+		student, err := s.studentRepo.FindByID(amendment.StudentID)
+		if err != nil {
+			return nil, pkg.NewAppError(pkg.ErrCodeGradeStudentNF, "student not found")
+		}
+		if pg, err = s.buildAmendedGrade(amendment, offering.CourseID, student.StudentNo); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		closed, err := s.workflowRepo.WithTx(tx).ReviewAmendment(amendment.ID, status, actor.UserID, note, now)
+		if err != nil {
+			return pkg.WrapError(pkg.ErrCodeUpdateFailed, "update failed", err)
+		}
+		if !closed {
+			return pkg.NewAppError(pkg.ErrCodeAmendmentClosed, "amendment already reviewed")
+		}
+		if pg == nil {
+			return nil
+		}
+		w := gradeWriter{s.gradeRepo.WithTx(tx), s.schemeRepo.WithTx(tx), s.auditRepo.WithTx(tx)}
+		return w.save([]pendingGrade{*pg}, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	amendment.Status = status
+	amendment.ReviewedBy = &actor.UserID
+	amendment.ReviewNote = note
+	amendment.ReviewedAt = &now
+	return amendment, nil
+}
+
+// GradeHistory lists the audit trail of one grade, oldest first. It outlives the grade,
+// so the history of a deleted grade stays available.
+func (s *gradeWorkflowService) GradeHistory(gradeID uint, role string, userID uint) ([]repository.GradeAuditRow, error) {
+	rows, err := s.auditRepo.FindAll(repository.GradeAuditQueryParams{GradeID: gradeID})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if len(rows) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeNotFound, "grade history not found")
+	}
+	if _, err := s.loadOffering(rows[0].OfferingID, role, userID); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// OfferingHistory lists the audit trail of all grades of a course offering, oldest first
+func (s *gradeWorkflowService) OfferingHistory(offeringID uint, role string, userID uint) ([]repository.GradeAuditRow, error) {
+	if _, err := s.loadOffering(offeringID, role, userID); err != nil {
+		return nil, err
+	}
+	rows, err := s.auditRepo.FindAll(repository.GradeAuditQueryParams{OfferingID: offeringID})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Rounding modes for computed final scores
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundFloor    = "floor"
+	RoundCeil     = "ceil"
+)
+
+// Component codes that map onto the legacy usual_score / exam_score fields
+const (
+	ComponentUsual = "usual"
+	ComponentExam  = "exam"
+)
+
+// CourseGradingScheme is the scheme in effect for a course; IsDefault is set when the
+// course has none of its own and the configured usual/exam weights apply
+type CourseGradingScheme struct {
+	model.GradingScheme
+	IsDefault bool `json:"is_default"`
+}
+
+// defaultGradingScheme builds the configured usual/exam scheme for a course
+func defaultGradingScheme(cfg config.Config, courseID uint) *model.GradingScheme {
+	scheme := &model.GradingScheme{
+		CourseID: courseID,
+		Rounding: cfg.GradeRounding,
+		Decimals: cfg.GradeDecimals,
+	}
+	if cfg.GradeUsualWeight > 0 {
+		scheme.Components = append(scheme.Components, model.GradingComponent{
+			Code: ComponentUsual, Name: "平时", Weight: cfg.GradeUsualWeight,
+		})
+	}
+	if cfg.GradeExamWeight > 0 {
+		scheme.Components = append(scheme.Components, model.GradingComponent{
+			Code: ComponentExam, Name: "考试", Weight: cfg.GradeExamWeight, SortOrder: 1,
+		})
+	}
+	return scheme
+}
+
+// loadGradingScheme returns the course's own scheme, or the configured default when it has none
+func loadGradingScheme(repo repository.GradingSchemeRepository, cfg config.Config, courseID uint) (*CourseGradingScheme, error) {
+	scheme, err := repo.FindByCourseID(courseID)
+	if err == nil {
+		return &CourseGradingScheme{GradingScheme: *scheme}, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return &CourseGradingScheme{GradingScheme: *defaultGradingScheme(cfg, courseID), IsDefault: true}, nil
+}
+
+// validateGradingScheme checks rounding, component codes and that weights add up to 100
+func validateGradingScheme(scheme *model.GradingScheme) error {
+	switch scheme.Rounding {
+	case RoundHalfUp, RoundHalfEven, RoundFloor, RoundCeil:
+	default:
+		return pkg.NewAppError(pkg.ErrCodeInvalidScheme, "rounding must be one of half_up/half_even/floor/ceil")
+	}
+	if scheme.Decimals < 0 || scheme.Decimals > 2 {
+		return pkg.NewAppError(pkg.ErrCodeInvalidScheme, "decimals must be between 0 and 2")
+	}
+	if len(scheme.Components) == 0 {
+		return pkg.NewAppError(pkg.ErrCodeInvalidScheme, "at least one component required")
+	}
+
+	seen := make(map[string]struct{})
+	total := 0.0
+	for _, c := range scheme.Components {
+		if c.Code == "" || len(c.Code) > 32 {
+			return pkg.NewAppError(pkg.ErrCodeInvalidScheme, "component code must be 1-32 characters")
+		}
+		if _, ok := seen[c.Code]; ok {
+			return pkg.NewAppError(pkg.ErrCodeInvalidScheme, "duplicate component: "+c.Code)
+		}
+		seen[c.Code] = struct{}{}
+		if c.Weight <= 0 || c.Weight > 100 {
+			return pkg.NewAppError(pkg.ErrCodeInvalidScheme, "component weight must be in (0, 100]: "+c.Code)
+		}
+		total += c.Weight
+	}
+	if math.Abs(total-100) > 1e-6 {
+		return pkg.NewAppError(pkg.ErrCodeInvalidScheme, fmt.Sprintf("component weights must sum to 100, got %g", total))
+	}
+	return nil
+}
+
+// computeFinal weighs the component scores into a rounded final score.
+// It returns nil until every component of the scheme has a score.
+func computeFinal(scheme *model.GradingScheme, scores map[string]*float64) *float64 {
+	sum := 0.0
+	for _, c := range scheme.Components {
+		v := scores[c.Code]
+		if v == nil {
+			return nil
+		}
+		sum += *v * c.Weight / 100
+	}
+	final := roundScore(sum, scheme.Rounding, scheme.Decimals)
+	return &final
+}
+
+// roundScore rounds v to decimals places. Float noise from the weighting is
+// trimmed first so that e.g. 88.4999999 still rounds half up to 89.
+func roundScore(v float64, mode string, decimals int) float64 {
+	p := math.Pow10(decimals)
+	x := math.Round(v*p*1e6) / 1e6
+	switch mode {
+	case RoundHalfEven:
+		x = math.RoundToEven(x)
+	case RoundFloor:
+		x = math.Floor(x)
+	case RoundCeil:
+		x = math.Ceil(x)
+	default:
+		x = math.Round(x)
+	}
+	return x / p
+}
+
+// validScore reports whether a score is absent or within 0-100
+func validScore(v *float64) bool {
+	return v == nil || (*v >= 0 && *v <= 100)
+}
+
+// buildGrade turns an input item into a grade and its component scores under scheme.
+// usual_score/exam_score are accepted as shorthand for the usual/exam components.
+// A final score may only be supplied by hand when OverrideFinal is set, unless it
+// matches the computed value.
+func buildGrade(scheme *model.GradingScheme, item GradeItem) (*model.Grade, []model.GradeComponentScore, error) {
+	scores := make(map[string]*float64, len(item.Components)+2)
+	for code, v := range item.Components {
+		scores[code] = v
+	}
+	if _, ok := scores[ComponentUsual]; !ok && item.UsualScore != nil {
+		scores[ComponentUsual] = item.UsualScore
+	}
+	if _, ok := scores[ComponentExam]; !ok && item.ExamScore != nil {
+		scores[ComponentExam] = item.ExamScore
+	}
+
+	known := make(map[string]struct{}, len(scheme.Components))
+	for _, c := range scheme.Components {
+		known[c.Code] = struct{}{}
+	}
+	codes := make([]string, 0, len(scores))
+	for code := range scores {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if _, ok := known[code]; !ok {
+			return nil, nil, pkg.NewAppError(pkg.ErrCodeInvalidScore, "component not in grading scheme: "+code)
+		}
+		if !validScore(scores[code]) {
+			return nil, nil, pkg.NewAppError(pkg.ErrCodeInvalidScore, "score out of range 0-100: "+code)
+		}
+	}
+	if !validScore(item.FinalScore) {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeInvalidScore, "final_score out of range 0-100")
+	}
+
+	computed := computeFinal(scheme, scores)
+	grade := &model.Grade{
+		UsualScore: scores[ComponentUsual],
+		ExamScore:  scores[ComponentExam],
+		FinalScore: computed,
+	}
+	if item.OverrideFinal {
+		if item.FinalScore == nil {
+			return nil, nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "final_score required when override_final is set")
+		}
+		grade.FinalScore = item.FinalScore
+		grade.FinalOverridden = true
+	} else if item.FinalScore != nil && (computed == nil || math.Abs(*computed-*item.FinalScore) > 1e-9) {
+		return nil, nil, pkg.NewAppErrorWithDetails(pkg.ErrCodeManualFinal,
+			"final_score is computed from the grading scheme; set override_final to enter it by hand",
+			map[string]any{"student_no": item.StudentNo, "computed_final": computed})
+	}
+
+	componentScores := make([]model.GradeComponentScore, 0, len(scheme.Components))
+	for _, c := range scheme.Components {
+		if v := scores[c.Code]; v != nil {
+			componentScores = append(componentScores, model.GradeComponentScore{Code: c.Code, Score: v})
+		}
+	}
+	return grade, componentScores, nil
+}
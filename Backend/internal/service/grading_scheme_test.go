@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+)
+
+func score(v float64) *float64 { return &v }
+
+func scheme(rounding string, decimals int, weights ...float64) *model.GradingScheme {
+	s := &model.GradingScheme{Rounding: rounding, Decimals: decimals}
+	codes := []string{ComponentUsual, ComponentExam, "lab"}
+	for i, w := range weights {
+		s.Components = append(s.Components, model.GradingComponent{Code: codes[i], Weight: w, SortOrder: i})
+	}
+	return s
+}
+
+func appErrCode(err error) int {
+	var appErr *pkg.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return 0
+}
+
+func TestRoundScore(t *testing.T) {
+	tests := []struct {
+		v        float64
+		mode     string
+		decimals int
+		want     float64
+	}{
+		{88.5, RoundHalfUp, 0, 89},
+		{88.49, RoundHalfUp, 0, 88},
+		{88.5, RoundHalfEven, 0, 88},
+		{89.5, RoundHalfEven, 0, 90},
+		{88.51, RoundHalfEven, 0, 89},
+		{88.5, RoundFloor, 0, 88},
+		{88.99, RoundFloor, 0, 88},
+		{88.5, RoundCeil, 0, 89},
+		{88.01, RoundCeil, 0, 89},
+		{88, RoundCeil, 0, 88},
+		// Float noise from the weighting is trimmed before rounding
+		{88.4999999, RoundHalfUp, 0, 89},
+		{88.0000001, RoundCeil, 0, 88},
+		{88.9999999, RoundFloor, 0, 89},
+		{88.25, RoundHalfUp, 1, 88.3},
+		{88.25, RoundHalfEven, 1, 88.2},
+		{88.35, RoundHalfEven, 1, 88.4},
+		{88.25, RoundFloor, 1, 88.2},
+		{88.21, RoundCeil, 1, 88.3},
+		{88.125, RoundHalfUp, 2, 88.13},
+		{88.125, RoundHalfEven, 2, 88.12},
+		{88.135, RoundHalfEven, 2, 88.14},
+		{0, RoundHalfUp, 0, 0},
+		{100, RoundCeil, 2, 100},
+		// Unknown modes round half up
+		{88.5, "", 0, 89},
+	}
+	for _, tt := range tests {
+		if got := roundScore(tt.v, tt.mode, tt.decimals); got != tt.want {
+			t.Errorf("roundScore(%v, %q, %d) = %v, want %v", tt.v, tt.mode, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestComputeFinal(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme *model.GradingScheme
+		scores map[string]*float64
+		want   *float64
+	}{
+		{"weighted", scheme(RoundHalfUp, 0, 30, 70),
+			map[string]*float64{ComponentUsual: score(85), ComponentExam: score(92)}, score(90)},
+		{"half up at .5", scheme(RoundHalfUp, 0, 50, 50),
+			map[string]*float64{ComponentUsual: score(90), ComponentExam: score(87)}, score(89)},
+		{"half even at .5 rounds to even", scheme(RoundHalfEven, 0, 50, 50),
+			map[string]*float64{ComponentUsual: score(90), ComponentExam: score(87)}, score(88)},
+		{"half even at .5 rounds up to even", scheme(RoundHalfEven, 0, 50, 50),
+			map[string]*float64{ComponentUsual: score(90), ComponentExam: score(85)}, score(88)},
+		{"floor at .5", scheme(RoundFloor, 0, 50, 50),
+			map[string]*float64{ComponentUsual: score(90), ComponentExam: score(87)}, score(88)},
+		{"ceil at .5", scheme(RoundCeil, 0, 50, 50),
+			map[string]*float64{ComponentUsual: score(90), ComponentExam: score(87)}, score(89)},
+		{"one decimal", scheme(RoundHalfUp, 1, 30, 70),
+			map[string]*float64{ComponentUsual: score(85), ComponentExam: score(89)}, score(87.8)},
+		{"three components", scheme(RoundHalfUp, 0, 20, 50, 30),
+			map[string]*float64{ComponentUsual: score(80), ComponentExam: score(75), "lab": score(95)}, score(82)},
+		{"single component", scheme(RoundHalfUp, 0, 100),
+			map[string]*float64{ComponentUsual: score(59.5)}, score(60)},
+		{"missing component", scheme(RoundHalfUp, 0, 30, 70),
+			map[string]*float64{ComponentUsual: score(85)}, nil},
+		{"nil component", scheme(RoundHalfUp, 0, 30, 70),
+			map[string]*float64{ComponentUsual: score(85), ComponentExam: nil}, nil},
+	}
+	for _, tt := range tests {
+		got := computeFinal(tt.scheme, tt.scores)
+		switch {
+		case got == nil && tt.want == nil:
+		case got == nil || tt.want == nil:
+			t.Errorf("%s: computeFinal = %v, want %v", tt.name, got, tt.want)
+		case *got != *tt.want:
+			t.Errorf("%s: computeFinal = %v, want %v", tt.name, *got, *tt.want)
+		}
+	}
+}
+
+func TestValidateGradingScheme(t *testing.T) {
+	component := func(code string, weight float64) model.GradingComponent {
+		return model.GradingComponent{Code: code, Weight: weight}
+	}
+	tests := []struct {
+		name       string
+		rounding   string
+		decimals   int
+		components []model.GradingComponent
+		ok         bool
+	}{
+		{"two components", RoundHalfUp, 0, []model.GradingComponent{component("usual", 30), component("exam", 70)}, true},
+		{"fractional weights summing to 100", RoundHalfEven, 2,
+			[]model.GradingComponent{component("a", 33.3), component("b", 33.3), component("c", 33.4)}, true},
+		{"single component", RoundFloor, 1, []model.GradingComponent{component("exam", 100)}, true},
+		{"ceil", RoundCeil, 0, []model.GradingComponent{component("exam", 100)}, true},
+		{"weights under 100", RoundHalfUp, 0, []model.GradingComponent{component("usual", 30), component("exam", 60)}, false},
+		{"weights over 100", RoundHalfUp, 0, []model.GradingComponent{component("usual", 40), component("exam", 70)}, false},
+		{"zero weight", RoundHalfUp, 0, []model.GradingComponent{component("usual", 0), component("exam", 100)}, false},
+		{"negative weight", RoundHalfUp, 0, []model.GradingComponent{component("usual", -10), component("exam", 110)}, false},
+		{"duplicate code", RoundHalfUp, 0, []model.GradingComponent{component("exam", 50), component("exam", 50)}, false},
+		{"empty code", RoundHalfUp, 0, []model.GradingComponent{component("", 100)}, false},
+		{"long code", RoundHalfUp, 0, []model.GradingComponent{component("abcdefghijklmnopqrstuvwxyz0123456", 100)}, false},
+		{"no components", RoundHalfUp, 0, nil, false},
+		{"unknown rounding", "bankers", 0, []model.GradingComponent{component("exam", 100)}, false},
+		{"empty rounding", "", 0, []model.GradingComponent{component("exam", 100)}, false},
+		{"too many decimals", RoundHalfUp, 3, []model.GradingComponent{component("exam", 100)}, false},
+		{"negative decimals", RoundHalfUp, -1, []model.GradingComponent{component("exam", 100)}, false},
+	}
+	for _, tt := range tests {
+		err := validateGradingScheme(&model.GradingScheme{Rounding: tt.rounding, Decimals: tt.decimals, Components: tt.components})
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.ok && appErrCode(err) != pkg.ErrCodeInvalidScheme {
+			t.Errorf("%s: error = %v, want code %d", tt.name, err, pkg.ErrCodeInvalidScheme)
+		}
+	}
+}
+
+func TestBuildGrade(t *testing.T) {
+	s := scheme(RoundHalfUp, 0, 30, 70)
+	tests := []struct {
+		name      string
+		item      GradeItem
+		wantFinal *float64
+		override  bool
+		code      int
+	}{
+		{"shorthand scores", GradeItem{UsualScore: score(85), ExamScore: score(92)}, score(90), false, 0},
+		{"components", GradeItem{Components: map[string]*float64{ComponentUsual: score(85), ComponentExam: score(92)}}, score(90), false, 0},
+		{"partial scores", GradeItem{UsualScore: score(85)}, nil, false, 0},
+		{"matching final", GradeItem{UsualScore: score(85), ExamScore: score(92), FinalScore: score(90)}, score(90), false, 0},
+		{"override", GradeItem{UsualScore: score(85), ExamScore: score(92), FinalScore: score(60), OverrideFinal: true}, score(60), true, 0},
+		{"override without scores", GradeItem{FinalScore: score(75), OverrideFinal: true}, score(75), true, 0},
+		{"manual final", GradeItem{UsualScore: score(85), ExamScore: score(92), FinalScore: score(91)}, nil, false, pkg.ErrCodeManualFinal},
+		{"override without final", GradeItem{UsualScore: score(85), OverrideFinal: true}, nil, false, pkg.ErrCodeMissingRequired},
+		{"unknown component", GradeItem{Components: map[string]*float64{"lab": score(90)}}, nil, false, pkg.ErrCodeInvalidScore},
+		{"score above 100", GradeItem{UsualScore: score(101)}, nil, false, pkg.ErrCodeInvalidScore},
+		{"score below 0", GradeItem{ExamScore: score(-1)}, nil, false, pkg.ErrCodeInvalidScore},
+		{"final out of range", GradeItem{FinalScore: score(120), OverrideFinal: true}, nil, false, pkg.ErrCodeInvalidScore},
+	}
+	for _, tt := range tests {
+		grade, _, err := buildGrade(s, tt.item)
+		if tt.code != 0 {
+			if appErrCode(err) != tt.code {
+				t.Errorf("%s: error = %v, want code %d", tt.name, err, tt.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if grade.FinalOverridden != tt.override {
+			t.Errorf("%s: FinalOverridden = %v, want %v", tt.name, grade.FinalOverridden, tt.override)
+		}
+		switch {
+		case grade.FinalScore == nil && tt.wantFinal == nil:
+		case grade.FinalScore == nil || tt.wantFinal == nil || *grade.FinalScore != *tt.wantFinal:
+			t.Errorf("%s: FinalScore = %v, want %v", tt.name, grade.FinalScore, tt.wantFinal)
+		}
+	}
+}
@@ -0,0 +1,604 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Importable entities
+const (
+	ImportDepartments = "departments"
+	ImportStudents    = "students"
+	ImportStaff       = "staff"
+	ImportCourses     = "courses"
+)
+
+// importColumns lists the header names an entity accepts
+type importColumns struct {
+	required []string
+	optional []string
+}
+
+var importColumnSets = map[string]importColumns{
+	ImportDepartments: {
+		required: []string{"dept_no", "name"},
+		optional: []string{"intro"},
+	},
+	ImportStudents: {
+		required: []string{"student_no", "name", "dept_no"},
+		optional: []string{"gender", "birth_date", "entry_score", "status"},
+	},
+	ImportStaff: {
+		required: []string{"staff_no", "name", "dept_no"},
+		optional: []string{"gender", "birth_month", "title", "major", "teaching_direction"},
+	},
+	// A course row with term_code also opens a section taught by staff_no. Repeating a
+	// course_no (or naming an existing course) with another term/section only adds the section.
+	// dept_no is the department offering the course; without it only administrators of
+	// every department see the course.
+	ImportCourses: {
+		required: []string{"course_no"},
+		optional: []string{"name", "hours", "credits", "dept_no", "term_code", "section_no", "staff_no",
+			"class_time", "class_location", "exam_time", "capacity"},
+	},
+}
+
+var importStudentStatuses = map[string]bool{
+	"in_school":    true,
+	"graduated":    true,
+	"transfer_out": true,
+	"transfer_in":  true,
+}
+
+// ImportRowError is one problem found in the file. Row is the spreadsheet row number
+// (the header included), so it matches what the user sees in their editor.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports a dry run or a committed import. Accounts are those provisioned
+// for the imported students or staff, with their one-time passwords.
+type ImportResult struct {
+	Entity    string               `json:"entity"`
+	Committed bool                 `json:"committed"`
+	Total     int                  `json:"total"`
+	Valid     int                  `json:"valid"`
+	Errors    []ImportRowError     `json:"errors"`
+	Accounts  []ProvisionedAccount `json:"accounts,omitempty"`
+}
+
+// ImportService defines the interface for bulk CSV/XLSX imports
+type ImportService interface {
+	// Import validates every row of the file; with commit set and no errors it writes
+	// all rows in one transaction, otherwise nothing is written. Students and staff may
+	// be imported with their accounts, see AccountOption.
+	Import(entity, filename string, r io.Reader, commit bool, account AccountOption, actor Actor) (*ImportResult, error)
+}
+
+type importService struct {
+	deptRepo     repository.DepartmentRepository
+	studentRepo  repository.StudentRepository
+	staffRepo    repository.StaffRepository
+	courseRepo   repository.CourseRepository
+	offeringRepo repository.OfferingRepository
+	termRepo     repository.TermRepository
+	userRepo     repository.UserRepository
+	provisioner  accountProvisioner
+	db           *gorm.DB
+	cfg          config.Config
+}
+
+// NewImportService creates a new ImportService
+func NewImportService(
+	deptRepo repository.DepartmentRepository,
+	studentRepo repository.StudentRepository,
+	staffRepo repository.StaffRepository,
+	courseRepo repository.CourseRepository,
+	offeringRepo repository.OfferingRepository,
+	termRepo repository.TermRepository,
+	userRepo repository.UserRepository,
+	roles RoleService,
+	db *gorm.DB,
+	cfg config.Config,
+) ImportService {
+	return &importService{
+		deptRepo:     deptRepo,
+		studentRepo:  studentRepo,
+		staffRepo:    staffRepo,
+		courseRepo:   courseRepo,
+		offeringRepo: offeringRepo,
+		termRepo:     termRepo,
+		userRepo:     userRepo,
+		provisioner:  accountProvisioner{roles: roles, cfg: cfg},
+		db:           db,
+		cfg:          cfg,
+	}
+}
+
+// importOp writes one validated row inside the import transaction
+type importOp func(tx *gorm.DB) error
+
+func (s *importService) Import(entity, filename string, r io.Reader, commit bool, account AccountOption, actor Actor) (*ImportResult, error) {
+	columns, ok := importColumnSets[entity]
+	if !ok {
+		return nil, pkg.NewAppError(pkg.ErrCodeImportFormat,
+			"unknown import type, expected one of: departments, students, staff, courses")
+	}
+	if account.Provision {
+		role := model.RoleStudent
+		switch entity {
+		case ImportStudents:
+		case ImportStaff:
+			role = staffRole(account.Role)
+		default:
+			return nil, pkg.NewAppError(pkg.ErrCodeImportFormat, "accounts are provisioned for students and staff only")
+		}
+		if err := s.provisioner.check(actor, role); err != nil {
+			return nil, err
+		}
+	}
+	table, err := readImportTable(filename, r, s.cfg.ImportMaxRows)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeImportFormat, err.Error(), err)
+	}
+
+	result := &ImportResult{Entity: entity, Total: len(table.rows), Errors: []ImportRowError{}}
+	result.Errors = append(result.Errors, checkImportHeader(table, columns)...)
+	if len(result.Errors) > 0 {
+		return s.finish(result, commit)
+	}
+
+	batch := newImportBatch(s, account)
+	var ops []importOp
+	for _, row := range table.rows {
+		c := &importCheck{row: row}
+		var op importOp
+		switch entity {
+		case ImportDepartments:
+			op = batch.department(c)
+		case ImportStudents:
+			op = batch.student(c)
+		case ImportStaff:
+			op = batch.staff(c)
+		case ImportCourses:
+			op = batch.course(c)
+		}
+		if len(c.errs) > 0 {
+			result.Errors = append(result.Errors, c.errs...)
+			continue
+		}
+		result.Valid++
+		if op != nil {
+			line := row.line
+			ops = append(ops, func(tx *gorm.DB) error {
+				if err := op(tx); err != nil {
+					return fmt.Errorf("row %d: %w", line, err)
+				}
+				return nil
+			})
+		}
+	}
+	if !commit || len(result.Errors) > 0 {
+		return s.finish(result, commit)
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, op := range ops {
+			if err := op(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "import failed, nothing was written: "+err.Error(), err)
+	}
+	result.Committed = true
+	result.Accounts = batch.accounts
+	return result, nil
+}
+
+// finish reports an import that wrote nothing; a failed commit is an error carrying the result
+func (s *importService) finish(result *ImportResult, commit bool) (*ImportResult, error) {
+	if commit && len(result.Errors) > 0 {
+		return nil, pkg.NewAppErrorWithDetails(pkg.ErrCodeImportInvalid,
+			"import has invalid rows, nothing was written", result)
+	}
+	return result, nil
+}
+
+// checkImportHeader reports missing required columns and columns the entity does not know
+func checkImportHeader(table *importTable, columns importColumns) []ImportRowError {
+	known := make(map[string]bool)
+	present := make(map[string]bool)
+	for _, col := range table.header {
+		present[col] = true
+	}
+
+	var errs []ImportRowError
+	for _, col := range columns.required {
+		known[col] = true
+		if !present[col] {
+			errs = append(errs, ImportRowError{Row: table.headerLine, Column: col, Message: "missing required column"})
+		}
+	}
+	for _, col := range columns.optional {
+		known[col] = true
+	}
+	for _, col := range table.header {
+		if col != "" && !known[col] {
+			errs = append(errs, ImportRowError{Row: table.headerLine, Column: col, Message: "unknown column"})
+		}
+	}
+	return errs
+}
+
+// importRow is one data row keyed by header name
+type importRow struct {
+	line   int
+	values map[string]string
+}
+
+// importCheck reads the fields of one row, collecting every problem instead of stopping at the first
+type importCheck struct {
+	row  importRow
+	errs []ImportRowError
+}
+
+func (c *importCheck) fail(column, message string) {
+	c.errs = append(c.errs, ImportRowError{Row: c.row.line, Column: column, Message: message})
+}
+
+func (c *importCheck) str(column string) string {
+	return c.row.values[column]
+}
+
+func (c *importCheck) required(column string) string {
+	v := c.row.values[column]
+	if v == "" {
+		c.fail(column, "required")
+	}
+	return v
+}
+
+// count parses a non-negative integer; empty means 0
+func (c *importCheck) count(column string) int {
+	v := c.row.values[column]
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		c.fail(column, "must be a non-negative integer")
+		return 0
+	}
+	return n
+}
+
+func (c *importCheck) number(column string) *float64 {
+	v := c.row.values[column]
+	if v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		c.fail(column, "must be a non-negative number")
+		return nil
+	}
+	return &f
+}
+
+// score parses a score between 0 and 100; empty means not entered
+func (c *importCheck) score(column string) *float64 {
+	f := c.number(column)
+	if f != nil && *f > 100 {
+		c.fail(column, "must be between 0 and 100")
+		return nil
+	}
+	return f
+}
+
+func (c *importCheck) date(column string) *time.Time {
+	v := c.row.values[column]
+	if v == "" {
+		return nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		c.fail(column, "must be a date like 2006-01-02")
+		return nil
+	}
+	return &t
+}
+
+// importBatch holds the lookups and in-file duplicate tracking of one import, and the
+// accounts it provisions
+type importBatch struct {
+	s        *importService
+	account  AccountOption
+	accounts []ProvisionedAccount
+	seen     map[string]int // unique key -> row that first used it
+	depts    map[string]*model.Department
+	teachers map[string]*model.Staff
+	terms    map[string]*model.Term
+	courses  map[string]*model.Course
+}
+
+func newImportBatch(s *importService, account AccountOption) *importBatch {
+	return &importBatch{
+		s:        s,
+		account:  account,
+		seen:     make(map[string]int),
+		depts:    make(map[string]*model.Department),
+		teachers: make(map[string]*model.Staff),
+		terms:    make(map[string]*model.Term),
+		courses:  make(map[string]*model.Course),
+	}
+}
+
+// claim records a unique key for the row, failing when an earlier row already used it
+func (b *importBatch) claim(c *importCheck, column, key string) bool {
+	if first, ok := b.seen[key]; ok {
+		c.fail(column, fmt.Sprintf("duplicate of row %d", first))
+		return false
+	}
+	b.seen[key] = c.row.line
+	return true
+}
+
+func (b *importBatch) dept(c *importCheck) *model.Department {
+	no := c.required("dept_no")
+	if no == "" {
+		return nil
+	}
+	dept, ok := b.depts[no]
+	if !ok {
+		dept, _ = b.s.deptRepo.FindByDeptNo(no)
+		b.depts[no] = dept
+	}
+	if dept == nil {
+		c.fail("dept_no", "department not found: "+no)
+	}
+	return dept
+}
+
+// username checks, when accounts are provisioned, that no account is named number yet
+func (b *importBatch) username(c *importCheck, column, number string) {
+	if !b.account.Provision {
+		return
+	}
+	taken, err := usernameTaken(b.s.userRepo, number)
+	if err != nil {
+		c.fail(column, "cannot check username: "+err.Error())
+	} else if taken {
+		c.fail(column, "username "+number+" is taken by another account")
+	}
+}
+
+func (b *importBatch) department(c *importCheck) importOp {
+	dept := &model.Department{
+		DeptNo: c.required("dept_no"),
+		Name:   c.required("name"),
+		Intro:  c.str("intro"),
+	}
+	if dept.DeptNo != "" && b.claim(c, "dept_no", "dept:"+dept.DeptNo) {
+		if _, err := b.s.deptRepo.FindByDeptNo(dept.DeptNo); err == nil {
+			c.fail("dept_no", "department already exists")
+		}
+	}
+	return func(tx *gorm.DB) error {
+		return b.s.deptRepo.WithTx(tx).Create(dept)
+	}
+}
+
+func (b *importBatch) student(c *importCheck) importOp {
+	student := &model.Student{
+		StudentNo:  c.required("student_no"),
+		Name:       c.required("name"),
+		Gender:     c.str("gender"),
+		BirthDate:  c.date("birth_date"),
+		EntryScore: c.number("entry_score"),
+		Status:     c.str("status"),
+	}
+	if student.StudentNo != "" && b.claim(c, "student_no", "student:"+student.StudentNo) {
+		if _, err := b.s.studentRepo.FindByStudentNo(student.StudentNo); err == nil {
+			c.fail("student_no", "student already exists")
+		}
+		b.username(c, "student_no", student.StudentNo)
+	}
+	if student.Status == "" {
+		student.Status = "in_school"
+	} else if !importStudentStatuses[student.Status] {
+		c.fail("status", "must be one of in_school, graduated, transfer_out, transfer_in")
+	}
+	if dept := b.dept(c); dept != nil {
+		student.DeptID = dept.ID
+	}
+	return func(tx *gorm.DB) error {
+		if err := b.s.studentRepo.WithTx(tx).Create(student); err != nil {
+			return err
+		}
+		if !b.account.Provision {
+			return nil
+		}
+		account, err := b.s.provisioner.student(b.s.userRepo.WithTx(tx), student)
+		if err != nil {
+			return err
+		}
+		b.accounts = append(b.accounts, *account)
+		return nil
+	}
+}
+
+func (b *importBatch) staff(c *importCheck) importOp {
+	staff := &model.Staff{
+		StaffNo:           c.required("staff_no"),
+		Name:              c.required("name"),
+		Gender:            c.str("gender"),
+		BirthMonth:        c.str("birth_month"),
+		Title:             c.str("title"),
+		Major:             c.str("major"),
+		TeachingDirection: c.str("teaching_direction"),
+	}
+	if staff.StaffNo != "" && b.claim(c, "staff_no", "staff:"+staff.StaffNo) {
+		if _, err := b.s.staffRepo.FindByStaffNo(staff.StaffNo); err == nil {
+			c.fail("staff_no", "staff already exists")
+		}
+		b.username(c, "staff_no", staff.StaffNo)
+	}
+	if staff.BirthMonth != "" {
+		if _, err := time.Parse("2006-01", staff.BirthMonth); err != nil {
+			c.fail("birth_month", "must be a month like 2006-01")
+		}
+	}
+	if dept := b.dept(c); dept != nil {
+		staff.DeptID = dept.ID
+	}
+	return func(tx *gorm.DB) error {
+		if err := b.s.staffRepo.WithTx(tx).Create(staff); err != nil {
+			return err
+		}
+		if !b.account.Provision {
+			return nil
+		}
+		account, err := b.s.provisioner.staff(b.s.userRepo.WithTx(tx), staff, staffRole(b.account.Role))
+		if err != nil {
+			return err
+		}
+		b.accounts = append(b.accounts, *account)
+		return nil
+	}
+}
+
+func (b *importBatch) course(c *importCheck) importOp {
+	courseNo := c.required("course_no")
+	termCode := c.str("term_code")
+	if courseNo == "" {
+		return nil
+	}
+
+	// The course is new unless it exists already or an earlier row creates it
+	course, known := b.courses[courseNo]
+	if !known {
+		if existing, err := b.s.courseRepo.FindByCourseNo(courseNo); err == nil {
+			course, known = existing, true
+			b.courses[courseNo] = existing
+		}
+	}
+
+	var ops []importOp
+	if !known {
+		course = &model.Course{
+			CourseNo: courseNo,
+			Name:     c.required("name"),
+			Hours:    c.count("hours"),
+			Credits:  c.count("credits"),
+		}
+		if c.str("dept_no") != "" {
+			if dept := b.dept(c); dept != nil {
+				course.DeptID = &dept.ID
+			}
+		}
+		b.courses[courseNo] = course
+		ops = append(ops, func(tx *gorm.DB) error {
+			return b.s.courseRepo.WithTx(tx).Create(course)
+		})
+	} else if termCode == "" {
+		c.fail("course_no", "course already exists")
+		return nil
+	} else if c.str("dept_no") != "" {
+		if dept := b.dept(c); dept != nil && (course.DeptID == nil || *course.DeptID != dept.ID) {
+			c.fail("dept_no", "course belongs to another department")
+		}
+	}
+
+	if termCode != "" {
+		if op := b.offering(c, course, termCode); op != nil {
+			ops = append(ops, op)
+		}
+	} else {
+		for _, col := range []string{"section_no", "staff_no", "class_time", "class_location", "exam_time", "capacity"} {
+			if c.str(col) != "" {
+				c.fail(col, "requires term_code")
+			}
+		}
+	}
+
+	return func(tx *gorm.DB) error {
+		for _, op := range ops {
+			if err := op(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// offering validates the section part of a course row. course may not have an ID yet;
+// it gets one when its own op runs earlier in the same transaction.
+func (b *importBatch) offering(c *importCheck, course *model.Course, termCode string) importOp {
+	offering := &model.CourseOffering{
+		SectionNo:     c.str("section_no"),
+		ClassLocation: c.str("class_location"),
+		ExamTime:      c.str("exam_time"),
+		Capacity:      c.count("capacity"),
+	}
+	if offering.SectionNo == "" {
+		offering.SectionNo = DefaultSectionNo
+	}
+
+	term, ok := b.terms[termCode]
+	if !ok {
+		term, _ = b.s.termRepo.FindByTermCode(termCode)
+		b.terms[termCode] = term
+	}
+	if term == nil {
+		c.fail("term_code", "term not found: "+termCode)
+	} else {
+		offering.TermID = term.ID
+		if b.claim(c, "section_no", "offering:"+course.CourseNo+"/"+termCode+"/"+offering.SectionNo) && course.ID != 0 {
+			if _, err := b.s.offeringRepo.FindBySection(course.ID, term.ID, offering.SectionNo); err == nil {
+				c.fail("section_no", "section already offered in this term")
+			}
+		}
+	}
+
+	if staffNo := c.required("staff_no"); staffNo != "" {
+		teacher, ok := b.teachers[staffNo]
+		if !ok {
+			teacher, _ = b.s.staffRepo.FindByStaffNo(staffNo)
+			b.teachers[staffNo] = teacher
+		}
+		if teacher == nil {
+			c.fail("staff_no", "staff not found: "+staffNo)
+		} else {
+			offering.TeacherID = teacher.ID
+		}
+	}
+
+	offering.ClassTime = c.str("class_time")
+	meetings, err := ParseClassTime(offering.ClassTime)
+	if err != nil {
+		c.fail("class_time", err.Error())
+	}
+	offering.Meetings = meetings
+
+	return func(tx *gorm.DB) error {
+		offering.CourseID = course.ID
+		txRepo := b.s.offeringRepo.WithTx(tx)
+		if err := txRepo.Create(offering); err != nil {
+			return err
+		}
+		return txRepo.ReplaceMeetings(offering.ID, offering.Meetings)
+	}
+}
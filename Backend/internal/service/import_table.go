@@ -0,0 +1,94 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// importTable is an uploaded sheet: normalized header names and the data rows,
+// each row keyed by header and tagged with its line number in the file
+type importTable struct {
+	headerLine int
+	header     []string
+	rows       []importRow
+}
+
+// readImportTable parses a CSV or XLSX file (chosen by extension). The first
+// non-empty row is the header; blank rows are skipped.
+func readImportTable(filename string, r io.Reader, maxRows int) (*importTable, error) {
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		all, err := cr.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+		records = all
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read xlsx: %w", err)
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("xlsx has no sheets")
+		}
+		all, err := f.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("read xlsx: %w", err)
+		}
+		records = all
+	default:
+		return nil, errors.New("unsupported file type, expected .csv or .xlsx")
+	}
+
+	t := &importTable{}
+	for i, rec := range records {
+		if isBlankRecord(rec) {
+			continue
+		}
+		if t.header == nil {
+			for j, h := range rec {
+				if j == 0 {
+					h = strings.TrimPrefix(h, "\ufeff")
+				}
+				t.header = append(t.header, strings.ToLower(strings.TrimSpace(h)))
+			}
+			t.headerLine = i + 1
+			continue
+		}
+		row := importRow{line: i + 1, values: make(map[string]string, len(t.header))}
+		for j, h := range t.header {
+			if j < len(rec) && h != "" {
+				row.values[h] = strings.TrimSpace(rec[j])
+			}
+		}
+		t.rows = append(t.rows, row)
+		if len(t.rows) > maxRows {
+			return nil, fmt.Errorf("too many rows, at most %d are accepted per file", maxRows)
+		}
+	}
+	if t.header == nil {
+		return nil, errors.New("file is empty")
+	}
+	return t, nil
+}
+
+func isBlankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
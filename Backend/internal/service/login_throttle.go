@@ -0,0 +1,76 @@
+package service
+
+import (
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// Audit log actions of refused logins
+const (
+	auditLoginFailed    = "login_failed"
+	auditLoginMFAFailed = "login_mfa_failed"
+	auditLoginLocked    = "login_locked"
+	auditLoginRefused   = "login_refused"
+)
+
+// Routes of the login steps and of the MFA changes confirmed with a code, as recorded
+// in the audit log
+const (
+	loginRoute       = "/auth/login"
+	mfaVerifyRoute   = "/auth/mfa/verify"
+	mfaDisableRoute  = "/auth/mfa/disable"
+	mfaRecoveryRoute = "/auth/mfa/recovery-codes"
+)
+
+func userThrottleKey(username string) string { return "user:" + username }
+
+func ipThrottleKey(ip string) string { return "ip:" + ip }
+
+// loginThrottle delays logins after failures: each failure of a key blocks it for
+// 1s, 2s, 4s... and reaching the key's limit locks it out for LOGIN_LOCKOUT_MINUTES
+type loginThrottle struct {
+	store repository.LoginThrottleStore
+	cfg   config.Config
+}
+
+func (t loginThrottle) lockout() time.Duration {
+	return time.Duration(t.cfg.LoginLockoutMinutes) * time.Minute
+}
+
+// blocked returns how long the longest block among keys still lasts, and whether it is a lockout
+func (t loginThrottle) blocked(keys []string, now time.Time) (time.Duration, bool, error) {
+	var wait time.Duration
+	locked := false
+	for _, key := range keys {
+		entry, err := t.store.Get(key)
+		if err != nil {
+			return 0, false, err
+		}
+		if entry == nil || entry.BlockedUntil == nil {
+			continue
+		}
+		if d := entry.BlockedUntil.Sub(now); d > wait {
+			wait, locked = d, entry.Locked
+		}
+	}
+	return wait, locked, nil
+}
+
+// fail counts a failure of key against its limit and blocks it; it reports whether
+// the key is now locked out. A limit of 0 never locks out.
+func (t loginThrottle) fail(key string, limit int, now time.Time) (bool, error) {
+	failures, err := t.store.AddFailure(key, now, t.lockout())
+	if err != nil {
+		return false, err
+	}
+	if limit > 0 && failures >= limit {
+		return true, t.store.Block(key, now.Add(t.lockout()), true)
+	}
+	delay := t.lockout()
+	if failures <= 30 {
+		delay = min(delay, time.Second<<(failures-1))
+	}
+	return false, t.store.Block(key, now.Add(delay), false)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+func newTestThrottle() loginThrottle {
+	cfg := config.Config{LoginLockoutMinutes: 15, LoginThrottleStore: "memory"}
+	return loginThrottle{store: repository.NewLoginThrottleStore(nil, cfg), cfg: cfg}
+}
+
+func TestLoginThrottleSchedule(t *testing.T) {
+	lockout := 15 * time.Minute
+	tests := []struct {
+		name  string
+		limit int
+		// want holds the block after each consecutive failure; locked marks a lockout
+		want []time.Duration
+		lock []bool
+	}{
+		{"backoff doubles up to the lockout", 0,
+			[]time.Duration{
+				time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second,
+				32 * time.Second, 64 * time.Second, 128 * time.Second, 256 * time.Second, 512 * time.Second,
+				lockout, lockout,
+			},
+			make([]bool, 12)},
+		{"limit locks out", 5,
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, lockout, lockout},
+			[]bool{false, false, false, false, true, true}},
+		{"limit of one", 1,
+			[]time.Duration{lockout, lockout},
+			[]bool{true, true}},
+	}
+	for _, tt := range tests {
+		th := newTestThrottle()
+		now := time.Unix(1700000000, 0)
+		for i, want := range tt.want {
+			locked, err := th.fail("user:alice", tt.limit, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if locked != tt.lock[i] {
+				t.Errorf("%s: failure %d locked = %v, want %v", tt.name, i+1, locked, tt.lock[i])
+			}
+			wait, waitLocked, err := th.blocked([]string{"user:alice"}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait != want || waitLocked != tt.lock[i] {
+				t.Errorf("%s: after failure %d blocked = %v, %v; want %v, %v", tt.name, i+1, wait, waitLocked, want, tt.lock[i])
+			}
+			// Each attempt waits out the previous block
+			now = now.Add(wait)
+		}
+	}
+}
+
+func TestLoginThrottleBlocked(t *testing.T) {
+	th := newTestThrottle()
+	now := time.Unix(1700000000, 0)
+	for range 3 {
+		if _, err := th.fail("user:alice", 0, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := th.fail("ip:10.0.0.1", 1, now); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		keys   []string
+		at     time.Duration
+		wait   time.Duration
+		locked bool
+	}{
+		{"backoff", []string{"user:alice"}, 0, 4 * time.Second, false},
+		{"backoff partly waited", []string{"user:alice"}, time.Second, 3 * time.Second, false},
+		{"backoff over", []string{"user:alice"}, 4 * time.Second, 0, false},
+		// The longest block among the keys wins
+		{"lockout wins", []string{"user:alice", "ip:10.0.0.1"}, 0, 15 * time.Minute, true},
+		{"lockout over", []string{"user:alice", "ip:10.0.0.1"}, 15 * time.Minute, 0, false},
+		{"unknown key", []string{"user:bob"}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		wait, locked, err := th.blocked(tt.keys, now.Add(tt.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != tt.wait || locked != tt.locked {
+			t.Errorf("%s: blocked = %v, %v; want %v, %v", tt.name, wait, locked, tt.wait, tt.locked)
+		}
+	}
+}
+
+func TestLoginThrottleWindowResets(t *testing.T) {
+	th := newTestThrottle()
+	now := time.Unix(1700000000, 0)
+	for i := range 5 {
+		locked, err := th.fail("user:alice", 5, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == 4) {
+			t.Fatalf("failure %d locked = %v", i+1, locked)
+		}
+	}
+	// Failures older than the lockout window no longer count
+	now = now.Add(15*time.Minute + time.Second)
+	locked, err := th.fail("user:alice", 5, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait, _, err := th.blocked([]string{"user:alice"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked || wait != time.Second {
+		t.Errorf("failure after the window: locked = %v, blocked %v; want false, 1s", locked, wait)
+	}
+}
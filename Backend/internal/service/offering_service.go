@@ -0,0 +1,247 @@
+package service
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// DefaultSectionNo is used when an offering is created without a section number
+const DefaultSectionNo = "01"
+
+// OfferingListResult represents paginated course offering list
+type OfferingListResult struct {
+	Items    []repository.OfferingRow `json:"items"`
+	Total    int64                    `json:"total"`
+	Page     int                      `json:"page"`
+	PageSize int                      `json:"page_size"`
+}
+
+// OfferingService defines the interface for course offering business logic
+type OfferingService interface {
+	List(params repository.OfferingQueryParams) (*OfferingListResult, error)
+	GetByID(id uint) (*repository.OfferingRow, error)
+	Create(offering *model.CourseOffering) error
+	Update(id uint, input *model.CourseOffering) (*model.CourseOffering, error)
+	Delete(id uint) error
+	WithScope(scope repository.Scope) OfferingService
+}
+
+type offeringService struct {
+	repo       repository.OfferingRepository
+	courseRepo repository.CourseRepository
+	termRepo   repository.TermRepository
+	promoter   waitlistPromoter
+	db         *gorm.DB
+}
+
+// NewOfferingService creates a new OfferingService
+func NewOfferingService(
+	repo repository.OfferingRepository,
+	courseRepo repository.CourseRepository,
+	termRepo repository.TermRepository,
+	enrollRepo repository.EnrollmentRepository,
+	waitlistRepo repository.WaitlistRepository,
+	db *gorm.DB,
+	cfg config.Config,
+) OfferingService {
+	return &offeringService{
+		repo:       repo,
+		courseRepo: courseRepo,
+		termRepo:   termRepo,
+		promoter:   waitlistPromoter{enrollRepo: enrollRepo, waitlistRepo: waitlistRepo, offeringRepo: repo, cfg: cfg},
+		db:         db,
+	}
+}
+
+// WithScope returns the service limited to the offerings of the scope's department's courses
+func (s *offeringService) WithScope(scope repository.Scope) OfferingService {
+	scoped := *s
+	scoped.repo = s.repo.WithScope(scope)
+	scoped.courseRepo = s.courseRepo.WithScope(scope)
+	return &scoped
+}
+
+// List returns offerings; pagination applies only when page or page size is given
+func (s *offeringService) List(params repository.OfferingQueryParams) (*OfferingListResult, error) {
+	items, total, err := s.repo.FindAll(params)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if err := s.attachMeetings(items); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+
+	return &OfferingListResult{
+		Items:    items,
+		Total:    total,
+		Page:     params.Page,
+		PageSize: params.PageSize,
+	}, nil
+}
+
+func (s *offeringService) GetByID(id uint) (*repository.OfferingRow, error) {
+	rows, err := s.repo.FindRowsByIDs([]uint{id})
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if len(rows) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeNotFound, "offering not found")
+	}
+	if err := s.attachMeetings(rows); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return &rows[0], nil
+}
+
+func (s *offeringService) Create(offering *model.CourseOffering) error {
+	if offering.CourseID == 0 || offering.TermID == 0 || offering.TeacherID == 0 {
+		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "course_id/term_id/teacher_id required")
+	}
+	if offering.SectionNo == "" {
+		offering.SectionNo = DefaultSectionNo
+	}
+	if offering.Capacity < 0 {
+		return pkg.NewAppError(pkg.ErrCodeInvalidCapacity, "capacity must be >= 0")
+	}
+	if _, err := s.courseRepo.FindByID(offering.CourseID); err != nil {
+		return pkg.NewAppError(pkg.ErrCodeCourseNotFound, "course not found")
+	}
+	if _, err := s.termRepo.FindByID(offering.TermID); err != nil {
+		return pkg.NewAppError(pkg.ErrCodeTermNotFound, "term not found")
+	}
+	if _, err := s.repo.FindBySection(offering.CourseID, offering.TermID, offering.SectionNo); err == nil {
+		return pkg.NewAppError(pkg.ErrCodeOfferingExists, "section already offered in this term")
+	}
+	if err := normalizeMeetings(offering); err != nil {
+		return err
+	}
+
+	offering.ID = 0
+	offering.GradeStatus = model.GradeStatusDraft
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := s.repo.WithTx(tx)
+		if err := txRepo.Create(offering); err != nil {
+			return err
+		}
+		return txRepo.ReplaceMeetings(offering.ID, offering.Meetings)
+	}); err != nil {
+		return pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+	}
+	return nil
+}
+
+func (s *offeringService) Update(id uint, input *model.CourseOffering) (*model.CourseOffering, error) {
+	current, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "offering not found", err)
+	}
+
+	// course_id and term_id immutable
+	if input.SectionNo != "" && input.SectionNo != current.SectionNo {
+		if _, err := s.repo.FindBySection(current.CourseID, current.TermID, input.SectionNo); err == nil {
+			return nil, pkg.NewAppError(pkg.ErrCodeOfferingExists, "section already offered in this term")
+		}
+		current.SectionNo = input.SectionNo
+	}
+	if input.TeacherID != 0 {
+		current.TeacherID = input.TeacherID
+	}
+	if input.Capacity < 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidCapacity, "capacity must be >= 0")
+	}
+	current.Capacity = input.Capacity
+	current.ClassTime = input.ClassTime
+	current.Meetings = input.Meetings
+	current.ClassLocation = input.ClassLocation
+	current.ExamTime = input.ExamTime
+	if err := normalizeMeetings(current); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := s.repo.WithTx(tx)
+		if err := txRepo.Update(current); err != nil {
+			return err
+		}
+		if err := txRepo.ReplaceMeetings(current.ID, current.Meetings); err != nil {
+			return err
+		}
+		// Seats added by a larger capacity go to the queue, in order
+		return s.promoter.fill(tx, current.ID)
+	}); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update failed", err)
+	}
+	return current, nil
+}
+
+func (s *offeringService) Delete(id uint) error {
+	// 避免破坏选课/成绩一致性
+	enrCnt, err := s.repo.CountEnrollments(id)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if enrCnt > 0 {
+		return pkg.NewAppError(pkg.ErrCodeCourseHasEnroll, "offering has enrollments")
+	}
+
+	grdCnt, err := s.repo.CountGrades(id)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if grdCnt > 0 {
+		return pkg.NewAppError(pkg.ErrCodeCourseHasGrades, "offering has grades")
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete failed", err)
+	}
+	return nil
+}
+
+// normalizeMeetings keeps ClassTime and Meetings in sync.
+// Structured meetings win when supplied; otherwise ClassTime is parsed.
+func normalizeMeetings(offering *model.CourseOffering) error {
+	if offering.Meetings != nil {
+		for _, m := range offering.Meetings {
+			if err := ValidateClassMeeting(m); err != nil {
+				return pkg.WrapError(pkg.ErrCodeInvalidClassTime, "invalid meeting: "+err.Error(), err)
+			}
+		}
+		offering.ClassTime = FormatClassTime(offering.Meetings)
+		return nil
+	}
+
+	meetings, err := ParseClassTime(offering.ClassTime)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeInvalidClassTime, "invalid class_time: "+err.Error(), err)
+	}
+	offering.Meetings = meetings
+	return nil
+}
+
+// attachMeetings loads the structured meetings for a page of offerings
+func (s *offeringService) attachMeetings(items []repository.OfferingRow) error {
+	ids := make([]uint, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.ID)
+	}
+	meetings, err := s.repo.FindMeetingsByOfferingIDs(ids)
+	if err != nil {
+		return err
+	}
+	byOffering := make(map[uint][]model.ClassMeeting)
+	for _, m := range meetings {
+		byOffering[m.OfferingID] = append(byOffering[m.OfferingID], m)
+	}
+	for i := range items {
+		items[i].Meetings = byOffering[items[i].ID]
+		if items[i].Meetings == nil {
+			items[i].Meetings = []model.ClassMeeting{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/passwords"
+)
+
+// checkPassword applies the password policy of cfg to a new password of username.
+// All violations are reported together in the details of a single error.
+func checkPassword(cfg config.Config, username, password string) error {
+	var violations []string
+	if n := utf8.RuneCountInString(password); n < cfg.PasswordMinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", cfg.PasswordMinLength))
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			classes++
+		}
+	}
+	if classes < cfg.PasswordMinClasses {
+		violations = append(violations, fmt.Sprintf(
+			"must mix at least %d of lower case, upper case, digits and symbols", cfg.PasswordMinClasses))
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if cfg.PasswordRejectCommon && passwords.IsCommon(password) {
+		violations = append(violations, "is too common")
+	}
+
+	if len(violations) > 0 {
+		return pkg.NewAppErrorWithDetails(pkg.ErrCodeWeakPassword, "password does not meet the policy",
+			map[string]any{"violations": violations})
+	}
+	return nil
+}
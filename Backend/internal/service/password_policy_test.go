@@ -0,0 +1,61 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/pkg"
+)
+
+func TestCheckPassword(t *testing.T) {
+	const (
+		tooShort = "must be at least 8 characters"
+		classes  = "must mix at least 3 of lower case, upper case, digits and symbols"
+		username = "must not contain the username"
+		common   = "is too common"
+	)
+	cfg := config.Config{PasswordMinLength: 8, PasswordMinClasses: 3, PasswordRejectCommon: true}
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		username string
+		password string
+		want     []string
+	}{
+		{"meets the policy", cfg, "alice", "Tr0ub4dor", nil},
+		{"all four classes", cfg, "alice", "k9#Lm2$pQ", nil},
+		{"exactly the minimum length", cfg, "alice", "Ab3defgh", nil},
+		{"one short of the minimum", cfg, "alice", "Ab3defg", []string{tooShort}},
+		// Length counts characters, not bytes
+		{"multibyte characters", cfg, "alice", "密码Ab3密码", []string{tooShort}},
+		{"two classes", cfg, "alice", "abcdefgh12", []string{classes}},
+		{"one class", cfg, "alice", "abcdefghij", []string{classes}},
+		{"symbols count as a class", cfg, "alice", "abcdefgh1!", nil},
+		{"contains the username", cfg, "alice", "xALICE#2024", []string{username}},
+		{"no username", cfg, "", "xALICE#2024", nil},
+		{"common password", cfg, "alice", "Password1", []string{common}},
+		{"common password ignoring case", cfg, "alice", "P@SSW0RD", []string{common}},
+		{"several violations", cfg, "bob", "bob1", []string{tooShort, classes, username}},
+		{"common allowed", config.Config{PasswordMinLength: 8, PasswordMinClasses: 3}, "alice", "Password1", nil},
+		{"no class requirement", config.Config{PasswordMinLength: 4}, "alice", "abcd", nil},
+	}
+	for _, tt := range tests {
+		err := checkPassword(tt.cfg, tt.username, tt.password)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: checkPassword(%q) = %v, want nil", tt.name, tt.password, err)
+			}
+			continue
+		}
+		appErr, ok := err.(*pkg.AppError)
+		if !ok || appErr.Code != pkg.ErrCodeWeakPassword {
+			t.Errorf("%s: checkPassword(%q) = %v, want code %d", tt.name, tt.password, err, pkg.ErrCodeWeakPassword)
+			continue
+		}
+		got := appErr.Details.(map[string]any)["violations"]
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -8,10 +8,20 @@ var ProviderSet = wire.NewSet(
 	NewStudentService,
 	NewStaffService,
 	NewCourseService,
+	NewOfferingService,
 	NewTermService,
 	NewEnrollmentService,
 	NewGradeService,
+	NewGradeWorkflowService,
+	NewGPAService,
+	NewDocumentIssuer,
+	NewTranscriptService,
+	NewCertificateService,
 	NewAuthService,
 	NewReportService,
 	NewUserService,
+	NewAccountService,
+	NewImportService,
+	NewAuditService,
+	NewRoleService,
 )
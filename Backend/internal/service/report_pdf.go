@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/lin-snow/edumgr/internal/pkg/fonts"
+)
+
+var reportColumns = []transcriptColumn{
+	{"序号", 12, "C"},
+	{"学号", 30, "C"},
+	{"姓名", 30, "C"},
+	{"性别", 14, "C"},
+	{"平时", 22, "C"},
+	{"考试", 22, "C"},
+	{"总评", 22, "C"},
+	{"备注", 28, "C"},
+}
+
+// distBands are the score bands of ScoreDist, highest first
+var distBands = []string{"90分以上", "80-89分", "70-79分", "60-69分", "60分以下"}
+
+const reportMarginX = 15
+
+// renderGradeReportPDF lays each course offering out from a new page: department header,
+// course information, the roster table, the distribution table with its bar chart, and
+// the signature block.
+func renderGradeReportPDF(courses []RosterCourse, generatedAt time.Time) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fonts.CJKFamily, "", fonts.CJK)
+	pdf.SetMargins(reportMarginX, 12, reportMarginX)
+	pdf.SetAutoPageBreak(true, transcriptMarginB)
+	pdf.AliasNbPages("{nb}")
+	pdf.SetTitle("Grade Report", true)
+	pdf.SetCreator("EduMgr", true)
+
+	generated := generatedAt.Format("2006-01-02 15:04")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(fonts.CJKFamily, "", 8)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(90, 5, "生成时间 "+generated, "", 0, "L", false, 0, "")
+		pdf.CellFormat(90, 5, fmt.Sprintf("第 %d / {nb} 页", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	for i := range courses {
+		drawCourseReport(pdf, &courses[i])
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawCourseReport(pdf *fpdf.Fpdf, c *RosterCourse) {
+	pdf.AddPage()
+	pdf.SetFont(fonts.CJKFamily, "", 16)
+	pdf.CellFormat(0, 9, c.TeacherDept, "", 1, "C", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 14)
+	pdf.CellFormat(0, 8, "课程成绩报告", "", 1, "C", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.CellFormat(0, 5, "Course Grade Report", "", 1, "C", false, 0, "")
+	pdf.Ln(3)
+
+	section := c.SectionNo
+	if section == "" {
+		section = "-"
+	}
+	info := [][2]string{
+		{"学期", c.TermCode}, {"课程号", c.CourseNo}, {"课程名称", c.CourseName},
+		{"教学班", section}, {"任课教师", c.TeacherName + " (" + c.TeacherNo + ")"},
+		{"学时/学分", fmt.Sprintf("%d / %d", c.Hours, c.Credits)},
+		{"上课时间", c.ClassTime}, {"上课地点", c.ClassLoc}, {"考试时间", c.ExamTime},
+	}
+	pdf.SetFont(fonts.CJKFamily, "", 10)
+	for i, kv := range info {
+		pdf.CellFormat(20, 7, kv[0]+"：", "", 0, "L", false, 0, "")
+		ln := 0
+		if i%3 == 2 {
+			ln = 1
+		}
+		pdf.CellFormat(40, 7, fitText(pdf, kv[1], 39), "", ln, "L", false, 0, "")
+	}
+	pdf.Ln(3)
+
+	drawReportHeader(pdf)
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	for i, stu := range c.Students {
+		if ensureSpace(pdf, transcriptRowH) {
+			drawReportHeader(pdf)
+			pdf.SetFont(fonts.CJKFamily, "", 9)
+		}
+		cells := []string{
+			strconv.Itoa(i + 1), stu.StudentNo, stu.Name, stu.Gender,
+			fmtScore(stu.UsualScore), fmtScore(stu.ExamScore), fmtScore(stu.FinalScore),
+			rosterRemark(stu),
+		}
+		for j, col := range reportColumns {
+			pdf.CellFormat(col.width, transcriptRowH, fitText(pdf, cells[j], col.width-2), "1", 0, col.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// Keep the distribution, the chart and the signatures on one page
+	ensureSpace(pdf, 110)
+	pdf.Ln(6)
+	pdf.SetFont(fonts.CJKFamily, "", 11)
+	pdf.CellFormat(0, 7, "成绩分布", "B", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	d := c.Dist
+	if d == nil {
+		d = &ScoreDist{}
+	}
+	counts := []int{d.Ge90Count, d.Ge80Count, d.Ge70Count, d.Ge60Count, d.Lt60Count}
+	rates := []float64{d.Ge90Rate, d.Ge80Rate, d.Ge70Rate, d.Ge60Rate, d.Lt60Rate}
+	top := pdf.GetY()
+	drawDistTable(pdf, counts, rates)
+	summaryY := pdf.GetY()
+	drawDistChart(pdf, reportMarginX+80, top, 100, 58, counts)
+
+	scored, sum := 0, 0.0
+	for _, stu := range c.Students {
+		if stu.FinalScore != nil {
+			scored++
+			sum += *stu.FinalScore
+		}
+	}
+	avg := "-"
+	if scored > 0 {
+		avg = fmt.Sprintf("%.2f", sum/float64(scored))
+	}
+	pdf.SetXY(reportMarginX, summaryY+2)
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.CellFormat(75, 6, fmt.Sprintf("选课 %d 人，已录总评 %d 人", len(c.Students), scored), "", 1, "L", false, 0, "")
+	pdf.CellFormat(75, 6, "平均分 "+avg, "", 1, "L", false, 0, "")
+
+	pdf.SetY(math.Max(pdf.GetY(), top+58) + 12)
+	drawSignatureBlock(pdf)
+}
+
+func drawReportHeader(pdf *fpdf.Fpdf) {
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range reportColumns {
+		pdf.CellFormat(col.width, transcriptRowH, col.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func rosterRemark(stu RosterStudent) string {
+	switch {
+	case stu.FinalScore == nil:
+		return "未录入"
+	case stu.Counted != nil && !*stu.Counted:
+		return "不计"
+	}
+	return ""
+}
+
+// drawDistTable draws the band/count/rate table at the left margin, with a total row
+func drawDistTable(pdf *fpdf.Fpdf, counts []int, rates []float64) {
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.SetFillColor(235, 235, 235)
+	pdf.CellFormat(28, transcriptRowH, "分数段", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(20, transcriptRowH, "人数", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(24, transcriptRowH, "比例", "1", 1, "C", true, 0, "")
+	total := 0
+	for i, band := range distBands {
+		total += counts[i]
+		pdf.CellFormat(28, transcriptRowH, band, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(20, transcriptRowH, strconv.Itoa(counts[i]), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(24, transcriptRowH, percent(rates[i]), "1", 1, "C", false, 0, "")
+	}
+	pdf.CellFormat(28, transcriptRowH, "合计", "1", 0, "C", false, 0, "")
+	pdf.CellFormat(20, transcriptRowH, strconv.Itoa(total), "1", 0, "C", false, 0, "")
+	pdf.CellFormat(24, transcriptRowH, "", "1", 1, "C", false, 0, "")
+}
+
+// drawDistChart draws a bar chart of the band counts into the box at (x, y) of size w×h
+func drawDistChart(pdf *fpdf.Fpdf, x, y, w, h float64, counts []int) {
+	const axisW, labelH = 8.0, 6.0
+	plotX, plotY := x+axisW, y+4
+	plotW, plotH := w-axisW, h-labelH-4
+
+	maxCount := 0
+	for _, n := range counts {
+		maxCount = max(maxCount, n)
+	}
+	step := max(1, int(math.Ceil(float64(maxCount)/5)))
+	ticks := max(1, int(math.Ceil(float64(maxCount)/float64(step))))
+	yMax := float64(step * ticks)
+
+	pdf.SetFont(fonts.CJKFamily, "", 7)
+	pdf.SetDrawColor(210, 210, 210)
+	pdf.SetLineWidth(0.1)
+	for i := 0; i <= ticks; i++ {
+		ty := plotY + plotH - plotH*float64(i*step)/yMax
+		pdf.Line(plotX, ty, plotX+plotW, ty)
+		pdf.SetXY(x, ty-2)
+		pdf.CellFormat(axisW-1, 4, strconv.Itoa(i*step), "", 0, "R", false, 0, "")
+	}
+
+	slot := plotW / float64(len(counts))
+	barW := slot * 0.6
+	for i, n := range counts {
+		bx := plotX + slot*float64(i) + (slot-barW)/2
+		bh := plotH * float64(n) / yMax
+		if i == len(counts)-1 {
+			pdf.SetFillColor(200, 90, 80)
+		} else {
+			pdf.SetFillColor(80, 120, 180)
+		}
+		if bh > 0 {
+			pdf.Rect(bx, plotY+plotH-bh, barW, bh, "F")
+		}
+		pdf.SetXY(bx, plotY+plotH-bh-4)
+		pdf.CellFormat(barW, 4, strconv.Itoa(n), "", 0, "C", false, 0, "")
+		pdf.SetXY(plotX+slot*float64(i), plotY+plotH+1)
+		pdf.CellFormat(slot, 4, distBands[i], "", 0, "C", false, 0, "")
+	}
+
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	pdf.Line(plotX, plotY, plotX, plotY+plotH)
+	pdf.Line(plotX, plotY+plotH, plotX+plotW, plotY+plotH)
+}
+
+// drawSignatureBlock leaves lines for the teacher's and the department head's signatures
+func drawSignatureBlock(pdf *fpdf.Fpdf) {
+	pdf.SetFont(fonts.CJKFamily, "", 10)
+	for _, who := range []string{"任课教师（签名）：", "系（院）负责人（签名）："} {
+		pdf.SetX(reportMarginX)
+		pdf.CellFormat(46, 10, who, "", 0, "L", false, 0, "")
+		pdf.CellFormat(50, 10, "", "B", 0, "L", false, 0, "")
+		pdf.CellFormat(16, 10, "", "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 10, "日期：        年      月      日", "", 1, "L", false, 0, "")
+		pdf.Ln(4)
+	}
+}
@@ -1,14 +1,20 @@
 package service
 
 import (
+	"fmt"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
-// RosterStudent represents a student in the roster
+// RosterStudent represents a student in the roster.
+// Counted is only set on grade reports and follows the configured attempt policy.
 type RosterStudent struct {
 	StudentNo  string   `json:"student_no"`
 	Name       string   `json:"name"`
@@ -16,6 +22,7 @@ type RosterStudent struct {
 	UsualScore *float64 `json:"usual_score,omitempty"`
 	ExamScore  *float64 `json:"exam_score,omitempty"`
 	FinalScore *float64 `json:"final_score,omitempty"`
+	Counted    *bool    `json:"counted,omitempty"`
 }
 
 // ScoreDist represents score distribution
@@ -32,12 +39,16 @@ type ScoreDist struct {
 	Lt60Rate  float64 `json:"lt60_rate"`
 }
 
-// RosterCourse represents a course in the roster
+// RosterCourse represents a course offering in the roster
 type RosterCourse struct {
+	OfferingID  uint            `json:"offering_id"`
+	TermCode    string          `json:"term_code"`
+	SectionNo   string          `json:"section_no"`
 	CourseNo    string          `json:"course_no"`
 	CourseName  string          `json:"course_name"`
 	TeacherNo   string          `json:"teacher_no"`
 	TeacherName string          `json:"teacher_name"`
+	TeacherDept string          `json:"teacher_dept"`
 	Hours       int             `json:"hours"`
 	Credits     int             `json:"credits"`
 	ClassTime   string          `json:"class_time"`
@@ -53,37 +64,69 @@ type ReportQueryParams struct {
 	CourseName  string
 	TeacherName string
 	DeptNo      string
+	TermCode    string
 }
 
-// ReportService defines the interface for report business logic
+// ReportService defines the interface for report business logic. Reports of roles holding
+// report:read:own_course instead of report:read only cover the offerings they teach.
 type ReportService interface {
-	GetGradeRoster(params ReportQueryParams) ([]RosterCourse, error)
-	GetGradeReport(params ReportQueryParams) ([]RosterCourse, error)
+	GetGradeRoster(params ReportQueryParams, role string, userID uint) ([]RosterCourse, error)
+	GetGradeReport(params ReportQueryParams, role string, userID uint) ([]RosterCourse, error)
+	ExportGradeRoster(w export.Writer, params ReportQueryParams, role string, userID uint) error
+	ExportGradeReport(w export.Writer, params ReportQueryParams, role string, userID uint) error
+	GradeReportPDF(params ReportQueryParams, role string, userID uint) ([]byte, error)
+	WithScope(scope repository.Scope) ReportService
 }
 
 type reportService struct {
-	repo repository.ReportRepository
+	repo      repository.ReportRepository
+	gradeRepo repository.GradeRepository
+	userRepo  repository.UserRepository
+	roles     RoleService
+	cfg       config.Config
 }
 
 // NewReportService creates a new ReportService
-func NewReportService(repo repository.ReportRepository) ReportService {
-	return &reportService{repo: repo}
+func NewReportService(repo repository.ReportRepository, gradeRepo repository.GradeRepository, userRepo repository.UserRepository, roles RoleService, cfg config.Config) ReportService {
+	return &reportService{repo: repo, gradeRepo: gradeRepo, userRepo: userRepo, roles: roles, cfg: cfg}
 }
 
-func (s *reportService) GetGradeRoster(params ReportQueryParams) ([]RosterCourse, error) {
-	return s.buildRoster(params, false)
+// WithScope returns the service limited to the students of the scope's department
+func (s *reportService) WithScope(scope repository.Scope) ReportService {
+	scoped := *s
+	scoped.repo = s.repo.WithScope(scope)
+	return &scoped
 }
 
-func (s *reportService) GetGradeReport(params ReportQueryParams) ([]RosterCourse, error) {
-	return s.buildRoster(params, true)
+func (s *reportService) GetGradeRoster(params ReportQueryParams, role string, userID uint) ([]RosterCourse, error) {
+	return s.buildRoster(params, false, role, userID)
 }
 
-func (s *reportService) buildRoster(params ReportQueryParams, withGrades bool) ([]RosterCourse, error) {
-	repoParams := repository.ReportQueryParams{
+func (s *reportService) GetGradeReport(params ReportQueryParams, role string, userID uint) ([]RosterCourse, error) {
+	return s.buildRoster(params, true, role, userID)
+}
+
+// repoParams converts params, limited to the offerings the caller teaches unless it
+// holds report:read
+func (s *reportService) repoParams(params ReportQueryParams, role string, userID uint) (repository.ReportQueryParams, error) {
+	teaching, err := readScope(s.roles, s.userRepo, s.cfg, role, userID, model.PermReportRead, model.PermReportReadOwnCourse)
+	if err != nil {
+		return repository.ReportQueryParams{}, err
+	}
+	return repository.ReportQueryParams{
 		CourseNo:    params.CourseNo,
 		CourseName:  params.CourseName,
 		TeacherName: params.TeacherName,
 		DeptNo:      params.DeptNo,
+		TermCode:    params.TermCode,
+		Teaching:    teaching,
+	}, nil
+}
+
+func (s *reportService) buildRoster(params ReportQueryParams, withGrades bool, role string, userID uint) ([]RosterCourse, error) {
+	repoParams, err := s.repoParams(params, role, userID)
+	if err != nil {
+		return nil, err
 	}
 
 	rows, err := s.repo.GetRosterData(repoParams, withGrades)
@@ -91,18 +134,36 @@ func (s *reportService) buildRoster(params ReportQueryParams, withGrades bool) (
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
 
-	// Group by course
+	var counted map[attemptKey]bool
+	if withGrades {
+		studentIDs := make([]uint, 0, len(rows))
+		courseIDs := make([]uint, 0, len(rows))
+		for _, r := range rows {
+			studentIDs = append(studentIDs, r.StudentID)
+			courseIDs = append(courseIDs, r.CourseID)
+		}
+		counted, err = loadCountedAttempts(s.gradeRepo, s.cfg.GradeAttemptPolicy, studentIDs, courseIDs)
+		if err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+	}
+
+	// Group by course offering
 	m := make(map[string]*RosterCourse)
 	order := make([]string, 0)
 	for _, r := range rows {
-		key := r.CourseNo
+		key := r.CourseNo + "|" + r.TermCode + "|" + r.SectionNo
 		rc, ok := m[key]
 		if !ok {
 			rc = &RosterCourse{
+				OfferingID:  r.OfferingID,
+				TermCode:    r.TermCode,
+				SectionNo:   r.SectionNo,
 				CourseNo:    r.CourseNo,
 				CourseName:  r.CourseName,
 				TeacherNo:   r.TeacherNo,
 				TeacherName: r.TeacherName,
+				TeacherDept: r.TeacherDept,
 				Hours:       r.Hours,
 				Credits:     r.Credits,
 				ClassTime:   r.ClassTime,
@@ -112,17 +173,22 @@ func (s *reportService) buildRoster(params ReportQueryParams, withGrades bool) (
 			m[key] = rc
 			order = append(order, key)
 		}
-		rc.Students = append(rc.Students, RosterStudent{
+		stu := RosterStudent{
 			StudentNo:  r.StudentNo,
 			Name:       r.StudentName,
 			Gender:     r.Gender,
 			UsualScore: r.UsualScore,
 			ExamScore:  r.ExamScore,
 			FinalScore: r.FinalScore,
-		})
+		}
+		if withGrades {
+			c := counted[attemptKey{r.StudentID, r.OfferingID}]
+			stu.Counted = &c
+		}
+		rc.Students = append(rc.Students, stu)
 	}
 
-	sort.Strings(order)
+	// Groups keep the order of the query: by course, the newest term first
 	result := make([]RosterCourse, 0, len(order))
 	for _, k := range order {
 		rc := *m[k]
@@ -138,6 +204,131 @@ func (s *reportService) buildRoster(params ReportQueryParams, withGrades bool) (
 	return result, nil
 }
 
+// GradeReportPDF renders the grade report for printing, one section per course offering
+func (s *reportService) GradeReportPDF(params ReportQueryParams, role string, userID uint) ([]byte, error) {
+	courses, err := s.GetGradeReport(params, role, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(courses) == 0 {
+		return nil, pkg.NewAppError(pkg.ErrCodeNotFound, "no course matches the filters")
+	}
+	data, err := renderGradeReportPDF(courses, time.Now())
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeRenderFailed, "render report failed", err)
+	}
+	return data, nil
+}
+
+var rosterExportHeader = []string{
+	"学期", "教学班", "课程号", "课程名称", "教师工号", "教师", "学时", "学分", "上课时间", "上课地点", "考试时间",
+	"学号", "姓名", "性别", "平时成绩", "考试成绩", "总评成绩",
+}
+
+var distExportHeader = []string{
+	"学期", "教学班", "课程号", "课程名称",
+	"90分以上", "比例", "80-89分", "比例", "70-79分", "比例", "60-69分", "比例", "60分以下", "比例",
+}
+
+// ExportGradeRoster writes the roster with empty score columns to be filled in by hand
+func (s *reportService) ExportGradeRoster(w export.Writer, params ReportQueryParams, role string, userID uint) error {
+	return s.exportRoster(w, params, false, role, userID)
+}
+
+// ExportGradeReport writes the students' scores, then the score distribution of each offering
+func (s *reportService) ExportGradeReport(w export.Writer, params ReportQueryParams, role string, userID uint) error {
+	return s.exportRoster(w, params, true, role, userID)
+}
+
+// exportRoster streams roster rows in report order. Rows arrive grouped by offering;
+// only one offering is held at a time, to resolve counted attempts and its distribution.
+func (s *reportService) exportRoster(w export.Writer, params ReportQueryParams, withGrades bool, role string, userID uint) error {
+	repoParams, err := s.repoParams(params, role, userID)
+	if err != nil {
+		return err
+	}
+	header, sheet := rosterExportHeader, "登分册"
+	if withGrades {
+		header, sheet = append(append([]string{}, rosterExportHeader...), "计入"), "成绩报表"
+	}
+	if err := w.Sheet(sheet, header); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+
+	var group []repository.RosterRow
+	var dists [][]any
+	flush := func() error {
+		if len(group) == 0 {
+			return nil
+		}
+		var counted map[attemptKey]bool
+		if withGrades {
+			studentIDs := make([]uint, len(group))
+			for i, r := range group {
+				studentIDs[i] = r.StudentID
+			}
+			var err error
+			counted, err = loadCountedAttempts(s.gradeRepo, s.cfg.GradeAttemptPolicy, studentIDs, []uint{group[0].CourseID})
+			if err != nil {
+				return err
+			}
+		}
+
+		students := make([]RosterStudent, 0, len(group))
+		for _, r := range group {
+			values := []any{r.TermCode, r.SectionNo, r.CourseNo, r.CourseName, r.TeacherNo, r.TeacherName,
+				r.Hours, r.Credits, r.ClassTime, r.ClassLoc, r.ExamTime,
+				r.StudentNo, r.StudentName, r.Gender, r.UsualScore, r.ExamScore, r.FinalScore}
+			if withGrades {
+				values = append(values, exportYesNo(counted[attemptKey{r.StudentID, r.OfferingID}]))
+				students = append(students, RosterStudent{FinalScore: r.FinalScore})
+			}
+			if err := w.Row(values...); err != nil {
+				return err
+			}
+		}
+		if withGrades {
+			d := s.calcDist(students)
+			first := group[0]
+			dists = append(dists, []any{first.TermCode, first.SectionNo, first.CourseNo, first.CourseName,
+				d.Ge90Count, percent(d.Ge90Rate), d.Ge80Count, percent(d.Ge80Rate), d.Ge70Count, percent(d.Ge70Rate),
+				d.Ge60Count, percent(d.Ge60Rate), d.Lt60Count, percent(d.Lt60Rate)})
+		}
+		group = group[:0]
+		return nil
+	}
+
+	err = s.repo.EachRosterRow(repoParams, withGrades, func(r *repository.RosterRow) error {
+		if len(group) > 0 && group[0].OfferingID != r.OfferingID {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		group = append(group, *r)
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil && withGrades {
+		if err = w.Sheet("分段统计", distExportHeader); err == nil {
+			for _, d := range dists {
+				if err = w.Row(d...); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
 func (s *reportService) calcDist(students []RosterStudent) *ScoreDist {
 	total := 0
 	cnt90, cnt80, cnt70, cnt60, cntlt := 0, 0, 0, 0, 0
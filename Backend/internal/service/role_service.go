@@ -0,0 +1,259 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// roleCacheTTL bounds how long role changes made by another instance take to apply here
+const roleCacheTTL = time.Minute
+
+// roleNamePattern is the form of a role name, which fits users.role and the access token
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,15}$`)
+
+// RoleRequest creates or updates a role; the name of an existing role cannot change
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleService manages roles and answers permission checks for middleware and services
+// from a cache of them. It implements middleware.Authorizer.
+type RoleService interface {
+	Can(role, perm string) bool
+	Permissions(role string) []string
+	Exists(role string) bool
+	Grants(role, target string) bool
+	List() ([]model.Role, error)
+	Create(req RoleRequest) (*model.Role, error)
+	Update(id uint, req RoleRequest) (*model.Role, error)
+	Delete(id uint) error
+}
+
+// cachedRole is a role with its permissions as a set
+type cachedRole struct {
+	perms []string
+	set   map[string]bool
+}
+
+type roleService struct {
+	repo repository.RoleRepository
+
+	mu       sync.Mutex
+	roles    map[string]cachedRole
+	loadedAt time.Time
+}
+
+// NewRoleService creates a new RoleService
+func NewRoleService(repo repository.RoleRepository) RoleService {
+	return &roleService{repo: repo}
+}
+
+// cached returns the roles, reloading them once the cache is stale. When the reload
+// fails the previous roles are kept and the next call tries again.
+func (s *roleService) cached() map[string]cachedRole {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roles != nil && time.Since(s.loadedAt) < roleCacheTTL {
+		return s.roles
+	}
+	roles, err := s.repo.FindAll()
+	if err != nil {
+		log.Printf("roles: load: %v", err)
+		return s.roles
+	}
+	m := make(map[string]cachedRole, len(roles))
+	for _, r := range roles {
+		set := make(map[string]bool, len(r.Permissions))
+		for _, p := range r.Permissions {
+			set[p] = true
+		}
+		m[r.Name] = cachedRole{perms: r.Permissions, set: set}
+	}
+	s.roles = m
+	s.loadedAt = time.Now()
+	return m
+}
+
+// invalidate makes the next check reload the roles
+func (s *roleService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// Can reports whether role holds perm; unknown roles hold nothing
+func (s *roleService) Can(role, perm string) bool {
+	return s.cached()[role].set[perm]
+}
+
+// Permissions lists the permissions of role
+func (s *roleService) Permissions(role string) []string {
+	perms := s.cached()[role].perms
+	if perms == nil {
+		return []string{}
+	}
+	return perms
+}
+
+func (s *roleService) Exists(role string) bool {
+	_, ok := s.cached()[role]
+	return ok
+}
+
+// Grants reports whether a holder of role may hand out target: every permission of target
+// is held by role, or narrows one it holds, as grade:write:own_course narrows grade:write
+func (s *roleService) Grants(role, target string) bool {
+	roles := s.cached()
+	t, ok := roles[target]
+	if !ok {
+		return false
+	}
+	held := roles[role].set
+	for _, p := range t.perms {
+		if held[p] {
+			continue
+		}
+		if i := strings.LastIndex(p, ":"); i > strings.Index(p, ":") && held[p[:i]] {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func (s *roleService) List() ([]model.Role, error) {
+	roles, err := s.repo.FindAll()
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return roles, nil
+}
+
+func (s *roleService) Create(req RoleRequest) (*model.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRole,
+			"name must be 2-16 lowercase letters, digits or underscores, starting with a letter")
+	}
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if s.Exists(req.Name) {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRole, "role already exists")
+	}
+
+	role := &model.Role{Name: req.Name, Label: req.Label, Permissions: perms}
+	if err := s.repo.Create(role); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create role failed", err)
+	}
+	s.invalidate()
+	return role, nil
+}
+
+// Update replaces the label and permissions of a role. The change applies to the
+// role's users at their next request, since access tokens only carry the role name.
+func (s *roleService) Update(id uint, req RoleRequest) (*model.Role, error) {
+	role, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "role not found", err)
+	}
+	if req.Name != "" && req.Name != role.Name {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRole, "role name cannot change")
+	}
+	perms, err := normalizePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keepRoleManager(role.ID, perms); err != nil {
+		return nil, err
+	}
+
+	role.Label = req.Label
+	role.Permissions = perms
+	if err := s.repo.Update(role); err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update role failed", err)
+	}
+	s.invalidate()
+	return role, nil
+}
+
+// Delete removes a role no user holds; built-in roles stay
+func (s *roleService) Delete(id uint) error {
+	role, err := s.repo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return pkg.NewAppError(pkg.ErrCodeNotFound, "role not found")
+	}
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if role.Builtin {
+		return pkg.NewAppError(pkg.ErrCodeRoleInUse, "built-in roles cannot be deleted")
+	}
+	n, err := s.repo.CountUsers(role.Name)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	if n > 0 {
+		return pkg.NewAppErrorWithDetails(pkg.ErrCodeRoleInUse, "role is assigned to users", map[string]any{"users": n})
+	}
+	if err := s.keepRoleManager(role.ID, nil); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(role.ID); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete role failed", err)
+	}
+	s.invalidate()
+	return nil
+}
+
+// keepRoleManager refuses to leave no role able to manage roles once role id has perms
+func (s *roleService) keepRoleManager(id uint, perms []string) error {
+	if slices.Contains(perms, model.PermRoleManage) {
+		return nil
+	}
+	roles, err := s.repo.FindAll()
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	for _, r := range roles {
+		if r.ID != id && slices.Contains(r.Permissions, model.PermRoleManage) {
+			return nil
+		}
+	}
+	return pkg.NewAppError(pkg.ErrCodeInvalidPerm, "another role must keep "+model.PermRoleManage)
+}
+
+// normalizePermissions checks perms against the known permissions and returns them
+// once each, in the order they are listed
+func normalizePermissions(perms []string) ([]string, error) {
+	var unknown []string
+	for _, p := range perms {
+		if !model.IsPermission(p) {
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, pkg.NewAppErrorWithDetails(pkg.ErrCodeInvalidPerm, "unknown permissions", map[string]any{"unknown": unknown})
+	}
+	result := []string{}
+	for _, p := range model.Permissions {
+		if slices.Contains(perms, p.Name) {
+			result = append(result, p.Name)
+		}
+	}
+	return result, nil
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
@@ -18,19 +22,43 @@ type StaffListResult struct {
 type StaffService interface {
 	List(staffNo, name, deptNo string) ([]repository.StaffWithDept, error)
 	ListPaginated(staffNo, name, deptNo string, page, pageSize int) (*StaffListResult, error)
+	Export(w export.Writer, staffNo, name, deptNo string) error
 	GetByID(id uint) (*model.Staff, error)
-	Create(staff *model.Staff) error
+	Create(staff *model.Staff, account AccountOption, actor Actor) (*ProvisionedAccount, error)
 	Update(id uint, input *model.Staff) (*model.Staff, error)
 	Delete(id uint) error
+	WithScope(scope repository.Scope) StaffService
 }
 
 type staffService struct {
-	repo repository.StaffRepository
+	repo        repository.StaffRepository
+	userRepo    repository.UserRepository
+	provisioner accountProvisioner
+	db          *gorm.DB
 }
 
 // NewStaffService creates a new StaffService
-func NewStaffService(repo repository.StaffRepository) StaffService {
-	return &staffService{repo: repo}
+func NewStaffService(
+	repo repository.StaffRepository,
+	userRepo repository.UserRepository,
+	roles RoleService,
+	db *gorm.DB,
+	cfg config.Config,
+) StaffService {
+	return &staffService{
+		repo:        repo,
+		userRepo:    userRepo,
+		provisioner: accountProvisioner{roles: roles, cfg: cfg},
+		db:          db,
+	}
+}
+
+// WithScope returns the service limited to the staff of the scope's department
+func (s *staffService) WithScope(scope repository.Scope) StaffService {
+	scoped := *s
+	scoped.repo = s.repo.WithScope(scope)
+	scoped.userRepo = s.userRepo.WithScope(scope)
+	return &scoped
 }
 
 func (s *staffService) List(staffNo, name, deptNo string) ([]repository.StaffWithDept, error) {
@@ -70,6 +98,22 @@ func (s *staffService) ListPaginated(staffNo, name, deptNo string, page, pageSiz
 	}, nil
 }
 
+var staffExportHeader = []string{"工号", "姓名", "性别", "出生年月", "院系号", "职称", "专业", "教学方向"}
+
+// Export writes every staff member matching the list filters
+func (s *staffService) Export(w export.Writer, staffNo, name, deptNo string) error {
+	if err := w.Sheet("教职工", staffExportHeader); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	if err := s.repo.EachWithDept(staffNo, name, deptNo, func(st *repository.StaffWithDept) error {
+		return w.Row(st.StaffNo, st.Name, st.Gender, st.BirthMonth, st.DeptNo,
+			st.Title, st.Major, st.TeachingDirection)
+	}); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
+}
+
 func (s *staffService) GetByID(id uint) (*model.Staff, error) {
 	staff, err := s.repo.FindByID(id)
 	if err != nil {
@@ -78,15 +122,38 @@ func (s *staffService) GetByID(id uint) (*model.Staff, error) {
 	return staff, nil
 }
 
-func (s *staffService) Create(staff *model.Staff) error {
+// Create adds the staff member and, when asked, their account named by the staff
+// number. Both are created or neither.
+func (s *staffService) Create(staff *model.Staff, account AccountOption, actor Actor) (*ProvisionedAccount, error) {
 	if staff.StaffNo == "" || staff.Name == "" || staff.DeptID == 0 {
-		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "staff_no/name/dept_id required")
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "staff_no/name/dept_id required")
 	}
 	staff.ID = 0
-	if err := s.repo.Create(staff); err != nil {
-		return pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+	if !account.Provision {
+		if err := s.repo.Create(staff); err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+		}
+		return nil, nil
 	}
-	return nil
+
+	role := staffRole(account.Role)
+	if err := s.provisioner.check(actor, role); err != nil {
+		return nil, err
+	}
+	var created *ProvisionedAccount
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).Create(staff); err != nil {
+			return pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+		}
+		var err error
+		created, err = s.provisioner.staff(s.userRepo.WithTx(tx), staff, role)
+		return err
+	})
+	if err != nil {
+		staff.ID = 0
+		return nil, err
+	}
+	return created, nil
 }
 
 func (s *staffService) Update(id uint, input *model.Staff) (*model.Staff, error) {
@@ -5,8 +5,10 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/pkg/export"
 	"github.com/lin-snow/edumgr/internal/repository"
 )
 
@@ -20,38 +22,82 @@ type StudentListResult struct {
 
 // StudentService defines the interface for student business logic
 type StudentService interface {
-	List(studentNo, name, deptNo string) ([]repository.StudentWithDept, error)
-	ListPaginated(studentNo, name, deptNo string, page, pageSize int) (*StudentListResult, error)
+	List(studentNo, name, deptNo, role string, userID uint) ([]repository.StudentWithDept, error)
+	ListPaginated(studentNo, name, deptNo string, page, pageSize int, role string, userID uint) (*StudentListResult, error)
+	Export(w export.Writer, studentNo, name, deptNo, role string, userID uint) error
 	GetByID(id uint) (*model.Student, error)
 	GetMyInfo(userID uint) (*repository.StudentWithDept, error)
-	Create(student *model.Student) error
+	Create(student *model.Student, account AccountOption, actor Actor) (*ProvisionedAccount, error)
 	Update(id uint, input *model.Student) (*model.Student, error)
 	Delete(id uint) error
 	Graduate(id uint) error
 	TransferOut(id uint) error
 	TransferIn(id uint) (*model.Student, error)
+	WithScope(scope repository.Scope) StudentService
 }
 
 type studentService struct {
-	repo     repository.StudentRepository
-	userRepo repository.UserRepository
-	db       *gorm.DB
+	repo        repository.StudentRepository
+	userRepo    repository.UserRepository
+	roles       RoleService
+	provisioner accountProvisioner
+	db          *gorm.DB
+	cfg         config.Config
 }
 
 // NewStudentService creates a new StudentService
-func NewStudentService(repo repository.StudentRepository, userRepo repository.UserRepository, db *gorm.DB) StudentService {
-	return &studentService{repo: repo, userRepo: userRepo, db: db}
+func NewStudentService(
+	repo repository.StudentRepository,
+	userRepo repository.UserRepository,
+	roles RoleService,
+	db *gorm.DB,
+	cfg config.Config,
+) StudentService {
+	return &studentService{
+		repo:        repo,
+		userRepo:    userRepo,
+		roles:       roles,
+		provisioner: accountProvisioner{roles: roles, cfg: cfg},
+		db:          db,
+		cfg:         cfg,
+	}
+}
+
+// WithScope returns the service limited to the students of the scope's department
+func (s *studentService) WithScope(scope repository.Scope) StudentService {
+	scoped := *s
+	scoped.repo = s.repo.WithScope(scope)
+	scoped.userRepo = s.userRepo.WithScope(scope)
+	return &scoped
+}
+
+// readRepo limits student listings to those enrolled in the offerings the caller teaches
+// unless it holds student:read
+func (s *studentService) readRepo(role string, userID uint) (repository.StudentRepository, error) {
+	teaching, err := readScope(s.roles, s.userRepo, s.cfg, role, userID, model.PermStudentRead, model.PermStudentReadOwnCourse)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.WithTeaching(teaching), nil
 }
 
-func (s *studentService) List(studentNo, name, deptNo string) ([]repository.StudentWithDept, error) {
-	items, err := s.repo.FindAll(studentNo, name, deptNo)
+func (s *studentService) List(studentNo, name, deptNo, role string, userID uint) ([]repository.StudentWithDept, error) {
+	repo, err := s.readRepo(role, userID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := repo.FindAll(studentNo, name, deptNo)
 	if err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
 	return items, nil
 }
 
-func (s *studentService) ListPaginated(studentNo, name, deptNo string, page, pageSize int) (*StudentListResult, error) {
+func (s *studentService) ListPaginated(studentNo, name, deptNo string, page, pageSize int, role string, userID uint) (*StudentListResult, error) {
+	repo, err := s.readRepo(role, userID)
+	if err != nil {
+		return nil, err
+	}
 	if page <= 0 {
 		page = 1
 	}
@@ -67,7 +113,7 @@ func (s *studentService) ListPaginated(studentNo, name, deptNo string, page, pag
 		PageSize:  pageSize,
 	}
 
-	items, total, err := s.repo.FindAllPaginated(params)
+	items, total, err := repo.FindAllPaginated(params)
 	if err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
 	}
@@ -80,6 +126,26 @@ func (s *studentService) ListPaginated(studentNo, name, deptNo string, page, pag
 	}, nil
 }
 
+var studentExportHeader = []string{"学号", "姓名", "性别", "出生日期", "入学成绩", "学籍状态", "院系号", "院系名称"}
+
+// Export writes every student matching the list filters
+func (s *studentService) Export(w export.Writer, studentNo, name, deptNo, role string, userID uint) error {
+	repo, err := s.readRepo(role, userID)
+	if err != nil {
+		return err
+	}
+	if err := w.Sheet("学生", studentExportHeader); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	if err := repo.EachWithDept(studentNo, name, deptNo, func(st *repository.StudentWithDept) error {
+		return w.Row(st.StudentNo, st.Name, st.Gender, st.BirthDate, st.EntryScore,
+			studentStatusLabel(st.Status), st.DeptNo, st.DeptName)
+	}); err != nil {
+		return pkg.WrapError(pkg.ErrCodeRenderFailed, "export failed", err)
+	}
+	return nil
+}
+
 func (s *studentService) GetByID(id uint) (*model.Student, error) {
 	student, err := s.repo.FindByID(id)
 	if err != nil {
@@ -107,15 +173,37 @@ func (s *studentService) GetMyInfo(userID uint) (*repository.StudentWithDept, er
 	return &items[0], nil
 }
 
-func (s *studentService) Create(student *model.Student) error {
+// Create adds the student and, when asked, their account named by the student number.
+// Both are created or neither.
+func (s *studentService) Create(student *model.Student, account AccountOption, actor Actor) (*ProvisionedAccount, error) {
 	if student.StudentNo == "" || student.Name == "" || student.DeptID == 0 {
-		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no/name/dept_id required")
+		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no/name/dept_id required")
 	}
 	student.ID = 0
-	if err := s.repo.Create(student); err != nil {
-		return pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+	if !account.Provision {
+		if err := s.repo.Create(student); err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+		}
+		return nil, nil
 	}
-	return nil
+
+	if err := s.provisioner.check(actor, model.RoleStudent); err != nil {
+		return nil, err
+	}
+	var created *ProvisionedAccount
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.repo.WithTx(tx).Create(student); err != nil {
+			return pkg.WrapError(pkg.ErrCodeCreateFailed, "create failed", err)
+		}
+		var err error
+		created, err = s.provisioner.student(s.userRepo.WithTx(tx), student)
+		return err
+	})
+	if err != nil {
+		student.ID = 0
+		return nil, err
+	}
+	return created, nil
 }
 
 func (s *studentService) Update(id uint, input *model.Student) (*model.Student, error) {
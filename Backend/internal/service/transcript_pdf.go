@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/lin-snow/edumgr/internal/pkg/fonts"
+)
+
+// transcriptColumn is one column of the course table
+type transcriptColumn struct {
+	title string
+	width float64
+	align string
+}
+
+var transcriptColumns = []transcriptColumn{
+	{"课程号", 24, "L"},
+	{"课程名称", 62, "L"},
+	{"学分", 14, "C"},
+	{"平时", 18, "C"},
+	{"考试", 18, "C"},
+	{"总评", 18, "C"},
+	{"绩点", 16, "C"},
+	{"备注", 20, "C"},
+}
+
+var studentStatusLabels = map[string]string{
+	"in_school":    "在读",
+	"graduated":    "毕业",
+	"transfer_out": "转出",
+	"transfer_in":  "转入",
+}
+
+// studentStatusLabel returns the Chinese label of a student status, or the status itself
+func studentStatusLabel(status string) string {
+	if label, ok := studentStatusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+const (
+	transcriptRowH    = 6.5
+	transcriptMarginB = 18
+)
+
+// renderTranscriptPDF lays a transcript out on A4 pages: student header, one table per
+// term with its GPA line, the cumulative summary, and a footer carrying the verification code.
+func renderTranscriptPDF(t *Transcript) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fonts.CJKFamily, "", fonts.CJK)
+	pdf.SetMargins(10, 12, 10)
+	pdf.SetAutoPageBreak(true, transcriptMarginB)
+	pdf.AliasNbPages("{nb}")
+	pdf.SetTitle("Transcript "+t.StudentNo, true)
+	pdf.SetCreator("EduMgr", true)
+
+	issued := t.IssuedAt.Format("2006-01-02 15:04")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(fonts.CJKFamily, "", 8)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(95, 5, "验证码 "+t.VerificationCode+"    出具时间 "+issued, "", 0, "L", false, 0, "")
+		pdf.CellFormat(95, 5, fmt.Sprintf("第 %d / {nb} 页", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	pdf.AddPage()
+	pdf.SetFont(fonts.CJKFamily, "", 18)
+	pdf.CellFormat(0, 10, "学生成绩单", "", 1, "C", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.CellFormat(0, 5, "Academic Transcript", "", 1, "C", false, 0, "")
+	pdf.Ln(3)
+
+	status := studentStatusLabel(t.Status)
+	pdf.SetFont(fonts.CJKFamily, "", 10)
+	info := [][2]string{
+		{"学号", t.StudentNo}, {"姓名", t.StudentName}, {"性别", t.Gender},
+		{"院系", t.DeptNo + " " + t.DeptName}, {"学籍状态", status}, {"绩点制", t.Scale},
+	}
+	for i, kv := range info {
+		pdf.CellFormat(18, 7, kv[0]+"：", "", 0, "L", false, 0, "")
+		ln := 0
+		if i%3 == 2 {
+			ln = 1
+		}
+		pdf.CellFormat(45.3, 7, kv[1], "", ln, "L", false, 0, "")
+	}
+	pdf.Ln(2)
+
+	if len(t.Terms) == 0 {
+		pdf.CellFormat(0, 10, "暂无成绩记录", "", 1, "C", false, 0, "")
+	}
+	for _, term := range t.Terms {
+		// Keep the term heading together with its table header and first row
+		ensureSpace(pdf, transcriptRowH*3+2)
+		pdf.Ln(2)
+		pdf.SetFont(fonts.CJKFamily, "", 11)
+		title := term.TermCode
+		if term.TermName != "" && term.TermName != term.TermCode {
+			title += "  " + term.TermName
+		}
+		pdf.CellFormat(0, 7, title, "", 1, "L", false, 0, "")
+		drawTranscriptHeader(pdf)
+
+		pdf.SetFont(fonts.CJKFamily, "", 9)
+		for _, c := range term.Courses {
+			if ensureSpace(pdf, transcriptRowH) {
+				drawTranscriptHeader(pdf)
+				pdf.SetFont(fonts.CJKFamily, "", 9)
+			}
+			cells := []string{
+				c.CourseNo, c.CourseName, strconv.Itoa(c.Credits),
+				fmtScore(c.UsualScore), fmtScore(c.ExamScore), fmtScore(c.FinalScore),
+				fmtPoints(c.Points), courseRemark(c),
+			}
+			for i, col := range transcriptColumns {
+				pdf.CellFormat(col.width, transcriptRowH, fitText(pdf, cells[i], col.width-2), "1", 0, col.align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+
+		pdf.SetFont(fonts.CJKFamily, "", 9)
+		pdf.CellFormat(0, 6, fmt.Sprintf("本学期 GPA %.2f    平均分 %.2f    获得/修读学分 %d/%d    不及格 %d 门",
+			term.Totals.GPA, term.Totals.AverageScore, term.Totals.EarnedCredits,
+			term.Totals.AttemptedCredits, term.Totals.FailedCount), "", 1, "R", false, 0, "")
+	}
+
+	ensureSpace(pdf, 24)
+	pdf.Ln(4)
+	pdf.SetFont(fonts.CJKFamily, "", 11)
+	pdf.CellFormat(0, 7, "累计", "B", 1, "L", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 10)
+	cum := t.Cumulative
+	pdf.CellFormat(0, 7, fmt.Sprintf("GPA %.2f / %.1f    加权平均分 %.2f    获得学分 %d    修读学分 %d    在修学分 %d    未通过课程 %d 门",
+		cum.GPA, t.ScaleMax, cum.AverageScore, cum.EarnedCredits, cum.AttemptedCredits,
+		cum.InProgressCredits, cum.FailedCount), "", 1, "L", false, 0, "")
+	pdf.SetFont(fonts.CJKFamily, "", 8)
+	pdf.CellFormat(0, 6, "重修课程按“"+t.Policy+"”规则计入 GPA；备注“不计”的修读不计入学分与绩点。", "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "本成绩单经电子签名，可凭验证码通过 /verify/"+t.VerificationCode+" 核验真伪。", "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawTranscriptHeader(pdf *fpdf.Fpdf) {
+	pdf.SetFont(fonts.CJKFamily, "", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range transcriptColumns {
+		pdf.CellFormat(col.width, transcriptRowH, col.title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// ensureSpace starts a new page when fewer than h millimetres remain; it reports whether it did
+func ensureSpace(pdf *fpdf.Fpdf, h float64) bool {
+	_, pageH := pdf.GetPageSize()
+	if pdf.GetY()+h <= pageH-transcriptMarginB {
+		return false
+	}
+	pdf.AddPage()
+	return true
+}
+
+// fitText shortens s with an ellipsis so it fits into width millimetres
+func fitText(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdf.GetStringWidth(string(r)+"…") > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+func fmtScore(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func fmtPoints(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+func courseRemark(c TranscriptCourse) string {
+	switch {
+	case c.FinalScore == nil:
+		return "在修"
+	case !c.Counted:
+		return "不计"
+	case c.Attempt > 1:
+		return "重修"
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/pkg"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// TranscriptCourse is one course attempt on a transcript
+type TranscriptCourse struct {
+	CourseNo   string   `json:"course_no"`
+	CourseName string   `json:"course_name"`
+	SectionNo  string   `json:"section_no"`
+	Credits    int      `json:"credits"`
+	Attempt    int      `json:"attempt"`
+	Counted    bool     `json:"counted"`
+	UsualScore *float64 `json:"usual_score"`
+	ExamScore  *float64 `json:"exam_score"`
+	FinalScore *float64 `json:"final_score"`
+	Points     *float64 `json:"points"`
+}
+
+// TranscriptTerm groups the courses of one term with its GPA figures
+type TranscriptTerm struct {
+	TermCode string             `json:"term_code"`
+	TermName string             `json:"term_name"`
+	Courses  []TranscriptCourse `json:"courses"`
+	Totals   GPATotals          `json:"totals"`
+}
+
+// Transcript is a student's full academic record. It is the signed subject of a
+// transcript issuance; the issue time and code live in the signed envelope.
+type Transcript struct {
+	StudentNo        string           `json:"student_no"`
+	StudentName      string           `json:"student_name"`
+	Gender           string           `json:"gender"`
+	DeptNo           string           `json:"dept_no"`
+	DeptName         string           `json:"dept_name"`
+	Status           string           `json:"status"`
+	Scale            string           `json:"scale"`
+	ScaleMax         float64          `json:"scale_max"`
+	Policy           string           `json:"attempt_policy"`
+	Terms            []TranscriptTerm `json:"terms"`
+	Cumulative       GPATotals        `json:"cumulative"`
+	IssuedAt         time.Time        `json:"-"`
+	VerificationCode string           `json:"-"`
+}
+
+// TranscriptService defines the interface for transcript generation
+type TranscriptService interface {
+	MyTranscriptPDF(userID uint, scale string) ([]byte, *Transcript, error)
+	StudentTranscriptPDF(studentNo, scale string, issuedBy uint) ([]byte, *Transcript, error)
+	WithScope(scope repository.Scope) TranscriptService
+}
+
+type transcriptService struct {
+	gradeRepo   repository.GradeRepository
+	studentRepo repository.StudentRepository
+	userRepo    repository.UserRepository
+	issuer      *DocumentIssuer
+	cfg         config.Config
+}
+
+// NewTranscriptService creates a new TranscriptService
+func NewTranscriptService(
+	gradeRepo repository.GradeRepository,
+	studentRepo repository.StudentRepository,
+	userRepo repository.UserRepository,
+	issuer *DocumentIssuer,
+	cfg config.Config,
+) TranscriptService {
+	return &transcriptService{gradeRepo: gradeRepo, studentRepo: studentRepo, userRepo: userRepo, issuer: issuer, cfg: cfg}
+}
+
+// WithScope returns the service limited to the students of the scope's department
+func (s *transcriptService) WithScope(scope repository.Scope) TranscriptService {
+	scoped := *s
+	scoped.studentRepo = s.studentRepo.WithScope(scope)
+	return &scoped
+}
+
+func (s *transcriptService) MyTranscriptPDF(userID uint, scale string) ([]byte, *Transcript, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user.StudentID == nil {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeStudentNotBound, "student not bound")
+	}
+	return s.render(*user.StudentID, scale, userID)
+}
+
+func (s *transcriptService) StudentTranscriptPDF(studentNo, scale string, issuedBy uint) ([]byte, *Transcript, error) {
+	if studentNo == "" {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "student_no required")
+	}
+	student, err := s.studentRepo.FindByStudentNo(studentNo)
+	if err != nil {
+		return nil, nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	return s.render(student.ID, scale, issuedBy)
+}
+
+// render signs and records the transcript before drawing it, so the printed code
+// always refers to an issuance that can be verified
+func (s *transcriptService) render(studentID uint, scale string, issuedBy uint) ([]byte, *Transcript, error) {
+	t, err := s.build(studentID, scale)
+	if err != nil {
+		return nil, nil, err
+	}
+	issuance, err := s.issuer.Issue(model.DocTypeTranscript, studentID, issuedBy, t)
+	if err != nil {
+		return nil, nil, pkg.WrapError(pkg.ErrCodeIssueFailed, "issue failed", err)
+	}
+	t.VerificationCode = FormatDocumentCode(issuance.Code)
+	t.IssuedAt = issuance.IssuedAt
+	data, err := renderTranscriptPDF(t)
+	if err != nil {
+		return nil, nil, pkg.WrapError(pkg.ErrCodeRenderFailed, "render transcript failed", err)
+	}
+	return data, t, nil
+}
+
+// build assembles the transcript of a student from their grade rows
+func (s *transcriptService) build(studentID uint, scaleName string) (*Transcript, error) {
+	if scaleName == "" {
+		scaleName = s.cfg.GPAScale
+	}
+	scale, ok := gpaScales[scaleName]
+	if !ok {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidGPAScale,
+			"unknown gpa scale, expected one of: "+strings.Join(GPAScaleNames(), ", "))
+	}
+
+	student, err := s.studentRepo.FindWithDeptByID(studentID)
+	if err != nil {
+		return nil, pkg.NewAppError(pkg.ErrCodeStudentNotFound, "student not found")
+	}
+	rows, err := s.gradeRepo.FindByStudentID(studentID)
+	if err != nil {
+		return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	// Issued transcripts only carry published grades
+	rows = publishedGrades(rows)
+
+	summary, counted := buildGPASummary(&student.Student, rows, scale, s.cfg)
+
+	// Oldest term first, courses by number within a term
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].TermCode != rows[j].TermCode {
+			return termAfter(rows[j].TermStart, rows[j].TermCode, rows[i].TermStart, rows[i].TermCode)
+		}
+		return rows[i].CourseNo < rows[j].CourseNo
+	})
+
+	totals := make(map[string]GPATotals, len(summary.Terms))
+	for _, t := range summary.Terms {
+		totals[t.TermCode] = t.GPATotals
+	}
+
+	t := &Transcript{
+		StudentNo:   student.StudentNo,
+		StudentName: student.Name,
+		Gender:      student.Gender,
+		DeptNo:      student.DeptNo,
+		DeptName:    student.DeptName,
+		Status:      student.Status,
+		Scale:       summary.Scale,
+		ScaleMax:    summary.ScaleMax,
+		Policy:      summary.Policy,
+		Cumulative:  summary.Cumulative,
+	}
+	seq := make(map[uint]int)
+	for _, r := range rows {
+		if len(t.Terms) == 0 || t.Terms[len(t.Terms)-1].TermCode != r.TermCode {
+			t.Terms = append(t.Terms, TranscriptTerm{
+				TermCode: r.TermCode,
+				TermName: r.TermName,
+				Totals:   totals[r.TermCode],
+			})
+		}
+		seq[r.CourseID]++
+		course := TranscriptCourse{
+			CourseNo:   r.CourseNo,
+			CourseName: r.CourseName,
+			SectionNo:  r.SectionNo,
+			Credits:    r.Credits,
+			Attempt:    seq[r.CourseID],
+			Counted:    counted[attemptKey{studentID, r.OfferingID}],
+			UsualScore: r.UsualScore,
+			ExamScore:  r.ExamScore,
+			FinalScore: r.FinalScore,
+		}
+		if r.FinalScore != nil {
+			p := scale.Points(*r.FinalScore)
+			course.Points = &p
+		}
+		term := &t.Terms[len(t.Terms)-1]
+		term.Courses = append(term.Courses, course)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+func (r *memStudentRepo) FindWithDeptByID(id uint) (*repository.StudentWithDept, error) {
+	for i := range r.students {
+		if r.students[i].ID == id {
+			return &repository.StudentWithDept{Student: r.students[i]}, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// memGradeRepo holds the grade rows of one student
+type memGradeRepo struct {
+	repository.GradeRepository
+	rows []repository.StudentGradeRow
+}
+
+func (r *memGradeRepo) FindByStudentID(studentID uint) ([]repository.StudentGradeRow, error) {
+	return append([]repository.StudentGradeRow(nil), r.rows...), nil
+}
+
+func TestTranscriptTermOrder(t *testing.T) {
+	// Newest term first, as the repository returns them; course 7 failed in spring and
+	// was retaken in fall
+	rows := []repository.StudentGradeRow{
+		{CourseID: 7, OfferingID: 3, CourseNo: "C07", Credits: 3, TermCode: "2024-FALL", TermStart: fall2024, FinalScore: score(75), Published: true},
+		{CourseID: 8, OfferingID: 4, CourseNo: "C08", Credits: 2, TermCode: "2024-FALL", TermStart: fall2024, FinalScore: score(90), Published: true},
+		{CourseID: 7, OfferingID: 2, CourseNo: "C07", Credits: 3, TermCode: "2024-SPRING", TermStart: spring2024, FinalScore: score(50), Published: true},
+		{CourseID: 9, OfferingID: 1, CourseNo: "C09", Credits: 2, TermCode: "2023-FALL", TermStart: fall2023, FinalScore: score(80), Published: true},
+	}
+	s := &transcriptService{
+		gradeRepo:   &memGradeRepo{rows: rows},
+		studentRepo: &memStudentRepo{students: []model.Student{{ID: 1, StudentNo: "2023001"}}},
+		cfg:         config.Config{GPAScale: GPAScale4, GradePassScore: 60, GradeAttemptPolicy: AttemptPolicyLatest},
+	}
+	tr, err := s.build(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type line struct {
+		Term    string
+		Course  string
+		Attempt int
+		Counted bool
+	}
+	var got []line
+	for _, term := range tr.Terms {
+		for _, c := range term.Courses {
+			got = append(got, line{term.TermCode, c.CourseNo, c.Attempt, c.Counted})
+		}
+	}
+	want := []line{
+		{"2023-FALL", "C09", 1, true},
+		{"2024-SPRING", "C07", 1, false},
+		{"2024-FALL", "C07", 2, true},
+		{"2024-FALL", "C08", 1, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transcript lines = %+v, want %+v", got, want)
+	}
+	// Per-term totals follow the same terms
+	if tr.Terms[1].Totals.FailedCount != 1 || tr.Terms[2].Totals.GPACredits != 5 {
+		t.Errorf("term totals = %+v, %+v", tr.Terms[1].Totals, tr.Terms[2].Totals)
+	}
+}
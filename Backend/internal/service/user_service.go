@@ -1,8 +1,11 @@
 package service
 
 import (
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/lin-snow/edumgr/internal/config"
 	"github.com/lin-snow/edumgr/internal/model"
 	"github.com/lin-snow/edumgr/internal/pkg"
 	"github.com/lin-snow/edumgr/internal/repository"
@@ -15,34 +18,88 @@ type CreateUserRequest struct {
 	Role      string `json:"role"`
 	StudentID *uint  `json:"student_id,omitempty"`
 	StaffID   *uint  `json:"staff_id,omitempty"`
+	DeptID    *uint  `json:"dept_id,omitempty"`
 }
 
 // UserInfo represents user information
 type UserInfo struct {
-	ID        uint   `json:"id"`
-	Username  string `json:"username"`
-	Role      string `json:"role"`
-	StudentID *uint  `json:"student_id,omitempty"`
-	StaffID   *uint  `json:"staff_id,omitempty"`
+	ID                 uint   `json:"id"`
+	Username           string `json:"username"`
+	Role               string `json:"role"`
+	StudentID          *uint  `json:"student_id,omitempty"`
+	StaffID            *uint  `json:"staff_id,omitempty"`
+	DeptID             *uint  `json:"dept_id,omitempty"`
+	MustChangePassword bool   `json:"must_change_password"`
 }
 
-// UserService defines the interface for user management business logic
+// UserService defines the interface for user management business logic.
+// The actor may only grant, and manage the holders of, roles within their own permissions.
 type UserService interface {
 	List() ([]UserInfo, error)
 	GetByID(id uint) (*UserInfo, error)
-	Create(req CreateUserRequest) (*UserInfo, error)
-	Update(id uint, req CreateUserRequest) (*UserInfo, error)
-	Delete(id uint) error
-	ResetPassword(id uint, newPassword string) error
+	Create(req CreateUserRequest, actor Actor) (*UserInfo, error)
+	Update(id uint, req CreateUserRequest, actor Actor) (*UserInfo, error)
+	Delete(id uint, actor Actor) error
+	ResetPassword(id uint, newPassword string, actor Actor) error
+	Unlock(id uint) error
+	ResetMFA(id uint, actor Actor) error
+	WithScope(scope repository.Scope) UserService
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo      repository.UserRepository
+	sessionRepo   repository.SessionRepository
+	mfaRepo       repository.MFARepository
+	throttleStore repository.LoginThrottleStore
+	roles         RoleService
+	cfg           config.Config
 }
 
 // NewUserService creates a new UserService
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	mfaRepo repository.MFARepository,
+	throttleStore repository.LoginThrottleStore,
+	roles RoleService,
+	cfg config.Config,
+) UserService {
+	return &userService{
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		mfaRepo:       mfaRepo,
+		throttleStore: throttleStore,
+		roles:         roles,
+		cfg:           cfg,
+	}
+}
+
+// WithScope returns the service limited to the accounts of the scope's department
+func (s *userService) WithScope(scope repository.Scope) UserService {
+	scoped := *s
+	scoped.userRepo = s.userRepo.WithScope(scope)
+	return &scoped
+}
+
+// checkGrant refuses to let the actor hand out, or manage a holder of, a role that
+// holds more than the actor does
+func (s *userService) checkGrant(actor Actor, role string) error {
+	if !s.roles.Grants(actor.Role, role) {
+		return pkg.NewAppError(pkg.ErrCodeForbidden, "cannot manage users of role "+role)
+	}
+	return nil
+}
+
+func userInfo(u *model.User) UserInfo {
+	return UserInfo{
+		ID:                 u.ID,
+		Username:           u.Username,
+		Role:               u.Role,
+		StudentID:          u.StudentID,
+		StaffID:            u.StaffID,
+		DeptID:             u.DeptID,
+		MustChangePassword: u.MustChangePassword,
+	}
 }
 
 func (s *userService) List() ([]UserInfo, error) {
@@ -53,13 +110,7 @@ func (s *userService) List() ([]UserInfo, error) {
 
 	result := make([]UserInfo, len(users))
 	for i, u := range users {
-		result[i] = UserInfo{
-			ID:        u.ID,
-			Username:  u.Username,
-			Role:      u.Role,
-			StudentID: u.StudentID,
-			StaffID:   u.StaffID,
-		}
+		result[i] = userInfo(&u)
 	}
 	return result, nil
 }
@@ -70,23 +121,23 @@ func (s *userService) GetByID(id uint) (*UserInfo, error) {
 		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "user not found", err)
 	}
 
-	return &UserInfo{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		StudentID: user.StudentID,
-		StaffID:   user.StaffID,
-	}, nil
+	info := userInfo(user)
+	return &info, nil
 }
 
-func (s *userService) Create(req CreateUserRequest) (*UserInfo, error) {
+func (s *userService) Create(req CreateUserRequest, actor Actor) (*UserInfo, error) {
 	if req.Username == "" || req.Password == "" || req.Role == "" {
 		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "username/password/role required")
 	}
 
-	// Validate role
-	if req.Role != "admin" && req.Role != "teacher" && req.Role != "student" {
-		return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "role must be admin/teacher/student")
+	if !s.roles.Exists(req.Role) {
+		return nil, pkg.NewAppError(pkg.ErrCodeInvalidRole, "unknown role: "+req.Role)
+	}
+	if err := s.checkGrant(actor, req.Role); err != nil {
+		return nil, err
+	}
+	if err := checkPassword(s.cfg, req.Username, req.Password); err != nil {
+		return nil, err
 	}
 
 	// Hash password
@@ -95,66 +146,86 @@ func (s *userService) Create(req CreateUserRequest) (*UserInfo, error) {
 		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "hash password failed", err)
 	}
 
+	// The password was chosen by an admin, so the user replaces it at first login
 	user := &model.User{
-		Username:     req.Username,
-		PasswordHash: string(hash),
-		Role:         req.Role,
-		StudentID:    req.StudentID,
-		StaffID:      req.StaffID,
+		Username:           req.Username,
+		PasswordHash:       string(hash),
+		Role:               req.Role,
+		StudentID:          req.StudentID,
+		StaffID:            req.StaffID,
+		DeptID:             req.DeptID,
+		MustChangePassword: true,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeCreateFailed, "create user failed", err)
 	}
 
-	return &UserInfo{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		StudentID: user.StudentID,
-		StaffID:   user.StaffID,
-	}, nil
+	info := userInfo(user)
+	return &info, nil
 }
 
-func (s *userService) Update(id uint, req CreateUserRequest) (*UserInfo, error) {
+func (s *userService) Update(id uint, req CreateUserRequest, actor Actor) (*UserInfo, error) {
 	user, err := s.userRepo.FindByID(id)
 	if err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeNotFound, "user not found", err)
 	}
+	if err := s.checkGrant(actor, user.Role); err != nil {
+		return nil, err
+	}
 
 	if req.Username != "" {
 		user.Username = req.Username
 	}
+	roleChanged := req.Role != "" && req.Role != user.Role
 	if req.Role != "" {
-		if req.Role != "admin" && req.Role != "teacher" && req.Role != "student" {
-			return nil, pkg.NewAppError(pkg.ErrCodeMissingRequired, "role must be admin/teacher/student")
+		if !s.roles.Exists(req.Role) {
+			return nil, pkg.NewAppError(pkg.ErrCodeInvalidRole, "unknown role: "+req.Role)
+		}
+		if err := s.checkGrant(actor, req.Role); err != nil {
+			return nil, err
 		}
 		user.Role = req.Role
 	}
 	user.StudentID = req.StudentID
 	user.StaffID = req.StaffID
+	deptChanged := scopeDept(user.DeptID) != scopeDept(req.DeptID)
+	user.DeptID = req.DeptID
 
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, pkg.WrapError(pkg.ErrCodeUpdateFailed, "update user failed", err)
 	}
+	// Access tokens carry the role and department, so sessions opened under the old ones end
+	if roleChanged || deptChanged {
+		reason := model.SessionRoleChanged
+		if !roleChanged {
+			reason = model.SessionScopeChanged
+		}
+		if _, err := s.sessionRepo.RevokeByUser(user.ID, reason, time.Now()); err != nil {
+			return nil, pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+		}
+	}
 
-	return &UserInfo{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		StudentID: user.StudentID,
-		StaffID:   user.StaffID,
-	}, nil
+	info := userInfo(user)
+	return &info, nil
 }
 
-func (s *userService) Delete(id uint) error {
+// Delete removes a user; their sessions go with them, which rejects their access tokens
+func (s *userService) Delete(id uint, actor Actor) error {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeNotFound, "user not found", err)
+	}
+	if err := s.checkGrant(actor, user.Role); err != nil {
+		return err
+	}
 	if err := s.userRepo.Delete(id); err != nil {
 		return pkg.WrapError(pkg.ErrCodeDeleteFailed, "delete user failed", err)
 	}
 	return nil
 }
 
-func (s *userService) ResetPassword(id uint, newPassword string) error {
+func (s *userService) ResetPassword(id uint, newPassword string, actor Actor) error {
 	if newPassword == "" {
 		return pkg.NewAppError(pkg.ErrCodeMissingRequired, "password required")
 	}
@@ -163,6 +234,12 @@ func (s *userService) ResetPassword(id uint, newPassword string) error {
 	if err != nil {
 		return pkg.WrapError(pkg.ErrCodeNotFound, "user not found", err)
 	}
+	if err := s.checkGrant(actor, user.Role); err != nil {
+		return err
+	}
+	if err := checkPassword(s.cfg, user.Username, newPassword); err != nil {
+		return err
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -170,8 +247,41 @@ func (s *userService) ResetPassword(id uint, newPassword string) error {
 	}
 
 	user.PasswordHash = string(hash)
+	user.MustChangePassword = true
 	if err := s.userRepo.Update(user); err != nil {
 		return pkg.WrapError(pkg.ErrCodeUpdateFailed, "update password failed", err)
 	}
+	if _, err := s.sessionRepo.RevokeByUser(user.ID, model.SessionPasswordReset, time.Now()); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return nil
+}
+
+// Unlock clears the failed logins and any lockout of the user's username.
+// Blocks on the client IP are left to expire.
+func (s *userService) Unlock(id uint) error {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeNotFound, "user not found", err)
+	}
+	if err := s.throttleStore.Reset(userThrottleKey(user.Username)); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
+	return nil
+}
+
+// ResetMFA removes the two-factor enrollment of a user who lost their device. Their
+// next login needs the password only; roles that require it enroll again right after.
+func (s *userService) ResetMFA(id uint, actor Actor) error {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return pkg.WrapError(pkg.ErrCodeNotFound, "user not found", err)
+	}
+	if err := s.checkGrant(actor, user.Role); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(id); err != nil {
+		return pkg.WrapError(pkg.ErrCodeDBError, "database error", err)
+	}
 	return nil
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/config"
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// waitlistPromoter moves queued students into the free seats of an offering. Its
+// repositories are never scoped: the queue and the seats of an offering span every
+// department, whoever frees the seat.
+type waitlistPromoter struct {
+	enrollRepo   repository.EnrollmentRepository
+	waitlistRepo repository.WaitlistRepository
+	offeringRepo repository.OfferingRepository
+	cfg          config.Config
+}
+
+// fill promotes queued students until the offering is full or nobody left in the
+// queue fits
+func (p waitlistPromoter) fill(tx *gorm.DB, offeringID uint) error {
+	for {
+		promoted, err := p.promote(tx, offeringID)
+		if err != nil || !promoted {
+			return err
+		}
+	}
+}
+
+// promote enrolls the first queued student who still fits the credit limit and
+// timetable, and reports whether there was a seat and such a student. Students who no
+// longer fit keep their place for a later seat.
+func (p waitlistPromoter) promote(tx *gorm.DB, offeringID uint) (bool, error) {
+	txEnrollRepo := p.enrollRepo.WithTx(tx)
+	txWaitlistRepo := p.waitlistRepo.WithTx(tx)
+	txOfferingRepo := p.offeringRepo.WithTx(tx)
+
+	seats, err := txEnrollRepo.LockOfferingSeats([]uint{offeringID})
+	if err != nil || len(seats) == 0 {
+		return false, err
+	}
+	seat := seats[0]
+	taken, err := txEnrollRepo.CountByOfferings([]uint{offeringID})
+	if err != nil {
+		return false, err
+	}
+	if seat.Capacity > 0 && taken[offeringID] >= int64(seat.Capacity) {
+		return false, nil
+	}
+
+	queue, err := txWaitlistRepo.FindQueue(offeringID)
+	if err != nil || len(queue) == 0 {
+		return false, err
+	}
+	offering, err := txOfferingRepo.FindByID(offeringID)
+	if err != nil {
+		return false, err
+	}
+	meetings, err := txOfferingRepo.FindMeetingRowsByOfferingIDs([]uint{offeringID})
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range queue {
+		dupCnt, err := txEnrollRepo.CountDuplicates(entry.StudentID, []uint{seat.CourseID}, p.cfg.GradePassScore)
+		if err != nil {
+			return false, err
+		}
+		if dupCnt > 0 {
+			continue
+		}
+		credits, err := txEnrollRepo.GetCurrentCredits(entry.StudentID, offering.TermID)
+		if err != nil {
+			return false, err
+		}
+		if credits+seat.Credits > MaxCreditsPerTerm {
+			continue
+		}
+		existing, err := txEnrollRepo.FindMeetingsByStudentAndTerm(entry.StudentID, offering.TermID)
+		if err != nil {
+			return false, err
+		}
+		if len(findScheduleConflicts(meetings, existing)) > 0 {
+			continue
+		}
+
+		if err := txEnrollRepo.CreateBatch([]model.Enrollment{{
+			StudentID:  entry.StudentID,
+			OfferingID: offeringID,
+		}}); err != nil {
+			return false, err
+		}
+		return true, txWaitlistRepo.Delete(entry.ID)
+	}
+	return false, nil
+}
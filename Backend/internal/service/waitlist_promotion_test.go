@@ -0,0 +1,121 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/lin-snow/edumgr/internal/model"
+	"github.com/lin-snow/edumgr/internal/repository"
+)
+
+// memSeats holds the enrollments and queue of one offering
+type memSeats struct {
+	repository.EnrollmentRepository
+	capacity int
+	enrolled []uint
+	// full lists students already at the credit limit
+	full map[uint]bool
+}
+
+func (r *memSeats) WithTx(tx *gorm.DB) repository.EnrollmentRepository { return r }
+
+func (r *memSeats) LockOfferingSeats(offeringIDs []uint) ([]repository.OfferingSeat, error) {
+	return []repository.OfferingSeat{{ID: offeringIDs[0], CourseID: 7, Credits: 3, Capacity: r.capacity}}, nil
+}
+
+func (r *memSeats) CountByOfferings(offeringIDs []uint) (map[uint]int64, error) {
+	return map[uint]int64{offeringIDs[0]: int64(len(r.enrolled))}, nil
+}
+
+func (r *memSeats) CountDuplicates(studentID uint, courseIDs []uint, passScore float64) (int64, error) {
+	return 0, nil
+}
+
+func (r *memSeats) GetCurrentCredits(studentID, termID uint) (int, error) {
+	if r.full[studentID] {
+		return MaxCreditsPerTerm, nil
+	}
+	return 0, nil
+}
+
+func (r *memSeats) FindMeetingsByStudentAndTerm(studentID, termID uint) ([]repository.OfferingMeetingRow, error) {
+	return nil, nil
+}
+
+func (r *memSeats) CreateBatch(enrollments []model.Enrollment) error {
+	for _, e := range enrollments {
+		r.enrolled = append(r.enrolled, e.StudentID)
+	}
+	return nil
+}
+
+type memQueue struct {
+	repository.WaitlistRepository
+	entries []model.WaitlistEntry
+}
+
+func (r *memQueue) WithTx(tx *gorm.DB) repository.WaitlistRepository { return r }
+
+func (r *memQueue) FindQueue(offeringID uint) ([]model.WaitlistEntry, error) {
+	return append([]model.WaitlistEntry(nil), r.entries...), nil
+}
+
+func (r *memQueue) Delete(id uint) error {
+	for i, e := range r.entries {
+		if e.ID == id {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+type memOfferings struct {
+	repository.OfferingRepository
+}
+
+func (memOfferings) WithTx(tx *gorm.DB) repository.OfferingRepository { return memOfferings{} }
+
+func (memOfferings) FindByID(id uint) (*model.CourseOffering, error) {
+	return &model.CourseOffering{ID: id, CourseID: 7, TermID: 1}, nil
+}
+
+func (memOfferings) FindMeetingRowsByOfferingIDs(offeringIDs []uint) ([]repository.OfferingMeetingRow, error) {
+	return nil, nil
+}
+
+func TestWaitlistFill(t *testing.T) {
+	queue := func() *memQueue {
+		return &memQueue{entries: []model.WaitlistEntry{
+			{ID: 1, StudentID: 11, OfferingID: 5},
+			{ID: 2, StudentID: 12, OfferingID: 5},
+			{ID: 3, StudentID: 13, OfferingID: 5},
+			{ID: 4, StudentID: 14, OfferingID: 5},
+		}}
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		full     map[uint]bool
+		want     []uint
+		left     int
+	}{
+		{"raised by two", 3, nil, []uint{10, 11, 12}, 2},
+		{"skips students over the credit limit", 3, map[uint]bool{11: true}, []uint{10, 12, 13}, 2},
+		{"unlimited takes the whole queue", 0, nil, []uint{10, 11, 12, 13, 14}, 0},
+		{"still full", 1, nil, []uint{10}, 4},
+	}
+	for _, tt := range tests {
+		seats := &memSeats{capacity: tt.capacity, enrolled: []uint{10}, full: tt.full}
+		waitlist := queue()
+		p := waitlistPromoter{enrollRepo: seats, waitlistRepo: waitlist, offeringRepo: memOfferings{}}
+		if err := p.fill(nil, 5); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(seats.enrolled, tt.want) || len(waitlist.entries) != tt.left {
+			t.Errorf("%s: enrolled %v with %d queued, want %v with %d", tt.name, seats.enrolled, len(waitlist.entries), tt.want, tt.left)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+-- Structured class meetings parsed from the free-text courses.class_time
+
+CREATE TABLE IF NOT EXISTS class_meetings (
+  id BIGSERIAL PRIMARY KEY,
+  course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  weekday SMALLINT NOT NULL,
+  start_period SMALLINT NOT NULL,
+  end_period SMALLINT NOT NULL,
+  start_week SMALLINT NOT NULL DEFAULT 1,
+  end_week SMALLINT NOT NULL DEFAULT 16,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT class_meetings_weekday_range CHECK (weekday BETWEEN 1 AND 7),
+  CONSTRAINT class_meetings_period_range CHECK (start_period >= 1 AND end_period >= start_period),
+  CONSTRAINT class_meetings_week_range CHECK (start_week >= 1 AND end_week >= start_week)
+);
+
+CREATE INDEX IF NOT EXISTS idx_class_meetings_course_id ON class_meetings(course_id);
+
+-- Migrate existing strings such as '周一 1-2节, 周三 3-4节', '星期5 5-6节(1-8周)' or
+-- '1-8周 周五 5-6节'. Follows service.ParseClassTime: the same separators, the same
+-- anchored segment pattern and the same range checks (periods 1-14, weeks 1-30). A course
+-- whose class_time does not parse stops the migration and is named in the error, so that
+-- it can be corrected rather than lose its timetable.
+DO $$
+DECLARE
+  c RECORD;
+  seg TEXT;
+  m TEXT[];
+  wd INT;
+  sp INT;
+  ep INT;
+  sw INT;
+  ew INT;
+  bad TEXT[] := '{}';
+BEGIN
+  FOR c IN SELECT id, course_no, class_time FROM courses ORDER BY course_no LOOP
+    FOREACH seg IN ARRAY regexp_split_to_array(translate(c.class_time, E'，；;、\n', ',,,,,'), ',') LOOP
+      seg := regexp_replace(seg, '^\s+|\s+$', '', 'g');
+      CONTINUE WHEN seg = '';
+      m := regexp_match(seg,
+        '^(?:第?(\d+)(?:-(\d+))?周\s*)?(?:周|星期)([一二三四五六日天1-7])\s*第?(\d+)(?:-(\d+))?节\s*(?:[(（[【]\s*第?(\d+)(?:-(\d+))?周\s*[]）)】])?$');
+      IF m IS NULL THEN
+        bad := bad || format('%s %L', c.course_no, seg);
+        CONTINUE;
+      END IF;
+
+      wd := CASE m[3]
+        WHEN '一' THEN 1 WHEN '二' THEN 2 WHEN '三' THEN 3 WHEN '四' THEN 4
+        WHEN '五' THEN 5 WHEN '六' THEN 6 WHEN '日' THEN 7 WHEN '天' THEN 7
+        ELSE m[3]::int
+      END;
+      sp := m[4]::int;
+      ep := COALESCE(m[5], m[4])::int;
+      -- A trailing week range wins over a leading one
+      IF m[6] IS NOT NULL THEN
+        sw := m[6]::int;
+        ew := COALESCE(m[7], m[6])::int;
+      ELSIF m[1] IS NOT NULL THEN
+        sw := m[1]::int;
+        ew := COALESCE(m[2], m[1])::int;
+      ELSE
+        sw := 1;
+        ew := 16;
+      END IF;
+      IF sp < 1 OR ep < sp OR ep > 14 OR sw < 1 OR ew < sw OR ew > 30 THEN
+        bad := bad || format('%s %L', c.course_no, seg);
+        CONTINUE;
+      END IF;
+
+      INSERT INTO class_meetings (course_id, weekday, start_period, end_period, start_week, end_week)
+      VALUES (c.id, wd, sp, ep, sw, ew);
+    END LOOP;
+  END LOOP;
+
+  IF array_length(bad, 1) > 0 THEN
+    RAISE EXCEPTION 'cannot parse the class_time of these courses, correct them and migrate again: %',
+      array_to_string(bad, '; ');
+  END IF;
+END $$;
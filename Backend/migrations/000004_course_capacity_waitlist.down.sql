@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS waitlist_entries;
+ALTER TABLE courses DROP CONSTRAINT IF EXISTS courses_capacity_range;
+ALTER TABLE courses DROP COLUMN IF EXISTS capacity;
@@ -0,0 +1,15 @@
+-- Per-term seat limits for courses and an ordered waitlist for full courses
+
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS capacity INT NOT NULL DEFAULT 0;
+ALTER TABLE courses ADD CONSTRAINT courses_capacity_range CHECK (capacity >= 0);
+
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+  id BIGSERIAL PRIMARY KEY,
+  student_id BIGINT NOT NULL REFERENCES students(id),
+  course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  term_id BIGINT NOT NULL REFERENCES terms(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (student_id, course_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_queue ON waitlist_entries(course_id, term_id, created_at, id);
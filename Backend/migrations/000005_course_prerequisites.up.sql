@@ -0,0 +1,24 @@
+-- Prerequisite graph between courses and per-student admin overrides.
+-- Edges with the same group_no are alternatives; every group must be met.
+
+CREATE TABLE IF NOT EXISTS course_prerequisites (
+  id BIGSERIAL PRIMARY KEY,
+  course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  group_no INT NOT NULL,
+  prereq_course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (course_id, group_no, prereq_course_id),
+  CONSTRAINT course_prerequisites_not_self CHECK (course_id <> prereq_course_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_prerequisites_prereq ON course_prerequisites(prereq_course_id);
+
+CREATE TABLE IF NOT EXISTS prerequisite_overrides (
+  id BIGSERIAL PRIMARY KEY,
+  student_id BIGINT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  course_id BIGINT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL DEFAULT '',
+  created_by BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (student_id, course_id)
+);
@@ -0,0 +1,76 @@
+-- Collapse offerings back onto courses. Each course takes the details of its most recent
+-- section; meetings of other sections are dropped.
+
+CREATE TEMP TABLE latest_offerings AS
+SELECT DISTINCT ON (o.course_id) o.*
+FROM course_offerings o
+JOIN terms t ON t.id = o.term_id
+ORDER BY o.course_id, t.start_date DESC NULLS LAST, t.term_code DESC, o.section_no ASC;
+
+ALTER TABLE courses
+  ADD COLUMN teacher_id BIGINT REFERENCES staff(id),
+  ADD COLUMN class_time TEXT NOT NULL DEFAULT '',
+  ADD COLUMN class_location TEXT NOT NULL DEFAULT '',
+  ADD COLUMN exam_time TEXT NOT NULL DEFAULT '',
+  ADD COLUMN capacity INT NOT NULL DEFAULT 0;
+
+UPDATE courses c SET
+  teacher_id = l.teacher_id,
+  class_time = l.class_time,
+  class_location = l.class_location,
+  exam_time = l.exam_time,
+  capacity = l.capacity
+FROM latest_offerings l
+WHERE l.course_id = c.id;
+
+-- Courses that were never offered have no teacher to restore
+UPDATE courses SET teacher_id = (SELECT MIN(id) FROM staff) WHERE teacher_id IS NULL;
+
+ALTER TABLE courses ALTER COLUMN teacher_id SET NOT NULL;
+ALTER TABLE courses ADD CONSTRAINT courses_capacity_range CHECK (capacity >= 0);
+CREATE INDEX IF NOT EXISTS idx_courses_teacher_id ON courses(teacher_id);
+
+-- Waitlists
+ALTER TABLE waitlist_entries
+  ADD COLUMN course_id BIGINT REFERENCES courses(id) ON DELETE CASCADE,
+  ADD COLUMN term_id BIGINT REFERENCES terms(id);
+UPDATE waitlist_entries w SET course_id = o.course_id, term_id = o.term_id
+FROM course_offerings o WHERE o.id = w.offering_id;
+ALTER TABLE waitlist_entries ALTER COLUMN course_id SET NOT NULL, ALTER COLUMN term_id SET NOT NULL;
+DROP INDEX IF EXISTS idx_waitlist_entries_queue;
+ALTER TABLE waitlist_entries DROP COLUMN offering_id;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_student_id_course_id_key UNIQUE (student_id, course_id);
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_queue ON waitlist_entries(course_id, term_id, created_at, id);
+
+-- Grades
+ALTER TABLE grades ADD COLUMN course_id BIGINT REFERENCES courses(id);
+UPDATE grades g SET course_id = o.course_id FROM course_offerings o WHERE o.id = g.offering_id;
+ALTER TABLE grades ALTER COLUMN course_id SET NOT NULL;
+DROP INDEX IF EXISTS idx_grades_offering_id;
+ALTER TABLE grades DROP COLUMN offering_id;
+ALTER TABLE grades ADD CONSTRAINT grades_student_id_course_id_key UNIQUE (student_id, course_id);
+CREATE INDEX IF NOT EXISTS idx_grades_course_id ON grades(course_id);
+
+-- Enrollments
+ALTER TABLE enrollments
+  ADD COLUMN course_id BIGINT REFERENCES courses(id),
+  ADD COLUMN term_id BIGINT REFERENCES terms(id);
+UPDATE enrollments e SET course_id = o.course_id, term_id = o.term_id
+FROM course_offerings o WHERE o.id = e.offering_id;
+ALTER TABLE enrollments ALTER COLUMN course_id SET NOT NULL, ALTER COLUMN term_id SET NOT NULL;
+DROP INDEX IF EXISTS idx_enrollments_offering_id;
+ALTER TABLE enrollments DROP COLUMN offering_id;
+ALTER TABLE enrollments ADD CONSTRAINT enrollments_student_id_course_id_key UNIQUE (student_id, course_id);
+CREATE INDEX IF NOT EXISTS idx_enrollments_term_id ON enrollments(term_id);
+
+-- Class meetings
+ALTER TABLE class_meetings ADD COLUMN course_id BIGINT REFERENCES courses(id) ON DELETE CASCADE;
+DELETE FROM class_meetings WHERE offering_id NOT IN (SELECT id FROM latest_offerings);
+UPDATE class_meetings m SET course_id = l.course_id FROM latest_offerings l WHERE l.id = m.offering_id;
+ALTER TABLE class_meetings ALTER COLUMN course_id SET NOT NULL;
+DROP INDEX IF EXISTS idx_class_meetings_offering_id;
+ALTER TABLE class_meetings DROP COLUMN offering_id;
+CREATE INDEX IF NOT EXISTS idx_class_meetings_course_id ON class_meetings(course_id);
+
+DROP TABLE latest_offerings;
+DROP TABLE IF EXISTS course_offerings;
@@ -0,0 +1,123 @@
+-- Split courses into catalog entries and per-term offerings (sections).
+-- Teacher, timetable, location, exam time and capacity move to course_offerings;
+-- enrollments, grades, waitlists and class meetings are re-keyed on the offering.
+
+CREATE TABLE IF NOT EXISTS course_offerings (
+  id BIGSERIAL PRIMARY KEY,
+  course_id BIGINT NOT NULL REFERENCES courses(id),
+  term_id BIGINT NOT NULL REFERENCES terms(id),
+  section_no TEXT NOT NULL DEFAULT '01',
+  teacher_id BIGINT NOT NULL REFERENCES staff(id),
+  class_time TEXT NOT NULL DEFAULT '',
+  class_location TEXT NOT NULL DEFAULT '',
+  exam_time TEXT NOT NULL DEFAULT '',
+  capacity INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (course_id, term_id, section_no),
+  CONSTRAINT course_offerings_capacity_range CHECK (capacity >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_course_offerings_term_id ON course_offerings(term_id);
+CREATE INDEX IF NOT EXISTS idx_course_offerings_teacher_id ON course_offerings(teacher_id);
+
+-- Without any term there is nowhere to offer the courses, and grades entered without an
+-- enrollment would be left without an offering; they are offered in a placeholder term
+INSERT INTO terms (term_code, name)
+SELECT 'LEGACY', '历史学期'
+WHERE NOT EXISTS (SELECT 1 FROM terms) AND EXISTS (SELECT 1 FROM courses);
+
+-- One section per course and term it was enrolled or waitlisted in
+INSERT INTO course_offerings (course_id, term_id, teacher_id, class_time, class_location, exam_time, capacity)
+SELECT c.id, ct.term_id, c.teacher_id, c.class_time, c.class_location, c.exam_time, c.capacity
+FROM courses c
+JOIN (
+  SELECT course_id, term_id FROM enrollments
+  UNION
+  SELECT course_id, term_id FROM waitlist_entries
+) ct ON ct.course_id = c.id;
+
+-- Courses nobody has taken yet are offered in the latest term so they stay selectable
+INSERT INTO course_offerings (course_id, term_id, teacher_id, class_time, class_location, exam_time, capacity)
+SELECT c.id, latest.id, c.teacher_id, c.class_time, c.class_location, c.exam_time, c.capacity
+FROM courses c
+CROSS JOIN (SELECT id FROM terms ORDER BY start_date DESC NULLS LAST, term_code DESC LIMIT 1) latest
+WHERE NOT EXISTS (SELECT 1 FROM course_offerings o WHERE o.course_id = c.id);
+
+-- Class meetings: copy each course's slots to every offering of that course
+ALTER TABLE class_meetings ADD COLUMN offering_id BIGINT REFERENCES course_offerings(id) ON DELETE CASCADE;
+
+INSERT INTO class_meetings (course_id, offering_id, weekday, start_period, end_period, start_week, end_week)
+SELECT m.course_id, o.id, m.weekday, m.start_period, m.end_period, m.start_week, m.end_week
+FROM class_meetings m
+JOIN course_offerings o ON o.course_id = m.course_id
+WHERE m.offering_id IS NULL;
+
+DELETE FROM class_meetings WHERE offering_id IS NULL;
+ALTER TABLE class_meetings ALTER COLUMN offering_id SET NOT NULL;
+DROP INDEX IF EXISTS idx_class_meetings_course_id;
+ALTER TABLE class_meetings DROP COLUMN course_id;
+CREATE INDEX IF NOT EXISTS idx_class_meetings_offering_id ON class_meetings(offering_id);
+
+-- Enrollments
+ALTER TABLE enrollments ADD COLUMN offering_id BIGINT REFERENCES course_offerings(id);
+
+UPDATE enrollments e SET offering_id = o.id
+FROM course_offerings o
+WHERE o.course_id = e.course_id AND o.term_id = e.term_id;
+
+ALTER TABLE enrollments ALTER COLUMN offering_id SET NOT NULL;
+ALTER TABLE enrollments DROP CONSTRAINT IF EXISTS enrollments_student_id_course_id_key;
+DROP INDEX IF EXISTS idx_enrollments_term_id;
+ALTER TABLE enrollments DROP COLUMN course_id, DROP COLUMN term_id;
+ALTER TABLE enrollments ADD CONSTRAINT enrollments_student_id_offering_id_key UNIQUE (student_id, offering_id);
+CREATE INDEX IF NOT EXISTS idx_enrollments_offering_id ON enrollments(offering_id);
+
+-- Grades belong to the offering the student was enrolled in,
+-- falling back to the course's most recent offering for grades entered without an enrollment
+ALTER TABLE grades ADD COLUMN offering_id BIGINT REFERENCES course_offerings(id);
+
+UPDATE grades g SET offering_id = e.offering_id
+FROM enrollments e
+JOIN course_offerings o ON o.id = e.offering_id
+WHERE e.student_id = g.student_id AND o.course_id = g.course_id;
+
+UPDATE grades g SET offering_id = (
+  SELECT o.id
+  FROM course_offerings o
+  JOIN terms t ON t.id = o.term_id
+  WHERE o.course_id = g.course_id
+  ORDER BY t.start_date DESC NULLS LAST, t.term_code DESC, o.section_no ASC
+  LIMIT 1
+)
+WHERE g.offering_id IS NULL;
+
+ALTER TABLE grades ALTER COLUMN offering_id SET NOT NULL;
+ALTER TABLE grades DROP CONSTRAINT IF EXISTS grades_student_id_course_id_key;
+DROP INDEX IF EXISTS idx_grades_course_id;
+ALTER TABLE grades DROP COLUMN course_id;
+ALTER TABLE grades ADD CONSTRAINT grades_student_id_offering_id_key UNIQUE (student_id, offering_id);
+CREATE INDEX IF NOT EXISTS idx_grades_offering_id ON grades(offering_id);
+
+-- Waitlists
+ALTER TABLE waitlist_entries ADD COLUMN offering_id BIGINT REFERENCES course_offerings(id) ON DELETE CASCADE;
+
+UPDATE waitlist_entries w SET offering_id = o.id
+FROM course_offerings o
+WHERE o.course_id = w.course_id AND o.term_id = w.term_id;
+
+ALTER TABLE waitlist_entries ALTER COLUMN offering_id SET NOT NULL;
+DROP INDEX IF EXISTS idx_waitlist_entries_queue;
+ALTER TABLE waitlist_entries DROP COLUMN course_id, DROP COLUMN term_id;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_student_id_offering_id_key UNIQUE (student_id, offering_id);
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_queue ON waitlist_entries(offering_id, created_at, id);
+
+-- Catalog courses keep only term-independent fields
+DROP INDEX IF EXISTS idx_courses_teacher_id;
+ALTER TABLE courses DROP CONSTRAINT IF EXISTS courses_capacity_range;
+ALTER TABLE courses
+  DROP COLUMN teacher_id,
+  DROP COLUMN class_time,
+  DROP COLUMN class_location,
+  DROP COLUMN exam_time,
+  DROP COLUMN capacity;
@@ -0,0 +1,4 @@
+ALTER TABLE grades DROP COLUMN IF EXISTS final_overridden;
+DROP TABLE IF EXISTS grade_component_scores;
+DROP TABLE IF EXISTS grading_components;
+DROP TABLE IF EXISTS grading_schemes;
@@ -0,0 +1,41 @@
+-- Per-course grading schemes: weighted components and rounding of the computed final score.
+-- Courses without a scheme fall back to the GRADE_DEFAULT_* settings (usual/exam weights).
+
+CREATE TABLE IF NOT EXISTS grading_schemes (
+  course_id BIGINT PRIMARY KEY REFERENCES courses(id) ON DELETE CASCADE,
+  rounding TEXT NOT NULL DEFAULT 'half_up',
+  decimals INT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT grading_schemes_rounding CHECK (rounding IN ('half_up', 'half_even', 'floor', 'ceil')),
+  CONSTRAINT grading_schemes_decimals CHECK (decimals >= 0 AND decimals <= 2)
+);
+
+CREATE TABLE IF NOT EXISTS grading_components (
+  id BIGSERIAL PRIMARY KEY,
+  course_id BIGINT NOT NULL REFERENCES grading_schemes(course_id) ON DELETE CASCADE,
+  code VARCHAR(32) NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  weight NUMERIC(5,2) NOT NULL,
+  sort_order INT NOT NULL DEFAULT 0,
+  UNIQUE (course_id, code),
+  CONSTRAINT grading_components_weight CHECK (weight > 0 AND weight <= 100)
+);
+
+-- Component scores of a grade; usual/exam are still mirrored into grades for existing clients
+CREATE TABLE IF NOT EXISTS grade_component_scores (
+  id BIGSERIAL PRIMARY KEY,
+  grade_id BIGINT NOT NULL REFERENCES grades(id) ON DELETE CASCADE,
+  code VARCHAR(32) NOT NULL,
+  score NUMERIC(5,2),
+  UNIQUE (grade_id, code),
+  CONSTRAINT grade_component_scores_range CHECK (score IS NULL OR (score >= 0 AND score <= 100))
+);
+
+INSERT INTO grade_component_scores (grade_id, code, score)
+SELECT id, 'usual', usual_score FROM grades WHERE usual_score IS NOT NULL
+UNION ALL
+SELECT id, 'exam', exam_score FROM grades WHERE exam_score IS NOT NULL;
+
+-- Finals entered before schemes existed are kept as manual overrides
+ALTER TABLE grades ADD COLUMN IF NOT EXISTS final_overridden BOOLEAN NOT NULL DEFAULT false;
+UPDATE grades SET final_overridden = true WHERE final_score IS NOT NULL;
@@ -0,0 +1,20 @@
+-- Issued transcripts and certificates. payload is the exact signed JSON (kept as TEXT so
+-- the bytes round-trip unchanged) and signature is its Ed25519 signature. student_id has
+-- no foreign key on purpose: issued documents stay verifiable after a student is deleted.
+
+CREATE TABLE IF NOT EXISTS document_issuances (
+  id BIGSERIAL PRIMARY KEY,
+  code VARCHAR(16) NOT NULL UNIQUE,
+  doc_type TEXT NOT NULL,
+  student_id BIGINT NOT NULL,
+  payload TEXT NOT NULL,
+  signature TEXT NOT NULL,
+  key_id VARCHAR(16) NOT NULL,
+  issued_by BIGINT NOT NULL,
+  issued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  revoked_at TIMESTAMPTZ,
+  revoke_reason TEXT NOT NULL DEFAULT '',
+  CONSTRAINT document_issuances_doc_type CHECK (doc_type IN ('transcript', 'enrollment_certificate'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_issuances_student ON document_issuances(student_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS grade_amendments;
+DROP TABLE IF EXISTS grade_status_logs;
+ALTER TABLE course_offerings DROP CONSTRAINT IF EXISTS course_offerings_grade_status;
+ALTER TABLE course_offerings DROP COLUMN IF EXISTS grade_status;
@@ -0,0 +1,47 @@
+-- Grade lifecycle of each course offering: draft -> submitted -> published -> locked.
+-- Teachers edit drafts only; published grades are visible to students; grades that are
+-- published or locked change only through an approved amendment request.
+
+ALTER TABLE course_offerings ADD COLUMN grade_status TEXT NOT NULL DEFAULT 'draft';
+ALTER TABLE course_offerings ADD CONSTRAINT course_offerings_grade_status
+  CHECK (grade_status IN ('draft', 'submitted', 'published', 'locked'));
+
+-- Offerings graded before the workflow existed stay visible to their students
+UPDATE course_offerings o SET grade_status = 'published'
+WHERE EXISTS (SELECT 1 FROM grades g WHERE g.offering_id = o.id);
+
+CREATE TABLE IF NOT EXISTS grade_status_logs (
+  id BIGSERIAL PRIMARY KEY,
+  offering_id BIGINT NOT NULL REFERENCES course_offerings(id) ON DELETE CASCADE,
+  from_status TEXT NOT NULL,
+  to_status TEXT NOT NULL,
+  user_id BIGINT NOT NULL,
+  note TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_grade_status_logs_offering ON grade_status_logs(offering_id);
+
+-- Requested change of one student's grade in a published or locked offering.
+-- components holds the requested component scores keyed by code.
+CREATE TABLE IF NOT EXISTS grade_amendments (
+  id BIGSERIAL PRIMARY KEY,
+  offering_id BIGINT NOT NULL REFERENCES course_offerings(id) ON DELETE CASCADE,
+  student_id BIGINT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  components JSONB NOT NULL DEFAULT '{}',
+  final_score NUMERIC(5,2),
+  override_final BOOLEAN NOT NULL DEFAULT false,
+  reason TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  requested_by BIGINT NOT NULL,
+  reviewed_by BIGINT,
+  review_note TEXT NOT NULL DEFAULT '',
+  reviewed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT grade_amendments_status CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_grade_amendments_status ON grade_amendments(status);
+-- At most one open request per student and offering
+CREATE UNIQUE INDEX IF NOT EXISTS idx_grade_amendments_pending
+  ON grade_amendments(offering_id, student_id) WHERE status = 'pending';
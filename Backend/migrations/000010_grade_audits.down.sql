@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS grade_audits;
+DROP FUNCTION IF EXISTS grade_audits_immutable();
@@ -0,0 +1,36 @@
+-- Append-only trail of grade changes. grade_id carries no foreign key so that the
+-- entries of deleted grades are kept; old_value/new_value hold the grade with its
+-- component scores before and after the change.
+
+CREATE TABLE IF NOT EXISTS grade_audits (
+  id BIGSERIAL PRIMARY KEY,
+  grade_id BIGINT NOT NULL,
+  student_id BIGINT NOT NULL,
+  offering_id BIGINT NOT NULL,
+  action TEXT NOT NULL,
+  old_value JSONB,
+  new_value JSONB,
+  user_id BIGINT NOT NULL,
+  role TEXT NOT NULL,
+  request_id TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT grade_audits_action CHECK (action IN ('insert', 'update', 'delete'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_grade_audits_grade ON grade_audits(grade_id);
+CREATE INDEX IF NOT EXISTS idx_grade_audits_offering ON grade_audits(offering_id);
+
+-- Entries can be appended but never changed or removed
+CREATE OR REPLACE FUNCTION grade_audits_immutable() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'grade_audits is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER grade_audits_no_update
+  BEFORE UPDATE OR DELETE ON grade_audits
+  FOR EACH ROW EXECUTE FUNCTION grade_audits_immutable();
+
+CREATE TRIGGER grade_audits_no_truncate
+  BEFORE TRUNCATE ON grade_audits
+  FOR EACH STATEMENT EXECUTE FUNCTION grade_audits_immutable();
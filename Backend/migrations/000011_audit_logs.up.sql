@@ -0,0 +1,27 @@
+-- Audit log of every write request under /api/v1. changes holds the fields of the
+-- target entity that the request changed as {"field": {"old": ..., "new": ...}}.
+-- Unlike grade_audits, entries are purged after AUDIT_RETENTION_DAYS.
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  role TEXT NOT NULL,
+  method TEXT NOT NULL,
+  route TEXT NOT NULL,
+  entity_type TEXT NOT NULL,
+  entity_id TEXT NOT NULL DEFAULT '',
+  action TEXT NOT NULL,
+  changes JSONB,
+  outcome TEXT NOT NULL,
+  status INT NOT NULL,
+  error_code INT NOT NULL DEFAULT 0,
+  message TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  remote_ip TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT audit_logs_outcome CHECK (outcome IN ('success', 'failure'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user ON audit_logs(user_id);
@@ -0,0 +1,10 @@
+-- Failed login tracking per username and per client IP, used when
+-- LOGIN_THROTTLE_STORE=db so that backoff and lockouts survive restarts.
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+  key TEXT PRIMARY KEY,
+  failures INT NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  blocked_until TIMESTAMPTZ,
+  locked BOOLEAN NOT NULL DEFAULT false
+);
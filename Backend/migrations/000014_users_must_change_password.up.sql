@@ -0,0 +1,4 @@
+-- Accounts whose password was set by an admin must choose their own before
+-- using the API; only /auth/change-password accepts their tokens.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,44 @@
+-- Roles as bundles of permissions. The built-in roles keep the access they had when
+-- roles were fixed strings; users.role now has to name one of them.
+
+CREATE TABLE IF NOT EXISTS roles (
+  id BIGSERIAL PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  label TEXT NOT NULL DEFAULT '',
+  builtin BOOLEAN NOT NULL DEFAULT FALSE,
+  permissions JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO roles (name, label, builtin, permissions) VALUES
+('admin', '管理员', TRUE, '[
+  "dept:read", "dept:write",
+  "student:read", "student:read:self", "student:write", "student:archive",
+  "staff:read", "staff:write",
+  "course:read", "course:write",
+  "term:read", "term:write",
+  "enrollment:read", "enrollment:read:self", "enrollment:write",
+  "prerequisite:override",
+  "grade:read", "grade:write", "grade:approve",
+  "transcript:issue",
+  "report:read",
+  "user:manage", "role:manage",
+  "data:import",
+  "audit:read", "audit:purge"
+]'),
+('teacher', '教师', TRUE, '[
+  "dept:read", "student:read", "staff:read", "course:read", "term:read",
+  "enrollment:read",
+  "grade:read", "grade:write:own_course",
+  "report:read"
+]'),
+('student', '学生', TRUE, '[
+  "course:read", "term:read",
+  "student:read:self",
+  "enrollment:read:self", "enrollment:write:self",
+  "grade:read:self"
+]')
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE users ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_courses_dept_id;
+ALTER TABLE courses DROP COLUMN IF EXISTS dept_id;
+ALTER TABLE users DROP COLUMN IF EXISTS dept_id;
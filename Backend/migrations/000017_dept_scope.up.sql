@@ -0,0 +1,19 @@
+-- Department-scoped administration. A user with a department only sees and changes the
+-- records of that department; courses belong to the department that offers them.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS dept_id BIGINT REFERENCES departments(id);
+
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS dept_id BIGINT REFERENCES departments(id);
+
+CREATE INDEX IF NOT EXISTS idx_courses_dept_id ON courses(dept_id);
+
+-- Each course goes to the department of the teacher of its most recent section
+UPDATE courses c SET dept_id = l.dept_id
+FROM (
+  SELECT DISTINCT ON (o.course_id) o.course_id, s.dept_id
+  FROM course_offerings o
+  JOIN terms t ON t.id = o.term_id
+  JOIN staff s ON s.id = o.teacher_id
+  ORDER BY o.course_id, t.start_date DESC NULLS LAST, t.term_code DESC, o.section_no ASC
+) l
+WHERE l.course_id = c.id AND c.dept_id IS NULL;
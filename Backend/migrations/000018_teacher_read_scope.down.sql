@@ -0,0 +1,9 @@
+UPDATE roles SET permissions = (
+  SELECT jsonb_agg(CASE p
+    WHEN 'grade:read:own_course' THEN 'grade:read'
+    WHEN 'enrollment:read:own_course' THEN 'enrollment:read'
+    WHEN 'report:read:own_course' THEN 'report:read'
+    ELSE p END)
+  FROM jsonb_array_elements_text(permissions) AS p
+), updated_at = now()
+WHERE name = 'teacher';
@@ -0,0 +1,12 @@
+-- Teachers read grades, enrollments and reports of the offerings they teach instead of
+-- every offering. Roles that kept the unnarrowed permissions are left as they are.
+
+UPDATE roles SET permissions = (
+  SELECT jsonb_agg(CASE p
+    WHEN 'grade:read' THEN 'grade:read:own_course'
+    WHEN 'enrollment:read' THEN 'enrollment:read:own_course'
+    WHEN 'report:read' THEN 'report:read:own_course'
+    ELSE p END)
+  FROM jsonb_array_elements_text(permissions) AS p
+), updated_at = now()
+WHERE name = 'teacher';
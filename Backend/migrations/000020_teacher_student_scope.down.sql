@@ -0,0 +1,7 @@
+UPDATE roles SET permissions = (
+  SELECT jsonb_agg(CASE p
+    WHEN 'student:read:own_course' THEN 'student:read'
+    ELSE p END)
+  FROM jsonb_array_elements_text(permissions) AS p
+), updated_at = now()
+WHERE name = 'teacher';
@@ -0,0 +1,10 @@
+-- Teachers list the students enrolled in the offerings they teach instead of every
+-- student. Roles that kept student:read are left as they are.
+
+UPDATE roles SET permissions = (
+  SELECT jsonb_agg(CASE p
+    WHEN 'student:read' THEN 'student:read:own_course'
+    ELSE p END)
+  FROM jsonb_array_elements_text(permissions) AS p
+), updated_at = now()
+WHERE name = 'teacher';
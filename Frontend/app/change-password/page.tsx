@@ -0,0 +1,158 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import { useRouter } from "next/navigation";
+import {
+  apiFetch,
+  getToken,
+  getUser,
+  landingPath,
+  logout,
+  passwordPolicyMessage,
+  setRefreshToken,
+  setToken,
+  setUser,
+  type User,
+} from "@/lib/api";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Alert, AlertDescription } from "@/components/ui/alert";
+import { KeyRound, Loader2 } from "lucide-react";
+
+type ChangeResp = {
+  token: string;
+  refresh_token: string;
+  user: User;
+};
+
+export default function ChangePasswordPage() {
+  const router = useRouter();
+  const [forced, setForced] = useState(false);
+  const [oldPassword, setOldPassword] = useState("");
+  const [newPassword, setNewPassword] = useState("");
+  const [confirmPassword, setConfirmPassword] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    if (!getToken()) {
+      router.replace("/login");
+      return;
+    }
+    setForced(!!getUser()?.must_change_password);
+  }, [router]);
+
+  async function onSubmit(e: React.FormEvent) {
+    e.preventDefault();
+    setError(null);
+    if (newPassword !== confirmPassword) {
+      setError("两次输入的新密码不一致");
+      return;
+    }
+
+    setLoading(true);
+    try {
+      const json = await apiFetch<ChangeResp>("/auth/change-password", {
+        method: "POST",
+        body: JSON.stringify({ old_password: oldPassword, new_password: newPassword }),
+      });
+      if (json.code !== 0 || !json.data?.token) {
+        setError(passwordPolicyMessage(json, "修改密码失败"));
+        return;
+      }
+      // 其他会话已被吊销，当前页面使用新会话继续
+      setToken(json.data.token);
+      setRefreshToken(json.data.refresh_token);
+      setUser(json.data.user);
+      router.push(landingPath(json.data.user));
+    } catch {
+      setError("网络错误，请检查后端服务是否启动");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function onLogout() {
+    await logout();
+    router.push("/login");
+  }
+
+  return (
+    <main className="min-h-screen flex items-center justify-center bg-background text-foreground p-6">
+      <Card className="w-full max-w-sm">
+        <CardHeader className="text-center">
+          <div className="mx-auto mb-2 flex h-12 w-12 items-center justify-center rounded-full bg-primary/10">
+            <KeyRound className="h-6 w-6 text-primary" />
+          </div>
+          <CardTitle>修改密码</CardTitle>
+          <CardDescription>
+            {forced ? "当前密码由管理员设置，请先修改密码再继续使用" : "修改后其他设备上的登录将失效"}
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          <form onSubmit={onSubmit} className="grid gap-4">
+            <div className="grid gap-2">
+              <Label htmlFor="oldPassword">当前密码</Label>
+              <Input
+                id="oldPassword"
+                type="password"
+                value={oldPassword}
+                onChange={(e) => setOldPassword(e.target.value)}
+                required
+              />
+            </div>
+            <div className="grid gap-2">
+              <Label htmlFor="newPassword">新密码</Label>
+              <Input
+                id="newPassword"
+                type="password"
+                value={newPassword}
+                onChange={(e) => setNewPassword(e.target.value)}
+                placeholder="至少8位，含大小写字母、数字、符号中的三类"
+                required
+              />
+            </div>
+            <div className="grid gap-2">
+              <Label htmlFor="confirmPassword">确认新密码</Label>
+              <Input
+                id="confirmPassword"
+                type="password"
+                value={confirmPassword}
+                onChange={(e) => setConfirmPassword(e.target.value)}
+                required
+              />
+            </div>
+            {error && (
+              <Alert variant="destructive">
+                <AlertDescription>{error}</AlertDescription>
+              </Alert>
+            )}
+            <Button type="submit" disabled={loading} className="w-full">
+              {loading ? (
+                <>
+                  <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+                  提交中...
+                </>
+              ) : (
+                "修改密码"
+              )}
+            </Button>
+          </form>
+          <div className="mt-4 text-center text-sm text-muted-foreground">
+            {forced ? (
+              <button type="button" onClick={onLogout} className="hover:text-foreground hover:underline">
+                退出登录
+              </button>
+            ) : (
+              <button type="button" onClick={() => router.back()} className="hover:text-foreground hover:underline">
+                返回
+              </button>
+            )}
+          </div>
+        </CardContent>
+      </Card>
+    </main>
+  );
+}
@@ -0,0 +1,87 @@
+"use client";
+
+import { useState } from "react";
+import { downloadCredentialsSheet, type ProvisionedAccount } from "@/lib/api";
+import { Button } from "@/components/ui/button";
+import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table";
+import { Alert, AlertDescription } from "@/components/ui/alert";
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from "@/components/ui/dialog";
+import { Printer } from "lucide-react";
+
+// 展示新开通账号的初始密码；关闭后无法再次查看，须在此打印凭条
+export function AccountsDialog({
+  accounts,
+  note,
+  onClose,
+}: {
+  accounts: ProvisionedAccount[];
+  note?: string;
+  onClose: () => void;
+}) {
+  const [err, setErr] = useState<string | null>(null);
+  const [printing, setPrinting] = useState(false);
+
+  async function print() {
+    setErr(null);
+    setPrinting(true);
+    setErr(await downloadCredentialsSheet(accounts));
+    setPrinting(false);
+  }
+
+  return (
+    <Dialog open={accounts.length > 0} onOpenChange={(open) => !open && onClose()}>
+      <DialogContent className="sm:max-w-[600px]">
+        <DialogHeader>
+          <DialogTitle>已开通 {accounts.length} 个账号</DialogTitle>
+          <DialogDescription>
+            初始密码仅显示这一次，首次登录后须修改。关闭前请打印凭条或记录密码。
+          </DialogDescription>
+        </DialogHeader>
+        {note && <p className="text-sm text-muted-foreground">{note}</p>}
+        {err && (
+          <Alert variant="destructive">
+            <AlertDescription>{err}</AlertDescription>
+          </Alert>
+        )}
+        <div className="max-h-[360px] overflow-y-auto">
+          <Table>
+            <TableHeader>
+              <TableRow>
+                <TableHead>学号/工号</TableHead>
+                <TableHead>姓名</TableHead>
+                <TableHead>用户名</TableHead>
+                <TableHead>初始密码</TableHead>
+              </TableRow>
+            </TableHeader>
+            <TableBody>
+              {accounts.map((a) => (
+                <TableRow key={a.user_id}>
+                  <TableCell>{a.number}</TableCell>
+                  <TableCell>{a.name}</TableCell>
+                  <TableCell className="font-mono">{a.username}</TableCell>
+                  <TableCell className="font-mono">{a.password}</TableCell>
+                </TableRow>
+              ))}
+            </TableBody>
+          </Table>
+        </div>
+        <DialogFooter>
+          <Button variant="outline" onClick={onClose}>
+            关闭
+          </Button>
+          <Button onClick={() => void print()} disabled={printing}>
+            <Printer className="mr-2 h-4 w-4" />
+            {printing ? "生成中..." : "打印凭条"}
+          </Button>
+        </DialogFooter>
+      </DialogContent>
+    </Dialog>
+  );
+}
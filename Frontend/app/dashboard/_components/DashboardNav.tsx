@@ -2,7 +2,7 @@
 
 import { useEffect, useState, useCallback } from "react";
 import { useRouter } from "next/navigation";
-import { clearToken, getToken, getUser, fetchCurrentUser, type User } from "@/lib/api";
+import { logout, getToken, getUser, fetchCurrentUser, type User } from "@/lib/api";
 import { Button } from "@/components/ui/button";
 import {
   DropdownMenu,
@@ -12,7 +12,7 @@ import {
   DropdownMenuSeparator,
   DropdownMenuTrigger,
 } from "@/components/ui/dropdown-menu";
-import { User as UserIcon, LogOut, LogIn } from "lucide-react";
+import { User as UserIcon, LogOut, LogIn, KeyRound, ShieldCheck } from "lucide-react";
 
 const roleLabels: Record<string, string> = {
   admin: "管理员",
@@ -53,7 +53,7 @@ export function DashboardNav() {
   }, [loadUser]);
 
   const handleLogout = () => {
-    clearToken();
+    void logout();
     setUser(null);
     router.push("/login");
   };
@@ -96,6 +96,14 @@ export function DashboardNav() {
           </div>
         </DropdownMenuLabel>
         <DropdownMenuSeparator />
+        <DropdownMenuItem onClick={() => router.push("/change-password")}>
+          <KeyRound className="mr-2 h-4 w-4" />
+          修改密码
+        </DropdownMenuItem>
+        <DropdownMenuItem onClick={() => router.push("/two-factor")}>
+          <ShieldCheck className="mr-2 h-4 w-4" />
+          两步验证
+        </DropdownMenuItem>
         <DropdownMenuItem onClick={handleLogout} className="text-destructive">
           <LogOut className="mr-2 h-4 w-4" />
           退出登录
@@ -3,7 +3,7 @@
 import { useMemo } from "react";
 import Link from "next/link";
 import { usePathname } from "next/navigation";
-import { getUser, type UserRole } from "@/lib/api";
+import { getUser, type User } from "@/lib/api";
 import {
   Building2,
   Users,
@@ -23,42 +23,41 @@ type NavLink = {
   href: string;
   label: string;
   icon: React.ComponentType<{ className?: string }>;
-  roles: UserRole[]; // 哪些角色可以看到
+  perms: string[]; // 含其中任一权限即可看到，空表示所有人
 };
 
 // 所有导航链接配置
 const allLinks: NavLink[] = [
-  { href: "/dashboard", label: "概览", icon: LayoutDashboard, roles: ["admin", "teacher", "student"] },
-  // 管理员专属
-  { href: "/dashboard/departments", label: "系管理", icon: Building2, roles: ["admin"] },
-  { href: "/dashboard/students", label: "学生管理", icon: Users, roles: ["admin"] },
-  { href: "/dashboard/staff", label: "教职工管理", icon: GraduationCap, roles: ["admin"] },
-  { href: "/dashboard/courses", label: "课程管理", icon: BookOpen, roles: ["admin"] },
-  { href: "/dashboard/enrollments", label: "选课管理", icon: ClipboardList, roles: ["admin"] },
-  // 管理员和教师
-  { href: "/dashboard/grades", label: "成绩管理", icon: FileSpreadsheet, roles: ["admin", "teacher"] },
-  { href: "/dashboard/reports", label: "统计报表", icon: BarChart3, roles: ["admin", "teacher"] },
-  // 学生专属
-  { href: "/dashboard/my-courses", label: "课程选课", icon: BookMarked, roles: ["student"] },
-  { href: "/dashboard/my-enrollments", label: "我的选课", icon: ListChecks, roles: ["student"] },
-  { href: "/dashboard/my-grades", label: "我的成绩", icon: Award, roles: ["student"] },
+  { href: "/dashboard", label: "概览", icon: LayoutDashboard, perms: [] },
+  // 管理
+  { href: "/dashboard/departments", label: "系管理", icon: Building2, perms: ["dept:write"] },
+  { href: "/dashboard/students", label: "学生管理", icon: Users, perms: ["student:write", "student:archive"] },
+  { href: "/dashboard/staff", label: "教职工管理", icon: GraduationCap, perms: ["staff:write"] },
+  { href: "/dashboard/courses", label: "课程管理", icon: BookOpen, perms: ["course:write"] },
+  { href: "/dashboard/enrollments", label: "选课管理", icon: ClipboardList, perms: ["enrollment:write"] },
+  // 成绩与报表
+  { href: "/dashboard/grades", label: "成绩管理", icon: FileSpreadsheet, perms: ["grade:read", "grade:read:own_course", "grade:write", "grade:write:own_course"] },
+  { href: "/dashboard/reports", label: "统计报表", icon: BarChart3, perms: ["report:read", "report:read:own_course"] },
+  // 学生本人
+  { href: "/dashboard/my-courses", label: "课程选课", icon: BookMarked, perms: ["enrollment:write:self"] },
+  { href: "/dashboard/my-enrollments", label: "我的选课", icon: ListChecks, perms: ["enrollment:read:self"] },
+  { href: "/dashboard/my-grades", label: "我的成绩", icon: Award, perms: ["grade:read:self"] },
 ];
 
-// 获取用户角色（同步）
-function getUserRole(): UserRole | null {
+// 获取当前用户（同步）
+function getCurrentUser(): User | null {
   if (typeof window === "undefined") return null;
-  const user = getUser();
-  return user?.role ?? null;
+  return getUser();
 }
 
 export function RoleBasedNav({ className, mobile = false }: { className?: string; mobile?: boolean }) {
   const pathname = usePathname();
-  const role = useMemo(() => getUserRole(), []);
+  const user = useMemo(() => getCurrentUser(), []);
 
-  // 根据角色过滤链接
+  // 根据角色权限过滤链接
   const visibleLinks = allLinks.filter((link) => {
-    if (!role) return false;
-    return link.roles.includes(role);
+    if (!user) return false;
+    return link.perms.length === 0 || link.perms.some((p) => user.permissions?.includes(p));
   });
 
   if (mobile) {
@@ -1,7 +1,7 @@
 "use client";
 
 import { useEffect, useState } from "react";
-import { apiFetch, canWrite, type Course, type Staff } from "@/lib/api";
+import { apiFetch, hasPermission, type Course, type Staff } from "@/lib/api";
 import { Section } from "../_components/Section";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
@@ -163,7 +163,7 @@ export default function CoursesPage() {
   }
 
   useEffect(() => {
-    setWritable(canWrite());
+    setWritable(hasPermission("course:write"));
     void loadStaff();
     void load();
     // eslint-disable-next-line react-hooks/exhaustive-deps
@@ -1,7 +1,7 @@
 "use client";
 
 import { useEffect, useState } from "react";
-import { apiFetch, canWrite, type Department } from "@/lib/api";
+import { apiFetch, hasPermission, type Department } from "@/lib/api";
 import { Section } from "../_components/Section";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
@@ -131,7 +131,7 @@ export default function DepartmentsPage() {
   }
 
   useEffect(() => {
-    setWritable(canWrite());
+    setWritable(hasPermission("dept:write"));
     void load();
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, []);
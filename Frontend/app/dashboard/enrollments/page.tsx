@@ -1,7 +1,7 @@
 "use client";
 
 import { useEffect, useState } from "react";
-import { apiFetch, canWrite, type Term, type Enrollment } from "@/lib/api";
+import { apiFetch, hasPermission, type Term, type Enrollment } from "@/lib/api";
 import { Section } from "../_components/Section";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
@@ -171,7 +171,7 @@ export default function EnrollmentsPage() {
   }
 
   useEffect(() => {
-    setWritable(canWrite());
+    setWritable(hasPermission("enrollment:write"));
     void loadTerms();
     void loadEnrollments();
     // eslint-disable-next-line react-hooks/exhaustive-deps
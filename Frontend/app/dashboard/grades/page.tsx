@@ -1,7 +1,7 @@
 "use client";
 
 import { useEffect, useMemo, useState } from "react";
-import { apiFetch, canGrade, type CourseGradeGroup } from "@/lib/api";
+import { apiFetch, hasPermission, type CourseGradeGroup } from "@/lib/api";
 import { Section } from "../_components/Section";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
@@ -114,7 +114,7 @@ export default function GradesPage() {
   }
 
   useEffect(() => {
-    setGradeEditable(canGrade());
+    setGradeEditable(hasPermission("grade:write", "grade:write:own_course"));
     void load();
     // eslint-disable-next-line react-hooks/exhaustive-deps
   }, []);
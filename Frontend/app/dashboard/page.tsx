@@ -2,7 +2,7 @@
 
 import { useEffect, useState } from "react";
 import Link from "next/link";
-import { apiFetch, getUser, type User, type UserRole, type Enrollment } from "@/lib/api";
+import { apiFetch, getUser, type User, type Enrollment } from "@/lib/api";
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
 import { 
   Building2, 
@@ -31,24 +31,24 @@ type NavLink = {
   label: string;
   icon: React.ComponentType<{ className?: string }>;
   desc: string;
-  roles: UserRole[];
+  perms: string[]; // 含其中任一权限即可看到
 };
 
-// 根据角色配置不同的功能入口
+// 根据角色权限配置不同的功能入口
 const allLinks: NavLink[] = [
-  // 管理员专属
-  { href: "/dashboard/departments", label: "系管理", icon: Building2, desc: "院系信息的增删改查", roles: ["admin"] },
-  { href: "/dashboard/students", label: "学生管理", icon: Users, desc: "学生信息、毕业、转学", roles: ["admin"] },
-  { href: "/dashboard/staff", label: "教职工管理", icon: GraduationCap, desc: "教师信息管理", roles: ["admin"] },
-  { href: "/dashboard/courses", label: "课程管理", icon: BookOpen, desc: "课程信息维护", roles: ["admin"] },
-  { href: "/dashboard/enrollments", label: "选课管理", icon: ClipboardList, desc: "学期、选课、退课", roles: ["admin"] },
-  // 管理员和教师
-  { href: "/dashboard/grades", label: "成绩管理", icon: FileSpreadsheet, desc: "成绩录入与查询", roles: ["admin", "teacher"] },
-  { href: "/dashboard/reports", label: "统计报表", icon: BarChart3, desc: "登记表、成绩报表", roles: ["admin", "teacher"] },
-  // 学生专属
-  { href: "/dashboard/my-courses", label: "课程选课", icon: BookMarked, desc: "浏览课程、点击选课", roles: ["student"] },
-  { href: "/dashboard/my-enrollments", label: "我的选课", icon: ListChecks, desc: "查看已选课程、退课", roles: ["student"] },
-  { href: "/dashboard/my-grades", label: "我的成绩", icon: Award, desc: "查看各科成绩", roles: ["student"] },
+  // 管理
+  { href: "/dashboard/departments", label: "系管理", icon: Building2, desc: "院系信息的增删改查", perms: ["dept:write"] },
+  { href: "/dashboard/students", label: "学生管理", icon: Users, desc: "学生信息、毕业、转学", perms: ["student:write", "student:archive"] },
+  { href: "/dashboard/staff", label: "教职工管理", icon: GraduationCap, desc: "教师信息管理", perms: ["staff:write"] },
+  { href: "/dashboard/courses", label: "课程管理", icon: BookOpen, desc: "课程信息维护", perms: ["course:write"] },
+  { href: "/dashboard/enrollments", label: "选课管理", icon: ClipboardList, desc: "学期、选课、退课", perms: ["enrollment:write"] },
+  // 成绩与报表
+  { href: "/dashboard/grades", label: "成绩管理", icon: FileSpreadsheet, desc: "成绩录入与查询", perms: ["grade:read", "grade:read:own_course", "grade:write", "grade:write:own_course"] },
+  { href: "/dashboard/reports", label: "统计报表", icon: BarChart3, desc: "登记表、成绩报表", perms: ["report:read", "report:read:own_course"] },
+  // 学生本人
+  { href: "/dashboard/my-courses", label: "课程选课", icon: BookMarked, desc: "浏览课程、点击选课", perms: ["enrollment:write:self"] },
+  { href: "/dashboard/my-enrollments", label: "我的选课", icon: ListChecks, desc: "查看已选课程、退课", perms: ["enrollment:read:self"] },
+  { href: "/dashboard/my-grades", label: "我的成绩", icon: Award, desc: "查看各科成绩", perms: ["grade:read:self"] },
 ];
 
 const roleLabels: Record<string, string> = {
@@ -118,10 +118,10 @@ export default function DashboardHome() {
     }
   }, [user]);
 
-  // 根据角色过滤链接
+  // 根据角色权限过滤链接
   const visibleLinks = allLinks.filter((link) => {
     if (!user) return false;
-    return link.roles.includes(user.role);
+    return link.perms.some((p) => user.permissions?.includes(p));
   });
 
   // 学生统计
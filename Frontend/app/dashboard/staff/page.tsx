@@ -1,8 +1,16 @@
 "use client";
 
 import { useEffect, useState } from "react";
-import { apiFetch, canWrite, type Staff, type Department } from "@/lib/api";
+import {
+  apiFetch,
+  hasPermission,
+  type Staff,
+  type Department,
+  type ProvisionedAccount,
+  type ProvisionResult,
+} from "@/lib/api";
 import { Section } from "../_components/Section";
+import { AccountsDialog } from "../_components/AccountsDialog";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
@@ -27,7 +35,7 @@ import {
   AlertDialogHeader,
   AlertDialogTitle,
 } from "@/components/ui/alert-dialog";
-import { Pencil, Trash2, Plus } from "lucide-react";
+import { Pencil, Trash2, Plus, KeyRound } from "lucide-react";
 
 const titleOptions = ["助教", "讲师", "副教授", "教授"];
 
@@ -51,6 +59,11 @@ export default function StaffPage() {
   const [formTitle, setFormTitle] = useState("讲师");
   const [formMajor, setFormMajor] = useState("");
   const [formTeachingDirection, setFormTeachingDirection] = useState("");
+  const [formProvision, setFormProvision] = useState(false);
+
+  // 新开通的账号及其初始密码
+  const [accounts, setAccounts] = useState<ProvisionedAccount[]>([]);
+  const [accountsNote, setAccountsNote] = useState<string | undefined>();
 
   // 删除确认对话框
   const [deleteDialogOpen, setDeleteDialogOpen] = useState(false);
@@ -58,6 +71,7 @@ export default function StaffPage() {
 
   // 使用 state 避免 hydration 错误
   const [writable, setWritable] = useState(false);
+  const [canProvision, setCanProvision] = useState(false);
 
   async function loadDepartments() {
     const res = await apiFetch<Department[]>("/api/v1/departments");
@@ -92,6 +106,7 @@ export default function StaffPage() {
     setFormTitle("讲师");
     setFormMajor("");
     setFormTeachingDirection("");
+    setFormProvision(false);
     setDialogOpen(true);
   }
 
@@ -131,19 +146,46 @@ export default function StaffPage() {
         return;
       }
     } else {
-      const res = await apiFetch<Staff>("/api/v1/staff", {
+      const res = await apiFetch<Staff & { account?: ProvisionedAccount }>("/api/v1/staff", {
         method: "POST",
-        body: JSON.stringify(payload),
+        body: JSON.stringify({ ...payload, provision_account: formProvision }),
       });
       if (res.code !== 0) {
         setErr(res.message);
         return;
       }
+      if (res.data?.account) {
+        setAccountsNote(undefined);
+        setAccounts([res.data.account]);
+      }
     }
     setDialogOpen(false);
     await load();
   }
 
+  // 为尚无账号的教职工批量开通教师账号
+  async function handleProvision() {
+    setErr(null);
+    const res = await apiFetch<ProvisionResult>("/api/v1/accounts/provision", {
+      method: "POST",
+      body: JSON.stringify({ kind: "staff" }),
+    });
+    if (res.code !== 0 || !res.data) {
+      setErr(res.message);
+      return;
+    }
+    const { created, skipped, remaining } = res.data;
+    if (created.length === 0) {
+      setErr(skipped.length ? `未开通账号：${skipped.length} 名教职工的工号已被其他账号占用` : "所有教职工均已开通账号");
+      return;
+    }
+    const notes: string[] = [];
+    if (skipped.length) notes.push(`${skipped.length} 名教职工的工号已被其他账号占用，未开通`);
+    if (remaining) notes.push(`还有 ${remaining} 名教职工待开通，请再次执行`);
+    setAccountsNote(notes.length ? notes.join("；") + "。" : undefined);
+    setAccounts(created);
+  }
+
   function openDeleteDialog(item: Staff) {
     setDeletingItem(item);
     setDeleteDialogOpen(true);
@@ -165,7 +207,8 @@ export default function StaffPage() {
   }
 
   useEffect(() => {
-    setWritable(canWrite());
+    setWritable(hasPermission("staff:write"));
+    setCanProvision(hasPermission("user:manage"));
     void loadDepartments();
     void load();
     // eslint-disable-next-line react-hooks/exhaustive-deps
@@ -175,12 +218,20 @@ export default function StaffPage() {
     <main className="grid gap-6">
       <div className="flex items-center justify-between">
         <h1 className="text-2xl font-semibold">教职工管理（Staff）</h1>
-        {writable && (
-          <Button onClick={openCreateDialog}>
-            <Plus className="mr-2 h-4 w-4" />
-            新增
-          </Button>
-        )}
+        <div className="flex gap-2">
+          {canProvision && (
+            <Button variant="outline" onClick={() => void handleProvision()}>
+              <KeyRound className="mr-2 h-4 w-4" />
+              批量开通账号
+            </Button>
+          )}
+          {writable && (
+            <Button onClick={openCreateDialog}>
+              <Plus className="mr-2 h-4 w-4" />
+              新增
+            </Button>
+          )}
+        </div>
       </div>
 
       {err && (
@@ -364,6 +415,16 @@ export default function StaffPage() {
                 placeholder="如：人工智能、数据库"
               />
             </div>
+            {!editingItem && canProvision && (
+              <label className="flex items-center gap-2 text-sm">
+                <input
+                  type="checkbox"
+                  checked={formProvision}
+                  onChange={(e) => setFormProvision(e.target.checked)}
+                />
+                同时开通教师登录账号（用户名为工号）
+              </label>
+            )}
           </div>
           <DialogFooter>
             <Button variant="outline" onClick={() => setDialogOpen(false)}>
@@ -395,6 +456,8 @@ export default function StaffPage() {
           </AlertDialogFooter>
         </AlertDialogContent>
       </AlertDialog>
+
+      <AccountsDialog accounts={accounts} note={accountsNote} onClose={() => setAccounts([])} />
     </main>
   );
 }
@@ -1,8 +1,16 @@
 "use client";
 
 import { useEffect, useState } from "react";
-import { apiFetch, canWrite, type Student, type Department } from "@/lib/api";
+import {
+  apiFetch,
+  hasPermission,
+  type Student,
+  type Department,
+  type ProvisionedAccount,
+  type ProvisionResult,
+} from "@/lib/api";
 import { Section } from "../_components/Section";
+import { AccountsDialog } from "../_components/AccountsDialog";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
@@ -34,7 +42,7 @@ import {
   DropdownMenuSeparator,
   DropdownMenuTrigger,
 } from "@/components/ui/dropdown-menu";
-import { Pencil, Trash2, Plus, MoreHorizontal, GraduationCap, ArrowRightLeft } from "lucide-react";
+import { Pencil, Trash2, Plus, MoreHorizontal, GraduationCap, ArrowRightLeft, KeyRound } from "lucide-react";
 
 const statusLabels: Record<string, string> = {
   "在读": "在读",
@@ -62,6 +70,11 @@ export default function StudentsPage() {
   const [formEntryScore, setFormEntryScore] = useState("");
   const [formDeptId, setFormDeptId] = useState("");
   const [formStatus, setFormStatus] = useState("在读");
+  const [formProvision, setFormProvision] = useState(false);
+
+  // 新开通的账号及其初始密码
+  const [accounts, setAccounts] = useState<ProvisionedAccount[]>([]);
+  const [accountsNote, setAccountsNote] = useState<string | undefined>();
 
   // 删除确认对话框
   const [deleteDialogOpen, setDeleteDialogOpen] = useState(false);
@@ -74,6 +87,7 @@ export default function StudentsPage() {
 
   // 使用 state 避免 hydration 错误
   const [writable, setWritable] = useState(false);
+  const [canProvision, setCanProvision] = useState(false);
 
   async function loadDepartments() {
     const res = await apiFetch<Department[]>("/api/v1/departments");
@@ -107,6 +121,7 @@ export default function StudentsPage() {
     setFormEntryScore("");
     setFormDeptId(departments[0]?.id.toString() ?? "");
     setFormStatus("在读");
+    setFormProvision(false);
     setDialogOpen(true);
   }
 
@@ -144,19 +159,46 @@ export default function StudentsPage() {
         return;
       }
     } else {
-      const res = await apiFetch<Student>("/api/v1/students", {
+      const res = await apiFetch<Student & { account?: ProvisionedAccount }>("/api/v1/students", {
         method: "POST",
-        body: JSON.stringify(payload),
+        body: JSON.stringify({ ...payload, provision_account: formProvision }),
       });
       if (res.code !== 0) {
         setErr(res.message);
         return;
       }
+      if (res.data?.account) {
+        setAccountsNote(undefined);
+        setAccounts([res.data.account]);
+      }
     }
     setDialogOpen(false);
     await load();
   }
 
+  // 为在读、转入且尚无账号的学生批量开通账号
+  async function handleProvision() {
+    setErr(null);
+    const res = await apiFetch<ProvisionResult>("/api/v1/accounts/provision", {
+      method: "POST",
+      body: JSON.stringify({ kind: "student" }),
+    });
+    if (res.code !== 0 || !res.data) {
+      setErr(res.message);
+      return;
+    }
+    const { created, skipped, remaining } = res.data;
+    if (created.length === 0) {
+      setErr(skipped.length ? `未开通账号：${skipped.length} 名学生的学号已被其他账号占用` : "所有学生均已开通账号");
+      return;
+    }
+    const notes: string[] = [];
+    if (skipped.length) notes.push(`${skipped.length} 名学生的学号已被其他账号占用，未开通`);
+    if (remaining) notes.push(`还有 ${remaining} 名学生待开通，请再次执行`);
+    setAccountsNote(notes.length ? notes.join("；") + "。" : undefined);
+    setAccounts(created);
+  }
+
   function openDeleteDialog(item: Student) {
     setDeletingItem(item);
     setDeleteDialogOpen(true);
@@ -199,7 +241,8 @@ export default function StudentsPage() {
   }
 
   useEffect(() => {
-    setWritable(canWrite());
+    setWritable(hasPermission("student:write"));
+    setCanProvision(hasPermission("user:manage"));
     void loadDepartments();
     void load();
     // eslint-disable-next-line react-hooks/exhaustive-deps
@@ -209,12 +252,20 @@ export default function StudentsPage() {
     <main className="grid gap-6">
       <div className="flex items-center justify-between">
         <h1 className="text-2xl font-semibold">学生管理（Students）</h1>
-        {writable && (
-          <Button onClick={openCreateDialog}>
-            <Plus className="mr-2 h-4 w-4" />
-            新增
-          </Button>
-        )}
+        <div className="flex gap-2">
+          {canProvision && (
+            <Button variant="outline" onClick={() => void handleProvision()}>
+              <KeyRound className="mr-2 h-4 w-4" />
+              批量开通账号
+            </Button>
+          )}
+          {writable && (
+            <Button onClick={openCreateDialog}>
+              <Plus className="mr-2 h-4 w-4" />
+              新增
+            </Button>
+          )}
+        </div>
       </div>
 
       {err && (
@@ -417,6 +468,16 @@ export default function StudentsPage() {
                 </Select>
               </div>
             )}
+            {!editingItem && canProvision && (
+              <label className="flex items-center gap-2 text-sm">
+                <input
+                  type="checkbox"
+                  checked={formProvision}
+                  onChange={(e) => setFormProvision(e.target.checked)}
+                />
+                同时开通登录账号（用户名为学号）
+              </label>
+            )}
           </div>
           <DialogFooter>
             <Button variant="outline" onClick={() => setDialogOpen(false)}>
@@ -474,6 +535,8 @@ export default function StudentsPage() {
           </AlertDialogFooter>
         </AlertDialogContent>
       </AlertDialog>
+
+      <AccountsDialog accounts={accounts} note={accountsNote} onClose={() => setAccounts([])} />
     </main>
   );
 }
@@ -0,0 +1,165 @@
+"use client";
+
+import { useEffect, useRef, useState } from "react";
+import { useRouter } from "next/navigation";
+import Link from "next/link";
+import { apiBase, setToken, setRefreshToken, setUser, landingPath, oidcStateKey, type User } from "@/lib/api";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Alert, AlertDescription } from "@/components/ui/alert";
+import { GraduationCap, Loader2 } from "lucide-react";
+
+type LoginResp = {
+  token?: string;
+  refresh_token?: string;
+  user?: User;
+  mfa_required?: boolean;
+  mfa_token?: string;
+};
+
+type Resp = { code: number; message: string; data?: LoginResp };
+
+// 身份提供方登录后重定向到此页（OIDC_REDIRECT_URL），携带 code 与 state
+export default function LoginCallbackPage() {
+  const router = useRouter();
+  const started = useRef(false);
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+  const [code, setCode] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  // 登录成功则保存令牌并跳转，需要两步验证时转入验证码输入
+  function finish(res: Response, json: Resp) {
+    if (res.ok && json.code === 0 && json.data?.mfa_required && json.data.mfa_token) {
+      setMfaToken(json.data.mfa_token);
+      return;
+    }
+    if (!res.ok || json.code !== 0 || !json.data?.token || !json.data.user || !json.data.refresh_token) {
+      setError(json.message || "登录失败");
+      return;
+    }
+    setToken(json.data.token);
+    setRefreshToken(json.data.refresh_token);
+    setUser(json.data.user);
+    router.replace(landingPath(json.data.user));
+  }
+
+  useEffect(() => {
+    // 开发模式下 effect 会执行两次，授权码只能兑换一次
+    if (started.current) return;
+    started.current = true;
+
+    async function callback() {
+      const params = new URLSearchParams(window.location.search);
+      const state = params.get("state");
+      const expected = sessionStorage.getItem(oidcStateKey);
+      sessionStorage.removeItem(oidcStateKey);
+      if (params.get("error")) {
+        setError(params.get("error_description") || "身份提供方拒绝了登录");
+        return;
+      }
+      if (!params.get("code") || !state || state !== expected) {
+        setError("登录已失效，请重新登录");
+        return;
+      }
+      try {
+        const res = await fetch(`${apiBase()}/auth/oidc/callback`, {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ code: params.get("code"), state }),
+        });
+        finish(res, (await res.json()) as Resp);
+      } catch {
+        setError("网络错误，请检查后端服务是否启动");
+      }
+    }
+    void callback();
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
+
+  async function onVerify(e: React.FormEvent) {
+    e.preventDefault();
+    setError(null);
+    setLoading(true);
+    try {
+      const res = await fetch(`${apiBase()}/auth/mfa/verify`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ mfa_token: mfaToken, code }),
+      });
+      const json = (await res.json()) as Resp;
+      if (json.code === 40114) {
+        // 验证码步骤超时，须重新登录
+        setMfaToken(null);
+      }
+      finish(res, json);
+    } catch {
+      setError("网络错误，请检查后端服务是否启动");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <main className="min-h-screen flex items-center justify-center bg-background text-foreground p-6">
+      <Card className="w-full max-w-sm">
+        <CardHeader className="text-center">
+          <div className="mx-auto mb-2 flex h-12 w-12 items-center justify-center rounded-full bg-primary/10">
+            <GraduationCap className="h-6 w-6 text-primary" />
+          </div>
+          <CardTitle>EduMgr 登录</CardTitle>
+          <CardDescription>{mfaToken ? "请输入两步验证码" : "统一身份认证"}</CardDescription>
+        </CardHeader>
+        <CardContent>
+          {mfaToken ? (
+            <form onSubmit={onVerify} className="grid gap-4">
+              <div className="grid gap-2">
+                <Label htmlFor="code">验证码</Label>
+                <Input
+                  id="code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  placeholder="身份验证器中的 6 位验证码或恢复码"
+                  autoComplete="one-time-code"
+                  autoFocus
+                  required
+                />
+              </div>
+              {error && (
+                <Alert variant="destructive">
+                  <AlertDescription>{error}</AlertDescription>
+                </Alert>
+              )}
+              <Button type="submit" disabled={loading} className="w-full">
+                {loading ? (
+                  <>
+                    <Loader2 className="mr-2 h-4 w-4 animate-spin" />
+                    登录中...
+                  </>
+                ) : (
+                  "验证"
+                )}
+              </Button>
+            </form>
+          ) : error ? (
+            <Alert variant="destructive">
+              <AlertDescription>{error}</AlertDescription>
+            </Alert>
+          ) : (
+            <div className="flex items-center justify-center gap-3 text-muted-foreground">
+              <Loader2 className="h-5 w-5 animate-spin" />
+              正在登录...
+            </div>
+          )}
+          <div className="mt-4 text-center text-sm text-muted-foreground">
+            <Link href="/login" className="hover:text-foreground hover:underline">
+              返回登录
+            </Link>
+          </div>
+        </CardContent>
+      </Card>
+    </main>
+  );
+}
@@ -3,7 +3,7 @@
 import { useEffect, useState } from "react";
 import { useRouter } from "next/navigation";
 import Link from "next/link";
-import { apiBase, setToken, setUser, getToken } from "@/lib/api";
+import { apiBase, setToken, setRefreshToken, setUser, getToken, landingPath, oidcStateKey, type User } from "@/lib/api";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
@@ -12,8 +12,11 @@ import { Alert, AlertDescription } from "@/components/ui/alert";
 import { GraduationCap, Loader2 } from "lucide-react";
 
 type LoginResp = {
-  token: string;
-  user: { id: number; username: string; role: string };
+  token?: string;
+  refresh_token?: string;
+  user?: User;
+  mfa_required?: boolean;
+  mfa_token?: string;
 };
 
 export default function LoginPage() {
@@ -21,8 +24,12 @@ export default function LoginPage() {
   const [checking, setChecking] = useState(true);
   const [username, setUsername] = useState("");
   const [password, setPassword] = useState("");
+  // 两步验证：密码通过后凭 mfa_token 提交验证码
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+  const [code, setCode] = useState("");
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState<string | null>(null);
+  const [oidcEnabled, setOidcEnabled] = useState(false);
 
   // 检查是否需要初始化 & 是否已登录
   useEffect(() => {
@@ -43,6 +50,8 @@ export default function LoginPage() {
           router.replace("/setup");
           return;
         }
+        const providers = await fetch(`${apiBase()}/auth/providers`).then((r) => r.json());
+        setOidcEnabled(providers.code === 0 && !!providers.data?.oidc);
       } catch {
         // 忽略错误，可能是后端未启动
       }
@@ -56,19 +65,35 @@ export default function LoginPage() {
     setError(null);
     setLoading(true);
     try {
-      const res = await fetch(`${apiBase()}/auth/login`, {
-        method: "POST",
-        headers: { "Content-Type": "application/json" },
-        body: JSON.stringify({ username, password }),
-      });
+      const res = mfaToken
+        ? await fetch(`${apiBase()}/auth/mfa/verify`, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ mfa_token: mfaToken, code }),
+          })
+        : await fetch(`${apiBase()}/auth/login`, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ username, password }),
+          });
       const json = (await res.json()) as { code: number; message: string; data?: LoginResp };
-      if (!res.ok || json.code !== 0 || !json.data?.token) {
+      if (json.code === 40114) {
+        // 验证码步骤超时，重新输入密码
+        setMfaToken(null);
+        setCode("");
+      }
+      if (res.ok && json.code === 0 && json.data?.mfa_required && json.data.mfa_token) {
+        setMfaToken(json.data.mfa_token);
+        return;
+      }
+      if (!res.ok || json.code !== 0 || !json.data?.token || !json.data.user || !json.data.refresh_token) {
         setError(json.message || "登录失败");
         return;
       }
       setToken(json.data.token);
-      setUser(json.data.user as { id: number; username: string; role: "student" | "teacher" | "admin" });
-      router.push("/dashboard");
+      setRefreshToken(json.data.refresh_token);
+      setUser(json.data.user);
+      router.push(landingPath(json.data.user));
     } catch {
       setError("网络错误，请检查后端服务是否启动");
     } finally {
@@ -76,6 +101,25 @@ export default function LoginPage() {
     }
   }
 
+  async function onSSO() {
+    setError(null);
+    setLoading(true);
+    try {
+      const res = await fetch(`${apiBase()}/auth/oidc/start`);
+      const json = (await res.json()) as { code: number; message: string; data?: { auth_url: string; state: string } };
+      if (!res.ok || json.code !== 0 || !json.data) {
+        setError(json.message || "单点登录暂不可用");
+        setLoading(false);
+        return;
+      }
+      sessionStorage.setItem(oidcStateKey, json.data.state);
+      window.location.href = json.data.auth_url;
+    } catch {
+      setError("网络错误，请检查后端服务是否启动");
+      setLoading(false);
+    }
+  }
+
   if (checking) {
     return (
       <main className="min-h-screen flex items-center justify-center bg-background text-foreground">
@@ -95,31 +139,48 @@ export default function LoginPage() {
             <GraduationCap className="h-6 w-6 text-primary" />
           </div>
           <CardTitle>EduMgr 登录</CardTitle>
-          <CardDescription>请输入账号密码登录系统</CardDescription>
+          <CardDescription>{mfaToken ? "请输入两步验证码" : "请输入账号密码登录系统"}</CardDescription>
         </CardHeader>
         <CardContent>
           <form onSubmit={onSubmit} className="grid gap-4">
-            <div className="grid gap-2">
-              <Label htmlFor="username">用户名</Label>
-              <Input
-                id="username"
-                value={username}
-                onChange={(e) => setUsername(e.target.value)}
-                placeholder="请输入用户名"
-                required
-              />
-            </div>
-            <div className="grid gap-2">
-              <Label htmlFor="password">密码</Label>
-              <Input
-                id="password"
-                type="password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-                placeholder="请输入密码"
-                required
-              />
-            </div>
+            {mfaToken ? (
+              <div className="grid gap-2">
+                <Label htmlFor="code">验证码</Label>
+                <Input
+                  id="code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  placeholder="身份验证器中的 6 位验证码或恢复码"
+                  autoComplete="one-time-code"
+                  autoFocus
+                  required
+                />
+              </div>
+            ) : (
+              <>
+                <div className="grid gap-2">
+                  <Label htmlFor="username">用户名</Label>
+                  <Input
+                    id="username"
+                    value={username}
+                    onChange={(e) => setUsername(e.target.value)}
+                    placeholder="请输入用户名"
+                    required
+                  />
+                </div>
+                <div className="grid gap-2">
+                  <Label htmlFor="password">密码</Label>
+                  <Input
+                    id="password"
+                    type="password"
+                    value={password}
+                    onChange={(e) => setPassword(e.target.value)}
+                    placeholder="请输入密码"
+                    required
+                  />
+                </div>
+              </>
+            )}
             {error && (
               <Alert variant="destructive">
                 <AlertDescription>{error}</AlertDescription>
@@ -131,11 +192,18 @@ export default function LoginPage() {
                   <Loader2 className="mr-2 h-4 w-4 animate-spin" />
                   登录中...
                 </>
+              ) : mfaToken ? (
+                "验证"
               ) : (
                 "登录"
               )}
             </Button>
           </form>
+          {oidcEnabled && !mfaToken && (
+            <Button type="button" variant="outline" disabled={loading} onClick={onSSO} className="mt-3 w-full">
+              统一身份认证登录
+            </Button>
+          )}
           <div className="mt-4 text-center text-sm text-muted-foreground">
             <Link href="/" className="hover:text-foreground hover:underline">
               返回首页
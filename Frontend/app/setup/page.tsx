@@ -2,7 +2,7 @@
 
 import { useEffect, useState } from "react";
 import { useRouter } from "next/navigation";
-import { apiBase, setToken, setUser } from "@/lib/api";
+import { apiBase, setToken, setRefreshToken, setUser, passwordPolicyMessage } from "@/lib/api";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
@@ -50,8 +50,8 @@ export default function SetupPage() {
       setError("请输入用户名");
       return;
     }
-    if (password.length < 6) {
-      setError("密码长度至少6位");
+    if (password.length < 8) {
+      setError("密码长度至少8位");
       return;
     }
     if (password !== confirmPassword) {
@@ -69,7 +69,7 @@ export default function SetupPage() {
       const json = await res.json();
       
       if (!res.ok || json.code !== 0) {
-        setError(json.message || "创建管理员失败");
+        setError(passwordPolicyMessage(json, "创建管理员失败"));
         return;
       }
 
@@ -83,6 +83,7 @@ export default function SetupPage() {
       
       if (loginRes.ok && loginJson.code === 0 && loginJson.data?.token) {
         setToken(loginJson.data.token);
+        setRefreshToken(loginJson.data.refresh_token);
         setUser(loginJson.data.user);
         router.push("/dashboard");
       } else {
@@ -153,7 +154,7 @@ export default function SetupPage() {
                 type="password"
                 value={password}
                 onChange={(e) => setPassword(e.target.value)}
-                placeholder="请输入密码（至少6位）"
+                placeholder="至少8位，含大小写字母、数字、符号中的三类"
                 required
               />
             </div>
@@ -0,0 +1,231 @@
+"use client";
+
+import { useCallback, useEffect, useState } from "react";
+import { useRouter } from "next/navigation";
+import { apiFetch, getToken, getUser, logout, refreshSession } from "@/lib/api";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { Alert, AlertDescription } from "@/components/ui/alert";
+import { Loader2, ShieldCheck } from "lucide-react";
+
+type MFAStatus = {
+  enabled: boolean;
+  required: boolean;
+  recovery_codes_left: number;
+};
+
+type MFASetup = {
+  secret: string;
+  uri: string;
+};
+
+export default function TwoFactorPage() {
+  const router = useRouter();
+  const [forced, setForced] = useState(false);
+  const [status, setStatus] = useState<MFAStatus | null>(null);
+  const [setup, setSetup] = useState<MFASetup | null>(null);
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [code, setCode] = useState("");
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const loadStatus = useCallback(async () => {
+    const json = await apiFetch<MFAStatus>("/auth/mfa");
+    if (json.code === 0 && json.data) setStatus(json.data);
+    else setError(json.message || "加载失败");
+  }, []);
+
+  useEffect(() => {
+    if (!getToken()) {
+      router.replace("/login");
+      return;
+    }
+    setForced(!!getUser()?.mfa_setup_required);
+    void loadStatus();
+  }, [router, loadStatus]);
+
+  // 提交需要验证码的操作
+  async function submit<T>(path: string, body?: object): Promise<T | null> {
+    setError(null);
+    setLoading(true);
+    try {
+      const json = await apiFetch<T>(path, { method: "POST", body: JSON.stringify(body ?? {}) });
+      if (json.code !== 0) {
+        setError(json.message || "操作失败");
+        return null;
+      }
+      return json.data ?? null;
+    } catch {
+      setError("网络错误，请检查后端服务是否启动");
+      return null;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function onStart() {
+    const data = await submit<MFASetup>("/auth/mfa/setup");
+    if (data) setSetup(data);
+  }
+
+  async function onEnable(e: React.FormEvent) {
+    e.preventDefault();
+    const data = await submit<{ recovery_codes: string[] }>("/auth/mfa/enable", { code });
+    if (!data) return;
+    setSetup(null);
+    setCode("");
+    setRecoveryCodes(data.recovery_codes);
+    await loadStatus();
+  }
+
+  async function onRegenerate() {
+    const data = await submit<{ recovery_codes: string[] }>("/auth/mfa/recovery-codes", { code });
+    if (!data) return;
+    setCode("");
+    setRecoveryCodes(data.recovery_codes);
+    await loadStatus();
+  }
+
+  async function onDisable() {
+    const data = await submit<{ disabled: boolean }>("/auth/mfa/disable", { code });
+    if (!data) return;
+    setCode("");
+    await loadStatus();
+  }
+
+  // 启用后换取不再受限的令牌，刷新时一并更新用户信息
+  async function onContinue() {
+    if (getUser()?.mfa_setup_required) await refreshSession();
+    router.push("/dashboard");
+  }
+
+  async function onLogout() {
+    await logout();
+    router.push("/login");
+  }
+
+  return (
+    <main className="min-h-screen flex items-center justify-center bg-background text-foreground p-6">
+      <Card className="w-full max-w-md">
+        <CardHeader className="text-center">
+          <div className="mx-auto mb-2 flex h-12 w-12 items-center justify-center rounded-full bg-primary/10">
+            <ShieldCheck className="h-6 w-6 text-primary" />
+          </div>
+          <CardTitle>两步验证</CardTitle>
+          <CardDescription>
+            {forced ? "当前角色须启用两步验证后才能继续使用" : "登录时除密码外还需输入身份验证器中的验证码"}
+          </CardDescription>
+        </CardHeader>
+        <CardContent className="grid gap-4">
+          {!status ? (
+            <div className="flex items-center justify-center gap-2 text-sm text-muted-foreground">
+              <Loader2 className="h-4 w-4 animate-spin" />
+              加载中...
+            </div>
+          ) : recoveryCodes ? (
+            <>
+              <p className="text-sm">
+                请妥善保存以下恢复码。每个恢复码只能使用一次，可在无法使用身份验证器时代替验证码登录；离开本页后将不再显示。
+              </p>
+              <div className="grid grid-cols-2 gap-2 rounded-lg border border-border bg-muted/50 p-3 font-mono text-sm">
+                {recoveryCodes.map((c) => (
+                  <span key={c}>{c}</span>
+                ))}
+              </div>
+              <Button onClick={onContinue} className="w-full">
+                我已保存，继续
+              </Button>
+            </>
+          ) : setup ? (
+            <form onSubmit={onEnable} className="grid gap-4">
+              <p className="text-sm">
+                在身份验证器（如 Google Authenticator、Microsoft Authenticator）中添加账号：在手机上打开下方链接，或手动输入密钥。
+              </p>
+              <a href={setup.uri} className="break-all text-sm text-primary underline">
+                {setup.uri}
+              </a>
+              <div className="rounded-lg border border-border bg-muted/50 p-3 font-mono text-sm break-all">
+                {setup.secret}
+              </div>
+              <div className="grid gap-2">
+                <Label htmlFor="code">验证码</Label>
+                <Input
+                  id="code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  placeholder="身份验证器中的 6 位验证码"
+                  autoComplete="one-time-code"
+                  required
+                />
+              </div>
+              {error && (
+                <Alert variant="destructive">
+                  <AlertDescription>{error}</AlertDescription>
+                </Alert>
+              )}
+              <Button type="submit" disabled={loading} className="w-full">
+                {loading ? <Loader2 className="mr-2 h-4 w-4 animate-spin" /> : null}
+                启用
+              </Button>
+            </form>
+          ) : status.enabled ? (
+            <>
+              <p className="text-sm">
+                两步验证已启用，剩余 {status.recovery_codes_left} 个恢复码。重新生成恢复码{status.required ? "" : "或停用"}需输入验证码。
+              </p>
+              <div className="grid gap-2">
+                <Label htmlFor="code">验证码</Label>
+                <Input
+                  id="code"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  placeholder="6 位验证码或恢复码"
+                  autoComplete="one-time-code"
+                />
+              </div>
+              {error && (
+                <Alert variant="destructive">
+                  <AlertDescription>{error}</AlertDescription>
+                </Alert>
+              )}
+              <div className="flex gap-2">
+                <Button onClick={onRegenerate} disabled={loading || !code} className="flex-1">
+                  重新生成恢复码
+                </Button>
+                {!status.required && (
+                  <Button variant="destructive" onClick={onDisable} disabled={loading || !code} className="flex-1">
+                    停用
+                  </Button>
+                )}
+              </div>
+            </>
+          ) : (
+            <>
+              {error && (
+                <Alert variant="destructive">
+                  <AlertDescription>{error}</AlertDescription>
+                </Alert>
+              )}
+              <Button onClick={onStart} disabled={loading} className="w-full">
+                开始设置
+              </Button>
+            </>
+          )}
+          <div className="text-center text-sm text-muted-foreground">
+            {forced ? (
+              <button type="button" onClick={onLogout} className="hover:text-foreground hover:underline">
+                退出登录
+              </button>
+            ) : (
+              <button type="button" onClick={() => router.back()} className="hover:text-foreground hover:underline">
+                返回
+              </button>
+            )}
+          </div>
+        </CardContent>
+      </Card>
+    </main>
+  );
+}
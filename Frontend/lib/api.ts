@@ -6,7 +6,8 @@ export type ApiResponse<T> = {
   data?: T;
 };
 
-export type UserRole = "student" | "teacher" | "admin";
+// 角色名：内置 student / teacher / admin，管理员可新增其他角色
+export type UserRole = string;
 
 export type User = {
   id: number;
@@ -14,6 +15,12 @@ export type User = {
   role: UserRole;
   student_id?: number;
   staff_id?: number;
+  // 所属系：非空时只能管理本系数据
+  dept_id?: number;
+  must_change_password?: boolean;
+  mfa_setup_required?: boolean;
+  // 角色所含权限，如 "grade:write:own_course"
+  permissions?: string[];
 };
 
 export type Department = {
@@ -109,12 +116,31 @@ export type CourseGradeGroup = {
   rows: GradeRow[];
 };
 
+// 为学生/教职工开通的账号；初始密码仅在开通时返回一次
+export type ProvisionedAccount = {
+  user_id: number;
+  username: string;
+  password: string;
+  role: string;
+  number: string;
+  name: string;
+};
+
+export type ProvisionResult = {
+  created: ProvisionedAccount[];
+  skipped: { id: number; number?: string; code: number; message: string }[];
+  remaining: number;
+};
+
 // ==================== Token 管理 ====================
 
 export function apiBase() {
   return process.env.NEXT_PUBLIC_API_BASE ?? "http://localhost:8080";
 }
 
+// 单点登录：/auth/oidc/start 返回的 state 存于 sessionStorage，回调页据此确认回调属于本次登录
+export const oidcStateKey = "edumgr_oidc_state";
+
 export function getToken() {
   if (typeof window === "undefined") return null;
   return localStorage.getItem("edumgr_token");
@@ -125,12 +151,75 @@ export function setToken(token: string) {
   localStorage.setItem("edumgr_token", token);
 }
 
+export function getRefreshToken() {
+  if (typeof window === "undefined") return null;
+  return localStorage.getItem("edumgr_refresh_token");
+}
+
+export function setRefreshToken(token: string) {
+  if (typeof window === "undefined") return;
+  localStorage.setItem("edumgr_refresh_token", token);
+}
+
 export function clearToken() {
   if (typeof window === "undefined") return;
   localStorage.removeItem("edumgr_token");
+  localStorage.removeItem("edumgr_refresh_token");
   localStorage.removeItem("edumgr_user");
 }
 
+let refreshing: Promise<boolean> | null = null;
+
+// 用 refresh token 换取新的 access token；同一时间只发起一次
+export function refreshSession(): Promise<boolean> {
+  if (!refreshing) {
+    refreshing = doRefresh().finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+}
+
+async function doRefresh(): Promise<boolean> {
+  const refreshToken = getRefreshToken();
+  if (!refreshToken) return false;
+  try {
+    const res = await fetch(`${apiBase()}/auth/refresh`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    const json = (await res.json()) as ApiResponse<{ token: string; refresh_token: string; user?: User }>;
+    if (res.ok && json.code === 0 && json.data) {
+      setToken(json.data.token);
+      setRefreshToken(json.data.refresh_token);
+      // 角色权限可能已被修改
+      if (json.data.user) setUser(json.data.user);
+      return true;
+    }
+  } catch {
+    return false;
+  }
+  // 其他标签页可能已轮换了 refresh token
+  return getRefreshToken() !== refreshToken;
+}
+
+// 注销当前会话，服务端同时吊销其 access token
+export async function logout() {
+  const refreshToken = getRefreshToken();
+  clearToken();
+  if (!refreshToken) return;
+  try {
+    await fetch(`${apiBase()}/auth/logout`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+  } catch {
+    // 本地已清除登录状态
+  }
+}
+
 // ==================== 用户状态管理 ====================
 
 export function getUser(): User | null {
@@ -149,40 +238,32 @@ export function setUser(user: User) {
   localStorage.setItem("edumgr_user", JSON.stringify(user));
 }
 
-export function isAdmin(): boolean {
-  const user = getUser();
-  return user?.role === "admin";
-}
-
-export function isTeacher(): boolean {
-  const user = getUser();
-  return user?.role === "teacher";
-}
-
-export function isStudent(): boolean {
-  const user = getUser();
-  return user?.role === "student";
-}
-
-export function canWrite(): boolean {
-  const user = getUser();
-  return user?.role === "admin";
+// 登录后的去处：须先修改密码或启用两步验证的用户先完成这些步骤
+export function landingPath(user: User): string {
+  if (user.must_change_password) return "/change-password";
+  if (user.mfa_setup_required) return "/two-factor";
+  return "/dashboard";
 }
 
-export function canGrade(): boolean {
-  const user = getUser();
-  return user?.role === "admin" || user?.role === "teacher";
+// 当前用户的角色是否含任一权限
+export function hasPermission(...perms: string[]): boolean {
+  const granted = getUser()?.permissions ?? [];
+  return perms.some((p) => granted.includes(p));
 }
 
 // ==================== API 请求 ====================
 
-export async function apiFetch<T>(path: string, init?: RequestInit): Promise<ApiResponse<T>> {
+export async function apiFetch<T>(path: string, init?: RequestInit, retried = false): Promise<ApiResponse<T>> {
   const headers = new Headers(init?.headers);
   const token = getToken();
   if (token) headers.set("Authorization", `Bearer ${token}`);
   if (init?.body && !headers.get("Content-Type")) headers.set("Content-Type", "application/json");
 
   const res = await fetch(`${apiBase()}${path}`, { ...init, headers });
+  // access token 过期时刷新一次后重试
+  if (res.status === 401 && token && !retried && (await refreshSession())) {
+    return apiFetch<T>(path, init, true);
+  }
   let json: ApiResponse<T>;
   try {
     json = (await res.json()) as ApiResponse<T>;
@@ -190,9 +271,47 @@ export async function apiFetch<T>(path: string, init?: RequestInit): Promise<Api
     json = { code: res.ok ? 0 : res.status, message: "invalid response" };
   }
   if (res.status === 401) clearToken();
+  // 管理员设置的密码须先修改
+  if (json.code === 40303 && typeof window !== "undefined") window.location.assign("/change-password");
+  // 角色要求两步验证但尚未启用
+  if (json.code === 40304 && typeof window !== "undefined") window.location.assign("/two-factor");
   return json;
 }
 
+// 打印账号开通凭条（PDF），以浏览器下载保存
+export async function downloadCredentialsSheet(accounts: ProvisionedAccount[]): Promise<string | null> {
+  const headers = new Headers({ "Content-Type": "application/json" });
+  const token = getToken();
+  if (token) headers.set("Authorization", `Bearer ${token}`);
+  try {
+    const res = await fetch(`${apiBase()}/api/v1/accounts/credentials-sheet`, {
+      method: "POST",
+      headers,
+      body: JSON.stringify({ accounts }),
+    });
+    if (!res.ok) {
+      const json = (await res.json()) as ApiResponse<unknown>;
+      return json.message || "打印凭条失败";
+    }
+    const url = URL.createObjectURL(await res.blob());
+    const a = document.createElement("a");
+    a.href = url;
+    a.download = "credentials.pdf";
+    a.click();
+    URL.revokeObjectURL(url);
+    return null;
+  } catch {
+    return "打印凭条失败";
+  }
+}
+
+// 拼接密码策略未满足的规则
+export function passwordPolicyMessage(json: ApiResponse<unknown>, fallback: string): string {
+  const violations = (json.data as { violations?: string[] } | undefined)?.violations;
+  if (json.code === 40096 && violations?.length) return `密码不符合要求：${violations.join("；")}`;
+  return json.message || fallback;
+}
+
 // ==================== 便捷 API 方法 ====================
 
 export async function fetchCurrentUser(): Promise<User | null> {